@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -30,8 +32,95 @@ var (
 	encryptEnabled  bool
 	encryptPassword string
 	policyName      string
+	noProgress      bool
+
+	// Параметры копирования во вторичные хранилища
+	copyTo             []string
+	copyRetentionCount int
+	copyRetentionDays  int
+	copyFrom           string
+	copyDestination    string
+	copyPolicyID       string
+
+	// Ограничения скорости передачи для политики
+	uploadLimit      string
+	downloadLimit    string
+	maxParallelParts int
+
+	// Параметры проверки сохраненных бэкапов
+	verifyPolicyID string
+	verifyJobID    string
+	verifySample   int
+	verifyDeep     bool
+
+	// Параметры проверки восстановления
+	restoreTestSchedule string
+	restoreTestSample   int
+	restoreTestCommand  string
+	restoreTestTimeout  time.Duration
+	restoreTestPolicyID string
+
+	// Параметры данных четности
+	parityPercent int
+	repairJobID   string
+
+	// Размер тома для разбиения бэкапа
+	volumeSize string
+
+	// Поведение при пересечении запусков политики
+	overlapMode string
+
+	// Параметры восстановления
+	restorePolicyID    string
+	restoreJobID       string
+	restoreAt          string
+	restoreInclude     []string
+	restoreTarget      string
+	restoreConflict    string
+	restoreDryRun      bool
+	restoreSnapshotDir string
+	restoreNoSnapshot  bool
+
+	// Параметры поиска файлов по бэкапам
+	findPolicyID string
+	findName     string
+	findPath     string
+
+	// Параметры истории версий файла
+	versionsPolicyID string
+
+	// Параметры сервера просмотра бэкапов
+	serveListen  string
+	serveToken   string
+	serveTLSCert string
+	serveTLSKey  string
+
+	// Параметры API-токенов
+	tokenName    string
+	tokenRoles   []string
+	tokenExpires time.Duration
+
+	// Параметры просмотра журнала аудита
+	auditActor    string
+	auditPolicyID string
+	auditAction   string
+	auditLimit    int
+
+	// Параметры отмены задачи
+	cancelReason string
+	cancelWait   bool
 )
 
+// restoreTimeLayouts форматы времени флага --at, время местное
+var restoreTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
 // Корневая команда
 var rootCmd = &cobra.Command{
 	Use:   "backupist",
@@ -48,11 +137,293 @@ var createCmd = &cobra.Command{
 
 Пример использования:
   backupist create --source /home/user/documents --destination /backups
-  backupist create -s /data -d s3://my-bucket/backups --schedule "0 2 * * *" --encrypt`,
+  backupist create -s /data -d s3://my-bucket/backups --schedule "0 2 * * *" --encrypt
+  backupist create -s /data -d /backups --copy-to s3-offsite --copy-retention-days 90
+  backupist create -s /data -d s3://my-bucket/backups --upload-limit 10MB/s
+  backupist create -s /data -d s3://my-bucket/backups --parity 10
+  backupist create -s /data -d /mnt/usb/backups --volume-size 4G
+  backupist create -s /data -d s3://my-bucket/backups --schedule "*/15 * * * *" --overlap queue`,
 	PreRunE: validateCreateFlags,
 	RunE:    runCreate,
 }
 
+// Команда для копирования бэкапов во вторичное хранилище
+var copyCmd = &cobra.Command{
+	Use:   "copy",
+	Short: "Скопировать бэкапы во вторичное хранилище",
+	Long: `Копирует завершенные бэкапы из одного хранилища в другое.
+Уже скопированные бэкапы пропускаются, копии учитываются в каталоге
+отдельно и хранятся по собственной политике хранения.
+
+Хранилище указывается именем из storage.configs, типом хранилища
+по умолчанию или URL.
+
+Пример использования:
+  backupist copy --from local --to s3-offsite
+  backupist copy --from local --to s3://offsite-bucket/backups --retention-count 30`,
+	RunE: runCopy,
+}
+
+// Команда для проверки сохраненных бэкапов
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Проверить сохраненные бэкапы",
+	Long: `Скачивает бэкапы из хранилища и сравнивает SHA-256 с контрольной
+суммой из каталога. Если у бэкапа есть данные четности, при
+несовпадении сообщается, сколько блоков повреждено и можно ли их
+исправить командой repair. С флагом --deep проверяются и данные
+четности, бэкап расшифровывается и проверяется каждая запись архива.
+Результат каждой проверки записывается в каталог.
+
+Пример использования:
+  backupist verify
+  backupist verify --policy <policy-id> --deep
+  backupist verify --sample 3`,
+	RunE: runVerify,
+}
+
+// Команда для исправления бэкапа по данным четности
+var repairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Исправить поврежденный бэкап по данным четности",
+	Long: `Скачивает бэкап и его данные четности, восстанавливает поврежденные
+блоки кодом Рида-Соломона и записывает исправленный бэкап в хранилище
+поверх поврежденного. Поврежденные данные четности пересоздаются.
+Данные четности создаются для политик с флагом --parity.
+
+Пример использования:
+  backupist repair --job <job-id>`,
+	RunE: runRepair,
+}
+
+// Команда для восстановления файлов из бэкапа
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Восстановить файлы из бэкапа политики",
+	Long: `Восстанавливает файлы из бэкапа политики в указанную директорию.
+Выбирается последний бэкап, начатый не позже времени --at (по умолчанию
+последний бэкап). Шаблоны --include задаются относительно исходной
+директории политики: * соответствует части имени, ** - любому
+количеству директорий. Бэкап читается из хранилища потоком, извлекаются
+только подходящие файлы.
+
+Существующие файлы обрабатываются по режиму --conflict: overwrite
+(заменить), overwrite-if-newer (заменить, если файл в бэкапе новее),
+skip (оставить) или rename (восстановить рядом как имя.restored.расш).
+Каждый файл записывается во временный файл и переименовывается на место
+целевого. Заменяемые файлы перед заменой сохраняются в директорию
+<target>.pre-restore-<время> (или --snapshot-dir). С флагом --dry-run
+выводится список изменений без записи файлов.
+
+Пример использования:
+  backupist restore --policy <policy-id> --target /tmp/r
+  backupist restore --policy <policy-id> --at 2026-10-01T12:00 --include 'home/*/docs/**' --target /tmp/r
+  backupist restore --policy <policy-id> --target /srv/data --conflict overwrite-if-newer --dry-run`,
+	RunE: runRestore,
+}
+
+// Команда для просмотра содержимого бэкапа
+var lsCmd = &cobra.Command{
+	Use:   "ls <job-id> [path]",
+	Short: "Показать содержимое директории бэкапа",
+	Long: `Выводит файлы и директории бэкапа задачи по индексу из каталога,
+не скачивая бэкап. Путь задается относительно исходной директории
+политики. Для бэкапов без индекса он строится по архиву при первом
+обращении.
+
+Пример использования:
+  backupist ls <job-id>
+  backupist ls <job-id> home/alice/docs`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runLs,
+}
+
+// Команда для вывода файла из бэкапа
+var catCmd = &cobra.Command{
+	Use:   "cat <job-id> <file>",
+	Short: "Вывести файл из бэкапа",
+	Long: `Читает бэкап задачи из хранилища потоком до нужного файла и выводит
+его содержимое в stdout. Содержимое сверяется с SHA-256 из индекса.
+
+Пример использования:
+  backupist cat <job-id> etc/nginx/nginx.conf > nginx.conf`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCat,
+}
+
+// Команда для поиска файлов по бэкапам
+var findCmd = &cobra.Command{
+	Use:   "find",
+	Short: "Найти файлы во всех бэкапах",
+	Long: `Ищет файлы по индексу из каталога во всех завершенных бэкапах
+(или бэкапах одной политики) и выводит, в каких бэкапах они есть.
+--name сравнивается с именем файла, --path - с путем относительно
+исходной директории (** - любое количество директорий).
+
+Пример использования:
+  backupist find --name '*.sql'
+  backupist find --policy <policy-id> --path 'home/*/docs/**'`,
+	RunE: runFind,
+}
+
+// Команда для просмотра версий файла
+var versionsCmd = &cobra.Command{
+	Use:   "versions <path>",
+	Short: "Показать версии файла во всех бэкапах",
+	Long: `Выводит по индексу из каталога все бэкапы, в которых есть файл,
+с размером, временем изменения и SHA-256. Версии, отличающиеся от
+предыдущей версии той же политики, отмечаются звездочкой. Путь
+задается исходным или относительно исходной директории политики.
+
+Пример использования:
+  backupist versions /etc/nginx/nginx.conf
+  backupist versions --policy <policy-id> nginx/nginx.conf`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVersions,
+}
+
+// Команда для сравнения двух бэкапов
+var diffCmd = &cobra.Command{
+	Use:   "diff <job-a> <job-b>",
+	Short: "Сравнить файлы двух бэкапов",
+	Long: `Сравнивает индексы файлов двух бэкапов из каталога и выводит
+добавленные (+), удаленные (-) и измененные (M) в <job-b> файлы.
+
+Пример использования:
+  backupist diff <job-a> <job-b>`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDiff,
+}
+
+// Команда для запуска сервера просмотра бэкапов
+var serveSnapshotsCmd = &cobra.Command{
+	Use:   "serve-snapshots",
+	Short: "Открыть бэкапы по WebDAV только для чтения",
+	Long: `Запускает WebDAV-сервер, в котором каждая политика - директория с
+бэкапами, а каждый бэкап - дерево его файлов. Файлы расшифровываются
+и распаковываются из архива при чтении. Сервер подключается в
+файловом менеджере как сетевой диск или открывается в браузере.
+
+Доступ по токену: заголовок "Authorization: Bearer <токен>" или
+Basic-авторизация с токеном в качестве пароля (имя любое). Без TLS
+сервер слушает только loopback-адрес; чтобы открыть его в сети,
+задайте сертификат и ключ. Параметры задаются флагами или в
+конфигурации:
+
+  snapshot_server:
+    listen: "127.0.0.1:8090"
+    token: "<не короче 16 символов>"
+    tls_cert: "/etc/backupist/snapshots.crt"
+    tls_key: "/etc/backupist/snapshots.key"
+
+Пример использования:
+  backupist serve-snapshots --listen 0.0.0.0:8090 --tls-cert server.crt --tls-key server.key`,
+	RunE: runServeSnapshots,
+}
+
+// Команда для проверки восстановления
+var restoreTestCmd = &cobra.Command{
+	Use:   "restore-test",
+	Short: "Проверить восстановление последнего бэкапа политики",
+	Long: `Восстанавливает последний бэкап политики во временную директорию,
+сравнивает файлы с манифестом из каталога (размер, права, SHA-256)
+и выполняет команду проверки, заданную в политике. Результат
+записывается в каталог и отправляется в уведомлениях.
+
+Пример использования:
+  backupist restore-test --policy <policy-id>`,
+	RunE: runRestoreTest,
+}
+
+// Команда для запуска задач по расписанию
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Выполнять бэкапы и проверки по расписанию",
+	Long: `Запускает планировщик, который выполняет бэкапы политик и проверки
+восстановления по их cron-расписанию, а также регулярную проверку
+сохраненных бэкапов по расписанию из секции verify конфигурации:
+
+  verify:
+    schedule: "0 4 * * *"
+    sample: 5
+    deep: true`,
+	RunE: runDaemon,
+}
+
+// Команда для управления задачами бэкапа
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Управлять задачами бэкапа",
+}
+
+// Команда для отмены задачи бэкапа
+var jobsCancelCmd = &cobra.Command{
+	Use:   "cancel <job-id>",
+	Short: "Отменить задачу бэкапа",
+	Long: `Отменяет задачу бэкапа. Запрос отмены записывается в каталог, и
+процесс, выполняющий задачу (daemon, сервер API или create), в течение
+нескольких секунд прерывает загрузку, удаляет частично записанные
+объекты и переводит задачу в статус cancelled с указанной причиной.
+Задача, еще не начавшая выполняться, отменяется сразу.
+
+Пример использования:
+  backupist jobs cancel <job-id> --reason "окно обслуживания" --wait`,
+	Args: cobra.ExactArgs(1),
+	RunE: runJobsCancel,
+}
+
+// Команда для управления API-токенами
+var apiTokenCmd = &cobra.Command{
+	Use:   "api-token",
+	Short: "Управлять токенами доступа к REST API",
+	Long: `Создает, выводит и отзывает токены доступа к REST API сервера.
+
+Роль задается как role (на все политики) или role:policy-id (на одну
+политику): viewer - просмотр, operator - запуск бэкапов, восстановление
+и удаление, admin - вдобавок создание политик и журнал аудита.`,
+}
+
+// Команда для создания API-токена
+var apiTokenCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Создать API-токен",
+	Long: `Создает API-токен и выводит его значение. Значение не сохраняется
+и выводится только один раз.
+
+Пример использования:
+  backupist api-token create --name ci --role operator:<policy-id>
+  backupist api-token create --name monitoring --role viewer --expires 720h`,
+	RunE: runAPITokenCreate,
+}
+
+// Команда для просмотра API-токенов
+var apiTokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Показать API-токены",
+	RunE:  runAPITokenList,
+}
+
+// Команда для отзыва API-токена
+var apiTokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <id|name>",
+	Short: "Отозвать API-токен",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runAPITokenRevoke,
+}
+
+// Команда для просмотра журнала аудита
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Показать журнал аудита REST API",
+	Long: `Выводит записи журнала действий, выполненных через REST API,
+начиная с новых, включая запросы, отклоненные проверкой доступа.
+
+Пример использования:
+  backupist audit --action backup.restore
+  backupist audit --actor ci --limit 100`,
+	RunE: runAudit,
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
@@ -68,13 +439,117 @@ func init() {
 	createCmd.Flags().BoolVarP(&encryptEnabled, "encrypt", "e", false, "шифровать бэкап (по умолчанию false)")
 	createCmd.Flags().StringVarP(&encryptPassword, "password", "p", "", "пароль для шифрования (обязателен, если --encrypt=true)")
 	createCmd.Flags().StringVarP(&policyName, "name", "n", "", "имя политики бэкапа (по умолчанию генерируется автоматически)")
+	createCmd.Flags().StringArrayVar(&copyTo, "copy-to", nil, "вторичное хранилище для копии бэкапа (можно указать несколько раз)")
+	createCmd.Flags().IntVar(&copyRetentionCount, "copy-retention-count", 0, "количество копий для хранения во вторичных хранилищах (0 - без ограничения)")
+	createCmd.Flags().IntVar(&copyRetentionDays, "copy-retention-days", 0, "срок хранения копий в днях (0 - без ограничения)")
+	createCmd.Flags().StringVar(&uploadLimit, "upload-limit", "", "ограничение скорости загрузки в хранилище, например 10MB/s")
+	createCmd.Flags().StringVar(&downloadLimit, "download-limit", "", "ограничение скорости скачивания из хранилища, например 10MB/s")
+	createCmd.Flags().IntVar(&maxParallelParts, "max-parallel-parts", 0, "количество параллельно загружаемых частей файла (0 - по умолчанию)")
+	createCmd.Flags().StringVar(&restoreTestSchedule, "restore-test-schedule", "", "cron-расписание проверки восстановления (для команды daemon)")
+	createCmd.Flags().IntVar(&restoreTestSample, "restore-test-sample", 0, "сколько случайных файлов сравнивать при проверке восстановления (0 - все)")
+	createCmd.Flags().StringVar(&restoreTestCommand, "restore-test-command", "", "имя команды проверки восстановленного бэкапа из restore_test.commands конфигурации")
+	createCmd.Flags().DurationVar(&restoreTestTimeout, "restore-test-timeout", 0, "ограничение времени команды проверки (0 - без ограничения)")
+	createCmd.Flags().StringVar(&volumeSize, "volume-size", "", "разбить бэкап на тома указанного размера, например 4G (тома загружаются по мере создания)")
+	createCmd.Flags().BoolVar(&noProgress, "no-progress", false, "не выводить прогресс бэкапа (выводится, только если stderr - терминал)")
+	createCmd.Flags().IntVar(&parityPercent, "parity", 0, "данные четности: сколько процентов поврежденных блоков можно восстановить (0 - не создавать)")
+	createCmd.Flags().StringVar(&overlapMode, "overlap", string(types.OverlapSkip), "если предыдущий запуск политики еще выполняется: skip - пропустить, queue - дождаться, cancel-previous - отменить предыдущий")
 
 	// Обязательные флаги
 	createCmd.MarkFlagRequired("source")
 	createCmd.MarkFlagRequired("destination")
 
-	// Добавляем команду create к корневой команде
+	// Флаги команды copy
+	copyCmd.Flags().StringVar(&copyFrom, "from", "", "исходное хранилище (обязательный)")
+	copyCmd.Flags().StringVar(&copyDestination, "to", "", "вторичное хранилище (обязательный)")
+	copyCmd.Flags().StringVar(&copyPolicyID, "policy", "", "копировать бэкапы только указанной политики")
+	copyCmd.Flags().IntVar(&copyRetentionCount, "retention-count", 0, "количество копий для хранения (0 - без ограничения)")
+	copyCmd.Flags().IntVar(&copyRetentionDays, "retention-days", 0, "срок хранения копий в днях (0 - без ограничения)")
+	copyCmd.MarkFlagRequired("from")
+	copyCmd.MarkFlagRequired("to")
+
+	// Флаги команды verify
+	verifyCmd.Flags().StringVar(&verifyPolicyID, "policy", "", "проверить бэкапы только указанной политики")
+	verifyCmd.Flags().StringVar(&verifyJobID, "job", "", "проверить бэкап указанной задачи")
+	verifyCmd.Flags().IntVar(&verifySample, "sample", 0, "проверить указанное количество случайных бэкапов (0 - все)")
+	verifyCmd.Flags().BoolVar(&verifyDeep, "deep", false, "проверить данные четности, расшифровать бэкап и проверить каждую запись архива")
+
+	// Флаги команды repair
+	repairCmd.Flags().StringVar(&repairJobID, "job", "", "задача, бэкап которой исправляется (обязательный)")
+	repairCmd.MarkFlagRequired("job")
+
+	// Флаги команды restore
+	restoreCmd.Flags().StringVar(&restorePolicyID, "policy", "", "политика, бэкап которой восстанавливается (обязательный)")
+	restoreCmd.Flags().StringVar(&restoreJobID, "job", "", "восстановить бэкап указанной задачи")
+	restoreCmd.Flags().StringVar(&restoreAt, "at", "", "момент времени, например 2026-10-01T12:00 (по умолчанию последний бэкап)")
+	restoreCmd.Flags().StringArrayVar(&restoreInclude, "include", nil, "шаблон путей для восстановления (можно указать несколько раз)")
+	restoreCmd.Flags().StringVar(&restoreTarget, "target", "", "директория восстановления (обязательный)")
+	restoreCmd.Flags().StringVar(&restoreConflict, "conflict", string(backup.ConflictOverwrite), "что делать с существующими файлами: overwrite, overwrite-if-newer, skip, rename")
+	restoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "только вывести список изменений")
+	restoreCmd.Flags().StringVar(&restoreSnapshotDir, "snapshot-dir", "", "директория для сохранения заменяемых файлов (по умолчанию <target>.pre-restore-<время>)")
+	restoreCmd.Flags().BoolVar(&restoreNoSnapshot, "no-snapshot", false, "не сохранять заменяемые файлы; бэкап тогда проверяется целиком до замены файлов")
+	restoreCmd.MarkFlagRequired("policy")
+	restoreCmd.MarkFlagRequired("target")
+	restoreCmd.MarkFlagsMutuallyExclusive("job", "at")
+	restoreCmd.MarkFlagsMutuallyExclusive("snapshot-dir", "no-snapshot")
+
+	// Флаги команды find
+	findCmd.Flags().StringVar(&findPolicyID, "policy", "", "искать только в бэкапах указанной политики")
+	findCmd.Flags().StringVar(&findName, "name", "", "шаблон имени файла, например '*.sql'")
+	findCmd.Flags().StringVar(&findPath, "path", "", "шаблон пути относительно исходной директории")
+
+	// Флаги команды versions
+	versionsCmd.Flags().StringVar(&versionsPolicyID, "policy", "", "искать только в бэкапах указанной политики")
+
+	// Флаги команды serve-snapshots
+	serveSnapshotsCmd.Flags().StringVar(&serveListen, "listen", "", "адрес сервера (по умолчанию из конфигурации, 127.0.0.1:8090)")
+	serveSnapshotsCmd.Flags().StringVar(&serveToken, "token", "", "токен доступа (по умолчанию из конфигурации)")
+	serveSnapshotsCmd.Flags().StringVar(&serveTLSCert, "tls-cert", "", "файл сертификата TLS (по умолчанию из конфигурации)")
+	serveSnapshotsCmd.Flags().StringVar(&serveTLSKey, "tls-key", "", "файл ключа сертификата TLS (по умолчанию из конфигурации)")
+
+	// Флаги команды restore-test
+	restoreTestCmd.Flags().StringVar(&restoreTestPolicyID, "policy", "", "политика, бэкап которой проверяется (обязательный)")
+	restoreTestCmd.MarkFlagRequired("policy")
+
+	// Флаги команды jobs cancel
+	jobsCancelCmd.Flags().StringVar(&cancelReason, "reason", "", "причина отмены, записывается в задачу")
+	jobsCancelCmd.Flags().BoolVar(&cancelWait, "wait", false, "дождаться завершения задачи")
+
+	jobsCmd.AddCommand(jobsCancelCmd)
+
+	// Флаги команды api-token create
+	apiTokenCreateCmd.Flags().StringVar(&tokenName, "name", "", "имя токена, попадает в журнал аудита (обязательный)")
+	apiTokenCreateCmd.Flags().StringArrayVar(&tokenRoles, "role", nil, "роль role или role:policy-id (можно указать несколько раз, обязательный)")
+	apiTokenCreateCmd.Flags().DurationVar(&tokenExpires, "expires", 0, "срок действия токена, например 720h (0 - бессрочный)")
+	apiTokenCreateCmd.MarkFlagRequired("name")
+	apiTokenCreateCmd.MarkFlagRequired("role")
+
+	apiTokenCmd.AddCommand(apiTokenCreateCmd)
+	apiTokenCmd.AddCommand(apiTokenListCmd)
+	apiTokenCmd.AddCommand(apiTokenRevokeCmd)
+
+	// Флаги команды audit
+	auditCmd.Flags().StringVar(&auditActor, "actor", "", "только записи указанного токена или пользователя")
+	auditCmd.Flags().StringVar(&auditPolicyID, "policy", "", "только записи указанной политики")
+	auditCmd.Flags().StringVar(&auditAction, "action", "", "только указанное действие, например backup.restore")
+	auditCmd.Flags().IntVar(&auditLimit, "limit", 50, "количество записей (0 - все)")
+
+	// Добавляем команды к корневой команде
 	rootCmd.AddCommand(createCmd)
+	rootCmd.AddCommand(copyCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(repairCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(restoreTestCmd)
+	rootCmd.AddCommand(lsCmd)
+	rootCmd.AddCommand(catCmd)
+	rootCmd.AddCommand(findCmd)
+	rootCmd.AddCommand(versionsCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(serveSnapshotsCmd)
+	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(jobsCmd)
+	rootCmd.AddCommand(apiTokenCmd)
+	rootCmd.AddCommand(auditCmd)
 }
 
 // initConfig читает конфигурационный файл
@@ -107,23 +582,78 @@ func validateCreateFlags(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("количество версий должно быть не менее 1")
 	}
 
+	// Проверка хранения копий
+	if copyRetentionCount < 0 || copyRetentionDays < 0 {
+		return fmt.Errorf("параметры хранения копий не могут быть отрицательными")
+	}
+
+	// Проверка ограничений скорости
+	if err := config.ValidateBandwidthConfig(policyBandwidth()); err != nil {
+		return err
+	}
+
+	// Проверка параметров проверки восстановления
+	if restoreTestSample < 0 || restoreTestTimeout < 0 {
+		return fmt.Errorf("параметры проверки восстановления не могут быть отрицательными")
+	}
+
+	// Проверка размера тома
+	if _, err := config.ParseSize(volumeSize); err != nil {
+		return err
+	}
+
+	// Проверка режима пересечения запусков
+	switch types.OverlapMode(overlapMode) {
+	case types.OverlapSkip, types.OverlapQueue, types.OverlapCancelPrevious:
+	default:
+		return fmt.Errorf("неизвестный режим --overlap %q: допустимы skip, queue, cancel-previous", overlapMode)
+	}
+
+	// Проверка процента данных четности
+	if parityPercent < 0 || parityPercent > 100 {
+		return fmt.Errorf("процент данных четности должен быть от 0 до 100")
+	}
+
 	return nil
 }
 
-// runCreate выполняет команду create
-func runCreate(cmd *cobra.Command, args []string) error {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// interruptContext возвращает контекст, отменяемый первым SIGINT или SIGTERM
+//
+// Задачи, выполняемые с этим контекстом, завершаются в статусе cancelled
+// с сигналом в причине; временные файлы и частично загруженные объекты
+// удаляются. Повторный сигнал завершает процесс сразу, без очистки.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
 
-	// Обработка сигналов для graceful shutdown
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		<-signalCh
-		fmt.Println("\nПолучен сигнал прерывания, завершаем работу...")
-		cancel()
+		sig, ok := <-signalCh
+		if !ok {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "\nПолучен сигнал %s, отменяем задачу (повторный сигнал завершит процесс сразу)...\n", sig)
+		cancel(backup.CancelCause("получен сигнал " + sig.String()))
+
+		if sig, ok = <-signalCh; ok {
+			fmt.Fprintf(os.Stderr, "Получен повторный сигнал %s, принудительное завершение\n", sig)
+			os.Exit(128 + int(sig.(syscall.Signal)))
+		}
 	}()
 
+	return ctx, func() {
+		// После Stop сигналы в канал не приходят, и его можно закрыть
+		signal.Stop(signalCh)
+		close(signalCh)
+		cancel(nil)
+	}
+}
+
+// runCreate выполняет команду create
+func runCreate(cmd *cobra.Command, args []string) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
 	// Загрузка конфигурации
 	cfg, err := config.LoadConfig(cfgFile)
 	if err != nil {
@@ -138,6 +668,7 @@ func runCreate(cmd *cobra.Command, args []string) error {
 	if err := service.Initialize(ctx); err != nil {
 		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
 	}
+	defer service.Close()
 
 	// Создание политики бэкапа
 	policy := &types.BackupPolicy{
@@ -154,6 +685,22 @@ func runCreate(cmd *cobra.Command, args []string) error {
 		UpdatedAt:          time.Now(),
 	}
 
+	policy.Bandwidth = policyBandwidth()
+	policy.RestoreTest = policyRestoreTest()
+	if parityPercent > 0 {
+		policy.Parity = &types.ParityConfig{Percent: parityPercent}
+	}
+	policy.VolumeSize, _ = config.ParseSize(volumeSize)
+	policy.Overlap = types.OverlapMode(overlapMode)
+
+	for _, destination := range copyTo {
+		policy.CopyTargets = append(policy.CopyTargets, types.CopyTarget{
+			Destination:    destination,
+			RetentionCount: copyRetentionCount,
+			RetentionDays:  copyRetentionDays,
+		})
+	}
+
 	// Если имя не указано, генерируем его на основе исходного пути
 	if policy.Name == "" {
 		policy.Name = fmt.Sprintf("backup-%s", filepath.Base(sourcePath))
@@ -173,6 +720,7 @@ func runCreate(cmd *cobra.Command, args []string) error {
 	// Если расписание указано, выводим информацию о нем
 	if policy.Schedule != "" {
 		fmt.Printf("Расписание: %s\n", policy.Schedule)
+		fmt.Printf("Пересечение запусков: %s\n", policy.Overlap)
 	} else {
 		fmt.Println("Расписание: не указано (ручной запуск)")
 	}
@@ -183,7 +731,9 @@ func runCreate(cmd *cobra.Command, args []string) error {
 
 	// Запуск бэкапа
 	fmt.Println("\nЗапуск процесса бэкапа...")
+	stopProgress := startProgress(service, job.ID)
 	result, err := service.ExecuteBackup(ctx, job)
+	stopProgress()
 	if err != nil {
 		return fmt.Errorf("ошибка выполнения бэкапа: %w", err)
 	}
@@ -205,6 +755,948 @@ func runCreate(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("Контрольная сумма: %s\n", result.Checksum)
+	if result.RemoteChecksum != "" {
+		fmt.Printf("Проверено в хранилище: %s\n", result.RemoteChecksum)
+	}
+	if result.ParityPath != "" {
+		fmt.Printf("Данные четности: %s\n", result.ParityPath)
+	}
+	if result.Volumes > 0 {
+		fmt.Printf("Томов: %d (манифест: %s)\n", result.Volumes, result.BackupPath)
+	}
+
+	printCopies(result.Copies)
+
+	return nil
+}
+
+// progressBarWidth ширина полосы прогресса в символах
+const progressBarWidth = 24
+
+// startProgress выводит в stderr строку прогресса задачи, пока не будет
+// вызвана возвращаемая функция
+//
+// Строка перерисовывается на месте, поэтому выводится только в терминал.
+func startProgress(service *backup.Service, jobID string) func() {
+	if noProgress || !isTerminal(os.Stderr) {
+		return func() {}
+	}
+
+	events, unsubscribe := service.Events().Subscribe(jobID)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			if event.Progress != nil {
+				fmt.Fprintf(os.Stderr, "\r\033[K%s", formatProgress(event.Progress))
+			}
+		}
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}()
+
+	return func() {
+		unsubscribe()
+		<-done
+	}
+}
+
+// formatProgress возвращает строку прогресса: этап, полоса, файлы, байты, скорость и оставшееся время
+func formatProgress(p *types.JobProgress) string {
+	filled := min(int(p.PercentComplete/100*progressBarWidth), progressBarWidth)
+	line := fmt.Sprintf("%-9s [%s%s] %5.1f%%", p.Phase,
+		strings.Repeat("#", filled), strings.Repeat("-", progressBarWidth-filled), p.PercentComplete)
+
+	if p.TotalFiles > 0 {
+		line += fmt.Sprintf("  %d/%d файлов", p.FilesProcessed, p.TotalFiles)
+	} else if p.FilesProcessed > 0 {
+		line += fmt.Sprintf("  %d файлов", p.FilesProcessed)
+	}
+	if p.TotalBytes > 0 {
+		line += fmt.Sprintf("  %s / %s", formatBytes(p.BytesProcessed), formatBytes(p.TotalBytes))
+	} else if p.BytesProcessed > 0 {
+		line += "  " + formatBytes(p.BytesProcessed)
+	}
+	if p.BytesPerSecond > 0 {
+		line += fmt.Sprintf("  %s/с", formatBytes(int64(p.BytesPerSecond)))
+	}
+	if eta := p.ETA.Round(time.Second); eta > 0 {
+		line += fmt.Sprintf("  осталось %s", eta)
+	}
+
+	// Длинный путь сокращается с начала: важнее имя файла
+	if file := []rune(p.CurrentFile); len(file) > 40 {
+		line += "  ..." + string(file[len(file)-37:])
+	} else if len(file) > 0 {
+		line += "  " + string(file)
+	}
+
+	return line
+}
+
+// formatBytes возвращает размер в байтах в читаемом виде
+func formatBytes(size int64) string {
+	units := []string{"Б", "КБ", "МБ", "ГБ", "ТБ"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", size, units[0])
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
+// isTerminal проверяет, подключен ли файл к терминалу
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// policyBandwidth возвращает ограничения скорости политики из флагов или nil
+func policyBandwidth() *types.BandwidthConfig {
+	if uploadLimit == "" && downloadLimit == "" && maxParallelParts == 0 {
+		return nil
+	}
+
+	return &types.BandwidthConfig{
+		UploadRate:       uploadLimit,
+		DownloadRate:     downloadLimit,
+		MaxParallelParts: maxParallelParts,
+	}
+}
+
+// policyRestoreTest возвращает параметры проверки восстановления из флагов или nil
+func policyRestoreTest() *types.RestoreTestConfig {
+	if restoreTestSchedule == "" && restoreTestSample == 0 && restoreTestCommand == "" && restoreTestTimeout == 0 {
+		return nil
+	}
+
+	return &types.RestoreTestConfig{
+		Schedule:    restoreTestSchedule,
+		SampleFiles: restoreTestSample,
+		Command:     restoreTestCommand,
+		Timeout:     restoreTestTimeout,
+	}
+}
+
+// runCopy выполняет команду copy
+func runCopy(cmd *cobra.Command, args []string) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if copyRetentionCount < 0 || copyRetentionDays < 0 {
+		return fmt.Errorf("параметры хранения копий не могут быть отрицательными")
+	}
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	copies, err := service.CopyBackups(ctx, backup.CopyOptions{
+		From:           copyFrom,
+		To:             copyDestination,
+		PolicyID:       copyPolicyID,
+		RetentionCount: copyRetentionCount,
+		RetentionDays:  copyRetentionDays,
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка копирования бэкапов: %w", err)
+	}
+
+	if len(copies) == 0 {
+		fmt.Println("Нет новых бэкапов для копирования")
+		return nil
+	}
+
+	var copyValues []types.BackupCopy
+	for _, backupCopy := range copies {
+		copyValues = append(copyValues, *backupCopy)
+	}
+	printCopies(copyValues)
+
+	return nil
+}
+
+// printCopies выводит результаты копирования во вторичные хранилища
+func printCopies(copies []types.BackupCopy) {
+	if len(copies) == 0 {
+		return
+	}
+
+	fmt.Println("\nКопии во вторичных хранилищах:")
+	for _, backupCopy := range copies {
+		if backupCopy.Status == types.JobStatusCompleted {
+			fmt.Printf("  %s: %s (%d байт)\n", backupCopy.Destination, backupCopy.RemotePath, backupCopy.Size)
+		} else {
+			fmt.Printf("  %s: ошибка: %s\n", backupCopy.Destination, backupCopy.Error)
+		}
+	}
+}
+
+// runVerify выполняет команду verify
+func runVerify(cmd *cobra.Command, args []string) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if verifySample < 0 {
+		return fmt.Errorf("размер выборки не может быть отрицательным")
+	}
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	verifications, err := service.VerifyBackups(ctx, backup.VerifyOptions{
+		PolicyID: verifyPolicyID,
+		JobID:    verifyJobID,
+		Sample:   verifySample,
+		Deep:     verifyDeep,
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка проверки бэкапов: %w", err)
+	}
+
+	if len(verifications) == 0 {
+		fmt.Println("Нет бэкапов для проверки")
+		return nil
+	}
+
+	var failed int
+	for _, verification := range verifications {
+		if verification.Status == types.VerifyStatusPassed {
+			fmt.Printf("  OK     %s (%s)\n", verification.BackupPath, verification.Duration.Round(time.Millisecond))
+			if verification.Entries > 0 {
+				fmt.Printf("         записей архива: %d\n", verification.Entries)
+			}
+		} else {
+			failed++
+			fmt.Printf("  ОШИБКА %s: %s\n", verification.BackupPath, verification.Error)
+			if verification.Repairable {
+				fmt.Printf("         исправить: backupist repair --job %s\n", verification.JobID)
+			}
+		}
+	}
+
+	fmt.Printf("\nПроверено бэкапов: %d, с ошибками: %d\n", len(verifications), failed)
+	if failed > 0 {
+		return fmt.Errorf("не прошли проверку %d из %d бэкапов", failed, len(verifications))
+	}
+
+	return nil
+}
+
+// runRepair выполняет команду repair
+func runRepair(cmd *cobra.Command, args []string) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	result, err := service.RepairBackup(ctx, repairJobID)
+	if result != nil {
+		report := result.Report
+		fmt.Printf("Блоков данных: %d, повреждено: %d\n", report.Blocks, report.DamagedBlocks)
+		fmt.Printf("Повреждено блоков четности: %d\n", report.DamagedParity)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка исправления бэкапа: %w", err)
+	}
+
+	if !result.Report.Damaged() {
+		fmt.Println("Повреждений не найдено")
+		return nil
+	}
+	if result.Repaired {
+		fmt.Printf("Бэкап исправлен, контрольная сумма: %s\n", result.Checksum)
+	}
+	if result.ParityRebuilt {
+		fmt.Println("Данные четности пересозданы")
+	}
+
+	return nil
+}
+
+// runRestore выполняет команду restore
+func runRestore(cmd *cobra.Command, args []string) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	at, err := parseRestoreTime(restoreAt)
+	if err != nil {
+		return err
+	}
+	conflict, err := backup.ParseConflictMode(restoreConflict)
+	if err != nil {
+		return err
+	}
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	result, err := service.Restore(ctx, backup.RestoreOptions{
+		PolicyID:    restorePolicyID,
+		JobID:       restoreJobID,
+		At:          at,
+		Include:     restoreInclude,
+		Target:      restoreTarget,
+		Conflict:    conflict,
+		DryRun:      restoreDryRun,
+		SnapshotDir: restoreSnapshotDir,
+		NoSnapshot:  restoreNoSnapshot,
+	})
+	if result != nil {
+		fmt.Printf("Бэкап: %s (%s)\n", result.JobID, result.StartedAt.Local().Format("2006-01-02 15:04:05"))
+		if restoreDryRun {
+			printRestoreChanges(result.Changes)
+			fmt.Printf("Будет восстановлено файлов: %d (%d байт)\n", result.Files, result.Bytes)
+		} else {
+			fmt.Printf("Восстановлено файлов: %d (%d байт)\n", result.Files, result.Bytes)
+		}
+		fmt.Printf("Заменено: %d, рядом с существующими: %d, пропущено: %d\n", result.Overwritten, result.Renamed, result.Skipped)
+		if result.SnapshotDir != "" {
+			fmt.Printf("Замененные файлы сохранены в %s\n", result.SnapshotDir)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка восстановления: %w", err)
+	}
+
+	if restoreDryRun {
+		fmt.Println("Пробный запуск: файлы не изменены")
+		return nil
+	}
+
+	fmt.Printf("Длительность: %s\n", result.Duration.Round(time.Millisecond))
+	fmt.Printf("Файлы восстановлены в %s\n", restoreTarget)
+	return nil
+}
+
+// printRestoreChanges выводит изменения пробного запуска восстановления
+func printRestoreChanges(changes []backup.RestoreChange) {
+	for _, change := range changes {
+		switch change.Action {
+		case backup.RestoreActionCreate:
+			fmt.Printf("  создать    %s (%d байт)\n", change.Path, change.Size)
+		case backup.RestoreActionOverwrite:
+			fmt.Printf("  заменить   %s (%d байт)\n", change.Path, change.Size)
+		case backup.RestoreActionRename:
+			fmt.Printf("  рядом      %s -> %s (%d байт)\n", change.Path, change.NewPath, change.Size)
+		case backup.RestoreActionSkip:
+			fmt.Printf("  пропустить %s\n", change.Path)
+		}
+	}
+}
+
+// parseRestoreTime разбирает значение флага --at; пустое значение - нулевое время
+func parseRestoreTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range restoreTimeLayouts {
+		if at, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return at, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("неверный формат времени: %s (ожидается, например, 2026-10-01T12:00)", value)
+}
+
+// runLs выполняет команду ls
+func runLs(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	dir := ""
+	if len(args) > 1 {
+		dir = args[1]
+	}
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	entries, err := service.ListFiles(ctx, args[0], dir)
+	if err != nil {
+		return fmt.Errorf("ошибка получения содержимого бэкапа: %w", err)
+	}
+
+	for _, entry := range entries {
+		modTime := entry.ModTime.Local().Format("2006-01-02 15:04")
+		if entry.Dir {
+			fmt.Printf("d---------  %12d  %s  %s/ (файлов: %d)\n", entry.Size, modTime, entry.Name, entry.Files)
+		} else {
+			fmt.Printf("%s  %12d  %s  %s\n", os.FileMode(entry.Mode), entry.Size, modTime, entry.Name)
+		}
+	}
+
+	return nil
+}
+
+// runCat выполняет команду cat
+func runCat(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	if err := service.CatFile(ctx, args[0], args[1], os.Stdout); err != nil {
+		return fmt.Errorf("ошибка чтения файла из бэкапа: %w", err)
+	}
+
+	return nil
+}
+
+// runFind выполняет команду find
+func runFind(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	files, err := service.FindFiles(ctx, backup.FindOptions{
+		PolicyID: findPolicyID,
+		Name:     findName,
+		Path:     findPath,
+	})
+	var unindexed *backup.UnindexedError
+	if errors.As(err, &unindexed) {
+		fmt.Fprintf(os.Stderr, "Предупреждение: %v\n", err)
+	} else if err != nil {
+		return fmt.Errorf("ошибка поиска файлов: %w", err)
+	}
+
+	if len(files) == 0 {
+		fmt.Println("Файлы не найдены")
+		return nil
+	}
+
+	for _, file := range files {
+		fmt.Printf("%s  %s  %12d  %s\n", file.JobID, file.StartedAt.Local().Format("2006-01-02 15:04"), file.Size, file.Path)
+	}
+	fmt.Printf("\nНайдено: %d\n", len(files))
+
+	return nil
+}
+
+// runVersions выполняет команду versions
+func runVersions(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	versions, err := service.FileVersions(ctx, versionsPolicyID, args[0])
+	var unindexed *backup.UnindexedError
+	if errors.As(err, &unindexed) {
+		fmt.Fprintf(os.Stderr, "Предупреждение: %v\n", err)
+	} else if err != nil {
+		return fmt.Errorf("ошибка получения версий файла: %w", err)
+	}
+
+	if len(versions) == 0 {
+		fmt.Println("Файл не найден в бэкапах")
+		return nil
+	}
+
+	changes := 0
+	for _, version := range versions {
+		marker := " "
+		if version.Changed {
+			marker = "*"
+			changes++
+		}
+		checksum := version.Checksum
+		if checksum == "" {
+			checksum = "-"
+		} else if len(checksum) > 16 {
+			checksum = checksum[:16]
+		}
+		fmt.Printf("%s %s  %s  %12d  %s  %s\n", marker, version.JobID,
+			version.StartedAt.Local().Format("2006-01-02 15:04"), version.Size,
+			version.ModTime.Local().Format("2006-01-02 15:04:05"), checksum)
+	}
+	fmt.Printf("\nБэкапов: %d, версий: %d\n", len(versions), changes)
+
+	return nil
+}
+
+// runDiff выполняет команду diff
+func runDiff(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	diff, err := service.DiffBackups(ctx, args[0], args[1])
+	if err != nil {
+		return fmt.Errorf("ошибка сравнения бэкапов: %w", err)
+	}
+
+	if len(diff) == 0 {
+		fmt.Println("Различий нет")
+		return nil
+	}
+
+	counts := make(map[backup.DiffKind]int)
+	for _, change := range diff {
+		counts[change.Kind]++
+		switch change.Kind {
+		case backup.DiffAdded:
+			fmt.Printf("+ %12d  %s\n", change.New.Size, change.Path)
+		case backup.DiffRemoved:
+			fmt.Printf("- %12d  %s\n", change.Old.Size, change.Path)
+		case backup.DiffModified:
+			fmt.Printf("M %12d  %s (было %d)\n", change.New.Size, change.Path, change.Old.Size)
+		}
+	}
+	fmt.Printf("\nДобавлено: %d, удалено: %d, изменено: %d\n",
+		counts[backup.DiffAdded], counts[backup.DiffRemoved], counts[backup.DiffModified])
+
+	return nil
+}
+
+// runServeSnapshots выполняет команду serve-snapshots
+func runServeSnapshots(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	serverConfig := cfg.SnapshotServer
+	if serveListen != "" {
+		serverConfig.Listen = serveListen
+	}
+	if serveToken != "" {
+		serverConfig.Token = serveToken
+	}
+	if serveTLSCert != "" {
+		serverConfig.TLSCert = serveTLSCert
+	}
+	if serveTLSKey != "" {
+		serverConfig.TLSKey = serveTLSKey
+	}
+	if err := config.ValidateSnapshotServerConfig(&serverConfig); err != nil {
+		return fmt.Errorf("ошибка валидации параметров сервера: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	scheme := "http"
+	if serverConfig.TLSCert != "" {
+		scheme = "https"
+	}
+	fmt.Printf("Бэкапы доступны по адресу %s://%s/, для остановки нажмите Ctrl+C\n", scheme, serverConfig.Listen)
+
+	return service.ServeSnapshots(ctx, serverConfig)
+}
+
+// runRestoreTest выполняет команду restore-test
+func runRestoreTest(cmd *cobra.Command, args []string) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	result, err := service.RunRestoreTest(ctx, restoreTestPolicyID)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки восстановления: %w", err)
+	}
+
+	fmt.Printf("Бэкап: %s\n", result.JobID)
+	fmt.Printf("Проверено файлов: %d, с ошибками: %d\n", result.FilesChecked, result.FilesFailed)
+	fmt.Printf("Время восстановления: %s\n", result.RestoreDuration.Round(time.Millisecond))
+	fmt.Printf("Общее время: %s\n", result.Duration.Round(time.Millisecond))
+	if result.CommandOutput != "" {
+		fmt.Printf("Вывод команды проверки:\n%s\n", result.CommandOutput)
+	}
+
+	if result.Status != types.VerifyStatusPassed {
+		return fmt.Errorf("проверка восстановления не пройдена: %s", result.Error)
+	}
+
+	fmt.Println("Проверка восстановления пройдена")
+	return nil
+}
+
+// runDaemon выполняет команду daemon
+func runDaemon(cmd *cobra.Command, args []string) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	fmt.Println("Планировщик запущен, для остановки нажмите Ctrl+C")
+
+	return backup.NewScheduler(service).Run(ctx)
+}
+
+// runJobsCancel выполняет команду jobs cancel
+func runJobsCancel(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	reason := cancelReason
+	if reason == "" {
+		reason = "командой jobs cancel"
+	}
+
+	job, err := service.CancelJob(ctx, args[0], reason)
+	if err != nil {
+		return fmt.Errorf("ошибка отмены задачи: %w", err)
+	}
+
+	if job.Status == types.JobStatusCancelled {
+		fmt.Printf("Задача %s отменена\n", job.ID)
+		return nil
+	}
+	fmt.Printf("Запрошена отмена задачи %s\n", job.ID)
+	if !cancelWait {
+		return nil
+	}
+
+	// Задачу завершает выполняющий ее процесс
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for !backup.JobFinished(job.Status) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if job, err = service.GetJob(ctx, job.ID); err != nil {
+			return fmt.Errorf("ошибка получения задачи: %w", err)
+		}
+	}
+
+	fmt.Printf("Задача завершена в статусе %s", job.Status)
+	if job.Error != "" {
+		fmt.Printf(": %s", job.Error)
+	}
+	fmt.Println()
+
+	return nil
+}
+
+// runAPITokenCreate выполняет команду api-token create
+func runAPITokenCreate(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var roles []types.RoleBinding
+	for _, value := range tokenRoles {
+		binding, err := config.ParseRoleBinding(value)
+		if err != nil {
+			return err
+		}
+		roles = append(roles, binding)
+	}
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	token, value, err := service.CreateAPIToken(ctx, tokenName, roles, tokenExpires)
+	if err != nil {
+		return fmt.Errorf("ошибка создания API-токена: %w", err)
+	}
+
+	fmt.Printf("Токен %s создан, ID: %s\n", token.Name, token.ID)
+	fmt.Printf("Роли: %s\n", formatRoles(token.Roles))
+	if token.ExpiresAt != nil {
+		fmt.Printf("Действует до: %s\n", token.ExpiresAt.Local().Format("2006-01-02 15:04"))
+	}
+	fmt.Printf("\n%s\n\nСохраните значение токена: повторно его получить нельзя\n", value)
+
+	return nil
+}
+
+// runAPITokenList выполняет команду api-token list
+func runAPITokenList(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	tokens, err := service.ListAPITokens(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка получения API-токенов: %w", err)
+	}
+
+	if len(tokens) == 0 {
+		fmt.Println("API-токенов нет")
+		return nil
+	}
+
+	now := time.Now()
+	for _, token := range tokens {
+		state := "активен"
+		switch {
+		case token.RevokedAt != nil:
+			state = "отозван " + token.RevokedAt.Local().Format("2006-01-02 15:04")
+		case token.ExpiresAt != nil && now.After(*token.ExpiresAt):
+			state = "истек " + token.ExpiresAt.Local().Format("2006-01-02 15:04")
+		case token.ExpiresAt != nil:
+			state = "до " + token.ExpiresAt.Local().Format("2006-01-02 15:04")
+		}
+		lastUsed := "-"
+		if token.LastUsedAt != nil {
+			lastUsed = token.LastUsedAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%s  %-20s  %-30s  использован: %-16s  %s\n", token.ID, token.Name, formatRoles(token.Roles), lastUsed, state)
+	}
+
+	return nil
+}
+
+// runAPITokenRevoke выполняет команду api-token revoke
+func runAPITokenRevoke(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	token, err := service.RevokeAPIToken(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("ошибка отзыва API-токена: %w", err)
+	}
+
+	fmt.Printf("Токен %s (%s) отозван\n", token.Name, token.ID)
+	return nil
+}
+
+// formatRoles возвращает роли в формате флага --role
+func formatRoles(roles []types.RoleBinding) string {
+	values := make([]string, 0, len(roles))
+	for _, binding := range roles {
+		if binding.PolicyID == "" {
+			values = append(values, string(binding.Role))
+		} else {
+			values = append(values, string(binding.Role)+":"+binding.PolicyID)
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+// runAudit выполняет команду audit
+func runAudit(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if auditLimit < 0 {
+		return fmt.Errorf("количество записей не может быть отрицательным")
+	}
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, logger.NewStructuredLogger("main"))
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	entries, total, err := service.ListAudit(ctx, backup.AuditFilter{
+		Actor:    auditActor,
+		PolicyID: auditPolicyID,
+		Action:   auditAction,
+		Limit:    auditLimit,
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка чтения журнала аудита: %w", err)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("Записей нет")
+		return nil
+	}
+
+	for _, entry := range entries {
+		actor := entry.Actor
+		if actor == "" {
+			actor = "-"
+		}
+		fmt.Printf("%s  %-16s  %-6s  %-16s  %d  %s %s", entry.Time.Local().Format("2006-01-02 15:04:05"),
+			actor, entry.AuthMethod, entry.Action, entry.Status, entry.Method, entry.Path)
+		if entry.Error != "" {
+			fmt.Printf("  (%s)", entry.Error)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("\nПоказано: %d из %d\n", len(entries), total)
 
 	return nil
 }
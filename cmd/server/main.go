@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"backupist/internal/core/backup"
+	"backupist/internal/core/config"
+	"backupist/internal/handlers/rest"
+	"backupist/internal/logger"
+
+	"github.com/spf13/cobra"
+)
+
+// shutdownTimeout сколько ждать завершения запросов при остановке сервера
+const shutdownTimeout = 30 * time.Second
+
+var (
+	// Параметры командной строки
+	cfgFile    string
+	listenAddr string
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "backupist-server",
+	Short: "REST API сервер Backupist",
+	Long: `Запускает REST API для управления политиками, бэкапами и
+восстановлением. Спецификация OpenAPI 3 доступна по адресу
+/api/v1/openapi.json. Адрес сервера задается флагом --listen или в
+конфигурации:
+
+  api:
+    listen: "127.0.0.1:8080"
+
+Запросы аутентифицируются заголовком "Authorization: Bearer <токен>":
+API-токеном (backupist api-token create) или JWT провайдера OIDC,
+роли которого берутся из claim в формате role или role:policy-id:
+
+  api:
+    auth:
+      oidc:
+        issuer: "https://sso.example.com/realms/main"
+        audience: "backupist"
+        roles_claim: "roles"
+
+Каждое действие записывается в журнал аудита (backupist audit).`,
+	RunE: runServer,
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "путь к файлу конфигурации")
+	rootCmd.Flags().StringVar(&listenAddr, "listen", "", "адрес сервера (по умолчанию из конфигурации, 127.0.0.1:8080)")
+}
+
+// runServer запускает REST API сервер до сигнала остановки
+func runServer(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Загрузка конфигурации
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	apiConfig := cfg.API
+	if listenAddr != "" {
+		apiConfig.Listen = listenAddr
+	}
+	if err := config.ValidateAPIServerConfig(&apiConfig); err != nil {
+		return fmt.Errorf("ошибка валидации параметров сервера: %w", err)
+	}
+
+	log := logger.NewStructuredLogger("server")
+
+	// Инициализация сервиса бэкапа
+	service := backup.NewService(cfg, log)
+	if err := service.Initialize(ctx); err != nil {
+		return fmt.Errorf("ошибка инициализации сервиса: %w", err)
+	}
+	defer service.Close()
+
+	switch {
+	case apiConfig.Auth.Disabled:
+		log.WarnContext(ctx, "Аутентификация API отключена: любой запрос выполняется с ролью admin")
+	case apiConfig.Auth.OIDC == nil:
+		if tokens, err := service.ListAPITokens(ctx); err == nil && len(tokens) == 0 {
+			log.WarnContext(ctx, "Нет API-токенов и не настроен OIDC: создайте токен командой backupist api-token create")
+		}
+	}
+
+	api := rest.NewServer(service, apiConfig, log)
+	defer api.Close()
+
+	server := &http.Server{
+		Addr:              apiConfig.Listen,
+		Handler:           api,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	server.RegisterOnShutdown(api.CloseStreams)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	log.InfoContext(ctx, "REST API сервер запущен", "listen", apiConfig.Listen)
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("ошибка запуска сервера: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	// Повторный сигнал обрабатывается по умолчанию и завершает процесс
+	// сразу, не дожидаясь отмены запущенных бэкапов
+	cancel()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer shutdownCancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("ошибка остановки сервера: %w", err)
+	}
+
+	log.InfoContext(ctx, "REST API сервер остановлен")
+	return nil
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,160 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// APITokenPrefix префикс API-токенов; по нему токен отличается от JWT
+	APITokenPrefix = "bkp_"
+
+	// tokenUsageInterval как часто обновляется время последнего использования токена
+	tokenUsageInterval = time.Minute
+)
+
+// ErrUnauthenticated токен отсутствует, отозван или истек
+var ErrUnauthenticated = errors.New("не пройдена аутентификация")
+
+// AuditFilter параметры выборки журнала аудита
+type AuditFilter struct {
+	Actor    string // Пусто - все субъекты
+	PolicyID string // Пусто - все политики
+	Action   string // Пусто - все действия
+	Limit    int    // 0 - без ограничения
+	Offset   int
+}
+
+// CreateAPIToken создает API-токен с ролями и возвращает его вместе со
+// значением токена, которое больше нигде не сохраняется
+//
+// ttl 0 - токен без срока действия.
+func (s *Service) CreateAPIToken(ctx context.Context, name string, roles []types.RoleBinding, ttl time.Duration) (*types.APIToken, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", &catalogError{kind: ErrInvalid, err: errors.New("не указано имя токена")}
+	}
+	if len(roles) == 0 {
+		return nil, "", &catalogError{kind: ErrInvalid, err: errors.New("не указаны роли токена")}
+	}
+	if ttl < 0 {
+		return nil, "", &catalogError{kind: ErrInvalid, err: errors.New("срок действия токена не может быть отрицательным")}
+	}
+	if _, err := s.getAPIToken(ctx, name); err == nil {
+		return nil, "", &catalogError{kind: ErrInvalid, err: fmt.Errorf("токен с именем %s уже существует", name)}
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, "", err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("ошибка генерации токена: %w", err)
+	}
+	value := APITokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	token := &types.APIToken{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Roles:     roles,
+		CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		expiresAt := token.CreatedAt.Add(ttl)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := s.saveAPIToken(ctx, token, hashAPIToken(value)); err != nil {
+		return nil, "", err
+	}
+
+	return token, value, nil
+}
+
+// ListAPITokens возвращает все API-токены, включая отозванные
+func (s *Service) ListAPITokens(ctx context.Context) ([]*types.APIToken, error) {
+	return s.getAPITokens(ctx)
+}
+
+// RevokeAPIToken отзывает API-токен по ID или имени
+func (s *Service) RevokeAPIToken(ctx context.Context, idOrName string) (*types.APIToken, error) {
+	token, err := s.getAPIToken(ctx, idOrName)
+	if err != nil {
+		return nil, err
+	}
+	if token.RevokedAt != nil {
+		return token, nil
+	}
+
+	revokedAt := time.Now()
+	if err := s.revokeAPIToken(ctx, token.ID, revokedAt); err != nil {
+		return nil, err
+	}
+	token.RevokedAt = &revokedAt
+
+	return token, nil
+}
+
+// AuthenticateAPIToken возвращает действующий API-токен по его значению
+//
+// Для неизвестного, отозванного или истекшего токена возвращается
+// ошибка вида ErrUnauthenticated.
+func (s *Service) AuthenticateAPIToken(ctx context.Context, value string) (*types.APIToken, error) {
+	token, err := s.getAPITokenByHash(ctx, hashAPIToken(value))
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil, &catalogError{kind: ErrUnauthenticated, err: errors.New("неизвестный API-токен")}
+	case err != nil:
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.RevokedAt != nil {
+		return nil, &catalogError{kind: ErrUnauthenticated, err: fmt.Errorf("API-токен %s отозван", token.Name)}
+	}
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, &catalogError{kind: ErrUnauthenticated, err: fmt.Errorf("срок действия API-токена %s истек", token.Name)}
+	}
+
+	// Время использования обновляется не на каждый запрос
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= tokenUsageInterval {
+		if err := s.updateAPITokenUsage(ctx, token.ID, now); err != nil {
+			s.logger.WarnContext(ctx, "Ошибка обновления времени использования API-токена", "token", token.Name, "error", err)
+		}
+		token.LastUsedAt = &now
+	}
+
+	return token, nil
+}
+
+// hashAPIToken возвращает SHA-256 значения токена
+//
+// Токен содержит 256 случайных бит, поэтому медленный хеш для
+// защиты от перебора не нужен.
+func hashAPIToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// RecordAudit добавляет запись в журнал аудита
+func (s *Service) RecordAudit(ctx context.Context, entry *types.AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	return s.saveAuditEntry(ctx, entry)
+}
+
+// ListAudit возвращает записи журнала аудита по фильтру, начиная с новых,
+// и общее количество подходящих записей
+func (s *Service) ListAudit(ctx context.Context, filter AuditFilter) ([]*types.AuditEntry, int, error) {
+	return s.listAuditEntries(ctx, filter)
+}
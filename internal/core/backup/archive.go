@@ -2,13 +2,13 @@ package backup
 
 import (
 	"archive/tar"
+	"backupist/pkg/types"
 	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 )
 
@@ -21,8 +21,28 @@ func (s *Service) createArchive(ctx context.Context, sourcePath, archivePath str
 	}
 	defer outFile.Close()
 
+	if err := s.writeArchive(ctx, sourcePath, archivePath, outFile); err != nil {
+		return err
+	}
+
+	if err := outFile.Close(); err != nil {
+		return fmt.Errorf("ошибка записи файла архива: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "Архив создан успешно",
+		"source", sourcePath,
+		"archive", archivePath)
+
+	return nil
+}
+
+// writeArchive записывает tar.gz архив директории в поток
+//
+// skipPath - файл, который не попадает в архив (сам архив, если он
+// создается внутри исходной директории).
+func (s *Service) writeArchive(ctx context.Context, sourcePath, skipPath string, w io.Writer) error {
 	// Создаем gzip writer
-	gzWriter := gzip.NewWriter(outFile)
+	gzWriter := gzip.NewWriter(w)
 	defer gzWriter.Close()
 
 	// Создаем tar writer
@@ -31,9 +51,10 @@ func (s *Service) createArchive(ctx context.Context, sourcePath, archivePath str
 
 	// Определяем базовую директорию для расчета относительных путей
 	baseDir := filepath.Dir(sourcePath)
+	progress := progressFrom(ctx)
 
 	// Рекурсивно добавляем файлы в архив
-	err = filepath.Walk(sourcePath, func(filePath string, info os.FileInfo, err error) error {
+	err := filepath.Walk(sourcePath, func(filePath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return fmt.Errorf("ошибка при обходе файла %s: %w", filePath, err)
 		}
@@ -46,7 +67,7 @@ func (s *Service) createArchive(ctx context.Context, sourcePath, archivePath str
 		}
 
 		// Пропускаем сам архив, если он находится внутри исходной директории
-		if filePath == archivePath {
+		if filePath == skipPath {
 			return nil
 		}
 
@@ -77,9 +98,10 @@ func (s *Service) createArchive(ctx context.Context, sourcePath, archivePath str
 				return fmt.Errorf("ошибка открытия файла %s: %w", filePath, err)
 			}
 			defer file.Close()
+			progress.file(header.Name)
 
 			// Копируем содержимое файла в архив
-			if _, err := io.Copy(tarWriter, file); err != nil {
+			if _, err := io.Copy(tarWriter, progressReader(ctx, file, types.ProgressPhaseArchive)); err != nil {
 				return fmt.Errorf("ошибка записи файла %s в архив: %w", filePath, err)
 			}
 		}
@@ -91,9 +113,13 @@ func (s *Service) createArchive(ctx context.Context, sourcePath, archivePath str
 		return fmt.Errorf("ошибка создания архива: %w", err)
 	}
 
-	s.logger.InfoContext(ctx, "Архив создан успешно",
-		"source", sourcePath,
-		"archive", archivePath)
+	// Закрываем явно: при закрытии дописываются концы tar и gzip
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("ошибка завершения tar: %w", err)
+	}
+	if err := gzWriter.Close(); err != nil {
+		return fmt.Errorf("ошибка завершения gzip: %w", err)
+	}
 
 	return nil
 }
@@ -107,72 +133,8 @@ func (s *Service) extractArchive(ctx context.Context, archivePath, destPath stri
 	}
 	defer file.Close()
 
-	// Создаем gzip reader
-	gzReader, err := gzip.NewReader(file)
-	if err != nil {
-		return fmt.Errorf("ошибка создания gzip reader: %w", err)
-	}
-	defer gzReader.Close()
-
-	// Создаем tar reader
-	tarReader := tar.NewReader(gzReader)
-
-	// Извлекаем файлы
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("ошибка чтения заголовка tar: %w", err)
-		}
-
-		// Проверяем контекст на отмену
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-
-		// Создаем полный путь для извлечения
-		fullPath := filepath.Join(destPath, header.Name)
-
-		// Проверяем на попытку выхода за пределы целевой директории (zip slip)
-		if !strings.HasPrefix(fullPath, filepath.Clean(destPath)+string(os.PathSeparator)) {
-			return fmt.Errorf("небезопасный путь в архиве: %s", header.Name)
-		}
-
-		switch header.Typeflag {
-		case tar.TypeDir:
-			// Создаем директорию
-			if err := os.MkdirAll(fullPath, os.FileMode(header.Mode)); err != nil {
-				return fmt.Errorf("ошибка создания директории %s: %w", fullPath, err)
-			}
-
-		case tar.TypeReg:
-			// Создаем директорию для файла
-			if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-				return fmt.Errorf("ошибка создания директории для файла %s: %w", fullPath, err)
-			}
-
-			// Создаем файл
-			outFile, err := os.OpenFile(fullPath, os.O_CREATE|os.O_WRONLY, os.FileMode(header.Mode))
-			if err != nil {
-				return fmt.Errorf("ошибка создания файла %s: %w", fullPath, err)
-			}
-
-			// Копируем содержимое
-			if _, err := io.Copy(outFile, tarReader); err != nil {
-				outFile.Close()
-				return fmt.Errorf("ошибка записи файла %s: %w", fullPath, err)
-			}
-			outFile.Close()
-
-		default:
-			s.logger.WarnContext(ctx, "Неподдерживаемый тип файла в архиве",
-				"type", header.Typeflag,
-				"name", header.Name)
-		}
+	if err := s.extractArchiveStream(ctx, file, destPath); err != nil {
+		return err
 	}
 
 	s.logger.InfoContext(ctx, "Архив извлечен успешно",
@@ -182,6 +144,14 @@ func (s *Service) extractArchive(ctx context.Context, archivePath, destPath stri
 	return nil
 }
 
+// extractArchiveStream извлекает tar.gz архив из потока в указанную директорию
+//
+// Существующие файлы заменяются.
+func (s *Service) extractArchiveStream(ctx context.Context, r io.Reader, destPath string) error {
+	ex := &extractor{service: s, destPath: destPath, result: &RestoreResult{}}
+	return ex.extract(ctx, r)
+}
+
 // getDirectorySize вычисляет общий размер директории в байтах
 func (s *Service) getDirectorySize(path string) (int64, error) {
 	var totalSize int64
@@ -288,18 +258,13 @@ type ArchiveInfo struct {
 	ModTime          time.Time `json:"mod_time"`
 }
 
-// validateArchive проверяет целостность архива
-func (s *Service) validateArchive(ctx context.Context, archivePath string) error {
-	file, err := os.Open(archivePath)
-	if err != nil {
-		return fmt.Errorf("ошибка открытия архива: %w", err)
-	}
-	defer file.Close()
-
+// validateArchive проверяет целостность потока архива и возвращает
+// количество записей в нем
+func (s *Service) validateArchive(ctx context.Context, archive io.Reader) (int, error) {
 	// Проверяем gzip заголовок
-	gzReader, err := gzip.NewReader(file)
+	gzReader, err := gzip.NewReader(archive)
 	if err != nil {
-		return fmt.Errorf("ошибка чтения gzip заголовка: %w", err)
+		return 0, fmt.Errorf("ошибка чтения gzip заголовка: %w", err)
 	}
 	defer gzReader.Close()
 
@@ -310,7 +275,7 @@ func (s *Service) validateArchive(ctx context.Context, archivePath string) error
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return 0, ctx.Err()
 		default:
 		}
 
@@ -319,7 +284,7 @@ func (s *Service) validateArchive(ctx context.Context, archivePath string) error
 			break
 		}
 		if err != nil {
-			return fmt.Errorf("ошибка чтения tar заголовка: %w", err)
+			return 0, fmt.Errorf("ошибка чтения tar заголовка: %w", err)
 		}
 
 		fileCount++
@@ -328,16 +293,20 @@ func (s *Service) validateArchive(ctx context.Context, archivePath string) error
 		if header.Typeflag == tar.TypeReg {
 			// Читаем и отбрасываем содержимое для проверки целостности
 			if _, err := io.Copy(io.Discard, tarReader); err != nil {
-				return fmt.Errorf("ошибка чтения содержимого файла %s: %w", header.Name, err)
+				return 0, fmt.Errorf("ошибка чтения содержимого файла %s: %w", header.Name, err)
 			}
 		}
 	}
 
+	// Дочитываем gzip поток, чтобы проверить его контрольную сумму
+	if _, err := io.Copy(io.Discard, gzReader); err != nil {
+		return 0, fmt.Errorf("ошибка чтения gzip потока: %w", err)
+	}
+
 	s.logger.InfoContext(ctx, "Архив валидирован успешно",
-		"archive", archivePath,
 		"files", fileCount)
 
-	return nil
+	return fileCount, nil
 }
 
 // createIncrementalArchive создает инкрементальный архив
@@ -0,0 +1,310 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	storageapi "backupist/pkg/storage"
+	"backupist/pkg/types"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+)
+
+const (
+	// defaultAzureBlockSize размер блока по умолчанию для загрузки больших файлов
+	defaultAzureBlockSize = 8 * 1024 * 1024
+	// defaultAzureConcurrency количество параллельно загружаемых блоков по умолчанию
+	defaultAzureConcurrency = 4
+)
+
+// AzureStorage реализация хранилища Azure Blob Storage
+type AzureStorage struct {
+	client      *container.Client
+	accessTier  *blob.AccessTier
+	blockSize   int64
+	concurrency int
+}
+
+// NewAzureStorage создает новый экземпляр хранилища Azure Blob Storage
+//
+// Поддерживается аутентификация по ключу учетной записи (shared key) и по SAS токену.
+// Для эмулятора Azurite адрес сервиса задается через Endpoint,
+// например http://127.0.0.1:10000/devstoreaccount1.
+func NewAzureStorage(cfg *types.AzureConfig) (*AzureStorage, error) {
+	if err := config.ValidateAzureConfig(cfg); err != nil {
+		return nil, fmt.Errorf("ошибка валидации конфигурации Azure: %w", err)
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", cfg.AccountName)
+	}
+	containerURL := strings.TrimSuffix(endpoint, "/") + "/" + cfg.Container
+
+	var client *container.Client
+	var err error
+
+	if cfg.AccountKey != "" {
+		cred, credErr := container.NewSharedKeyCredential(cfg.AccountName, cfg.AccountKey)
+		if credErr != nil {
+			return nil, fmt.Errorf("ошибка создания учетных данных Azure: %w", credErr)
+		}
+		client, err = container.NewClientWithSharedKeyCredential(containerURL, cred, nil)
+	} else {
+		client, err = container.NewClientWithNoCredential(containerURL+"?"+strings.TrimPrefix(cfg.SASToken, "?"), nil)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания Azure клиента: %w", err)
+	}
+
+	storage := &AzureStorage{
+		client:      client,
+		blockSize:   cfg.BlockSize,
+		concurrency: cfg.Concurrency,
+	}
+
+	if storage.blockSize == 0 {
+		storage.blockSize = defaultAzureBlockSize
+	}
+	if storage.concurrency <= 0 {
+		storage.concurrency = defaultAzureConcurrency
+	}
+	if cfg.AccessTier != "" {
+		tier := blob.AccessTier(cfg.AccessTier)
+		storage.accessTier = &tier
+	}
+
+	return storage, nil
+}
+
+// Upload загружает файл в Azure Blob Storage
+//
+// Файлы больше размера блока загружаются отдельными блоками (StageBlock)
+// параллельно, после чего список блоков фиксируется одним запросом.
+func (as *AzureStorage) Upload(ctx context.Context, localPath, remotePath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия локального файла: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("ошибка получения информации о файле: %w", err)
+	}
+
+	blobClient := as.client.NewBlockBlobClient(filepath.ToSlash(remotePath))
+
+	if info.Size() <= as.blockSize {
+		body := streaming.NopCloser(throttleReadSeeker(ctx, file, directionUpload))
+		_, err := blobClient.Upload(ctx, body, &blockblob.UploadOptions{Tier: as.accessTier})
+		if err != nil {
+			return fmt.Errorf("ошибка загрузки файла в Azure: %w", err)
+		}
+		return nil
+	}
+
+	blockIDs, err := as.stageBlocks(ctx, blobClient, file, info.Size())
+	if err != nil {
+		return err
+	}
+
+	_, err = blobClient.CommitBlockList(ctx, blockIDs, &blockblob.CommitBlockListOptions{Tier: as.accessTier})
+	if err != nil {
+		return fmt.Errorf("ошибка фиксации списка блоков в Azure: %w", err)
+	}
+
+	return nil
+}
+
+// stageBlocks параллельно загружает блоки файла и возвращает их идентификаторы по порядку
+func (as *AzureStorage) stageBlocks(ctx context.Context, blobClient *blockblob.Client, file *os.File, size int64) ([]string, error) {
+	blockCount := int((size + as.blockSize - 1) / as.blockSize)
+	blockIDs := make([]string, blockCount)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	semaphore := make(chan struct{}, maxParallelParts(ctx, as.concurrency))
+
+	for i := 0; i < blockCount; i++ {
+		// Идентификаторы блоков должны иметь одинаковую длину
+		blockIDs[i] = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%08d", i)))
+
+		offset := int64(i) * as.blockSize
+		length := as.blockSize
+		if offset+length > size {
+			length = size - offset
+		}
+
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(blockID string, section io.ReadSeeker) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			body := streaming.NopCloser(throttleReadSeeker(ctx, section, directionUpload))
+			if _, err := blobClient.StageBlock(ctx, blockID, body, nil); err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("ошибка загрузки блока в Azure: %w", err)
+					cancel()
+				})
+			}
+		}(blockIDs[i], io.NewSectionReader(file, offset, length))
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return blockIDs, nil
+}
+
+// Download скачивает файл из Azure Blob Storage
+func (as *AzureStorage) Download(ctx context.Context, remotePath, localPath string) error {
+	// Создаем директорию для локального файла
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("ошибка создания локальной директории: %w", err)
+	}
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("ошибка создания локального файла: %w", err)
+	}
+	defer file.Close()
+
+	blobClient := as.client.NewBlobClient(filepath.ToSlash(remotePath))
+
+	// Параллельное скачивание блоков пишет в файл по смещениям, поэтому
+	// при ограничении скорости файл скачивается одним потоком
+	if len(bandwidthLimiters(ctx)) > 0 {
+		resp, err := blobClient.DownloadStream(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("ошибка скачивания файла из Azure: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if _, err := io.Copy(file, throttleReader(ctx, resp.Body, directionDownload)); err != nil {
+			return fmt.Errorf("ошибка скачивания файла из Azure: %w", err)
+		}
+		return nil
+	}
+
+	_, err = blobClient.DownloadFile(ctx, file, &blob.DownloadFileOptions{
+		BlockSize:   as.blockSize,
+		Concurrency: uint16(maxParallelParts(ctx, as.concurrency)),
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка скачивания файла из Azure: %w", err)
+	}
+
+	return nil
+}
+
+// Open открывает blob для чтения потоком
+func (as *AzureStorage) Open(ctx context.Context, remotePath string) (io.ReadCloser, error) {
+	resp, err := as.client.NewBlobClient(filepath.ToSlash(remotePath)).DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка скачивания файла из Azure: %w", err)
+	}
+
+	return &readCloser{Reader: throttleReader(ctx, resp.Body, directionDownload), Closer: resp.Body}, nil
+}
+
+// Delete удаляет файл из Azure Blob Storage
+func (as *AzureStorage) Delete(ctx context.Context, remotePath string) error {
+	_, err := as.client.NewBlobClient(filepath.ToSlash(remotePath)).Delete(ctx, nil)
+	return err
+}
+
+// List возвращает список объектов в контейнере Azure
+func (as *AzureStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	var objects []string
+
+	pager := as.client.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
+		Prefix: &prefix,
+	})
+
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения списка объектов Azure: %w", err)
+		}
+
+		for _, item := range page.Segment.BlobItems {
+			if item.Name != nil {
+				objects = append(objects, *item.Name)
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+// Verify сравнивает MD5 блоба с локальным файлом
+//
+// Сервис хранит MD5 только для блобов, записанных одним запросом;
+// для блобов из блоков сравнивается размер.
+func (as *AzureStorage) Verify(ctx context.Context, localPath, remotePath string) (string, error) {
+	props, err := as.client.NewBlobClient(filepath.ToSlash(remotePath)).GetProperties(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения свойств блоба Azure: %w", err)
+	}
+
+	if len(props.ContentMD5) == 0 {
+		if props.ContentLength == nil {
+			return "", fmt.Errorf("%w: сервис не вернул размер блоба", storageapi.ErrVerifyUnsupported)
+		}
+		return verifySize(localPath, remotePath, *props.ContentLength)
+	}
+
+	local, err := hashFile(localPath, md5.New(), 0, -1)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Equal(local, props.ContentMD5) {
+		return "", checksumMismatch(remotePath, "md5", local, props.ContentMD5)
+	}
+
+	return "md5:" + hex.EncodeToString(props.ContentMD5), nil
+}
+
+func (as *AzureStorage) Exists(ctx context.Context, remotePath string) (bool, error) {
+	_, err := as.client.NewBlobClient(filepath.ToSlash(remotePath)).GetProperties(ctx, nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("ошибка проверки существования объекта в Azure: %w", err)
+	}
+	return true, nil
+}
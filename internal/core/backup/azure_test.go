@@ -0,0 +1,143 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	"context"
+	"crypto/rand"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Учетная запись разработки Azurite (общеизвестная, не секрет)
+const (
+	azuriteAccount = "devstoreaccount1"
+	azuriteKey     = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+// azuriteEndpoint возвращает адрес Blob-сервиса Azurite или пропускает
+// тест, если эмулятор не запущен; адрес задается AZURITE_BLOB_ENDPOINT
+func azuriteEndpoint(t *testing.T) string {
+	t.Helper()
+
+	endpoint := os.Getenv("AZURITE_BLOB_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "http://127.0.0.1:10000/" + azuriteAccount
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.DialTimeout("tcp", u.Host, time.Second)
+	if err != nil {
+		t.Skipf("Azurite недоступен по адресу %s: %v", endpoint, err)
+	}
+	conn.Close()
+
+	return endpoint
+}
+
+// newTestAzureFactory фабрика хранилищ с учетной записью Azurite
+func newTestAzureFactory(t *testing.T, endpoint string) *StorageFactory {
+	t.Helper()
+
+	cfg := config.NewConfig()
+	cfg.Storage.AzureAccountName = azuriteAccount
+	cfg.Storage.AzureAccountKey = azuriteKey
+	cfg.Storage.AzureEndpoint = endpoint
+	cfg.Storage.AzureBlockSize = 64 << 10
+	cfg.Storage.AzureConcurrency = 3
+	cfg.Storage.Retry.MaxAttempts = 1
+
+	factory := NewStorageFactory(cfg)
+	t.Cleanup(func() { factory.Close() })
+	return factory
+}
+
+func TestAzureConfigConcurrency(t *testing.T) {
+	factory := newTestAzureFactory(t, "http://127.0.0.1:10000/"+azuriteAccount)
+
+	cfg, err := factory.resolveAzureConfig(&url.URL{Scheme: "azure", Host: "backups"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage, err := NewAzureStorage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storage.concurrency != 3 || storage.blockSize != 64<<10 {
+		t.Fatalf("параметры загрузки из конфигурации: concurrency %d, block size %d", storage.concurrency, storage.blockSize)
+	}
+}
+
+func TestAzureStorage(t *testing.T) {
+	endpoint := azuriteEndpoint(t)
+	ctx := context.Background()
+
+	containerName := "test-" + uuid.New().String()[:8]
+	factory := newTestAzureFactory(t, endpoint)
+	cfg, err := factory.resolveAzureConfig(&url.URL{Scheme: "azure", Host: containerName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	azure, err := NewAzureStorage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := azure.client.Create(ctx, nil); err != nil {
+		t.Fatalf("создание контейнера: %v", err)
+	}
+	t.Cleanup(func() { azure.client.Delete(context.Background(), nil) })
+
+	storage, err := factory.CreateStorage("azure://" + containerName + "/daily")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Файл больше блока загружается несколькими блоками
+	data := make([]byte, 300<<10)
+	rand.Read(data)
+	local := filepath.Join(t.TempDir(), "backup.tar")
+	if err := os.WriteFile(local, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Upload(ctx, local, "2026/backup.tar"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyUpload(ctx, storage, local, "2026/backup.tar"); err != nil {
+		t.Fatalf("проверка загруженного файла: %v", err)
+	}
+
+	downloaded := filepath.Join(t.TempDir(), "restored.tar")
+	if err := storage.Download(ctx, "2026/backup.tar", downloaded); err != nil {
+		t.Fatal(err)
+	}
+	if restored, _ := os.ReadFile(downloaded); !slices.Equal(restored, data) {
+		t.Fatal("скачанный файл не совпадает с загруженным")
+	}
+
+	for remotePath, want := range map[string]bool{"2026/backup.tar": true, "2026/missing.tar": false} {
+		exists, err := storage.Exists(ctx, remotePath)
+		if err != nil || exists != want {
+			t.Fatalf("Exists(%s) = %v, %v; ожидалось %v", remotePath, exists, err, want)
+		}
+	}
+
+	files, err := storage.List(ctx, "2026")
+	if err != nil || !slices.Equal(files, []string{"2026/backup.tar"}) {
+		t.Fatalf("List(2026) = %v, %v", files, err)
+	}
+
+	if err := storage.Delete(ctx, "2026/backup.tar"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := storage.Exists(ctx, "2026/backup.tar"); exists {
+		t.Fatal("файл существует после удаления")
+	}
+}
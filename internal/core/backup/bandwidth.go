@@ -0,0 +1,451 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	storageapi "backupist/pkg/storage"
+	"backupist/pkg/types"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// throttleChunk максимальный объем данных, на который ожидается один токен-бакет
+const throttleChunk = 32 * 1024
+
+// transferDirection направление передачи данных
+type transferDirection int
+
+const (
+	directionUpload transferDirection = iota
+	directionDownload
+)
+
+// BandwidthLimiter ограничивает скорость передачи данных по алгоритму token bucket
+//
+// Один ограничитель делится между всеми передачами, которые его используют,
+// поэтому общая скорость не превышает заданную. Лимит пересчитывается
+// по расписанию при каждом ожидании.
+type BandwidthLimiter struct {
+	mu       sync.Mutex
+	upload   *rate.Limiter
+	download *rate.Limiter
+
+	uploadRate       int64
+	downloadRate     int64
+	windows          []bandwidthWindow
+	maxParallelParts int
+	now              func() time.Time
+}
+
+// bandwidthWindow разобранное окно расписания
+type bandwidthWindow struct {
+	days         map[time.Weekday]bool
+	start, end   time.Duration
+	uploadRate   int64
+	downloadRate int64
+}
+
+// NewBandwidthLimiter создает ограничитель по конфигурации
+//
+// Возвращает nil, если конфигурация не задает ни одного ограничения.
+func NewBandwidthLimiter(cfg types.BandwidthConfig) (*BandwidthLimiter, error) {
+	if err := config.ValidateBandwidthConfig(&cfg); err != nil {
+		return nil, fmt.Errorf("ошибка валидации ограничений скорости: %w", err)
+	}
+
+	bl := &BandwidthLimiter{
+		upload:           rate.NewLimiter(rate.Inf, throttleChunk),
+		download:         rate.NewLimiter(rate.Inf, throttleChunk),
+		maxParallelParts: cfg.MaxParallelParts,
+		now:              time.Now,
+	}
+
+	// Ошибки разбора исключены валидацией выше
+	bl.uploadRate, _ = config.ParseRate(cfg.UploadRate)
+	bl.downloadRate, _ = config.ParseRate(cfg.DownloadRate)
+
+	limited := bl.uploadRate > 0 || bl.downloadRate > 0
+	for _, w := range cfg.Schedule {
+		window := bandwidthWindow{days: make(map[time.Weekday]bool)}
+		for _, day := range w.Days {
+			weekday, _ := config.ParseWeekday(day)
+			window.days[weekday] = true
+		}
+		window.start, _ = config.ParseDayTime(w.Start)
+		window.end, _ = config.ParseDayTime(w.End)
+		window.uploadRate, _ = config.ParseRate(w.UploadRate)
+		window.downloadRate, _ = config.ParseRate(w.DownloadRate)
+
+		limited = limited || window.uploadRate > 0 || window.downloadRate > 0
+		bl.windows = append(bl.windows, window)
+	}
+
+	if !limited && bl.maxParallelParts == 0 {
+		return nil, nil
+	}
+
+	return bl, nil
+}
+
+// MaxParallelParts возвращает ограничение на количество параллельно передаваемых частей
+func (bl *BandwidthLimiter) MaxParallelParts() int {
+	return bl.maxParallelParts
+}
+
+// wait ожидает возможности передать n байт в заданном направлении
+func (bl *BandwidthLimiter) wait(ctx context.Context, direction transferDirection, n int) error {
+	return bl.limiter(direction).WaitN(ctx, n)
+}
+
+// limiter возвращает token bucket направления с лимитом, действующим сейчас
+func (bl *BandwidthLimiter) limiter(direction transferDirection) *rate.Limiter {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	uploadRate, downloadRate := bl.currentRates(bl.now())
+
+	limiter, bytesPerSecond := bl.upload, uploadRate
+	if direction == directionDownload {
+		limiter, bytesPerSecond = bl.download, downloadRate
+	}
+
+	limit := rate.Inf
+	if bytesPerSecond > 0 {
+		limit = rate.Limit(bytesPerSecond)
+	}
+	if limiter.Limit() != limit {
+		limiter.SetLimit(limit)
+	}
+
+	return limiter
+}
+
+// currentRates возвращает скорости, действующие в момент now
+func (bl *BandwidthLimiter) currentRates(now time.Time) (int64, int64) {
+	sinceMidnight := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+	yesterday := now.AddDate(0, 0, -1).Weekday()
+
+	for _, window := range bl.windows {
+		var active bool
+		if window.start <= window.end {
+			active = sinceMidnight >= window.start && sinceMidnight < window.end && window.matches(now.Weekday())
+		} else {
+			// Окно через полночь относится ко дню, в который началось
+			active = sinceMidnight >= window.start && window.matches(now.Weekday()) ||
+				sinceMidnight < window.end && window.matches(yesterday)
+		}
+
+		if active {
+			return window.uploadRate, window.downloadRate
+		}
+	}
+
+	return bl.uploadRate, bl.downloadRate
+}
+
+// matches проверяет, действует ли окно в указанный день недели
+func (w bandwidthWindow) matches(day time.Weekday) bool {
+	return len(w.days) == 0 || w.days[day]
+}
+
+// bandwidthKey ключ контекста для ограничителей скорости
+type bandwidthKey struct{}
+
+// WithBandwidth добавляет ограничитель скорости к передачам, выполняемым с контекстом
+//
+// Ограничители накапливаются: передача ждет каждый из них, поэтому
+// ограничение политики действует вместе с глобальным.
+func WithBandwidth(ctx context.Context, limiter *BandwidthLimiter) context.Context {
+	if limiter == nil {
+		return ctx
+	}
+
+	limiters := bandwidthLimiters(ctx)
+	for _, existing := range limiters {
+		if existing == limiter {
+			return ctx
+		}
+	}
+
+	next := make([]*BandwidthLimiter, len(limiters), len(limiters)+1)
+	copy(next, limiters)
+
+	return context.WithValue(ctx, bandwidthKey{}, append(next, limiter))
+}
+
+// bandwidthLimiters возвращает ограничители скорости из контекста
+func bandwidthLimiters(ctx context.Context) []*BandwidthLimiter {
+	limiters, _ := ctx.Value(bandwidthKey{}).([]*BandwidthLimiter)
+	return limiters
+}
+
+// maxParallelParts возвращает допустимое количество параллельных частей передачи
+func maxParallelParts(ctx context.Context, defaultParts int) int {
+	parts := defaultParts
+	for _, limiter := range bandwidthLimiters(ctx) {
+		if limit := limiter.MaxParallelParts(); limit > 0 && limit < parts {
+			parts = limit
+		}
+	}
+
+	return max(parts, 1)
+}
+
+// throttleReader ограничивает скорость чтения ограничителями из контекста
+//
+// Через него проходят загрузки всех хранилищ, поэтому здесь же
+// учитывается прогресс загрузки задачи из контекста.
+func throttleReader(ctx context.Context, r io.Reader, direction transferDirection) io.Reader {
+	if direction == directionUpload {
+		r = progressReader(ctx, r, types.ProgressPhaseUpload)
+	}
+
+	limiters := bandwidthLimiters(ctx)
+	if len(limiters) == 0 {
+		return r
+	}
+
+	return &throttledReader{ctx: ctx, r: r, direction: direction, limiters: limiters}
+}
+
+// throttleReadSeeker ограничивает скорость чтения с сохранением возможности перемотки,
+// необходимой SDK для повторной отправки запроса
+func throttleReadSeeker(ctx context.Context, r io.ReadSeeker, direction transferDirection) io.ReadSeeker {
+	if direction == directionUpload {
+		r = progressReadSeeker(ctx, r, types.ProgressPhaseUpload)
+	}
+
+	limiters := bandwidthLimiters(ctx)
+	if len(limiters) == 0 {
+		return r
+	}
+
+	return &throttledReadSeeker{
+		throttledReader: throttledReader{ctx: ctx, r: r, direction: direction, limiters: limiters},
+		seeker:          r,
+	}
+}
+
+// throttleWriter ограничивает скорость записи ограничителями из контекста
+func throttleWriter(ctx context.Context, w io.Writer, direction transferDirection) io.Writer {
+	limiters := bandwidthLimiters(ctx)
+	if len(limiters) == 0 {
+		return w
+	}
+
+	return &throttledWriter{ctx: ctx, w: w, direction: direction, limiters: limiters}
+}
+
+// throttledReader читает данные не быстрее ограничителей скорости
+type throttledReader struct {
+	ctx       context.Context
+	r         io.Reader
+	direction transferDirection
+	limiters  []*BandwidthLimiter
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+
+	n, err := tr.r.Read(p)
+	if n > 0 {
+		for _, limiter := range tr.limiters {
+			if waitErr := limiter.wait(tr.ctx, tr.direction, n); waitErr != nil {
+				return n, waitErr
+			}
+		}
+	}
+
+	return n, err
+}
+
+// throttledReadSeeker throttledReader с поддержкой перемотки
+type throttledReadSeeker struct {
+	throttledReader
+	seeker io.Seeker
+}
+
+func (ts *throttledReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return ts.seeker.Seek(offset, whence)
+}
+
+// throttledWriter записывает данные не быстрее ограничителей скорости
+type throttledWriter struct {
+	ctx       context.Context
+	w         io.Writer
+	direction transferDirection
+	limiters  []*BandwidthLimiter
+}
+
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	var written int
+
+	for len(p) > 0 {
+		chunk := p[:min(len(p), throttleChunk)]
+		for _, limiter := range tw.limiters {
+			if err := limiter.wait(tw.ctx, tw.direction, len(chunk)); err != nil {
+				return written, err
+			}
+		}
+
+		n, err := tw.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// bandwidthStorage применяет глобальный ограничитель скорости ко всем операциям хранилища
+type bandwidthStorage struct {
+	storage StorageProvider
+	limiter *BandwidthLimiter
+}
+
+func (bs *bandwidthStorage) Upload(ctx context.Context, localPath, remotePath string) error {
+	return bs.storage.Upload(WithBandwidth(ctx, bs.limiter), localPath, remotePath)
+}
+
+func (bs *bandwidthStorage) Download(ctx context.Context, remotePath, localPath string) error {
+	return bs.storage.Download(WithBandwidth(ctx, bs.limiter), remotePath, localPath)
+}
+
+func (bs *bandwidthStorage) Delete(ctx context.Context, remotePath string) error {
+	return bs.storage.Delete(ctx, remotePath)
+}
+
+func (bs *bandwidthStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	return bs.storage.List(ctx, prefix)
+}
+
+func (bs *bandwidthStorage) Exists(ctx context.Context, remotePath string) (bool, error) {
+	return bs.storage.Exists(ctx, remotePath)
+}
+
+func (bs *bandwidthStorage) Open(ctx context.Context, remotePath string) (io.ReadCloser, error) {
+	opener, ok := bs.storage.(storageapi.Opener)
+	if !ok {
+		return nil, storageapi.ErrOpenUnsupported
+	}
+	return opener.Open(WithBandwidth(ctx, bs.limiter), remotePath)
+}
+
+func (bs *bandwidthStorage) Verify(ctx context.Context, localPath, remotePath string) (string, error) {
+	return verifyUpload(ctx, bs.storage, localPath, remotePath)
+}
+
+// Close закрывает обернутое хранилище, если оно держит подключения
+func (bs *bandwidthStorage) Close() error {
+	if closer, ok := bs.storage.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// pacedStorage ограничивает скорость хранилищ, которые передают данные сами:
+// хранилищ из публичного реестра и внешних плагинов
+//
+// Такие провайдеры не читают ограничители из контекста, поэтому объем
+// переданного файла списывается с ограничителей целиком: загрузка длится
+// не меньше, чем при заданной скорости, а скачивание задерживает следующие
+// передачи. Средняя скорость не превышает лимит, мгновенная - может.
+type pacedStorage struct {
+	storage StorageProvider
+}
+
+func (ps *pacedStorage) Upload(ctx context.Context, localPath, remotePath string) error {
+	limiters := bandwidthLimiters(ctx)
+	if len(limiters) == 0 {
+		return ps.storage.Upload(ctx, localPath, remotePath)
+	}
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("ошибка получения размера файла: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- ps.storage.Upload(ctx, localPath, remotePath) }()
+
+	paceErr := pace(ctx, limiters, directionUpload, info.Size())
+	if err := <-done; err != nil {
+		return err
+	}
+	return paceErr
+}
+
+func (ps *pacedStorage) Download(ctx context.Context, remotePath, localPath string) error {
+	if err := ps.storage.Download(ctx, remotePath, localPath); err != nil {
+		return err
+	}
+
+	limiters := bandwidthLimiters(ctx)
+	if len(limiters) == 0 {
+		return nil
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("ошибка получения размера файла: %w", err)
+	}
+	return pace(ctx, limiters, directionDownload, info.Size())
+}
+
+func (ps *pacedStorage) Delete(ctx context.Context, remotePath string) error {
+	return ps.storage.Delete(ctx, remotePath)
+}
+
+func (ps *pacedStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	return ps.storage.List(ctx, prefix)
+}
+
+func (ps *pacedStorage) Exists(ctx context.Context, remotePath string) (bool, error) {
+	return ps.storage.Exists(ctx, remotePath)
+}
+
+func (ps *pacedStorage) Open(ctx context.Context, remotePath string) (io.ReadCloser, error) {
+	opener, ok := ps.storage.(storageapi.Opener)
+	if !ok {
+		return nil, storageapi.ErrOpenUnsupported
+	}
+	reader, err := opener.Open(ctx, remotePath)
+	if err != nil {
+		return nil, err
+	}
+	return &readCloser{Reader: throttleReader(ctx, reader, directionDownload), Closer: reader}, nil
+}
+
+func (ps *pacedStorage) Verify(ctx context.Context, localPath, remotePath string) (string, error) {
+	return verifyUpload(ctx, ps.storage, localPath, remotePath)
+}
+
+// Close закрывает обернутое хранилище, если оно держит подключения или процессы
+func (ps *pacedStorage) Close() error {
+	if closer, ok := ps.storage.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// pace списывает size байт с ограничителей скорости порциями throttleChunk
+func pace(ctx context.Context, limiters []*BandwidthLimiter, direction transferDirection, size int64) error {
+	for size > 0 {
+		chunk := int(min(size, throttleChunk))
+		for _, limiter := range limiters {
+			if err := limiter.wait(ctx, direction, chunk); err != nil {
+				return err
+			}
+		}
+		size -= int64(chunk)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	storageapi "backupist/pkg/storage"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// copyProvider стороннее хранилище, которое передает данные само и не
+// знает об ограничителях скорости
+type copyProvider struct {
+	root string
+}
+
+func (c *copyProvider) Upload(ctx context.Context, localPath, remotePath string) error {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(c.root, remotePath), data, 0644)
+}
+
+func (c *copyProvider) Download(ctx context.Context, remotePath, localPath string) error {
+	data, err := os.ReadFile(filepath.Join(c.root, remotePath))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(localPath, data, 0644)
+}
+
+func (c *copyProvider) Delete(ctx context.Context, remotePath string) error {
+	return os.Remove(filepath.Join(c.root, remotePath))
+}
+
+func (c *copyProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	return nil, nil
+}
+
+func (c *copyProvider) Exists(ctx context.Context, remotePath string) (bool, error) {
+	_, err := os.Stat(filepath.Join(c.root, remotePath))
+	return err == nil, nil
+}
+
+func TestBandwidthRegistryBackend(t *testing.T) {
+	root := t.TempDir()
+	storageapi.Register(storageapi.Backend{
+		Scheme: "copytest",
+		New: func(ctx context.Context, cfg storageapi.Config) (storageapi.Provider, error) {
+			return &copyProvider{root: root}, nil
+		},
+	})
+
+	cfg := config.NewConfig()
+	cfg.Storage.Retry.MaxAttempts = 1
+	cfg.Storage.Bandwidth.UploadRate = "256KiB/s"
+	cfg.Storage.Bandwidth.DownloadRate = "256KiB/s"
+	factory := NewStorageFactory(cfg)
+	t.Cleanup(func() { factory.Close() })
+	limiter, err := NewBandwidthLimiter(cfg.Storage.Bandwidth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory.SetBandwidth(limiter)
+
+	storage, err := factory.CreateStorage("copytest://archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// 320 КиБ при 256 КиБ/с: за вычетом начального запаса не меньше секунды
+	local := filepath.Join(t.TempDir(), "backup.tar")
+	writeTestFile(t, local, strings.Repeat("x", 320<<10))
+
+	start := time.Now()
+	if err := storage.Upload(ctx, local, "backup.tar"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("загрузка 320 КиБ заняла %s, ограничение скорости не применено", elapsed)
+	}
+
+	start = time.Now()
+	if err := storage.Download(ctx, "backup.tar", filepath.Join(t.TempDir(), "restored.tar")); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("скачивание 320 КиБ заняло %s, ограничение скорости не применено", elapsed)
+	}
+}
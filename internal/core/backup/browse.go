@@ -0,0 +1,453 @@
+package backup
+
+import (
+	"archive/tar"
+	"backupist/pkg/types"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotFile файл в завершенном бэкапе (снимке) политики
+type SnapshotFile struct {
+	types.BackupFile
+	PolicyID  string
+	StartedAt time.Time // Время начала бэкапа
+}
+
+// DirEntry запись листинга директории бэкапа
+type DirEntry struct {
+	Name    string
+	Dir     bool
+	Size    int64  // Для директории - суммарный размер файлов в ней
+	Files   int    // Для директории - количество файлов в ней
+	Mode    uint32 // Права доступа файла
+	ModTime time.Time
+}
+
+// FindOptions параметры поиска файлов по бэкапам
+type FindOptions struct {
+	PolicyID string // Пусто - бэкапы всех политик
+	Name     string // Шаблон имени файла (path.Match)
+	Path     string // Шаблон пути относительно исходной директории, ** - любые директории
+}
+
+// ListFiles возвращает содержимое директории dir бэкапа задачи
+//
+// Листинг строится по индексу файлов из каталога, бэкап не скачивается.
+// Для бэкапов без индекса он создается по заголовкам архива.
+func (s *Service) ListFiles(ctx context.Context, jobID, dir string) ([]DirEntry, error) {
+	files, err := s.backupIndex(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	entries := make(map[string]*DirEntry)
+	for _, file := range files {
+		if file.Path == dir {
+			return []DirEntry{fileEntry(path.Base(file.Path), file)}, nil
+		}
+
+		rel, ok := strings.CutPrefix(file.Path, prefix)
+		if !ok {
+			continue
+		}
+
+		name, _, nested := strings.Cut(rel, "/")
+		if !nested {
+			entry := fileEntry(name, file)
+			entries[name] = &entry
+			continue
+		}
+
+		entry, ok := entries[name]
+		if !ok {
+			entry = &DirEntry{Name: name, Dir: true}
+			entries[name] = entry
+		}
+		entry.Size += file.Size
+		entry.Files++
+		if file.ModTime.After(entry.ModTime) {
+			entry.ModTime = file.ModTime
+		}
+	}
+
+	if len(entries) == 0 && dir != "" {
+		return nil, fmt.Errorf("в бэкапе задачи %s нет пути %s", jobID, dir)
+	}
+
+	list := make([]DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, *entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Dir != list[j].Dir {
+			return list[i].Dir
+		}
+		return list[i].Name < list[j].Name
+	})
+
+	return list, nil
+}
+
+// fileEntry возвращает запись листинга для файла индекса
+func fileEntry(name string, file types.BackupFile) DirEntry {
+	return DirEntry{
+		Name:    name,
+		Size:    file.Size,
+		Mode:    file.Mode,
+		ModTime: file.ModTime,
+	}
+}
+
+// CatFile записывает в w содержимое файла filePath из бэкапа задачи
+//
+// Бэкап читается из хранилища потоком до нужной записи архива.
+// Если в индексе есть SHA-256 файла, содержимое сверяется с ним.
+func (s *Service) CatFile(ctx context.Context, jobID, filePath string, w io.Writer) error {
+	job, err := s.browsableJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+
+	policy, err := s.getPolicy(ctx, job.PolicyID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения политики: %w", err)
+	}
+
+	files, err := s.backupIndex(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+
+	filePath = strings.Trim(path.Clean("/"+filePath), "/")
+	var file *types.BackupFile
+	for i := range files {
+		if files[i].Path == filePath {
+			file = &files[i]
+			break
+		}
+	}
+	if file == nil {
+		return fmt.Errorf("в бэкапе задачи %s нет файла %s", job.ID, filePath)
+	}
+
+	reader, err := s.openEntry(ctx, policy, job, file)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(w, reader); err != nil {
+		return fmt.Errorf("ошибка чтения файла %s: %w", filePath, err)
+	}
+
+	return nil
+}
+
+// openEntry открывает файл индекса бэкапа задачи для чтения потоком
+//
+// Архив читается из хранилища (с расшифровкой) до записи файла. При
+// чтении до конца содержимое сверяется с SHA-256 из индекса.
+func (s *Service) openEntry(ctx context.Context, policy *types.BackupPolicy, job *types.BackupJob, file *types.BackupFile) (io.ReadCloser, error) {
+	tempDir, err := os.MkdirTemp("", "backupist-entry-*")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания временной директории: %w", err)
+	}
+
+	archive, err := s.openArchive(ctx, policy, job, tempDir)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return nil, err
+	}
+
+	entry := &entryReader{archive: archive, tempDir: tempDir, path: file.Path, hash: sha256.New(), expected: file.Checksum}
+
+	entry.gzReader, err = gzip.NewReader(archive)
+	if err != nil {
+		entry.Close()
+		return nil, fmt.Errorf("ошибка создания gzip reader: %w", err)
+	}
+
+	entryName := backupName(job.BackupPath) + "/" + file.Path
+	tarReader := tar.NewReader(entry.gzReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			entry.Close()
+			return nil, fmt.Errorf("файл %s не найден в архиве бэкапа", file.Path)
+		}
+		if err != nil {
+			entry.Close()
+			return nil, fmt.Errorf("ошибка чтения заголовка tar: %w", err)
+		}
+		if header.Name == entryName && header.Typeflag == tar.TypeReg {
+			entry.Reader = tarReader
+			return entry, nil
+		}
+	}
+}
+
+// entryReader поток файла из архива бэкапа
+type entryReader struct {
+	io.Reader
+	archive  io.ReadCloser
+	gzReader *gzip.Reader
+	tempDir  string
+
+	path     string
+	hash     hash.Hash
+	expected string // Пусто - не проверять
+}
+
+func (er *entryReader) Read(p []byte) (int, error) {
+	n, err := er.Reader.Read(p)
+	er.hash.Write(p[:n])
+
+	if err == io.EOF && er.expected != "" {
+		if checksum := hex.EncodeToString(er.hash.Sum(nil)); checksum != er.expected {
+			return n, fmt.Errorf("%s: SHA-256 %s, в индексе %s", er.path, checksum, er.expected)
+		}
+	}
+
+	return n, err
+}
+
+// Close закрывает архив и удаляет временную директорию
+func (er *entryReader) Close() error {
+	if er.gzReader != nil {
+		er.gzReader.Close()
+	}
+	err := er.archive.Close()
+	os.RemoveAll(er.tempDir)
+	return err
+}
+
+// FindFiles ищет файлы по имени или пути во всех завершенных бэкапах
+//
+// Поиск выполняется по индексу файлов из каталога; индекс бэкапов без
+// него строится перед поиском. Результат упорядочен по времени бэкапа.
+func (s *Service) FindFiles(ctx context.Context, opts FindOptions) ([]SnapshotFile, error) {
+	if opts.Name == "" && opts.Path == "" {
+		return nil, fmt.Errorf("не указан шаблон имени или пути")
+	}
+	if _, err := path.Match(opts.Name, ""); err != nil {
+		return nil, fmt.Errorf("неверный шаблон имени %q: %w", opts.Name, err)
+	}
+
+	var pathPattern []string
+	if opts.Path != "" {
+		var err error
+		if pathPattern, err = normalizeInclude([]string{opts.Path}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Найденное в проиндексированных бэкапах возвращается и вместе с
+	// *UnindexedError
+	var unindexed *UnindexedError
+	if err := s.indexSnapshots(ctx, opts.PolicyID); err != nil && !errors.As(err, &unindexed) {
+		return nil, err
+	}
+
+	files, err := s.getSnapshotFiles(ctx, opts.PolicyID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var found []SnapshotFile
+	for _, file := range files {
+		if opts.Name != "" {
+			if ok, _ := path.Match(opts.Name, path.Base(file.Path)); !ok {
+				continue
+			}
+		}
+		if pathPattern != nil && !matchGlob(pathPattern[0], file.Path) {
+			continue
+		}
+		found = append(found, file)
+	}
+
+	if unindexed != nil {
+		return found, unindexed
+	}
+	return found, nil
+}
+
+// browsableJob возвращает завершенную задачу бэкапа, содержимое которой можно просматривать
+func (s *Service) browsableJob(ctx context.Context, jobID string) (*types.BackupJob, error) {
+	job, err := s.getBackupJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != types.JobStatusCompleted || job.BackupPath == "" {
+		return nil, fmt.Errorf("бэкап задачи %s не завершен или удален", job.ID)
+	}
+	return job, nil
+}
+
+// backupIndex возвращает индекс файлов бэкапа задачи из каталога
+//
+// Для бэкапов, созданных до появления индекса, он строится по
+// заголовкам архива и сохраняется в каталог; SHA-256 файлов в таком
+// индексе нет. Задача с сохраненным индексом отмечается в каталоге,
+// поэтому бэкап без файлов не скачивается повторно.
+func (s *Service) backupIndex(ctx context.Context, jobID string) ([]types.BackupFile, error) {
+	job, err := s.browsableJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	indexed, err := s.isJobIndexed(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+	files, err := s.getBackupFiles(ctx, job.ID)
+	if err != nil || indexed {
+		return files, err
+	}
+
+	// Индекс сохранен до появления отметки
+	if len(files) > 0 {
+		return files, s.markJobIndexed(ctx, job.ID)
+	}
+
+	policy, err := s.getPolicy(ctx, job.PolicyID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения политики: %w", err)
+	}
+
+	files, err = s.indexArchive(ctx, policy, job)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения индекса бэкапа: %w", err)
+	}
+	if err := s.saveBackupFiles(ctx, job.ID, files); err != nil {
+		return nil, fmt.Errorf("ошибка сохранения индекса бэкапа: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "Построен индекс файлов бэкапа",
+		"job_id", job.ID,
+		"files", len(files))
+
+	return files, nil
+}
+
+// UnindexedError бэкапы, индекс файлов которых не удалось построить;
+// поиск по каталогу их не видит
+type UnindexedError struct {
+	JobIDs []string
+	Err    error
+}
+
+func (e *UnindexedError) Error() string {
+	return fmt.Sprintf("нет индекса файлов бэкапов задач %s: %v", strings.Join(e.JobIDs, ", "), e.Err)
+}
+
+func (e *UnindexedError) Unwrap() error {
+	return e.Err
+}
+
+// indexSnapshots строит индекс файлов завершенных бэкапов политики,
+// у которых его нет; пустой policyID - бэкапы всех политик
+//
+// Бэкапы, индекс которых построить не удалось, возвращаются в
+// *UnindexedError.
+func (s *Service) indexSnapshots(ctx context.Context, policyID string) error {
+	jobIDs, err := s.getUnindexedJobs(ctx, policyID)
+	if err != nil {
+		return err
+	}
+
+	unindexed := &UnindexedError{}
+	var errs []error
+	for _, jobID := range jobIDs {
+		if _, err := s.backupIndex(ctx, jobID); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			unindexed.JobIDs = append(unindexed.JobIDs, jobID)
+			errs = append(errs, fmt.Errorf("%s: %w", jobID, err))
+		}
+	}
+	if len(unindexed.JobIDs) == 0 {
+		return nil
+	}
+
+	unindexed.Err = errors.Join(errs...)
+	return unindexed
+}
+
+// indexArchive читает заголовки архива бэкапа и возвращает его файлы
+func (s *Service) indexArchive(ctx context.Context, policy *types.BackupPolicy, job *types.BackupJob) ([]types.BackupFile, error) {
+	tempDir, err := os.MkdirTemp("", "backupist-index-*")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания временной директории: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	archive, err := s.openArchive(ctx, policy, job, tempDir)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	gzReader, err := gzip.NewReader(archive)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания gzip reader: %w", err)
+	}
+	defer gzReader.Close()
+
+	root := backupName(job.BackupPath) + "/"
+	var files []types.BackupFile
+
+	tarReader := tar.NewReader(gzReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения заголовка tar: %w", err)
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		rel, ok := strings.CutPrefix(header.Name, root)
+		if !ok || header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		files = append(files, types.BackupFile{
+			Path:       rel,
+			SourcePath: filepath.Join(policy.SourcePath, filepath.FromSlash(rel)),
+			Size:       header.Size,
+			Mode:       uint32(os.FileMode(header.Mode).Perm()),
+			ModTime:    header.ModTime,
+		})
+	}
+
+	// Дочитываем поток до конца, чтобы проверить контрольную сумму бэкапа
+	if _, err := io.Copy(io.Discard, archive); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
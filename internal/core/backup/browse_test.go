@@ -0,0 +1,199 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// browseFiles файлы исходной директории для тестов просмотра бэкапов
+var browseFiles = map[string]string{
+	"a.txt":         "alpha",
+	"dir/b.txt":     "beta",
+	"dir/sub/c.log": "gamma",
+}
+
+// dropIndex удаляет индекс файлов задачи из каталога, как у бэкапа,
+// созданного до появления индекса
+func dropIndex(t *testing.T, service *Service, jobID string) {
+	t.Helper()
+
+	if _, err := service.db.Exec(`DELETE FROM backup_files WHERE job_id = ?`, jobID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.db.Exec(`UPDATE backup_jobs SET indexed = false WHERE id = ?`, jobID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "list", browseFiles, nil)
+	job := runTestBackup(t, service, policy.ID)
+	ctx := context.Background()
+
+	names := func(entries []DirEntry) []string {
+		var list []string
+		for _, entry := range entries {
+			list = append(list, entry.Name)
+		}
+		return list
+	}
+
+	root, err := service.ListFiles(ctx, job.ID, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Директории перечисляются первыми, с размером и числом файлов в них
+	if !slices.Equal(names(root), []string{"dir", "a.txt"}) || !root[0].Dir || root[0].Files != 2 || root[0].Size != 9 || root[1].Size != 5 {
+		t.Fatalf("корень бэкапа: %+v", root)
+	}
+
+	dir, err := service.ListFiles(ctx, job.ID, "dir/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names(dir), []string{"sub", "b.txt"}) {
+		t.Fatalf("директория dir: %+v", dir)
+	}
+
+	file, err := service.ListFiles(ctx, job.ID, "dir/sub/c.log")
+	if err != nil || len(file) != 1 || file[0].Name != "c.log" || file[0].Dir {
+		t.Fatalf("листинг файла: %+v, %v", file, err)
+	}
+
+	if _, err := service.ListFiles(ctx, job.ID, "missing"); err == nil {
+		t.Fatal("листинг несуществующего пути прошел без ошибки")
+	}
+}
+
+func TestCatFile(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "cat", browseFiles, func(policy *types.BackupPolicy) {
+		policy.EncryptionEnabled = true
+		policy.EncryptionPassword = "secret"
+	})
+	job := runTestBackup(t, service, policy.ID)
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	if err := service.CatFile(ctx, job.ID, "dir/b.txt", &out); err != nil || out.String() != "beta" {
+		t.Fatalf("содержимое файла: %q, %v", out.String(), err)
+	}
+
+	if err := service.CatFile(ctx, job.ID, "dir/missing.txt", &out); err == nil {
+		t.Fatal("чтение несуществующего файла прошло без ошибки")
+	}
+
+	// Содержимое сверяется с SHA-256 из индекса
+	_, err := service.db.Exec(`UPDATE backup_files SET checksum = ? WHERE job_id = ? AND relative_path = ?`,
+		strings.Repeat("0", 64), job.ID, "dir/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	err = service.CatFile(ctx, job.ID, "dir/b.txt", &out)
+	if err == nil || !strings.Contains(err.Error(), "в индексе "+strings.Repeat("0", 64)) {
+		t.Fatalf("чтение файла с другой SHA-256 в индексе: %v", err)
+	}
+}
+
+func TestFindFiles(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "find", browseFiles, nil)
+	first := runTestBackup(t, service, policy.ID)
+
+	// Имя бэкапа задается с точностью до секунды
+	time.Sleep(1100 * time.Millisecond)
+	writeTestFile(t, policy.SourcePath+"/dir/d.txt", "delta")
+	second := runTestBackup(t, service, policy.ID)
+	ctx := context.Background()
+
+	found := func(files []SnapshotFile) []string {
+		var list []string
+		for _, file := range files {
+			list = append(list, file.JobID[:8]+":"+file.Path)
+		}
+		return list
+	}
+	want := func(items ...string) []string { return items }
+
+	byName, err := service.FindFiles(ctx, FindOptions{Name: "*.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := want(
+		first.ID[:8]+":a.txt", first.ID[:8]+":dir/b.txt",
+		second.ID[:8]+":a.txt", second.ID[:8]+":dir/b.txt", second.ID[:8]+":dir/d.txt",
+	)
+	if !slices.Equal(found(byName), expected) {
+		t.Fatalf("поиск по имени: %v", found(byName))
+	}
+
+	byPath, err := service.FindFiles(ctx, FindOptions{PolicyID: policy.ID, Path: "dir/**/*.log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(found(byPath), want(first.ID[:8]+":dir/sub/c.log", second.ID[:8]+":dir/sub/c.log")) {
+		t.Fatalf("поиск по пути: %v", found(byPath))
+	}
+
+	if _, err := service.FindFiles(ctx, FindOptions{}); err == nil {
+		t.Fatal("поиск без шаблона прошел без ошибки")
+	}
+
+	// Индекс бэкапа без него строится по архиву перед поиском
+	dropIndex(t, service, first.ID)
+	backfilled, err := service.FindFiles(ctx, FindOptions{Name: "c.log"})
+	if err != nil || !slices.Equal(found(backfilled), want(first.ID[:8]+":dir/sub/c.log", second.ID[:8]+":dir/sub/c.log")) {
+		t.Fatalf("поиск по бэкапу без индекса: %v, %v", found(backfilled), err)
+	}
+	if indexed, err := service.isJobIndexed(ctx, first.ID); err != nil || !indexed {
+		t.Fatalf("индекс построен, но не отмечен: %v, %v", indexed, err)
+	}
+
+	// Бэкап, индекс которого не построить, называется в ошибке, а поиск
+	// по остальным бэкапам возвращает результат
+	dropIndex(t, service, first.ID)
+	if err := os.Remove(storedBackup(t, service, first)); err != nil {
+		t.Fatal(err)
+	}
+	partial, err := service.FindFiles(ctx, FindOptions{Name: "c.log"})
+	var unindexed *UnindexedError
+	if !errors.As(err, &unindexed) || !slices.Equal(unindexed.JobIDs, []string{first.ID}) {
+		t.Fatalf("поиск с бэкапом без индекса: %v", err)
+	}
+	if !slices.Equal(found(partial), want(second.ID[:8]+":dir/sub/c.log")) {
+		t.Fatalf("поиск с бэкапом без индекса: %v", found(partial))
+	}
+}
+
+func TestEmptyIndexNotRebuilt(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "empty", browseFiles, nil)
+	job := runTestBackup(t, service, policy.ID)
+	ctx := context.Background()
+
+	// Бэкап отмечен проиндексированным; пустой индекс как у бэкапа без файлов
+	if _, err := service.db.Exec(`DELETE FROM backup_files WHERE job_id = ?`, job.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Отмеченный индекс не строится заново: бэкап не скачивается
+	if err := os.Remove(storedBackup(t, service, job)); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := service.ListFiles(ctx, job.ID, "")
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("листинг пустого бэкапа: %+v, %v", entries, err)
+	}
+	if _, err := service.FindFiles(ctx, FindOptions{Name: "*"}); err != nil {
+		t.Fatalf("поиск с пустым бэкапом: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// cancelPollInterval как часто выполняющаяся задача проверяет запросы
+// отмены, сохраненные другими процессами (команда jobs cancel, сервер API)
+const cancelPollInterval = 2 * time.Second
+
+// ErrCancelled задача отменена; причина отмены - в тексте ошибки
+var ErrCancelled = errors.New("задача отменена")
+
+// CancelCause возвращает причину отмены для context.WithCancelCause
+//
+// Задача, контекст которой отменен с этой причиной, завершается в
+// статусе cancelled, а причина записывается в ее ошибку.
+func CancelCause(reason string) error {
+	return fmt.Errorf("%w: %s", ErrCancelled, reason)
+}
+
+// CancelJob отменяет задачу бэкапа с причиной reason
+//
+// Задача, выполняющаяся в этом процессе, отменяется сразу, в другом
+// процессе - в течение cancelPollInterval, когда он увидит сохраненный
+// запрос отмены. Задача, еще не начавшая выполняться, сразу переводится
+// в статус cancelled.
+func (s *Service) CancelJob(ctx context.Context, jobID, reason string) (*types.BackupJob, error) {
+	job, err := s.getBackupJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if JobFinished(job.Status) {
+		return nil, &catalogError{kind: ErrConflict, err: fmt.Errorf("задача %s уже завершена в статусе %s", job.ID, job.Status)}
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		reason = "по запросу пользователя"
+	}
+	if err := s.saveCancelRequest(ctx, job.ID, reason); err != nil {
+		return nil, err
+	}
+
+	s.jobsMu.Lock()
+	cancel, running := s.running[job.ID]
+	s.jobsMu.Unlock()
+
+	switch {
+	case running:
+		cancel(CancelCause(reason))
+	case job.Status == types.JobStatusPending:
+		if err := s.markCancelled(ctx, job, CancelCause(reason)); err != nil {
+			return nil, err
+		}
+	}
+
+	s.attachProgress(job)
+	return job, nil
+}
+
+// startJob регистрирует выполняющуюся задачу и возвращает ее контекст
+//
+// Контекст отменяется вызовом CancelJob в этом процессе или запросом
+// отмены из другого процесса; запрос, сохраненный до запуска, отменяет
+// контекст сразу. Возвращаемая функция снимает регистрацию.
+func (s *Service) startJob(ctx context.Context, jobID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	s.jobsMu.Lock()
+	s.running[jobID] = cancel
+	s.jobsMu.Unlock()
+
+	if reason, ok, err := s.getCancelRequest(ctx, jobID); err == nil && ok {
+		cancel(CancelCause(reason))
+	}
+	go s.watchCancel(ctx, jobID, cancel)
+
+	return ctx, func() {
+		s.jobsMu.Lock()
+		delete(s.running, jobID)
+		s.jobsMu.Unlock()
+		cancel(nil)
+	}
+}
+
+// watchCancel отменяет контекст задачи, когда в каталоге появляется запрос ее отмены
+func (s *Service) watchCancel(ctx context.Context, jobID string, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(cancelPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reason, ok, err := s.getCancelRequest(ctx, jobID)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.WarnContext(ctx, "Ошибка проверки запроса отмены задачи", "job_id", jobID, "error", err)
+			}
+			continue
+		}
+		if ok {
+			cancel(CancelCause(reason))
+			return
+		}
+	}
+}
+
+// cancelError возвращает причину отмены задачи с отмененным контекстом
+func cancelError(ctx context.Context) error {
+	cause := context.Cause(ctx)
+	if errors.Is(cause, ErrCancelled) {
+		return cause
+	}
+	return CancelCause(cause.Error())
+}
+
+// markCancelled переводит задачу в статус cancelled с причиной cause
+func (s *Service) markCancelled(ctx context.Context, job *types.BackupJob, cause error) error {
+	completedAt := time.Now()
+	job.Status = types.JobStatusCancelled
+	job.Error = cause.Error()
+	job.CompletedAt = &completedAt
+
+	if err := s.saveBackupJob(context.WithoutCancel(ctx), job); err != nil {
+		return err
+	}
+	s.publishStatus(job)
+
+	return nil
+}
+
+// markFailed переводит задачу в статус failed с ошибкой err и возвращает
+// ошибку, с которой задача завершена
+//
+// Ошибка после отмены контекста - ее следствие, поэтому задача
+// завершается в статусе cancelled с причиной отмены. Статус сохраняется и
+// при отмененном контексте.
+func (s *Service) markFailed(ctx context.Context, job *types.BackupJob, err error) error {
+	completedAt := time.Now()
+	job.Status = types.JobStatusFailed
+	if ctx.Err() != nil {
+		job.Status = types.JobStatusCancelled
+		err = cancelError(ctx)
+	}
+	job.Error = err.Error()
+	job.CompletedAt = &completedAt
+
+	if saveErr := s.saveBackupJob(context.WithoutCancel(ctx), job); saveErr != nil {
+		s.logger.ErrorContext(ctx, "Ошибка сохранения статуса задачи",
+			"job_id", job.ID,
+			"error", saveErr.Error())
+	}
+	s.publishStatus(job)
+
+	return err
+}
+
+// discardPartial удаляет из хранилища объекты, запись которых прервана
+// отменой задачи
+//
+// При других ошибках объекты не трогаются: многочастная загрузка
+// возобновится при повторе.
+func (s *Service) discardPartial(ctx context.Context, storage StorageProvider, remotePaths ...string) {
+	if ctx.Err() == nil {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	for _, remotePath := range remotePaths {
+		if exists, err := storage.Exists(ctx, remotePath); err != nil || !exists {
+			continue
+		}
+		if err := storage.Delete(ctx, remotePath); err != nil {
+			s.logger.WarnContext(ctx, "Ошибка удаления частично записанного объекта",
+				"remote_path", remotePath,
+				"error", err.Error())
+		}
+	}
+}
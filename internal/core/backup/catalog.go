@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	"backupist/pkg/types"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Виды ошибок каталога, проверяемые через errors.Is
+var (
+	ErrNotFound = errors.New("не найдено")                    // Политика, задача или результат бэкапа не найдены
+	ErrInvalid  = errors.New("некорректные данные")           // Объект не прошел валидацию
+	ErrConflict = errors.New("недопустимо в текущем статусе") // Например, отмена завершенной задачи
+)
+
+// catalogError ошибка каталога определенного вида
+type catalogError struct {
+	kind error
+	err  error
+}
+
+func (e *catalogError) Error() string   { return e.err.Error() }
+func (e *catalogError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// notFoundf возвращает ошибку отсутствия объекта в каталоге
+func notFoundf(format string, args ...any) error {
+	return &catalogError{kind: ErrNotFound, err: fmt.Errorf(format, args...)}
+}
+
+// JobFilter параметры выборки задач бэкапа
+type JobFilter struct {
+	PolicyID  string          // Пусто - задачи всех политик
+	PolicyIDs []string        // Если не nil - только задачи этих политик (права доступа)
+	Status    types.JobStatus // Пусто - задачи в любом статусе
+	Backups   bool            // Только задачи с сохраненным бэкапом
+	StartedBy time.Time       // Не нулевое - только задачи, начатые не позже
+	Limit     int             // 0 - без ограничения
+	Offset    int
+}
+
+// ListPolicies возвращает все политики бэкапа
+func (s *Service) ListPolicies(ctx context.Context) ([]*types.BackupPolicy, error) {
+	return s.getAllPolicies(ctx)
+}
+
+// GetPolicy возвращает политику бэкапа по ID
+func (s *Service) GetPolicy(ctx context.Context, policyID string) (*types.BackupPolicy, error) {
+	return s.getPolicy(ctx, policyID)
+}
+
+// CreatePolicy проверяет и сохраняет новую политику бэкапа
+func (s *Service) CreatePolicy(ctx context.Context, policy *types.BackupPolicy) error {
+	if policy.ID == "" {
+		policy.ID = uuid.New().String()
+	}
+	policy.CreatedAt = time.Now()
+	policy.UpdatedAt = policy.CreatedAt
+
+	if err := config.ValidateBackupPolicy(policy); err != nil {
+		return &catalogError{kind: ErrInvalid, err: fmt.Errorf("ошибка валидации политики: %w", err)}
+	}
+	if err := s.validateRestoreTest(policy); err != nil {
+		return &catalogError{kind: ErrInvalid, err: fmt.Errorf("ошибка валидации политики: %w", err)}
+	}
+
+	return s.savePolicy(ctx, policy)
+}
+
+// DeletePolicy удаляет политику и записи о ее бэкапах из каталога
+//
+// Бэкапы в хранилище не удаляются. Незавершенные загрузки политики
+// не продолжаются, их готовые бэкапы удаляются.
+func (s *Service) DeletePolicy(ctx context.Context, policyID string) error {
+	if _, err := s.getPolicy(ctx, policyID); err != nil {
+		return err
+	}
+
+	uploads, err := s.getPendingUploads(ctx, policyID)
+	if err != nil {
+		return err
+	}
+	if err := s.deletePolicy(ctx, policyID); err != nil {
+		return err
+	}
+	for _, upload := range uploads {
+		os.RemoveAll(filepath.Join(s.pendingUploadsDir(), upload.JobID))
+	}
+
+	return nil
+}
+
+// ListJobs возвращает задачи бэкапа по фильтру, начиная с новых, и
+// общее количество подходящих задач
+func (s *Service) ListJobs(ctx context.Context, filter JobFilter) ([]*types.BackupJob, int, error) {
+	jobs, total, err := s.listBackupJobs(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, job := range jobs {
+		s.attachProgress(job)
+	}
+	return jobs, total, nil
+}
+
+// GetJob возвращает задачу бэкапа по ID
+func (s *Service) GetJob(ctx context.Context, jobID string) (*types.BackupJob, error) {
+	job, err := s.getBackupJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	s.attachProgress(job)
+	return job, nil
+}
+
+// attachProgress добавляет к выполняющейся задаче ее текущий прогресс
+func (s *Service) attachProgress(job *types.BackupJob) {
+	if job.Status == types.JobStatusRunning {
+		job.Progress = s.events.Progress(job.ID)
+	}
+}
+
+// GetBackupResult возвращает результат бэкапа задачи
+func (s *Service) GetBackupResult(ctx context.Context, jobID string) (*types.BackupResult, error) {
+	return s.getBackupResult(ctx, jobID)
+}
+
+// DeleteBackup удаляет бэкап задачи из хранилища и каталога
+func (s *Service) DeleteBackup(ctx context.Context, jobID string) error {
+	job, err := s.browsableJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	return s.deleteBackup(ctx, job)
+}
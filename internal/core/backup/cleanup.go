@@ -92,11 +92,16 @@ func (s *Service) getBackupsForPolicy(ctx context.Context, policy *types.BackupP
 		}
 	}
 
+	storage, _, err := s.resolveDestination(policy.DestinationPath)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка определения хранилища политики: %w", err)
+	}
+
 	// Проверяем наличие файлов бэкапов в хранилище
 	var verifiedBackups []*types.BackupJob
 	for _, backup := range successfulBackups {
 		// Проверяем наличие файла в хранилище
-		exists, err := s.backupExists(ctx, backup.BackupPath)
+		exists, err := s.backupExists(ctx, storage, backup.BackupPath)
 		if err != nil {
 			s.logger.WarnContext(ctx, "Ошибка проверки наличия бэкапа",
 				"backup_id", backup.ID,
@@ -118,15 +123,26 @@ func (s *Service) getBackupsForPolicy(ctx context.Context, policy *types.BackupP
 }
 
 // backupExists проверяет наличие файла бэкапа в хранилище
-func (s *Service) backupExists(ctx context.Context, backupPath string) (bool, error) {
-	return s.storage.Exists(ctx, backupPath)
+func (s *Service) backupExists(ctx context.Context, storage StorageProvider, backupPath string) (bool, error) {
+	return storage.Exists(ctx, backupPath)
 }
 
 // deleteBackup удаляет бэкап из хранилища и базы данных
 func (s *Service) deleteBackup(ctx context.Context, backup *types.BackupJob) error {
-	// Удаляем файл из хранилища
-	if err := s.storage.Delete(ctx, backup.BackupPath); err != nil {
-		return fmt.Errorf("ошибка удаления файла из хранилища: %w", err)
+	storage, err := s.storageForJob(ctx, backup)
+	if err != nil {
+		return fmt.Errorf("ошибка определения хранилища бэкапа: %w", err)
+	}
+
+	// Удаляем файлы из хранилища: тома и файлы четности, затем сам бэкап или манифест томов
+	objects, err := s.backupObjects(ctx, storage, backup.BackupPath)
+	if err != nil {
+		return fmt.Errorf("ошибка получения списка файлов бэкапа: %w", err)
+	}
+	for _, object := range objects {
+		if err := storage.Delete(ctx, object); err != nil {
+			return fmt.Errorf("ошибка удаления файла из хранилища: %w", err)
+		}
 	}
 
 	// Начинаем транзакцию
@@ -148,6 +164,12 @@ func (s *Service) deleteBackup(ctx context.Context, backup *types.BackupJob) err
 		return fmt.Errorf("ошибка удаления результата бэкапа: %w", err)
 	}
 
+	// Удаляем результаты по хранилищам
+	_, err = tx.ExecContext(ctx, "DELETE FROM backup_targets WHERE job_id = ?", backup.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления результатов по хранилищам: %w", err)
+	}
+
 	// Обновляем статус задачи бэкапа
 	_, err = tx.ExecContext(ctx, "UPDATE backup_jobs SET status = 'deleted' WHERE id = ?", backup.ID)
 	if err != nil {
@@ -195,7 +217,7 @@ func (s *Service) applyRetentionPolicies(ctx context.Context) error {
 func (s *Service) cleanupOrphanedBackups(ctx context.Context) error {
 	// Получаем список осиротевших бэкапов
 	query := `
-		SELECT j.id, j.backup_path, j.created_at
+		SELECT j.id, j.backup_path, j.destination, j.created_at
 		FROM backup_jobs j
 		LEFT JOIN backup_policies p ON j.policy_id = p.id
 		WHERE p.id IS NULL AND j.status = 'completed'
@@ -210,7 +232,7 @@ func (s *Service) cleanupOrphanedBackups(ctx context.Context) error {
 	var orphanedBackups []*types.BackupJob
 	for rows.Next() {
 		backup := &types.BackupJob{}
-		if err := rows.Scan(&backup.ID, &backup.BackupPath, &backup.CreatedAt); err != nil {
+		if err := rows.Scan(&backup.ID, &backup.BackupPath, &backup.Destination, &backup.CreatedAt); err != nil {
 			return fmt.Errorf("ошибка сканирования результата: %w", err)
 		}
 		orphanedBackups = append(orphanedBackups, backup)
@@ -250,7 +272,7 @@ func (s *Service) cleanupFailedBackups(ctx context.Context, olderThan time.Durat
 	cutoffTime := time.Now().Add(-olderThan)
 
 	query := `
-		SELECT id, policy_id, backup_path, error, created_at
+		SELECT id, policy_id, backup_path, destination, error, created_at
 		FROM backup_jobs
 		WHERE status = 'failed' AND created_at < ?
 	`
@@ -264,7 +286,7 @@ func (s *Service) cleanupFailedBackups(ctx context.Context, olderThan time.Durat
 	var failedBackups []*types.BackupJob
 	for rows.Next() {
 		backup := &types.BackupJob{}
-		if err := rows.Scan(&backup.ID, &backup.PolicyID, &backup.BackupPath, &backup.Error, &backup.CreatedAt); err != nil {
+		if err := rows.Scan(&backup.ID, &backup.PolicyID, &backup.BackupPath, &backup.Destination, &backup.Error, &backup.CreatedAt); err != nil {
 			return fmt.Errorf("ошибка сканирования результата: %w", err)
 		}
 		failedBackups = append(failedBackups, backup)
@@ -294,7 +316,11 @@ func (s *Service) cleanupFailedBackups(ctx context.Context, olderThan time.Durat
 
 		// Если есть путь к файлу, пытаемся удалить его из хранилища
 		if backup.BackupPath != "" {
-			if err := s.storage.Delete(ctx, backup.BackupPath); err != nil {
+			storage, err := s.storageForJob(ctx, backup)
+			if err == nil {
+				err = storage.Delete(ctx, backup.BackupPath)
+			}
+			if err != nil {
 				s.logger.WarnContext(ctx, "Ошибка удаления файла неудачного бэкапа",
 					"backup_id", backup.ID,
 					"backup_path", backup.BackupPath,
@@ -0,0 +1,352 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CopyOptions параметры копирования бэкапов между хранилищами
+type CopyOptions struct {
+	From           string // Исходное хранилище: имя из storage.configs, тип хранилища по умолчанию или URL
+	To             string // Вторичное хранилище: имя из storage.configs или URL
+	PolicyID       string // Копировать бэкапы только этой политики
+	RetentionCount int    // Количество копий для хранения, 0 - без ограничения
+	RetentionDays  int    // Срок хранения копий в днях, 0 - без ограничения
+}
+
+// resolveStorageRef определяет хранилище по ссылке из политики или командной строки
+//
+// Ссылка может быть именем из storage.configs, типом хранилища по умолчанию
+// (например, local) или адресом, который понимает resolveDestination.
+func (s *Service) resolveStorageRef(ref string) (StorageProvider, string, error) {
+	if _, ok := s.config.Storage.Configs[ref]; ok {
+		return s.factory.Resolve("storage://" + ref)
+	}
+
+	if ref == s.config.Storage.Type || ref == s.config.Storage.Default {
+		return s.defaultStorage("")
+	}
+
+	if filepath.IsAbs(ref) || strings.Contains(ref, "://") {
+		return s.resolveDestination(ref)
+	}
+
+	return nil, "", fmt.Errorf("хранилище не найдено: %s", ref)
+}
+
+// CopyBackups копирует завершенные бэкапы из одного хранилища в другое
+//
+// Уже скопированные бэкапы пропускаются, поэтому команду можно запускать
+// повторно, например по расписанию. После копирования применяется
+// политика хранения копий.
+func (s *Service) CopyBackups(ctx context.Context, opts CopyOptions) ([]*types.BackupCopy, error) {
+	source, sourcePrefix, err := s.resolveStorageRef(opts.From)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка определения исходного хранилища: %w", err)
+	}
+	if _, _, err := s.resolveStorageRef(opts.To); err != nil {
+		return nil, fmt.Errorf("ошибка определения вторичного хранилища: %w", err)
+	}
+
+	var policies []*types.BackupPolicy
+	if opts.PolicyID != "" {
+		policy, err := s.getPolicy(ctx, opts.PolicyID)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	} else if policies, err = s.getAllPolicies(ctx); err != nil {
+		return nil, fmt.Errorf("ошибка получения политик: %w", err)
+	}
+
+	var copies []*types.BackupCopy
+	for _, policy := range policies {
+		jobs, _, err := s.listBackupJobs(ctx, JobFilter{PolicyID: policy.ID, Status: types.JobStatusCompleted, Backups: true})
+		if err != nil {
+			return copies, err
+		}
+
+		for _, job := range jobs {
+			// Копируем только бэкапы, записанные в исходное хранилище
+			storage, err := s.storageForJob(ctx, job)
+			if err != nil || !sameLocation(source, sourcePrefix, storage, job.BackupPath) {
+				continue
+			}
+
+			copied, err := s.hasBackupCopy(ctx, job.ID, opts.To)
+			if err != nil {
+				return copies, err
+			}
+			if copied {
+				continue
+			}
+
+			backupCopy, err := s.CopyBackup(ctx, job, opts.To)
+			if err != nil {
+				s.logger.WarnContext(ctx, "Ошибка копирования бэкапа",
+					"job_id", job.ID,
+					"destination", opts.To,
+					"error", err.Error())
+			}
+			if backupCopy != nil {
+				copies = append(copies, backupCopy)
+			}
+		}
+
+		if err := s.cleanupOldCopies(ctx, policy.ID, opts.To, opts.RetentionCount, opts.RetentionDays); err != nil {
+			s.logger.WarnContext(ctx, "Ошибка очистки старых копий",
+				"policy_id", policy.ID,
+				"destination", opts.To,
+				"error", err.Error())
+		}
+	}
+
+	return copies, nil
+}
+
+// sameLocation проверяет, что путь бэкапа находится внутри префикса исходного хранилища
+//
+// Одно и то же место может быть доступно через разные провайдеры:
+// хранилище по умолчанию и абсолютный путь назначения политики,
+// поэтому сравниваются физические расположения, а не экземпляры.
+func sameLocation(source StorageProvider, prefix string, storage StorageProvider, backupPath string) bool {
+	source, prefix = storageLocation(source, prefix)
+	storage, backupPath = storageLocation(storage, backupPath)
+
+	if source != storage {
+		return false
+	}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || backupPath == prefix || strings.HasPrefix(backupPath, prefix+"/")
+}
+
+// storageLocation снимает обертки префиксов и приводит локальные пути к абсолютным
+//
+// Для локальной файловой системы возвращается nil и абсолютный путь.
+func storageLocation(storage StorageProvider, p string) (StorageProvider, string) {
+	for {
+		switch st := storage.(type) {
+		case *prefixedStorage:
+			storage, p = st.storage, path.Join(st.prefix, p)
+		case *bandwidthStorage:
+			storage = st.storage
+		case *LocalStorage:
+			abs, err := filepath.Abs(filepath.Join(st.basePath, filepath.FromSlash(p)))
+			if err != nil {
+				abs = filepath.Join(st.basePath, filepath.FromSlash(p))
+			}
+			return nil, filepath.ToSlash(abs)
+		default:
+			return storage, strings.TrimPrefix(p, "/")
+		}
+	}
+}
+
+// CopyBackup копирует бэкап задачи во вторичное хранилище
+//
+// Запись о копии сохраняется и при ошибке, чтобы неудачные попытки
+// были видны в каталоге.
+func (s *Service) CopyBackup(ctx context.Context, job *types.BackupJob, destination string) (*types.BackupCopy, error) {
+	backupCopy := &types.BackupCopy{
+		ID:          uuid.New().String(),
+		JobID:       job.ID,
+		PolicyID:    job.PolicyID,
+		Destination: destination,
+		Status:      types.JobStatusRunning,
+		CreatedAt:   time.Now(),
+	}
+
+	err := s.copyBackup(ctx, job, backupCopy)
+
+	completedAt := time.Now()
+	backupCopy.CompletedAt = &completedAt
+	if err != nil {
+		backupCopy.Status = types.JobStatusFailed
+		backupCopy.Error = err.Error()
+	} else {
+		backupCopy.Status = types.JobStatusCompleted
+	}
+
+	// Запись о копии сохраняем и при отмене контекста
+	if saveErr := s.saveBackupCopy(context.WithoutCancel(ctx), backupCopy); saveErr != nil && err == nil {
+		err = saveErr
+	}
+
+	if err != nil {
+		return backupCopy, err
+	}
+
+	s.logger.InfoContext(ctx, "Бэкап скопирован во вторичное хранилище",
+		"job_id", job.ID,
+		"destination", destination,
+		"remote_path", backupCopy.RemotePath,
+		"size", backupCopy.Size)
+
+	return backupCopy, nil
+}
+
+// copyBackup переносит файлы бэкапа (тома и данные четности тоже) через временные файлы
+func (s *Service) copyBackup(ctx context.Context, job *types.BackupJob, backupCopy *types.BackupCopy) error {
+	source, err := s.storageForJob(ctx, job)
+	if err != nil {
+		return fmt.Errorf("ошибка определения хранилища бэкапа: %w", err)
+	}
+
+	target, prefix, err := s.resolveStorageRef(backupCopy.Destination)
+	if err != nil {
+		return fmt.Errorf("ошибка определения вторичного хранилища: %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "backup-copy-*")
+	if err != nil {
+		return fmt.Errorf("ошибка создания временной директории: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	objects, err := s.backupObjects(ctx, source, job.BackupPath)
+	if err != nil {
+		return fmt.Errorf("ошибка получения списка файлов бэкапа: %w", err)
+	}
+
+	// Файлы копируются по одному, манифест томов - последним. При ошибке
+	// уже записанные файлы удаляются, чтобы не оставлять неполную копию
+	var written []string
+	for _, object := range objects {
+		localPath := filepath.Join(tempDir, path.Base(object))
+		if err := source.Download(ctx, object, localPath); err != nil {
+			s.abortCopy(ctx, target, written)
+			return fmt.Errorf("ошибка загрузки бэкапа из хранилища: %w", err)
+		}
+
+		if size, err := s.getFileSize(localPath); err == nil {
+			backupCopy.Size += size
+		}
+
+		remotePath := path.Join(prefix, path.Base(object))
+		if err := target.Upload(ctx, localPath, remotePath); err != nil {
+			s.abortCopy(ctx, target, written)
+			return fmt.Errorf("ошибка загрузки во вторичное хранилище: %w", err)
+		}
+		written = append(written, remotePath)
+		os.Remove(localPath)
+	}
+
+	backupCopy.RemotePath = path.Join(prefix, path.Base(job.BackupPath))
+
+	return nil
+}
+
+// abortCopy удаляет файлы незавершенной копии из вторичного хранилища
+func (s *Service) abortCopy(ctx context.Context, target StorageProvider, written []string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, remotePath := range written {
+		if err := target.Delete(ctx, remotePath); err != nil {
+			s.logger.WarnContext(ctx, "Ошибка удаления файла незавершенной копии",
+				"remote_path", remotePath,
+				"error", err.Error())
+		}
+	}
+}
+
+// replicateBackup копирует завершенный бэкап во вторичные хранилища политики
+//
+// Ошибки копирования не влияют на результат бэкапа и только логируются.
+func (s *Service) replicateBackup(ctx context.Context, policy *types.BackupPolicy, job *types.BackupJob) []types.BackupCopy {
+	var copies []types.BackupCopy
+
+	for _, target := range policy.CopyTargets {
+		backupCopy, err := s.CopyBackup(ctx, job, target.Destination)
+		if err != nil {
+			s.logger.WarnContext(ctx, "Ошибка копирования бэкапа во вторичное хранилище",
+				"job_id", job.ID,
+				"destination", target.Destination,
+				"error", err.Error())
+		}
+		if backupCopy != nil {
+			copies = append(copies, *backupCopy)
+		}
+
+		if err := s.cleanupOldCopies(ctx, policy.ID, target.Destination, target.RetentionCount, target.RetentionDays); err != nil {
+			s.logger.WarnContext(ctx, "Ошибка очистки старых копий",
+				"policy_id", policy.ID,
+				"destination", target.Destination,
+				"error", err.Error())
+		}
+	}
+
+	return copies
+}
+
+// cleanupOldCopies удаляет копии сверх количества и срока хранения
+//
+// Копии хранятся независимо от основных бэкапов: удаление бэкапа
+// по политике хранения не затрагивает его копии, и наоборот.
+func (s *Service) cleanupOldCopies(ctx context.Context, policyID, destination string, retentionCount, retentionDays int) error {
+	if retentionCount <= 0 && retentionDays <= 0 {
+		return nil
+	}
+
+	copies, err := s.getBackupCopies(ctx, policyID, destination)
+	if err != nil {
+		return err
+	}
+
+	cutoffTime := time.Now().AddDate(0, 0, -retentionDays)
+
+	for i, backupCopy := range copies {
+		expired := retentionCount > 0 && i >= retentionCount
+		if retentionDays > 0 && backupCopy.CreatedAt.Before(cutoffTime) {
+			expired = true
+		}
+		if !expired {
+			continue
+		}
+
+		if err := s.deleteBackupCopy(ctx, backupCopy); err != nil {
+			s.logger.WarnContext(ctx, "Ошибка удаления копии бэкапа",
+				"copy_id", backupCopy.ID,
+				"destination", destination,
+				"remote_path", backupCopy.RemotePath,
+				"error", err.Error())
+			continue
+		}
+
+		s.logger.InfoContext(ctx, "Копия бэкапа удалена",
+			"copy_id", backupCopy.ID,
+			"destination", destination,
+			"remote_path", backupCopy.RemotePath,
+			"created_at", backupCopy.CreatedAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// deleteBackupCopy удаляет копию из вторичного хранилища и помечает запись удаленной
+func (s *Service) deleteBackupCopy(ctx context.Context, backupCopy *types.BackupCopy) error {
+	storage, _, err := s.resolveStorageRef(backupCopy.Destination)
+	if err != nil {
+		return fmt.Errorf("ошибка определения вторичного хранилища: %w", err)
+	}
+
+	objects, err := s.backupObjects(ctx, storage, backupCopy.RemotePath)
+	if err != nil {
+		return fmt.Errorf("ошибка получения списка файлов копии: %w", err)
+	}
+	for _, object := range objects {
+		if err := storage.Delete(ctx, object); err != nil {
+			return fmt.Errorf("ошибка удаления файла из хранилища: %w", err)
+		}
+	}
+
+	backupCopy.Status = types.JobStatusDeleted
+	return s.saveBackupCopy(ctx, backupCopy)
+}
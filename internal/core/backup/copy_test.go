@@ -0,0 +1,59 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+// failingUploadStorage хранилище, в котором загрузки после первых okUploads
+// завершаются ошибкой
+type failingUploadStorage struct {
+	StorageProvider
+	okUploads int
+}
+
+func (f *failingUploadStorage) Upload(ctx context.Context, localPath, remotePath string) error {
+	if f.okUploads == 0 {
+		return errors.New("хранилище недоступно")
+	}
+	f.okUploads--
+	return f.StorageProvider.Upload(ctx, localPath, remotePath)
+}
+
+func TestCopyBackupRemovesPartialCopy(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "parity", map[string]string{"a.txt": "alpha"}, func(policy *types.BackupPolicy) {
+		policy.Parity = &types.ParityConfig{Percent: 10}
+	})
+	job := runTestBackup(t, service, policy.ID)
+
+	// Файл четности копируется первым, загрузка самого бэкапа падает
+	target := t.TempDir()
+	service.factory.Register("failing", func(u *url.URL) (StorageProvider, error) {
+		return &failingUploadStorage{StorageProvider: NewLocalStorage(target), okUploads: 1}, nil
+	})
+
+	backupCopy, err := service.CopyBackup(context.Background(), job, "failing://copies/daily")
+	if err == nil {
+		t.Fatal("копирование в недоступное хранилище завершилось без ошибки")
+	}
+	if backupCopy == nil || backupCopy.Status != types.JobStatusFailed {
+		t.Fatalf("запись о копии: %+v", backupCopy)
+	}
+	if files := remoteFiles(t, target); len(files) != 0 {
+		t.Fatalf("во вторичном хранилище остались файлы незавершенной копии: %v", files)
+	}
+
+	// Успешная копия содержит бэкап и файл четности
+	target = filepath.Join(t.TempDir(), "copies")
+	if _, err := service.CopyBackup(context.Background(), job, target); err != nil {
+		t.Fatal(err)
+	}
+	if files := remoteFiles(t, target); len(files) != 2 {
+		t.Fatalf("файлы копии: %v", files)
+	}
+}
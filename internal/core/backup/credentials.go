@@ -0,0 +1,258 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// resolveS3Config собирает конфигурацию S3 для URL s3://bucket/prefix
+//
+// Учетные данные ищутся в порядке:
+//  1. именованный профиль (?profile=name): запись Storage.Configs типа s3
+//     или профиль из файла ~/.aws/credentials;
+//  2. ключи из глобальной конфигурации (storage.s3_access_key);
+//  3. переменные окружения AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN;
+//  4. файл ~/.aws/credentials (профиль AWS_PROFILE или default).
+//
+// Параметры region и secure из URL имеют приоритет над остальными источниками.
+// Параметр endpoint принимается, только если совпадает с адресом сервиса,
+// для которого заданы учетные данные (см. checkURLEndpoint).
+func (sf *StorageFactory) resolveS3Config(u *url.URL) (*types.S3Config, error) {
+	query := u.Query()
+	storageConfig := sf.config.Storage
+
+	cfg := &types.S3Config{
+		Endpoint: storageConfig.S3Endpoint,
+		UseSSL:   storageConfig.S3UseSSL,
+		Region:   storageConfig.S3Region,
+	}
+
+	if profile := query.Get("profile"); profile != "" {
+		if named, ok := storageConfig.Configs[profile]; ok {
+			if named.Type != types.StorageTypeS3 || named.S3Config == nil {
+				return nil, fmt.Errorf("профиль %s не является конфигурацией S3", profile)
+			}
+			*cfg = *named.S3Config
+		} else {
+			value, err := credentials.NewFileAWSCredentials("", profile).Get()
+			if err != nil {
+				return nil, fmt.Errorf("ошибка чтения профиля AWS %s: %w", profile, err)
+			}
+			cfg.AccessKeyID = value.AccessKeyID
+			cfg.SecretAccessKey = value.SecretAccessKey
+			cfg.SessionToken = value.SessionToken
+		}
+	}
+
+	cfg.Bucket = u.Host
+	credentialsEndpoint := cfg.Endpoint
+
+	if endpoint := query.Get("endpoint"); endpoint != "" {
+		cfg.Endpoint = endpoint
+	}
+	if region := query.Get("region"); region != "" {
+		cfg.Region = region
+	}
+	if secure := query.Get("secure"); secure != "" {
+		useSSL, err := strconv.ParseBool(secure)
+		if err != nil {
+			return nil, fmt.Errorf("неверное значение параметра secure: %s", secure)
+		}
+		cfg.UseSSL = useSSL
+	}
+
+	if cfg.AccessKeyID == "" {
+		cfg.AccessKeyID = storageConfig.S3AccessKey
+		cfg.SecretAccessKey = storageConfig.S3SecretKey
+	}
+	if cfg.AccessKeyID == "" {
+		if value, err := credentials.NewEnvAWS().Get(); err == nil && value.AccessKeyID != "" {
+			cfg.AccessKeyID = value.AccessKeyID
+			cfg.SecretAccessKey = value.SecretAccessKey
+			cfg.SessionToken = value.SessionToken
+		}
+	}
+	if cfg.AccessKeyID == "" {
+		if value, err := credentials.NewFileAWSCredentials("", "").Get(); err == nil && value.AccessKeyID != "" {
+			cfg.AccessKeyID = value.AccessKeyID
+			cfg.SecretAccessKey = value.SecretAccessKey
+			cfg.SessionToken = value.SessionToken
+		}
+	}
+
+	if err := checkURLEndpoint(cfg.Endpoint, credentialsEndpoint, cfg.AccessKeyID != ""); err != nil {
+		return nil, err
+	}
+
+	if cfg.Region == "" {
+		cfg.Region = sf.defaultS3Region()
+	}
+
+	return cfg, nil
+}
+
+// checkURLEndpoint проверяет адрес сервиса из параметра endpoint URL
+//
+// Учетные данные из конфигурации и окружения отправляются только на адрес,
+// для которого они заданы: глобальный или из именованной конфигурации.
+// Иначе автор политики мог бы указать свой адрес и получить ключи.
+// Без учетных данных адрес может быть любым.
+func checkURLEndpoint(endpoint, credentialsEndpoint string, hasCredentials bool) error {
+	if !hasCredentials || endpoint == credentialsEndpoint {
+		return nil
+	}
+	return fmt.Errorf("адрес сервиса %s из URL хранилища не совпадает с адресом, для которого заданы учетные данные; "+
+		"задайте его в именованной конфигурации (?profile=name)", endpoint)
+}
+
+// defaultS3Region возвращает регион S3 для конфигураций без явного региона:
+// из глобальной конфигурации, переменных AWS_REGION/AWS_DEFAULT_REGION или us-east-1
+func (sf *StorageFactory) defaultS3Region() string {
+	if region := sf.config.Storage.S3Region; region != "" {
+		return region
+	}
+	if region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"); region != "" {
+		return region
+	}
+	// S3-совместимые сервисы (MinIO и т.п.) обычно не требуют региона
+	return "us-east-1"
+}
+
+// resolveGCSConfig собирает конфигурацию GCS для URL gcs://bucket/prefix
+//
+// Файл учетных данных берется из профиля (?profile=name), параметра ?credentials=path,
+// глобальной конфигурации или переменной GOOGLE_APPLICATION_CREDENTIALS.
+// Project ID берется из параметра ?project=id, профиля, переменных окружения
+// GOOGLE_CLOUD_PROJECT/GCLOUD_PROJECT или из самого файла учетных данных.
+func (sf *StorageFactory) resolveGCSConfig(u *url.URL) (*types.GCSConfig, error) {
+	query := u.Query()
+	storageConfig := sf.config.Storage
+
+	cfg := &types.GCSConfig{}
+
+	if profile := query.Get("profile"); profile != "" {
+		named, ok := storageConfig.Configs[profile]
+		if !ok || named.Type != types.StorageTypeGCS || named.GCSConfig == nil {
+			return nil, fmt.Errorf("профиль GCS не найден: %s", profile)
+		}
+		*cfg = *named.GCSConfig
+	}
+
+	cfg.Bucket = u.Host
+
+	if credentialsPath := query.Get("credentials"); credentialsPath != "" {
+		cfg.CredentialsPath = credentialsPath
+		cfg.ServiceAccountJSON = ""
+	}
+	if cfg.CredentialsPath == "" && cfg.ServiceAccountJSON == "" {
+		cfg.CredentialsPath = storageConfig.GCSCredentialsPath
+	}
+	if cfg.CredentialsPath == "" && cfg.ServiceAccountJSON == "" {
+		cfg.CredentialsPath = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+
+	if project := query.Get("project"); project != "" {
+		cfg.ProjectID = project
+	}
+	if cfg.ProjectID == "" {
+		cfg.ProjectID = firstEnv("GOOGLE_CLOUD_PROJECT", "GCLOUD_PROJECT")
+	}
+	if cfg.ProjectID == "" {
+		cfg.ProjectID = gcsCredentialsProjectID(cfg)
+	}
+
+	return cfg, nil
+}
+
+// resolveAzureConfig собирает конфигурацию Azure для URL azure://container/prefix
+//
+// Учетные данные берутся из профиля (?profile=name), глобальной конфигурации
+// или переменных окружения AZURE_STORAGE_ACCOUNT, AZURE_STORAGE_KEY, AZURE_STORAGE_SAS_TOKEN.
+// Параметр endpoint принимается, только если совпадает с адресом сервиса
+// из той же конфигурации, что и учетные данные.
+func (sf *StorageFactory) resolveAzureConfig(u *url.URL) (*types.AzureConfig, error) {
+	query := u.Query()
+	storageConfig := sf.config.Storage
+
+	cfg := &types.AzureConfig{
+		AccountName: storageConfig.AzureAccountName,
+		AccountKey:  storageConfig.AzureAccountKey,
+		SASToken:    storageConfig.AzureSASToken,
+		Endpoint:    storageConfig.AzureEndpoint,
+		AccessTier:  storageConfig.AzureAccessTier,
+		BlockSize:   storageConfig.AzureBlockSize,
+		Concurrency: storageConfig.AzureConcurrency,
+	}
+
+	if profile := query.Get("profile"); profile != "" {
+		named, ok := storageConfig.Configs[profile]
+		if !ok || named.Type != types.StorageTypeAzure || named.AzureConfig == nil {
+			return nil, fmt.Errorf("профиль Azure не найден: %s", profile)
+		}
+		*cfg = *named.AzureConfig
+	}
+
+	cfg.Container = u.Host
+	credentialsEndpoint := cfg.Endpoint
+
+	if endpoint := query.Get("endpoint"); endpoint != "" {
+		cfg.Endpoint = endpoint
+	}
+	if tier := query.Get("tier"); tier != "" {
+		cfg.AccessTier = tier
+	}
+
+	if cfg.AccountName == "" {
+		cfg.AccountName = os.Getenv("AZURE_STORAGE_ACCOUNT")
+	}
+	if cfg.AccountKey == "" && cfg.SASToken == "" {
+		cfg.AccountKey = os.Getenv("AZURE_STORAGE_KEY")
+		cfg.SASToken = os.Getenv("AZURE_STORAGE_SAS_TOKEN")
+	}
+
+	if err := checkURLEndpoint(cfg.Endpoint, credentialsEndpoint, cfg.AccountKey != "" || cfg.SASToken != ""); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// gcsCredentialsProjectID читает project_id из ключа сервисного аккаунта
+func gcsCredentialsProjectID(cfg *types.GCSConfig) string {
+	data := []byte(cfg.ServiceAccountJSON)
+	if len(data) == 0 {
+		if cfg.CredentialsPath == "" {
+			return ""
+		}
+
+		var err error
+		if data, err = os.ReadFile(cfg.CredentialsPath); err != nil {
+			return ""
+		}
+	}
+
+	var key struct {
+		ProjectID string `json:"project_id"`
+	}
+	if err := json.Unmarshal(data, &key); err != nil {
+		return ""
+	}
+
+	return key.ProjectID
+}
+
+// firstEnv возвращает значение первой непустой переменной окружения
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -4,9 +4,12 @@ import (
 	"backupist/pkg/types"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -28,6 +31,11 @@ func (s *Service) initDatabase() error {
 		return fmt.Errorf("ошибка создания таблиц: %w", err)
 	}
 
+	// Обновление схемы существующей базы
+	if err = s.migrateTables(); err != nil {
+		return fmt.Errorf("ошибка обновления схемы БД: %w", err)
+	}
+
 	return nil
 }
 
@@ -90,11 +98,137 @@ func (s *Service) createTables() error {
 			FOREIGN KEY (job_id) REFERENCES backup_jobs(id)
 		)`,
 
+		`CREATE TABLE IF NOT EXISTS backup_targets (
+			job_id TEXT NOT NULL,
+			target TEXT NOT NULL,
+			remote_path TEXT NOT NULL,
+			status TEXT NOT NULL,
+			error TEXT,
+			duration_ms INTEGER DEFAULT 0,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (job_id, target),
+			FOREIGN KEY (job_id) REFERENCES backup_jobs(id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS backup_copies (
+			id TEXT PRIMARY KEY,
+			job_id TEXT NOT NULL,
+			policy_id TEXT NOT NULL,
+			destination TEXT NOT NULL,
+			remote_path TEXT NOT NULL,
+			status TEXT NOT NULL,
+			error TEXT,
+			size INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			completed_at DATETIME,
+			FOREIGN KEY (job_id) REFERENCES backup_jobs(id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS upload_sessions (
+			key TEXT PRIMARY KEY,
+			upload_id TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			part_size INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS pending_uploads (
+			job_id TEXT PRIMARY KEY,
+			policy_id TEXT NOT NULL,
+			local_path TEXT NOT NULL,
+			parity_path TEXT,
+			remote_path TEXT NOT NULL,
+			result TEXT NOT NULL,
+			files TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (job_id) REFERENCES backup_jobs(id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS backup_verifications (
+			id TEXT PRIMARY KEY,
+			job_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			error TEXT,
+			checksum TEXT,
+			deep BOOLEAN DEFAULT false,
+			entries INTEGER DEFAULT 0,
+			duration_ms INTEGER DEFAULT 0,
+			verified_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (job_id) REFERENCES backup_jobs(id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS restore_tests (
+			id TEXT PRIMARY KEY,
+			policy_id TEXT NOT NULL,
+			job_id TEXT,
+			status TEXT NOT NULL,
+			error TEXT,
+			files_checked INTEGER DEFAULT 0,
+			files_failed INTEGER DEFAULT 0,
+			command_output TEXT,
+			restore_duration_ms INTEGER DEFAULT 0,
+			duration_ms INTEGER DEFAULT 0,
+			started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (policy_id) REFERENCES backup_policies(id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS api_tokens (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE,
+			token_hash TEXT NOT NULL UNIQUE,
+			roles TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME,
+			last_used_at DATETIME,
+			revoked_at DATETIME
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS job_cancellations (
+			job_id TEXT PRIMARY KEY,
+			reason TEXT NOT NULL,
+			requested_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (job_id) REFERENCES backup_jobs(id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS policy_locks (
+			policy_id TEXT PRIMARY KEY,
+			job_id TEXT NOT NULL,
+			owner TEXT NOT NULL,
+			acquired_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			FOREIGN KEY (policy_id) REFERENCES backup_policies(id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			time DATETIME NOT NULL,
+			actor TEXT NOT NULL,
+			auth_method TEXT NOT NULL,
+			action TEXT NOT NULL,
+			policy_id TEXT,
+			resource_id TEXT,
+			method TEXT NOT NULL,
+			path TEXT NOT NULL,
+			status INTEGER NOT NULL,
+			remote_addr TEXT,
+			error TEXT
+		)`,
+
 		`CREATE INDEX IF NOT EXISTS idx_backup_policies_name ON backup_policies(name)`,
 		`CREATE INDEX IF NOT EXISTS idx_backup_jobs_policy_id ON backup_jobs(policy_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_backup_jobs_status ON backup_jobs(status)`,
 		`CREATE INDEX IF NOT EXISTS idx_backup_results_job_id ON backup_results(job_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_backup_files_job_id ON backup_files(job_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_backup_files_relative_path ON backup_files(relative_path)`,
+		`CREATE INDEX IF NOT EXISTS idx_backup_files_file_path ON backup_files(file_path)`,
+		`CREATE INDEX IF NOT EXISTS idx_backup_targets_status ON backup_targets(status)`,
+		`CREATE INDEX IF NOT EXISTS idx_backup_copies_job_id ON backup_copies(job_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_backup_copies_destination ON backup_copies(policy_id, destination)`,
+		`CREATE INDEX IF NOT EXISTS idx_backup_verifications_job_id ON backup_verifications(job_id, verified_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_restore_tests_policy_id ON restore_tests(policy_id, started_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_time ON audit_log(time)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor, time)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_policy_id ON audit_log(policy_id, time)`,
 	}
 
 	for _, query := range queries {
@@ -106,16 +240,76 @@ func (s *Service) createTables() error {
 	return nil
 }
 
+// migrateTables добавляет столбцы, появившиеся после создания базы
+func (s *Service) migrateTables() error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"backup_policies", "copy_targets", "TEXT"},
+		{"backup_policies", "bandwidth", "TEXT"},
+		{"backup_results", "remote_checksum", "TEXT"},
+		{"backup_targets", "checksum", "TEXT"},
+		{"backup_files", "mode", "INTEGER DEFAULT 0"},
+		{"backup_files", "mod_time", "DATETIME"},
+		{"backup_policies", "restore_test", "TEXT"},
+		{"backup_policies", "parity", "TEXT"},
+		{"backup_results", "parity_path", "TEXT"},
+		{"backup_verifications", "damaged", "INTEGER DEFAULT 0"},
+		{"backup_verifications", "repairable", "BOOLEAN DEFAULT false"},
+		{"backup_policies", "volume_size", "INTEGER DEFAULT 0"},
+		{"backup_results", "volumes", "INTEGER DEFAULT 0"},
+		{"backup_policies", "overlap", "TEXT DEFAULT ''"},
+		{"backup_jobs", "destination", "TEXT DEFAULT ''"},
+		{"backup_jobs", "indexed", "BOOLEAN DEFAULT false"},
+	}
+
+	for _, c := range columns {
+		var count int
+		query := `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+		if err := s.db.QueryRow(query, c.table, c.column).Scan(&count); err != nil {
+			return fmt.Errorf("ошибка проверки столбца %s.%s: %w", c.table, c.column, err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		if _, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)); err != nil {
+			return fmt.Errorf("ошибка добавления столбца %s.%s: %w", c.table, c.column, err)
+		}
+	}
+
+	return nil
+}
+
 // savePolicy сохраняет политику бэкапа в базу данных
 func (s *Service) savePolicy(ctx context.Context, policy *types.BackupPolicy) error {
 	query := `
 		INSERT OR REPLACE INTO backup_policies (
 			id, name, source_path, destination_path, schedule_cron,
 			retention_count, archive_enabled, encryption_enabled, encryption_password,
-			updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
+			copy_targets, bandwidth, restore_test, parity, volume_size, overlap, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
 
-	_, err := s.db.ExecContext(ctx, query,
+	copyTargets, err := marshalJSONColumn(policy.CopyTargets, len(policy.CopyTargets) == 0)
+	if err != nil {
+		return err
+	}
+	bandwidth, err := marshalJSONColumn(policy.Bandwidth, policy.Bandwidth == nil)
+	if err != nil {
+		return err
+	}
+	restoreTest, err := marshalJSONColumn(policy.RestoreTest, policy.RestoreTest == nil)
+	if err != nil {
+		return err
+	}
+	parity, err := marshalJSONColumn(policy.Parity, policy.Parity == nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, query,
 		policy.ID,
 		policy.Name,
 		policy.SourcePath,
@@ -125,6 +319,12 @@ func (s *Service) savePolicy(ctx context.Context, policy *types.BackupPolicy) er
 		policy.ArchiveEnabled,
 		policy.EncryptionEnabled,
 		policy.EncryptionPassword,
+		copyTargets,
+		bandwidth,
+		restoreTest,
+		parity,
+		policy.VolumeSize,
+		policy.Overlap,
 	)
 
 	if err != nil {
@@ -143,7 +343,7 @@ func (s *Service) getPolicy(ctx context.Context, policyID string) (*types.Backup
 	query := `
 		SELECT id, name, source_path, destination_path, schedule_cron,
 			   retention_count, archive_enabled, encryption_enabled, encryption_password,
-			   created_at, updated_at
+			   copy_targets, bandwidth, restore_test, parity, volume_size, overlap, created_at, updated_at
 		FROM backup_policies 
 		WHERE id = ?`
 
@@ -151,6 +351,7 @@ func (s *Service) getPolicy(ctx context.Context, policyID string) (*types.Backup
 
 	policy := &types.BackupPolicy{}
 	var createdAt, updatedAt time.Time
+	var copyTargets, bandwidth, restoreTest, parity sql.NullString
 
 	err := row.Scan(
 		&policy.ID,
@@ -162,17 +363,36 @@ func (s *Service) getPolicy(ctx context.Context, policyID string) (*types.Backup
 		&policy.ArchiveEnabled,
 		&policy.EncryptionEnabled,
 		&policy.EncryptionPassword,
+		&copyTargets,
+		&bandwidth,
+		&restoreTest,
+		&parity,
+		&policy.VolumeSize,
+		&policy.Overlap,
 		&createdAt,
 		&updatedAt,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("политика с ID %s не найдена", policyID)
+			return nil, notFoundf("политика с ID %s не найдена", policyID)
 		}
 		return nil, fmt.Errorf("ошибка получения политики: %w", err)
 	}
 
+	if err := unmarshalJSONColumn(copyTargets, &policy.CopyTargets); err != nil {
+		return nil, err
+	}
+	if err := unmarshalJSONColumn(bandwidth, &policy.Bandwidth); err != nil {
+		return nil, err
+	}
+	if err := unmarshalJSONColumn(restoreTest, &policy.RestoreTest); err != nil {
+		return nil, err
+	}
+	if err := unmarshalJSONColumn(parity, &policy.Parity); err != nil {
+		return nil, err
+	}
+
 	policy.CreatedAt = createdAt
 	policy.UpdatedAt = updatedAt
 
@@ -184,8 +404,8 @@ func (s *Service) saveBackupJob(ctx context.Context, job *types.BackupJob) error
 	query := `
 		INSERT OR REPLACE INTO backup_jobs (
 			id, policy_id, status, started_at, completed_at, error,
-			files_processed, total_size, backup_path
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			files_processed, total_size, backup_path, destination
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	_, err := s.db.ExecContext(ctx, query,
 		job.ID,
@@ -197,6 +417,7 @@ func (s *Service) saveBackupJob(ctx context.Context, job *types.BackupJob) error
 		job.FilesProcessed,
 		job.TotalSize,
 		job.BackupPath,
+		job.Destination,
 	)
 
 	if err != nil {
@@ -212,8 +433,8 @@ func (s *Service) saveBackupResult(ctx context.Context, result *types.BackupResu
 		INSERT INTO backup_results (
 			id, job_id, backup_path, files_processed, total_size,
 			compressed_size, compression_ratio, encrypted, compressed,
-			checksum, duration_seconds
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			checksum, remote_checksum, parity_path, volumes, duration_seconds
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	resultID := fmt.Sprintf("result_%s", result.JobID)
 	durationSeconds := int64(result.Duration.Seconds())
@@ -229,6 +450,9 @@ func (s *Service) saveBackupResult(ctx context.Context, result *types.BackupResu
 		result.Encrypted,
 		result.Compressed,
 		result.Checksum,
+		result.RemoteChecksum,
+		result.ParityPath,
+		result.Volumes,
 		durationSeconds,
 	)
 
@@ -239,11 +463,253 @@ func (s *Service) saveBackupResult(ctx context.Context, result *types.BackupResu
 	return nil
 }
 
+// getBackupResult получает результат бэкапа задачи
+func (s *Service) getBackupResult(ctx context.Context, jobID string) (*types.BackupResult, error) {
+	query := `
+		SELECT job_id, backup_path, files_processed, total_size, compressed_size,
+			   compression_ratio, encrypted, compressed, checksum, remote_checksum, parity_path,
+			   volumes
+		FROM backup_results
+		WHERE job_id = ?`
+
+	result := &types.BackupResult{}
+	var checksum, remoteChecksum, parityPath sql.NullString
+
+	err := s.db.QueryRowContext(ctx, query, jobID).Scan(
+		&result.JobID,
+		&result.BackupPath,
+		&result.FilesProcessed,
+		&result.TotalSize,
+		&result.CompressedSize,
+		&result.CompressionRatio,
+		&result.Encrypted,
+		&result.Compressed,
+		&checksum,
+		&remoteChecksum,
+		&parityPath,
+		&result.Volumes,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, notFoundf("результат бэкапа задачи %s не найден", jobID)
+		}
+		return nil, fmt.Errorf("ошибка получения результата бэкапа: %w", err)
+	}
+
+	result.Checksum = checksum.String
+	result.RemoteChecksum = remoteChecksum.String
+	result.ParityPath = parityPath.String
+
+	return result, nil
+}
+
+// marshalJSONColumn сериализует значение для столбца JSON; пустое значение записывается как NULL
+func marshalJSONColumn(value any, empty bool) (sql.NullString, error) {
+	if empty {
+		return sql.NullString{}, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("ошибка сериализации значения для БД: %w", err)
+	}
+
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// unmarshalJSONColumn восстанавливает значение из столбца JSON
+func unmarshalJSONColumn(data sql.NullString, value any) error {
+	if !data.Valid || data.String == "" {
+		return nil
+	}
+
+	if err := json.Unmarshal([]byte(data.String), value); err != nil {
+		return fmt.Errorf("ошибка чтения значения из БД: %w", err)
+	}
+
+	return nil
+}
+
+// saveBackupCopy сохраняет запись о копии бэкапа
+func (s *Service) saveBackupCopy(ctx context.Context, backupCopy *types.BackupCopy) error {
+	query := `
+		INSERT OR REPLACE INTO backup_copies (
+			id, job_id, policy_id, destination, remote_path, status, error,
+			size, created_at, completed_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := s.db.ExecContext(ctx, query,
+		backupCopy.ID,
+		backupCopy.JobID,
+		backupCopy.PolicyID,
+		backupCopy.Destination,
+		backupCopy.RemotePath,
+		backupCopy.Status,
+		backupCopy.Error,
+		backupCopy.Size,
+		backupCopy.CreatedAt,
+		backupCopy.CompletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения копии бэкапа: %w", err)
+	}
+
+	return nil
+}
+
+// getBackupCopies получает завершенные копии бэкапов политики в хранилище,
+// от новых бэкапов к старым
+func (s *Service) getBackupCopies(ctx context.Context, policyID, destination string) ([]*types.BackupCopy, error) {
+	query := `
+		SELECT c.id, c.job_id, c.policy_id, c.destination, c.remote_path, c.status, c.error,
+			   c.size, c.created_at, c.completed_at
+		FROM backup_copies c
+		LEFT JOIN backup_jobs j ON j.id = c.job_id
+		WHERE c.policy_id = ? AND c.destination = ? AND c.status = 'completed'
+		ORDER BY j.created_at DESC, c.created_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, policyID, destination)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения копий бэкапов: %w", err)
+	}
+	defer rows.Close()
+
+	var copies []*types.BackupCopy
+	for rows.Next() {
+		backupCopy := &types.BackupCopy{}
+		var errorText sql.NullString
+
+		err := rows.Scan(
+			&backupCopy.ID,
+			&backupCopy.JobID,
+			&backupCopy.PolicyID,
+			&backupCopy.Destination,
+			&backupCopy.RemotePath,
+			&backupCopy.Status,
+			&errorText,
+			&backupCopy.Size,
+			&backupCopy.CreatedAt,
+			&backupCopy.CompletedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+
+		backupCopy.Error = errorText.String
+		copies = append(copies, backupCopy)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+
+	return copies, nil
+}
+
+// saveTargetResults сохраняет результаты записи бэкапа по хранилищам MultiStorage
+func (s *Service) saveTargetResults(ctx context.Context, jobID string, results []types.TargetResult) error {
+	query := `
+		INSERT OR REPLACE INTO backup_targets (
+			job_id, target, remote_path, status, error, checksum, duration_ms, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	for _, result := range results {
+		_, err := s.db.ExecContext(ctx, query,
+			jobID,
+			result.Target,
+			result.RemotePath,
+			result.Status,
+			result.Error,
+			result.Checksum,
+			result.Duration.Milliseconds(),
+			result.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения результата хранилища %s: %w", result.Target, err)
+		}
+	}
+
+	return nil
+}
+
+// updateTargetResult обновляет статус копии бэкапа в хранилище по пути копии
+func (s *Service) updateTargetResult(ctx context.Context, result types.TargetResult) error {
+	query := `
+		UPDATE backup_targets
+		SET status = ?, error = ?, duration_ms = ?, updated_at = ?
+		WHERE target = ? AND remote_path = ?`
+
+	_, err := s.db.ExecContext(ctx, query,
+		result.Status,
+		result.Error,
+		result.Duration.Milliseconds(),
+		result.UpdatedAt,
+		result.Target,
+		result.RemotePath,
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления результата хранилища %s: %w", result.Target, err)
+	}
+
+	return nil
+}
+
+// failedTarget копия бэкапа, которую не удалось записать в хранилище
+type failedTarget struct {
+	JobID    string
+	PolicyID string
+	Result   types.TargetResult
+}
+
+// getFailedTargets получает копии завершенных бэкапов, ожидающие синхронизации
+func (s *Service) getFailedTargets(ctx context.Context) ([]failedTarget, error) {
+	query := `
+		SELECT t.job_id, j.policy_id, t.target, t.remote_path, t.status, t.error, t.updated_at
+		FROM backup_targets t
+		JOIN backup_jobs j ON j.id = t.job_id
+		WHERE t.status = 'failed' AND j.status = 'completed'
+		ORDER BY t.updated_at`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения несинхронизированных копий: %w", err)
+	}
+	defer rows.Close()
+
+	var targets []failedTarget
+	for rows.Next() {
+		var target failedTarget
+		var errorText sql.NullString
+
+		err := rows.Scan(
+			&target.JobID,
+			&target.PolicyID,
+			&target.Result.Target,
+			&target.Result.RemotePath,
+			&target.Result.Status,
+			&errorText,
+			&target.Result.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+
+		target.Result.Error = errorText.String
+		targets = append(targets, target)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+
+	return targets, nil
+}
+
 // getBackupHistory получает историю бэкапов для политики
 func (s *Service) getBackupHistory(ctx context.Context, policyID string, limit int) ([]*types.BackupJob, error) {
 	query := `
 		SELECT id, policy_id, status, started_at, completed_at, error,
-			   files_processed, total_size, backup_path, created_at
+			   files_processed, total_size, backup_path, destination, created_at
 		FROM backup_jobs 
 		WHERE policy_id = ? 
 		ORDER BY created_at DESC 
@@ -270,6 +736,7 @@ func (s *Service) getBackupHistory(ctx context.Context, policyID string, limit i
 			&job.FilesProcessed,
 			&job.TotalSize,
 			&job.BackupPath,
+			&job.Destination,
 			&createdAt,
 		)
 		if err != nil {
@@ -287,12 +754,121 @@ func (s *Service) getBackupHistory(ctx context.Context, policyID string, limit i
 	return jobs, nil
 }
 
+// listBackupJobs получает задачи бэкапа по фильтру и общее количество подходящих задач
+func (s *Service) listBackupJobs(ctx context.Context, filter JobFilter) ([]*types.BackupJob, int, error) {
+	where := `
+		WHERE (? = '' OR policy_id = ?) AND (? = '' OR status = ?) AND (? = 0 OR backup_path != '')`
+	args := []any{filter.PolicyID, filter.PolicyID, filter.Status, filter.Status, filter.Backups}
+
+	if filter.PolicyIDs != nil {
+		if len(filter.PolicyIDs) == 0 {
+			return nil, 0, nil
+		}
+		where += " AND policy_id IN (?" + strings.Repeat(", ?", len(filter.PolicyIDs)-1) + ")"
+		for _, policyID := range filter.PolicyIDs {
+			args = append(args, policyID)
+		}
+	}
+
+	// Время хранится строкой со смещением часового пояса, поэтому
+	// сравнивается через julianday
+	if !filter.StartedBy.IsZero() {
+		where += " AND julianday(started_at) <= julianday(?)"
+		args = append(args, filter.StartedBy)
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM backup_jobs"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("ошибка подсчета задач бэкапа: %w", err)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // Без ограничения
+	}
+
+	query := `
+		SELECT id, policy_id, status, started_at, completed_at, error,
+			   files_processed, total_size, backup_path, destination, created_at
+		FROM backup_jobs` + where + `
+		ORDER BY created_at DESC
+		LIMIT ? OFFSET ?`
+
+	rows, err := s.db.QueryContext(ctx, query, append(args, limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка получения задач бэкапа: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*types.BackupJob
+	for rows.Next() {
+		job := &types.BackupJob{}
+
+		err := rows.Scan(
+			&job.ID,
+			&job.PolicyID,
+			&job.Status,
+			&job.StartedAt,
+			&job.CompletedAt,
+			&job.Error,
+			&job.FilesProcessed,
+			&job.TotalSize,
+			&job.BackupPath,
+			&job.Destination,
+			&job.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+
+	return jobs, total, nil
+}
+
+// getBackupJob получает задачу бэкапа по ID
+func (s *Service) getBackupJob(ctx context.Context, jobID string) (*types.BackupJob, error) {
+	query := `
+		SELECT id, policy_id, status, started_at, completed_at, error,
+			   files_processed, total_size, backup_path, destination, created_at
+		FROM backup_jobs
+		WHERE id = ?`
+
+	job := &types.BackupJob{}
+	err := s.db.QueryRowContext(ctx, query, jobID).Scan(
+		&job.ID,
+		&job.PolicyID,
+		&job.Status,
+		&job.StartedAt,
+		&job.CompletedAt,
+		&job.Error,
+		&job.FilesProcessed,
+		&job.TotalSize,
+		&job.BackupPath,
+		&job.Destination,
+		&job.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, notFoundf("задача бэкапа с ID %s не найдена", jobID)
+		}
+		return nil, fmt.Errorf("ошибка получения задачи бэкапа: %w", err)
+	}
+
+	return job, nil
+}
+
 // getAllPolicies получает все политики бэкапа
 func (s *Service) getAllPolicies(ctx context.Context) ([]*types.BackupPolicy, error) {
 	query := `
 		SELECT id, name, source_path, destination_path, schedule_cron,
 			   retention_count, archive_enabled, encryption_enabled, 
-			   created_at, updated_at
+			   copy_targets, bandwidth, restore_test, parity, volume_size, overlap, created_at, updated_at
 		FROM backup_policies 
 		ORDER BY created_at DESC`
 
@@ -305,6 +881,7 @@ func (s *Service) getAllPolicies(ctx context.Context) ([]*types.BackupPolicy, er
 	var policies []*types.BackupPolicy
 	for rows.Next() {
 		policy := &types.BackupPolicy{}
+		var copyTargets, bandwidth, restoreTest, parity sql.NullString
 
 		err := rows.Scan(
 			&policy.ID,
@@ -315,6 +892,12 @@ func (s *Service) getAllPolicies(ctx context.Context) ([]*types.BackupPolicy, er
 			&policy.RetentionCount,
 			&policy.ArchiveEnabled,
 			&policy.EncryptionEnabled,
+			&copyTargets,
+			&bandwidth,
+			&restoreTest,
+			&parity,
+			&policy.VolumeSize,
+			&policy.Overlap,
 			&policy.CreatedAt,
 			&policy.UpdatedAt,
 		)
@@ -322,6 +905,19 @@ func (s *Service) getAllPolicies(ctx context.Context) ([]*types.BackupPolicy, er
 			return nil, fmt.Errorf("ошибка сканирования политики: %w", err)
 		}
 
+		if err := unmarshalJSONColumn(copyTargets, &policy.CopyTargets); err != nil {
+			return nil, err
+		}
+		if err := unmarshalJSONColumn(bandwidth, &policy.Bandwidth); err != nil {
+			return nil, err
+		}
+		if err := unmarshalJSONColumn(restoreTest, &policy.RestoreTest); err != nil {
+			return nil, err
+		}
+		if err := unmarshalJSONColumn(parity, &policy.Parity); err != nil {
+			return nil, err
+		}
+
 		policies = append(policies, policy)
 	}
 
@@ -349,6 +945,24 @@ func (s *Service) deletePolicy(ctx context.Context, policyID string) error {
 		return fmt.Errorf("ошибка удаления результатов бэкапов: %w", err)
 	}
 
+	// Удаляем блокировку политики
+	_, err = tx.ExecContext(ctx, "DELETE FROM policy_locks WHERE policy_id = ?", policyID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления блокировки политики: %w", err)
+	}
+
+	// Удаляем запросы отмены задач
+	_, err = tx.ExecContext(ctx, "DELETE FROM job_cancellations WHERE job_id IN (SELECT id FROM backup_jobs WHERE policy_id = ?)", policyID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления запросов отмены задач: %w", err)
+	}
+
+	// Удаляем незавершенные загрузки
+	_, err = tx.ExecContext(ctx, "DELETE FROM pending_uploads WHERE policy_id = ?", policyID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления незавершенных загрузок: %w", err)
+	}
+
 	// Удаляем задачи бэкапов
 	_, err = tx.ExecContext(ctx, "DELETE FROM backup_jobs WHERE policy_id = ?", policyID)
 	if err != nil {
@@ -369,3 +983,717 @@ func (s *Service) deletePolicy(ctx context.Context, policyID string) error {
 	s.logger.InfoContext(ctx, "Политика бэкапа удалена", "policy_id", policyID)
 	return nil
 }
+
+// hasBackupCopy проверяет, скопирован ли уже бэкап задачи в хранилище
+func (s *Service) hasBackupCopy(ctx context.Context, jobID, destination string) (bool, error) {
+	query := `
+		SELECT COUNT(*) FROM backup_copies
+		WHERE job_id = ? AND destination = ? AND status IN ('completed', 'deleted')`
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, query, jobID, destination).Scan(&count); err != nil {
+		return false, fmt.Errorf("ошибка проверки копии бэкапа: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// saveBackupFiles сохраняет манифест файлов бэкапа и отмечает задачу
+// проиндексированной, в том числе при пустом манифесте
+func (s *Service) saveBackupFiles(ctx context.Context, jobID string, files []types.BackupFile) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO backup_files (
+			id, job_id, file_path, relative_path, file_size, checksum, mode, mod_time, processed
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, true)`)
+	if err != nil {
+		return fmt.Errorf("ошибка подготовки запроса: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, file := range files {
+		_, err := stmt.ExecContext(ctx,
+			uuid.New().String(),
+			jobID,
+			file.SourcePath,
+			file.Path,
+			file.Size,
+			file.Checksum,
+			file.Mode,
+			file.ModTime,
+		)
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения файла %s: %w", file.Path, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE backup_jobs SET indexed = true WHERE id = ?", jobID); err != nil {
+		return fmt.Errorf("ошибка отметки индекса задачи: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка подтверждения транзакции: %w", err)
+	}
+
+	return nil
+}
+
+// getBackupFiles получает манифест файлов бэкапа задачи
+func (s *Service) getBackupFiles(ctx context.Context, jobID string) ([]types.BackupFile, error) {
+	query := `
+		SELECT job_id, file_path, relative_path, file_size, checksum, mode, mod_time
+		FROM backup_files
+		WHERE job_id = ?
+		ORDER BY relative_path`
+
+	rows, err := s.db.QueryContext(ctx, query, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения манифеста бэкапа: %w", err)
+	}
+	defer rows.Close()
+
+	var files []types.BackupFile
+	for rows.Next() {
+		var file types.BackupFile
+		var checksum sql.NullString
+		var modTime sql.NullTime
+
+		err := rows.Scan(
+			&file.JobID,
+			&file.SourcePath,
+			&file.Path,
+			&file.Size,
+			&checksum,
+			&file.Mode,
+			&modTime,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+
+		file.Checksum = checksum.String
+		file.ModTime = modTime.Time
+		files = append(files, file)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+
+	return files, nil
+}
+
+// isJobIndexed проверяет, сохранен ли в каталоге индекс файлов бэкапа задачи
+func (s *Service) isJobIndexed(ctx context.Context, jobID string) (bool, error) {
+	var indexed bool
+	err := s.db.QueryRowContext(ctx, "SELECT indexed FROM backup_jobs WHERE id = ?", jobID).Scan(&indexed)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки индекса задачи: %w", err)
+	}
+	return indexed, nil
+}
+
+// getUnindexedJobs возвращает ID завершенных задач, индекс файлов которых
+// не отмечен в каталоге; пустой policyID - задачи всех политик
+func (s *Service) getUnindexedJobs(ctx context.Context, policyID string) ([]string, error) {
+	query := `
+		SELECT id FROM backup_jobs
+		WHERE status = ? AND backup_path != '' AND NOT indexed AND (? = '' OR policy_id = ?)
+		ORDER BY created_at`
+
+	rows, err := s.db.QueryContext(ctx, query, types.JobStatusCompleted, policyID, policyID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения задач без индекса: %w", err)
+	}
+	defer rows.Close()
+
+	var jobIDs []string
+	for rows.Next() {
+		var jobID string
+		if err := rows.Scan(&jobID); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		jobIDs = append(jobIDs, jobID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+
+	return jobIDs, nil
+}
+
+// markJobIndexed отмечает, что индекс файлов бэкапа задачи сохранен в каталоге
+func (s *Service) markJobIndexed(ctx context.Context, jobID string) error {
+	if _, err := s.db.ExecContext(ctx, "UPDATE backup_jobs SET indexed = true WHERE id = ?", jobID); err != nil {
+		return fmt.Errorf("ошибка отметки индекса задачи: %w", err)
+	}
+	return nil
+}
+
+// getSnapshotFiles получает файлы завершенных бэкапов из каталога
+// в порядке создания бэкапов; пустой policyID - файлы всех политик
+//
+// Непустой filePath выбирает версии одного файла: он сравнивается
+// с исходным путем и с путем относительно исходной директории.
+func (s *Service) getSnapshotFiles(ctx context.Context, policyID, filePath string) ([]SnapshotFile, error) {
+	query := `
+		SELECT f.job_id, f.file_path, f.relative_path, f.file_size, f.checksum, f.mode, f.mod_time,
+			   j.policy_id, j.started_at
+		FROM backup_files f
+		JOIN backup_jobs j ON j.id = f.job_id
+		WHERE j.status = ? AND j.backup_path != '' AND (? = '' OR j.policy_id = ?)
+			AND (? = '' OR f.file_path = ? OR f.relative_path = ?)
+		ORDER BY j.created_at, f.relative_path`
+
+	rows, err := s.db.QueryContext(ctx, query, types.JobStatusCompleted, policyID, policyID, filePath, filePath, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения файлов бэкапов: %w", err)
+	}
+	defer rows.Close()
+
+	var files []SnapshotFile
+	for rows.Next() {
+		var file SnapshotFile
+		var checksum sql.NullString
+		var modTime sql.NullTime
+
+		err := rows.Scan(
+			&file.JobID,
+			&file.SourcePath,
+			&file.Path,
+			&file.Size,
+			&checksum,
+			&file.Mode,
+			&modTime,
+			&file.PolicyID,
+			&file.StartedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+
+		file.Checksum = checksum.String
+		file.ModTime = modTime.Time
+		files = append(files, file)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+
+	return files, nil
+}
+
+// saveBackupVerification сохраняет результат проверки бэкапа
+func (s *Service) saveBackupVerification(ctx context.Context, verification *types.BackupVerification) error {
+	query := `
+		INSERT INTO backup_verifications (
+			id, job_id, status, error, checksum, deep, entries, damaged, repairable,
+			duration_ms, verified_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := s.db.ExecContext(ctx, query,
+		verification.ID,
+		verification.JobID,
+		verification.Status,
+		verification.Error,
+		verification.Checksum,
+		verification.Deep,
+		verification.Entries,
+		verification.Damaged,
+		verification.Repairable,
+		verification.Duration.Milliseconds(),
+		verification.VerifiedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения результата проверки бэкапа: %w", err)
+	}
+
+	return nil
+}
+
+// saveRestoreTest сохраняет результат проверки восстановления
+func (s *Service) saveRestoreTest(ctx context.Context, result *types.RestoreTestResult) error {
+	query := `
+		INSERT INTO restore_tests (
+			id, policy_id, job_id, status, error, files_checked, files_failed,
+			command_output, restore_duration_ms, duration_ms, started_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := s.db.ExecContext(ctx, query,
+		result.ID,
+		result.PolicyID,
+		result.JobID,
+		result.Status,
+		result.Error,
+		result.FilesChecked,
+		result.FilesFailed,
+		result.CommandOutput,
+		result.RestoreDuration.Milliseconds(),
+		result.Duration.Milliseconds(),
+		result.StartedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения результата проверки восстановления: %w", err)
+	}
+
+	return nil
+}
+
+// loadUploadSession получает сохраненную сессию загрузки; nil, если сессии нет
+func (s *Service) loadUploadSession(ctx context.Context, key string) (*UploadSession, error) {
+	query := `SELECT key, upload_id, size, part_size, created_at FROM upload_sessions WHERE key = ?`
+
+	session := &UploadSession{}
+	err := s.db.QueryRowContext(ctx, query, key).Scan(
+		&session.Key,
+		&session.UploadID,
+		&session.Size,
+		&session.PartSize,
+		&session.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ошибка получения сессии загрузки: %w", err)
+	}
+
+	return session, nil
+}
+
+// saveUploadSession сохраняет сессию загрузки
+func (s *Service) saveUploadSession(ctx context.Context, session *UploadSession) error {
+	query := `
+		INSERT OR REPLACE INTO upload_sessions (key, upload_id, size, part_size, created_at)
+		VALUES (?, ?, ?, ?, ?)`
+
+	_, err := s.db.ExecContext(ctx, query, session.Key, session.UploadID, session.Size, session.PartSize, session.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения сессии загрузки: %w", err)
+	}
+
+	return nil
+}
+
+// deleteUploadSession удаляет завершенную сессию загрузки
+func (s *Service) deleteUploadSession(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM upload_sessions WHERE key = ?", key); err != nil {
+		return fmt.Errorf("ошибка удаления сессии загрузки: %w", err)
+	}
+
+	return nil
+}
+
+// listUploadSessions возвращает сессии загрузки с префиксом ключа prefix,
+// созданные раньше before
+func (s *Service) listUploadSessions(ctx context.Context, prefix string, before time.Time) ([]*UploadSession, error) {
+	query := `
+		SELECT key, upload_id, size, part_size, created_at
+		FROM upload_sessions
+		WHERE substr(key, 1, length(?)) = ? AND created_at < ?`
+
+	rows, err := s.db.QueryContext(ctx, query, prefix, prefix, before)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения сессий загрузки: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*UploadSession
+	for rows.Next() {
+		session := &UploadSession{}
+		if err := rows.Scan(&session.Key, &session.UploadID, &session.Size, &session.PartSize, &session.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования сессии загрузки: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// savePendingUpload сохраняет готовый бэкап, загрузка которого не завершена
+func (s *Service) savePendingUpload(ctx context.Context, upload *pendingUpload) error {
+	result, err := json.Marshal(upload.Result)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации результата бэкапа: %w", err)
+	}
+	files, err := json.Marshal(upload.Result.Files)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации манифеста бэкапа: %w", err)
+	}
+
+	query := `
+		INSERT OR REPLACE INTO pending_uploads (job_id, policy_id, local_path, parity_path, remote_path, result, files, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err = s.db.ExecContext(ctx, query, upload.JobID, upload.PolicyID, upload.LocalPath, upload.ParityPath,
+		upload.RemotePath, string(result), string(files), upload.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения незавершенной загрузки: %w", err)
+	}
+
+	return nil
+}
+
+// getPendingUploads возвращает незавершенные загрузки политики policyID,
+// пустой policyID - всех политик, в порядке создания
+func (s *Service) getPendingUploads(ctx context.Context, policyID string) ([]*pendingUpload, error) {
+	query := `
+		SELECT job_id, policy_id, local_path, parity_path, remote_path, result, files, created_at
+		FROM pending_uploads
+		WHERE ? = '' OR policy_id = ?
+		ORDER BY created_at`
+
+	rows, err := s.db.QueryContext(ctx, query, policyID, policyID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения незавершенных загрузок: %w", err)
+	}
+	defer rows.Close()
+
+	var uploads []*pendingUpload
+	for rows.Next() {
+		upload := &pendingUpload{Result: &types.BackupResult{}}
+		var parityPath, files sql.NullString
+		var result string
+
+		err := rows.Scan(
+			&upload.JobID,
+			&upload.PolicyID,
+			&upload.LocalPath,
+			&parityPath,
+			&upload.RemotePath,
+			&result,
+			&files,
+			&upload.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования незавершенной загрузки: %w", err)
+		}
+
+		if err := json.Unmarshal([]byte(result), upload.Result); err != nil {
+			return nil, fmt.Errorf("ошибка разбора результата бэкапа %s: %w", upload.JobID, err)
+		}
+		if files.Valid {
+			if err := json.Unmarshal([]byte(files.String), &upload.Result.Files); err != nil {
+				return nil, fmt.Errorf("ошибка разбора манифеста бэкапа %s: %w", upload.JobID, err)
+			}
+		}
+		upload.ParityPath = parityPath.String
+		uploads = append(uploads, upload)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+
+	return uploads, nil
+}
+
+// deletePendingUpload удаляет запись о незавершенной загрузке
+func (s *Service) deletePendingUpload(ctx context.Context, jobID string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM pending_uploads WHERE job_id = ?", jobID); err != nil {
+		return fmt.Errorf("ошибка удаления незавершенной загрузки: %w", err)
+	}
+
+	return nil
+}
+
+// saveCancelRequest сохраняет запрос отмены задачи; повторный запрос
+// не меняет причину первого
+func (s *Service) saveCancelRequest(ctx context.Context, jobID, reason string) error {
+	query := `INSERT OR IGNORE INTO job_cancellations (job_id, reason, requested_at) VALUES (?, ?, ?)`
+
+	if _, err := s.db.ExecContext(ctx, query, jobID, reason, time.Now()); err != nil {
+		return fmt.Errorf("ошибка сохранения запроса отмены задачи: %w", err)
+	}
+
+	return nil
+}
+
+// getCancelRequest возвращает причину запрошенной отмены задачи;
+// ok false - отмена не запрошена
+func (s *Service) getCancelRequest(ctx context.Context, jobID string) (reason string, ok bool, err error) {
+	err = s.db.QueryRowContext(ctx, `SELECT reason FROM job_cancellations WHERE job_id = ?`, jobID).Scan(&reason)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("ошибка получения запроса отмены задачи: %w", err)
+	}
+
+	return reason, true, nil
+}
+
+// getPolicyLock возвращает аренду блокировки политики; nil - политика не заблокирована
+func (s *Service) getPolicyLock(ctx context.Context, policyID string) (*policyLease, error) {
+	query := `SELECT policy_id, job_id, owner, acquired_at, expires_at FROM policy_locks WHERE policy_id = ?`
+
+	lease := &policyLease{}
+	err := s.db.QueryRowContext(ctx, query, policyID).Scan(
+		&lease.PolicyID,
+		&lease.JobID,
+		&lease.Owner,
+		&lease.AcquiredAt,
+		&lease.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ошибка получения блокировки политики: %w", err)
+	}
+
+	return lease, nil
+}
+
+// savePolicyLock сохраняет аренду блокировки политики, заменяя прежнюю
+func (s *Service) savePolicyLock(ctx context.Context, lease *policyLease) error {
+	query := `
+		INSERT OR REPLACE INTO policy_locks (policy_id, job_id, owner, acquired_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)`
+
+	_, err := s.db.ExecContext(ctx, query, lease.PolicyID, lease.JobID, lease.Owner, lease.AcquiredAt, lease.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения блокировки политики: %w", err)
+	}
+
+	return nil
+}
+
+// renewPolicyLock продлевает аренду блокировки политики задачей jobID;
+// ok false - аренда принадлежит другой задаче или удалена
+func (s *Service) renewPolicyLock(ctx context.Context, policyID, jobID string, expiresAt time.Time) (ok bool, err error) {
+	query := `UPDATE policy_locks SET expires_at = ? WHERE policy_id = ? AND job_id = ?`
+
+	result, err := s.db.ExecContext(ctx, query, expiresAt, policyID, jobID)
+	if err != nil {
+		return false, fmt.Errorf("ошибка продления блокировки политики: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("ошибка продления блокировки политики: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// deletePolicyLock удаляет аренду блокировки политики, если она принадлежит задаче jobID
+func (s *Service) deletePolicyLock(ctx context.Context, policyID, jobID string) error {
+	query := `DELETE FROM policy_locks WHERE policy_id = ? AND job_id = ?`
+
+	if _, err := s.db.ExecContext(ctx, query, policyID, jobID); err != nil {
+		return fmt.Errorf("ошибка удаления блокировки политики: %w", err)
+	}
+
+	return nil
+}
+
+// saveAPIToken сохраняет API-токен с хешем его значения
+func (s *Service) saveAPIToken(ctx context.Context, token *types.APIToken, tokenHash string) error {
+	roles, err := marshalJSONColumn(token.Roles, false)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO api_tokens (id, name, token_hash, roles, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+
+	if _, err := s.db.ExecContext(ctx, query, token.ID, token.Name, tokenHash, roles, token.CreatedAt, token.ExpiresAt); err != nil {
+		return fmt.Errorf("ошибка сохранения API-токена: %w", err)
+	}
+
+	return nil
+}
+
+// apiTokenColumns столбцы API-токена в порядке scanAPIToken
+const apiTokenColumns = `id, name, roles, created_at, expires_at, last_used_at, revoked_at`
+
+// scanAPIToken читает API-токен из строки результата
+func scanAPIToken(row interface{ Scan(...any) error }) (*types.APIToken, error) {
+	token := &types.APIToken{}
+	var roles sql.NullString
+
+	if err := row.Scan(&token.ID, &token.Name, &roles, &token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt, &token.RevokedAt); err != nil {
+		return nil, err
+	}
+	if err := unmarshalJSONColumn(roles, &token.Roles); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// getAPITokenByHash получает API-токен по хешу его значения
+func (s *Service) getAPITokenByHash(ctx context.Context, tokenHash string) (*types.APIToken, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE token_hash = ?`, tokenHash)
+
+	token, err := scanAPIToken(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, notFoundf("API-токен не найден")
+		}
+		return nil, fmt.Errorf("ошибка получения API-токена: %w", err)
+	}
+
+	return token, nil
+}
+
+// getAPIToken получает API-токен по ID или имени
+func (s *Service) getAPIToken(ctx context.Context, idOrName string) (*types.APIToken, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE id = ? OR name = ?`, idOrName, idOrName)
+
+	token, err := scanAPIToken(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, notFoundf("API-токен %s не найден", idOrName)
+		}
+		return nil, fmt.Errorf("ошибка получения API-токена: %w", err)
+	}
+
+	return token, nil
+}
+
+// getAPITokens получает все API-токены в порядке создания
+func (s *Service) getAPITokens(ctx context.Context) ([]*types.APIToken, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens ORDER BY created_at, name`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения API-токенов: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*types.APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// updateAPITokenUsage отмечает время последнего использования API-токена
+func (s *Service) updateAPITokenUsage(ctx context.Context, tokenID string, usedAt time.Time) error {
+	if _, err := s.db.ExecContext(ctx, "UPDATE api_tokens SET last_used_at = ? WHERE id = ?", usedAt, tokenID); err != nil {
+		return fmt.Errorf("ошибка обновления API-токена: %w", err)
+	}
+
+	return nil
+}
+
+// revokeAPIToken отзывает API-токен
+func (s *Service) revokeAPIToken(ctx context.Context, tokenID string, revokedAt time.Time) error {
+	if _, err := s.db.ExecContext(ctx, "UPDATE api_tokens SET revoked_at = ? WHERE id = ?", revokedAt, tokenID); err != nil {
+		return fmt.Errorf("ошибка отзыва API-токена: %w", err)
+	}
+
+	return nil
+}
+
+// saveAuditEntry добавляет запись в журнал аудита
+func (s *Service) saveAuditEntry(ctx context.Context, entry *types.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (
+			time, actor, auth_method, action, policy_id, resource_id,
+			method, path, status, remote_addr, error
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := s.db.ExecContext(ctx, query,
+		entry.Time,
+		entry.Actor,
+		entry.AuthMethod,
+		entry.Action,
+		entry.PolicyID,
+		entry.ResourceID,
+		entry.Method,
+		entry.Path,
+		entry.Status,
+		entry.RemoteAddr,
+		entry.Error,
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка записи в журнал аудита: %w", err)
+	}
+
+	entry.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// listAuditEntries получает записи журнала аудита по фильтру, начиная с новых,
+// и общее количество подходящих записей
+func (s *Service) listAuditEntries(ctx context.Context, filter AuditFilter) ([]*types.AuditEntry, int, error) {
+	where := `
+		WHERE (? = '' OR actor = ?) AND (? = '' OR policy_id = ?) AND (? = '' OR action = ?)`
+	args := []any{filter.Actor, filter.Actor, filter.PolicyID, filter.PolicyID, filter.Action, filter.Action}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("ошибка подсчета записей аудита: %w", err)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // Без ограничения
+	}
+
+	query := `
+		SELECT id, time, actor, auth_method, action, policy_id, resource_id,
+			   method, path, status, remote_addr, error
+		FROM audit_log` + where + `
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`
+
+	rows, err := s.db.QueryContext(ctx, query, append(args, limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка получения записей аудита: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*types.AuditEntry
+	for rows.Next() {
+		entry := &types.AuditEntry{}
+		var policyID, resourceID, remoteAddr, errorText sql.NullString
+
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Time,
+			&entry.Actor,
+			&entry.AuthMethod,
+			&entry.Action,
+			&policyID,
+			&resourceID,
+			&entry.Method,
+			&entry.Path,
+			&entry.Status,
+			&remoteAddr,
+			&errorText,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+
+		entry.PolicyID = policyID.String
+		entry.ResourceID = resourceID.String
+		entry.RemoteAddr = remoteAddr.String
+		entry.Error = errorText.String
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+
+	return entries, total, nil
+}
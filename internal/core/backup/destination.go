@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// resolveDestination определяет хранилище и префикс пути в нем для адреса назначения
+//
+// Адрес назначения может быть:
+//   - URL хранилища: s3://bucket/prefix, gcs://bucket/prefix, azure://container/prefix,
+//     sftp://user@host:port/path, webdav://host/path, webdavs://host/path;
+//   - ссылкой на именованное хранилище из Storage.Configs: storage://name/prefix;
+//   - абсолютным локальным путем;
+//   - относительным путем в хранилище по умолчанию (Storage.Default или Storage.Type).
+//
+// Провайдеры создаются и кэшируются фабрикой хранилищ, поэтому политики
+// с общим хранилищем используют одно подключение.
+func (s *Service) resolveDestination(destination string) (StorageProvider, string, error) {
+	if filepath.IsAbs(destination) || strings.Contains(destination, "://") {
+		return s.factory.Resolve(destination)
+	}
+
+	return s.defaultStorage(destination)
+}
+
+// defaultStorage возвращает хранилище по умолчанию для относительного пути назначения
+func (s *Service) defaultStorage(prefix string) (StorageProvider, string, error) {
+	prefix = filepath.ToSlash(prefix)
+
+	if name := s.config.Storage.Default; name != "" {
+		if _, ok := s.config.Storage.Configs[name]; ok {
+			storage, _, err := s.factory.Resolve("storage://" + name)
+			return storage, prefix, err
+		}
+	}
+
+	if s.storage == nil {
+		return nil, "", fmt.Errorf("хранилище по умолчанию не инициализировано")
+	}
+
+	return s.storage, prefix, nil
+}
+
+// destinationAddress возвращает адрес назначения, который записывается в
+// задачу при загрузке
+//
+// Относительный путь в именованном хранилище по умолчанию записывается как
+// storage://name/prefix, чтобы бэкап находился и после смены Storage.Default.
+func (s *Service) destinationAddress(destination string) string {
+	if filepath.IsAbs(destination) || strings.Contains(destination, "://") {
+		return destination
+	}
+
+	if name := s.config.Storage.Default; name != "" {
+		if _, ok := s.config.Storage.Configs[name]; ok {
+			return "storage://" + path.Join(name, filepath.ToSlash(destination))
+		}
+	}
+
+	return destination
+}
+
+// storageForJob возвращает хранилище, в которое был записан бэкап задачи
+//
+// Хранилище определяется по адресу, записанному в задачу при загрузке.
+// Для задач, созданных до появления этого поля, используется текущее
+// назначение политики; если политики нет, возвращается ошибка.
+func (s *Service) storageForJob(ctx context.Context, job *types.BackupJob) (StorageProvider, error) {
+	destination := job.Destination
+	if destination == "" {
+		policy, err := s.getPolicy(ctx, job.PolicyID)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка определения хранилища задачи %s: %w", job.ID, err)
+		}
+		destination = policy.DestinationPath
+	}
+
+	storage, _, err := s.resolveDestination(destination)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка определения хранилища задачи %s: %w", job.ID, err)
+	}
+	return storage, nil
+}
@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"backupist/pkg/types"
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
@@ -17,9 +18,6 @@ import (
 
 // encryptFile шифрует файл с использованием AES-256-GCM
 func (s *Service) encryptFile(ctx context.Context, inputPath, outputPath, password string) error {
-	// Генерируем ключ из пароля
-	key := s.deriveKey(password)
-
 	// Открываем входной файл
 	inputFile, err := os.Open(inputPath)
 	if err != nil {
@@ -38,6 +36,64 @@ func (s *Service) encryptFile(ctx context.Context, inputPath, outputPath, passwo
 	}
 	defer outputFile.Close()
 
+	if err := s.encryptStream(ctx, progressReader(ctx, inputFile, types.ProgressPhaseEncrypt), outputFile, password); err != nil {
+		return err
+	}
+
+	if err := outputFile.Close(); err != nil {
+		return fmt.Errorf("ошибка записи выходного файла: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "Файл зашифрован успешно",
+		"input", inputPath,
+		"output", outputPath)
+
+	return nil
+}
+
+// decryptFile расшифровывает файл
+func (s *Service) decryptFile(ctx context.Context, inputPath, outputPath, password string) error {
+	// Открываем зашифрованный файл
+	inputFile, err := os.Open(inputPath)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия зашифрованного файла: %w", err)
+	}
+	defer inputFile.Close()
+
+	// Создаем выходной файл
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("ошибка создания директории: %w", err)
+	}
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("ошибка создания выходного файла: %w", err)
+	}
+	defer outputFile.Close()
+
+	if err := s.decryptStream(ctx, inputFile, outputFile, password); err != nil {
+		return err
+	}
+
+	if err := outputFile.Close(); err != nil {
+		return fmt.Errorf("ошибка записи выходного файла: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "Файл расшифрован успешно",
+		"input", inputPath,
+		"output", outputPath)
+
+	return nil
+}
+
+// encryptStream шифрует поток с использованием AES-256-GCM
+//
+// Данные шифруются блоками по 64 КБ, поэтому поток можно расшифровать
+// по частям, не читая его целиком.
+func (s *Service) encryptStream(ctx context.Context, r io.Reader, w io.Writer, password string) error {
+	// Генерируем ключ из пароля
+	key := s.deriveKey(password)
+
 	// Создаем AES cipher
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -56,12 +112,12 @@ func (s *Service) encryptFile(ctx context.Context, inputPath, outputPath, passwo
 		return fmt.Errorf("ошибка генерации nonce: %w", err)
 	}
 
-	// Записываем nonce в начало файла
-	if _, err := outputFile.Write(nonce); err != nil {
+	// Записываем nonce в начало потока
+	if _, err := w.Write(nonce); err != nil {
 		return fmt.Errorf("ошибка записи nonce: %w", err)
 	}
 
-	// Шифруем файл блоками
+	// Шифруем поток блоками
 	buffer := make([]byte, 64*1024) // 64KB блоки
 	for {
 		// Проверяем контекст на отмену
@@ -71,9 +127,9 @@ func (s *Service) encryptFile(ctx context.Context, inputPath, outputPath, passwo
 		default:
 		}
 
-		n, err := inputFile.Read(buffer)
-		if err != nil && err != io.EOF {
-			return fmt.Errorf("ошибка чтения входного файла: %w", err)
+		n, err := io.ReadFull(r, buffer)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("ошибка чтения входного потока: %w", err)
 		}
 
 		if n == 0 {
@@ -84,7 +140,7 @@ func (s *Service) encryptFile(ctx context.Context, inputPath, outputPath, passwo
 		ciphertext := gcm.Seal(nil, nonce, buffer[:n], nil)
 
 		// Записываем зашифрованный блок
-		if _, err := outputFile.Write(ciphertext); err != nil {
+		if _, err := w.Write(ciphertext); err != nil {
 			return fmt.Errorf("ошибка записи зашифрованного блока: %w", err)
 		}
 
@@ -92,36 +148,14 @@ func (s *Service) encryptFile(ctx context.Context, inputPath, outputPath, passwo
 		s.incrementNonce(nonce)
 	}
 
-	s.logger.InfoContext(ctx, "Файл зашифрован успешно",
-		"input", inputPath,
-		"output", outputPath)
-
 	return nil
 }
 
-// decryptFile расшифровывает файл
-func (s *Service) decryptFile(ctx context.Context, inputPath, outputPath, password string) error {
+// decryptStream расшифровывает поток, зашифрованный encryptStream
+func (s *Service) decryptStream(ctx context.Context, r io.Reader, w io.Writer, password string) error {
 	// Генерируем ключ из пароля
 	key := s.deriveKey(password)
 
-	// Открываем зашифрованный файл
-	inputFile, err := os.Open(inputPath)
-	if err != nil {
-		return fmt.Errorf("ошибка открытия зашифрованного файла: %w", err)
-	}
-	defer inputFile.Close()
-
-	// Создаем выходной файл
-	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-		return fmt.Errorf("ошибка создания директории: %w", err)
-	}
-
-	outputFile, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("ошибка создания выходного файла: %w", err)
-	}
-	defer outputFile.Close()
-
 	// Создаем AES cipher
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -134,13 +168,13 @@ func (s *Service) decryptFile(ctx context.Context, inputPath, outputPath, passwo
 		return fmt.Errorf("ошибка создания GCM mode: %w", err)
 	}
 
-	// Читаем nonce из начала файла
+	// Читаем nonce из начала потока
 	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(inputFile, nonce); err != nil {
+	if _, err := io.ReadFull(r, nonce); err != nil {
 		return fmt.Errorf("ошибка чтения nonce: %w", err)
 	}
 
-	// Расшифровываем файл блоками
+	// Расшифровываем поток блоками
 	// Размер зашифрованного блока = размер исходного блока + размер tag (16 байт для GCM)
 	encryptedBlockSize := 64*1024 + gcm.Overhead()
 	buffer := make([]byte, encryptedBlockSize)
@@ -153,9 +187,9 @@ func (s *Service) decryptFile(ctx context.Context, inputPath, outputPath, passwo
 		default:
 		}
 
-		n, err := inputFile.Read(buffer)
-		if err != nil && err != io.EOF {
-			return fmt.Errorf("ошибка чтения зашифрованного файла: %w", err)
+		n, err := io.ReadFull(r, buffer)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("ошибка чтения зашифрованного потока: %w", err)
 		}
 
 		if n == 0 {
@@ -169,7 +203,7 @@ func (s *Service) decryptFile(ctx context.Context, inputPath, outputPath, passwo
 		}
 
 		// Записываем расшифрованный блок
-		if _, err := outputFile.Write(plaintext); err != nil {
+		if _, err := w.Write(plaintext); err != nil {
 			return fmt.Errorf("ошибка записи расшифрованного блока: %w", err)
 		}
 
@@ -177,10 +211,6 @@ func (s *Service) decryptFile(ctx context.Context, inputPath, outputPath, passwo
 		s.incrementNonce(nonce)
 	}
 
-	s.logger.InfoContext(ctx, "Файл расшифрован успешно",
-		"input", inputPath,
-		"output", outputPath)
-
 	return nil
 }
 
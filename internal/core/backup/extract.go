@@ -0,0 +1,307 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// entryFilter выбирает записи архива для извлечения
+//
+// Возвращает путь записи относительно директории назначения и false,
+// если запись пропускается.
+type entryFilter func(name string) (string, bool)
+
+// extractor извлекает записи tar.gz архива в директорию назначения
+//
+// Каждый файл записывается во временный файл рядом с целевым и
+// переименовывается поверх него, поэтому прерванное восстановление
+// не оставляет недописанных файлов.
+type extractor struct {
+	service  *Service
+	destPath string
+	filter   entryFilter  // nil - все записи по их путям в архиве
+	conflict ConflictMode // Пусто - ConflictOverwrite
+	dryRun   bool         // Только собрать изменения в result.Changes
+
+	// snapshotDir директория, в которую перед заменой сохраняются
+	// существующие файлы; пусто - не сохранять
+	snapshotDir string
+
+	result *RestoreResult
+}
+
+// extract извлекает записи архива из потока
+func (ex *extractor) extract(ctx context.Context, r io.Reader) error {
+	// Создаем gzip reader
+	gzReader, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("ошибка создания gzip reader: %w", err)
+	}
+	defer gzReader.Close()
+
+	// Создаем tar reader
+	tarReader := tar.NewReader(gzReader)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка чтения заголовка tar: %w", err)
+		}
+
+		// Проверяем контекст на отмену
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		name := header.Name
+		if ex.filter != nil {
+			var ok bool
+			if name, ok = ex.filter(header.Name); !ok {
+				continue
+			}
+		}
+
+		// Создаем полный путь для извлечения
+		fullPath := filepath.Join(ex.destPath, name)
+
+		// Проверяем на попытку выхода за пределы целевой директории (zip slip)
+		if !strings.HasPrefix(fullPath, filepath.Clean(ex.destPath)+string(os.PathSeparator)) {
+			return fmt.Errorf("небезопасный путь в архиве: %s", header.Name)
+		}
+		if err := ex.checkParent(fullPath); err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if ex.dryRun {
+				continue
+			}
+			if err := os.MkdirAll(fullPath, os.FileMode(header.Mode)); err != nil {
+				return fmt.Errorf("ошибка создания директории %s: %w", fullPath, err)
+			}
+
+		case tar.TypeReg:
+			change, err := ex.plan(fullPath, header.Size, header.ModTime)
+			if err != nil {
+				return err
+			}
+			if ex.dryRun || change.Action == RestoreActionSkip {
+				continue
+			}
+			if err := ex.writeFile(ctx, change, tarReader, header); err != nil {
+				return err
+			}
+
+		default:
+			ex.service.logger.WarnContext(ctx, "Неподдерживаемый тип файла в архиве",
+				"type", header.Typeflag,
+				"name", header.Name)
+		}
+	}
+
+	return nil
+}
+
+// checkParent проверяет, что директория файла fullPath после разрешения
+// символических ссылок остается внутри директории назначения
+//
+// Сам архив символических ссылок не создает, но они могут уже быть в
+// директории назначения и вести за ее пределы.
+func (ex *extractor) checkParent(fullPath string) error {
+	root, err := resolveExisting(ex.destPath)
+	if err != nil {
+		return err
+	}
+	dir, err := resolveExisting(filepath.Dir(fullPath))
+	if err != nil {
+		return err
+	}
+
+	if !withinDir(root, dir) {
+		return fmt.Errorf("путь %s ведет за пределы директории восстановления через символическую ссылку", fullPath)
+	}
+	return nil
+}
+
+// withinDir проверяет, что очищенный путь path совпадает с dir или находится внутри нее
+func withinDir(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(os.PathSeparator))+string(os.PathSeparator))
+}
+
+// resolveExisting разрешает символические ссылки в существующей части
+// пути; несуществующий остаток добавляется как есть
+func resolveExisting(path string) (string, error) {
+	path = filepath.Clean(path)
+
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("ошибка разрешения пути %s: %w", path, err)
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", fmt.Errorf("ошибка разрешения пути %s: %w", path, err)
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
+	}
+}
+
+// plan определяет, что восстановление сделает с файлом fullPath, и
+// учитывает это в результате
+func (ex *extractor) plan(fullPath string, size int64, modTime time.Time) (RestoreChange, error) {
+	rel, err := filepath.Rel(ex.destPath, fullPath)
+	if err != nil {
+		return RestoreChange{}, fmt.Errorf("ошибка вычисления относительного пути: %w", err)
+	}
+
+	change := RestoreChange{Path: filepath.ToSlash(rel), Action: RestoreActionCreate, Size: size}
+
+	info, err := os.Lstat(fullPath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return change, fmt.Errorf("ошибка проверки файла %s: %w", fullPath, err)
+	case ex.conflict == ConflictSkip:
+		change.Action = RestoreActionSkip
+	case ex.conflict == ConflictOverwriteNewer && !modTime.After(info.ModTime()):
+		change.Action = RestoreActionSkip
+	case ex.conflict == ConflictRename:
+		change.Action = RestoreActionRename
+		change.NewPath = filepath.ToSlash(renamedPath(rel, ex.destPath))
+	case info.IsDir():
+		return change, fmt.Errorf("на месте файла %s находится директория", fullPath)
+	default:
+		change.Action = RestoreActionOverwrite
+	}
+
+	result := ex.result
+	switch change.Action {
+	case RestoreActionSkip:
+		result.Skipped++
+	case RestoreActionOverwrite:
+		result.Overwritten++
+	case RestoreActionRename:
+		result.Renamed++
+	}
+	if change.Action != RestoreActionSkip {
+		result.Files++
+		result.Bytes += size
+	}
+	if ex.dryRun {
+		result.Changes = append(result.Changes, change)
+	}
+
+	return change, nil
+}
+
+// writeFile записывает файл из архива через временный файл и
+// переименовывает его на место целевого
+func (ex *extractor) writeFile(ctx context.Context, change RestoreChange, r io.Reader, header *tar.Header) error {
+	targetPath := filepath.Join(ex.destPath, filepath.FromSlash(change.Path))
+	if change.Action == RestoreActionRename {
+		targetPath = filepath.Join(ex.destPath, filepath.FromSlash(change.NewPath))
+	}
+
+	// Создаем директорию для файла
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return fmt.Errorf("ошибка создания директории для файла %s: %w", targetPath, err)
+	}
+
+	if change.Action == RestoreActionOverwrite && ex.snapshotDir != "" {
+		if err := ex.snapshot(ctx, change.Path); err != nil {
+			return err
+		}
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(targetPath), "."+filepath.Base(targetPath)+".backupist-*")
+	if err != nil {
+		return fmt.Errorf("ошибка создания файла %s: %w", targetPath, err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := io.Copy(tempFile, r); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("ошибка записи файла %s: %w", targetPath, err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("ошибка записи файла %s: %w", targetPath, err)
+	}
+
+	// Права временного файла - 0600, поэтому выставляем их явно
+	if err := os.Chmod(tempFile.Name(), os.FileMode(header.Mode).Perm()); err != nil {
+		return fmt.Errorf("ошибка установки прав файла %s: %w", targetPath, err)
+	}
+	if err := os.Chtimes(tempFile.Name(), header.ModTime, header.ModTime); err != nil {
+		return fmt.Errorf("ошибка установки времени изменения файла %s: %w", targetPath, err)
+	}
+
+	if err := os.Rename(tempFile.Name(), targetPath); err != nil {
+		return fmt.Errorf("ошибка замены файла %s: %w", targetPath, err)
+	}
+
+	return nil
+}
+
+// snapshot сохраняет существующий файл в директорию снимка перед заменой
+//
+// Файл связывается жесткой ссылкой: замена переименованием оставляет
+// прежнее содержимое снимку. Если ссылку создать нельзя (другая
+// файловая система), файл копируется.
+func (ex *extractor) snapshot(ctx context.Context, rel string) error {
+	sourcePath := filepath.Join(ex.destPath, filepath.FromSlash(rel))
+	snapshotPath := filepath.Join(ex.snapshotDir, filepath.FromSlash(rel))
+
+	if err := os.MkdirAll(filepath.Dir(snapshotPath), 0700); err != nil {
+		return fmt.Errorf("ошибка создания директории снимка: %w", err)
+	}
+
+	if err := os.Link(sourcePath, snapshotPath); err != nil {
+		if _, err := ex.service.copyFile(sourcePath, snapshotPath); err != nil {
+			return fmt.Errorf("ошибка сохранения файла %s в снимок: %w", sourcePath, err)
+		}
+	}
+
+	if ex.result.SnapshotDir == "" {
+		ex.result.SnapshotDir = ex.snapshotDir
+		ex.service.logger.InfoContext(ctx, "Заменяемые файлы сохраняются в снимок",
+			"snapshot_dir", ex.snapshotDir)
+	}
+
+	return nil
+}
+
+// renamedPath возвращает свободное имя для файла, восстанавливаемого
+// рядом с существующим: docs/report.restored.xlsx, docs/report.restored-2.xlsx
+func renamedPath(rel, destPath string) string {
+	ext := filepath.Ext(rel)
+	base := strings.TrimSuffix(rel, ext)
+
+	for i := 1; ; i++ {
+		candidate := base + ".restored" + ext
+		if i > 1 {
+			candidate = fmt.Sprintf("%s.restored-%d%s", base, i, ext)
+		}
+		if _, err := os.Lstat(filepath.Join(destPath, candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
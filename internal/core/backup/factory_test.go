@@ -0,0 +1,31 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPluginNotLookedUpInPath(t *testing.T) {
+	// Исполняемый файл с именем плагина в PATH оставляет след при запуске
+	bin := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "started")
+	script := "#!/bin/sh\ntouch " + marker + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "backupist-storage-evil"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	factory := NewStorageFactory(config.NewConfig())
+	t.Cleanup(func() { factory.Close() })
+
+	_, err := factory.CreateStorage("evil://bucket/daily")
+	if err == nil || !strings.Contains(err.Error(), "неподдерживаемая схема") {
+		t.Fatalf("хранилище со схемой без плагина в конфигурации: %v", err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatal("запущен исполняемый файл из PATH")
+	}
+}
@@ -0,0 +1,156 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileVersion версия файла в одном из бэкапов
+type FileVersion struct {
+	SnapshotFile
+	Changed bool // Первая версия в политике, отличается от предыдущей или файла не было в предыдущем бэкапе
+}
+
+// DiffKind вид изменения файла между двумя бэкапами
+type DiffKind string
+
+const (
+	DiffAdded    DiffKind = "added"
+	DiffRemoved  DiffKind = "removed"
+	DiffModified DiffKind = "modified"
+)
+
+// FileDiff изменение файла между двумя бэкапами
+type FileDiff struct {
+	Path string
+	Kind DiffKind
+	Old  *types.BackupFile // nil для добавленного файла
+	New  *types.BackupFile // nil для удаленного файла
+}
+
+// FileVersions возвращает версии файла во всех завершенных бэкапах
+//
+// Файл задается исходным путем (/etc/nginx/nginx.conf) или путем
+// относительно исходной директории политики. Версии упорядочены по
+// времени бэкапа; Changed отмечает версии, содержимое которых
+// отличается от предыдущей версии той же политики, и версии,
+// появившиеся снова после бэкапа без этого файла. Индекс бэкапов
+// без него строится перед поиском.
+func (s *Service) FileVersions(ctx context.Context, policyID, filePath string) ([]FileVersion, error) {
+	filePath = strings.TrimSpace(filePath)
+	if filePath == "" {
+		return nil, fmt.Errorf("не указан путь файла")
+	}
+
+	// Версии из проиндексированных бэкапов возвращаются и вместе с
+	// *UnindexedError
+	var unindexed *UnindexedError
+	if err := s.indexSnapshots(ctx, policyID); err != nil && !errors.As(err, &unindexed) {
+		return nil, err
+	}
+
+	files, err := s.getSnapshotFiles(ctx, policyID, filepath.Clean(filePath))
+	if err != nil {
+		return nil, err
+	}
+
+	// Номера бэкапов в своей политике по времени: файл, которого не было
+	// в предыдущем бэкапе, отмечается измененным. Бэкапы без индекса не
+	// учитываются - неизвестно, был ли в них файл
+	jobs, _, err := s.listBackupJobs(ctx, JobFilter{PolicyID: policyID, Status: types.JobStatusCompleted, Backups: true})
+	if err != nil {
+		return nil, err
+	}
+	number := make(map[string]int, len(jobs))
+	count := make(map[string]int)
+	for _, job := range slices.Backward(jobs) {
+		if unindexed != nil && slices.Contains(unindexed.JobIDs, job.ID) {
+			continue
+		}
+		number[job.ID] = count[job.PolicyID]
+		count[job.PolicyID]++
+	}
+
+	type previousVersion struct {
+		file   types.BackupFile
+		number int
+	}
+
+	versions := make([]FileVersion, 0, len(files))
+	previous := make(map[string]previousVersion)
+	for _, file := range files {
+		last, seen := previous[file.PolicyID]
+		versions = append(versions, FileVersion{
+			SnapshotFile: file,
+			Changed:      !seen || number[file.JobID] != last.number+1 || fileChanged(last.file, file.BackupFile),
+		})
+		previous[file.PolicyID] = previousVersion{file: file.BackupFile, number: number[file.JobID]}
+	}
+
+	if unindexed != nil {
+		return versions, unindexed
+	}
+	return versions, nil
+}
+
+// DiffBackups сравнивает файлы двух бэкапов по индексу из каталога
+//
+// Возвращает добавленные в jobB, удаленные из jobA и измененные
+// файлы, упорядоченные по пути.
+func (s *Service) DiffBackups(ctx context.Context, jobA, jobB string) ([]FileDiff, error) {
+	oldFiles, err := s.backupIndex(ctx, jobA)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := s.backupIndex(ctx, jobB)
+	if err != nil {
+		return nil, err
+	}
+
+	old := make(map[string]*types.BackupFile, len(oldFiles))
+	for i := range oldFiles {
+		old[oldFiles[i].Path] = &oldFiles[i]
+	}
+
+	var diff []FileDiff
+	for i := range newFiles {
+		file := &newFiles[i]
+		previous, ok := old[file.Path]
+		switch {
+		case !ok:
+			diff = append(diff, FileDiff{Path: file.Path, Kind: DiffAdded, New: file})
+		case fileChanged(*previous, *file):
+			diff = append(diff, FileDiff{Path: file.Path, Kind: DiffModified, Old: previous, New: file})
+		}
+		delete(old, file.Path)
+	}
+	for _, file := range old {
+		diff = append(diff, FileDiff{Path: file.Path, Kind: DiffRemoved, Old: file})
+	}
+
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Path < diff[j].Path })
+
+	return diff, nil
+}
+
+// fileChanged сравнивает две версии файла: по SHA-256, если он есть
+// у обеих, иначе по размеру и времени изменения; учитываются и права
+//
+// Время сравнивается с точностью до секунды: в индексе, построенном по
+// заголовкам архива, оно хранится с такой точностью.
+func fileChanged(a, b types.BackupFile) bool {
+	if a.Mode != b.Mode || a.Size != b.Size {
+		return true
+	}
+	if a.Checksum != "" && b.Checksum != "" {
+		return a.Checksum != b.Checksum
+	}
+	return !a.ModTime.Truncate(time.Second).Equal(b.ModTime.Truncate(time.Second))
+}
@@ -0,0 +1,169 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// nextTestBackup выполняет очередной бэкап политики; имя бэкапа задается
+// с точностью до секунды, поэтому бэкапы разделены паузой
+func nextTestBackup(t *testing.T, service *Service, policyID string) *types.BackupJob {
+	t.Helper()
+
+	time.Sleep(1100 * time.Millisecond)
+	return runTestBackup(t, service, policyID)
+}
+
+// diffSummary возвращает изменения в виде "вид:путь"
+func diffSummary(diff []FileDiff) []string {
+	var list []string
+	for _, d := range diff {
+		list = append(list, string(d.Kind)+":"+d.Path)
+	}
+	return list
+}
+
+func TestDiffBackups(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "diff", map[string]string{
+		"same.txt":    "same",
+		"changed.txt": "before",
+		"touched.txt": "abcd",
+		"removed.txt": "gone",
+	}, nil)
+	first := runTestBackup(t, service, policy.ID)
+
+	// touched.txt меняется без изменения размера и времени изменения
+	touched := filepath.Join(policy.SourcePath, "touched.txt")
+	info, err := os.Stat(touched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, touched, "dcba")
+	if err := os.Chtimes(touched, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(policy.SourcePath, "changed.txt"), "after, longer")
+	writeTestFile(t, filepath.Join(policy.SourcePath, "dir", "added.txt"), "new")
+	if err := os.Remove(filepath.Join(policy.SourcePath, "removed.txt")); err != nil {
+		t.Fatal(err)
+	}
+	second := nextTestBackup(t, service, policy.ID)
+	ctx := context.Background()
+
+	diff, err := service.DiffBackups(ctx, first.ID, second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"modified:changed.txt", "added:dir/added.txt", "removed:removed.txt", "modified:touched.txt"}
+	if !slices.Equal(diffSummary(diff), want) {
+		t.Fatalf("изменения: %v", diffSummary(diff))
+	}
+	for _, d := range diff {
+		if (d.Kind == DiffAdded) != (d.Old == nil) || (d.Kind == DiffRemoved) != (d.New == nil) {
+			t.Fatalf("версии изменения %s: %+v", d.Path, d)
+		}
+	}
+
+	// Без SHA-256 в индексе файлы сравниваются по размеру и времени
+	// изменения: изменение touched.txt с прежними размером и временем не видно
+	if _, err := service.db.Exec(`UPDATE backup_files SET checksum = NULL`); err != nil {
+		t.Fatal(err)
+	}
+	diff, err = service.DiffBackups(ctx, first.ID, second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"modified:changed.txt", "added:dir/added.txt", "removed:removed.txt"}; !slices.Equal(diffSummary(diff), want) {
+		t.Fatalf("изменения без SHA-256: %v", diffSummary(diff))
+	}
+
+	// Обратное сравнение меняет добавленные и удаленные местами
+	diff, err = service.DiffBackups(ctx, second.ID, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"modified:changed.txt", "removed:dir/added.txt", "added:removed.txt"}; !slices.Equal(diffSummary(diff), want) {
+		t.Fatalf("обратные изменения: %v", diffSummary(diff))
+	}
+}
+
+func TestFileChanged(t *testing.T) {
+	modTime := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	base := types.BackupFile{Size: 4, Mode: 0644, ModTime: modTime, Checksum: "aa"}
+
+	for name, tt := range map[string]struct {
+		other   func(*types.BackupFile)
+		changed bool
+	}{
+		"та же версия":                    {func(*types.BackupFile) {}, false},
+		"другая SHA-256":                  {func(f *types.BackupFile) { f.Checksum = "bb" }, true},
+		"SHA-256 важнее времени":          {func(f *types.BackupFile) { f.ModTime = modTime.Add(time.Hour) }, false},
+		"другой размер":                   {func(f *types.BackupFile) { f.Size = 5 }, true},
+		"другие права":                    {func(f *types.BackupFile) { f.Mode = 0600 }, true},
+		"без SHA-256, то же время":        {func(f *types.BackupFile) { f.Checksum = "" }, false},
+		"без SHA-256, другое время":       {func(f *types.BackupFile) { f.Checksum = ""; f.ModTime = modTime.Add(time.Second) }, true},
+		"без SHA-256, доли секунды":       {func(f *types.BackupFile) { f.Checksum = ""; f.ModTime = modTime.Add(time.Millisecond) }, false},
+		"без SHA-256 у одной из версий":   {func(f *types.BackupFile) { f.Checksum = ""; f.ModTime = modTime.Add(time.Minute) }, true},
+		"без SHA-256 при разных размерах": {func(f *types.BackupFile) { f.Checksum = ""; f.Size = 3 }, true},
+	} {
+		other := base
+		tt.other(&other)
+		if got := fileChanged(base, other); got != tt.changed {
+			t.Fatalf("%s: fileChanged = %v", name, got)
+		}
+	}
+}
+
+func TestFileVersions(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "versions", map[string]string{"keep.txt": "keep", "a.txt": "one"}, nil)
+	target := filepath.Join(policy.SourcePath, "a.txt")
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Версии a.txt: исходная, без изменений, удален, появился снова
+	// с тем же содержимым и временем, изменен
+	jobs := []*types.BackupJob{runTestBackup(t, service, policy.ID)}
+	jobs = append(jobs, nextTestBackup(t, service, policy.ID))
+	if err := os.Remove(target); err != nil {
+		t.Fatal(err)
+	}
+	nextTestBackup(t, service, policy.ID)
+	writeTestFile(t, target, "one")
+	if err := os.Chtimes(target, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	jobs = append(jobs, nextTestBackup(t, service, policy.ID))
+	writeTestFile(t, target, "two")
+	jobs = append(jobs, nextTestBackup(t, service, policy.ID))
+
+	for _, filePath := range []string{"a.txt", target} {
+		versions, err := service.FileVersions(context.Background(), policy.ID, filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var jobIDs []string
+		var changed []bool
+		for _, version := range versions {
+			jobIDs = append(jobIDs, version.JobID)
+			changed = append(changed, version.Changed)
+		}
+		want := []string{jobs[0].ID, jobs[1].ID, jobs[2].ID, jobs[3].ID}
+		if !slices.Equal(jobIDs, want) || !slices.Equal(changed, []bool{true, false, true, true}) {
+			t.Fatalf("версии %s: задачи %v, изменены %v", filePath, jobIDs, changed)
+		}
+	}
+
+	if versions, err := service.FileVersions(context.Background(), policy.ID, "missing.txt"); err != nil || len(versions) != 0 {
+		t.Fatalf("версии несуществующего файла: %v, %v", versions, err)
+	}
+}
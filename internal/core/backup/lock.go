@@ -0,0 +1,292 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+const (
+	// policyLockLease срок аренды блокировки политики в каталоге; задача
+	// продлевает аренду, пока выполняется
+	policyLockLease = 2 * time.Minute
+
+	// policyLockRenewInterval как часто задача продлевает аренду
+	policyLockRenewInterval = policyLockLease / 4
+
+	// policyLockRetryInterval как часто ожидающий запуск проверяет,
+	// освободилась ли блокировка (режимы queue и cancel-previous)
+	policyLockRetryInterval = time.Second
+)
+
+// policyLease аренда блокировки политики в каталоге
+type policyLease struct {
+	PolicyID   string
+	JobID      string
+	Owner      string // hostname:pid процесса, выполняющего задачу
+	AcquiredAt time.Time
+	ExpiresAt  time.Time
+}
+
+// policyLock блокировка политики, удерживаемая выполняющейся задачей
+//
+// Состоит из файловой блокировки (flock), которая освобождается ядром при
+// любом завершении процесса, и аренды в каталоге, по которой другие
+// процессы узнают, какая задача удерживает политику.
+type policyLock struct {
+	service *Service
+	file    *flock.Flock
+	lease   policyLease
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// lockPolicy захватывает блокировку политики для задачи job
+//
+// Если политику удерживает предыдущий запуск, поведение определяется
+// policy.Overlap: skip - запуск отменяется, queue - ждет освобождения
+// блокировки, cancel-previous - отменяет предыдущий запуск и ждет его
+// завершения. Пока задача удерживает блокировку, аренда продлевается; если
+// аренду перехватили, контекст задачи отменяется.
+func (s *Service) lockPolicy(ctx context.Context, policy *types.BackupPolicy, job *types.BackupJob) (*policyLock, error) {
+	var waiting, cancelled bool
+
+	for {
+		lock, holder, err := s.tryLockPolicy(ctx, policy.ID, job.ID)
+		if err != nil {
+			return nil, err
+		}
+		if lock != nil {
+			lock.keep(ctx)
+			return lock, nil
+		}
+
+		switch policy.Overlap {
+		case types.OverlapQueue:
+		case types.OverlapCancelPrevious:
+			if !cancelled && holder != "" {
+				cancelled = true
+				s.logger.InfoContext(ctx, "Отмена предыдущего запуска политики",
+					"policy_id", policy.ID,
+					"job_id", job.ID,
+					"previous_job_id", holder)
+				if _, err := s.CancelJob(ctx, holder, "отменена новым запуском политики (задача "+job.ID+")"); err != nil && !errors.Is(err, ErrConflict) && !errors.Is(err, ErrNotFound) {
+					return nil, fmt.Errorf("ошибка отмены предыдущего запуска политики: %w", err)
+				}
+			}
+		default:
+			return nil, CancelCause(fmt.Sprintf("пропущена: предыдущий запуск политики еще выполняется (задача %s)", holder))
+		}
+
+		if !waiting {
+			waiting = true
+			s.logger.InfoContext(ctx, "Ожидание завершения предыдущего запуска политики",
+				"policy_id", policy.ID,
+				"job_id", job.ID,
+				"previous_job_id", holder)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, cancelError(ctx)
+		case <-time.After(policyLockRetryInterval):
+		}
+	}
+}
+
+// tryLockPolicy пытается захватить блокировку политики без ожидания
+//
+// Если политика занята, возвращает nil и ID удерживающей ее задачи (пустой,
+// если аренда еще не записана). Аренда, оставшаяся от процесса, который
+// завершился, не освободив блокировку, перехватывается, а ее задача
+// переводится в статус failed.
+func (s *Service) tryLockPolicy(ctx context.Context, policyID, jobID string) (*policyLock, string, error) {
+	dir := s.policyLocksDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, "", fmt.Errorf("ошибка создания директории блокировок: %w", err)
+	}
+
+	file := flock.New(filepath.Join(dir, policyID+".lock"))
+	locked, err := file.TryLock()
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка блокировки политики: %w", err)
+	}
+
+	previous, err := s.getPolicyLock(ctx, policyID)
+	if err != nil {
+		if locked {
+			file.Close()
+		}
+		return nil, "", err
+	}
+
+	owner := lockOwner()
+	now := time.Now()
+
+	if !locked {
+		if previous == nil {
+			return nil, "", nil
+		}
+		return nil, previous.JobID, nil
+	}
+
+	// Файл свободен, но действующую аренду держит процесс на другом
+	// хосте, который не видит наш flock
+	if previous != nil && previous.ExpiresAt.After(now) && !sameHost(previous.Owner, owner) {
+		file.Close()
+		return nil, previous.JobID, nil
+	}
+
+	lock := &policyLock{
+		service: s,
+		file:    file,
+		lease: policyLease{
+			PolicyID:   policyID,
+			JobID:      jobID,
+			Owner:      owner,
+			AcquiredAt: now,
+			ExpiresAt:  now.Add(policyLockLease),
+		},
+		stop: make(chan struct{}),
+	}
+	if err := s.savePolicyLock(ctx, &lock.lease); err != nil {
+		file.Close()
+		return nil, "", err
+	}
+
+	if previous != nil && previous.JobID != jobID {
+		s.logger.WarnContext(ctx, "Перехвачена блокировка политики, не освобожденная завершившимся процессом",
+			"policy_id", policyID,
+			"previous_job_id", previous.JobID,
+			"previous_owner", previous.Owner,
+			"expires_at", previous.ExpiresAt)
+		s.failStaleJob(ctx, previous)
+	}
+
+	return lock, "", nil
+}
+
+// failStaleJob переводит в статус failed незавершенную задачу, процесс
+// которой завершился, не освободив блокировку политики
+func (s *Service) failStaleJob(ctx context.Context, lease *policyLease) {
+	job, err := s.getBackupJob(ctx, lease.JobID)
+	if err != nil || JobFinished(job.Status) {
+		return
+	}
+
+	completedAt := time.Now()
+	job.Status = types.JobStatusFailed
+	job.Error = fmt.Sprintf("процесс %s завершился, не освободив блокировку политики", lease.Owner)
+	job.CompletedAt = &completedAt
+
+	if err := s.saveBackupJob(ctx, job); err != nil {
+		s.logger.WarnContext(ctx, "Ошибка сохранения зависшей задачи", "job_id", job.ID, "error", err.Error())
+		return
+	}
+	s.publishStatus(job)
+}
+
+// keep продлевает аренду, пока блокировка не освобождена
+//
+// Если аренду перехватил другой процесс (например, задача не могла продлить
+// ее дольше срока аренды), выполнение задачи отменяется: две задачи одной
+// политики не должны выполняться одновременно.
+func (l *policyLock) keep(ctx context.Context) {
+	l.done.Add(1)
+	go func() {
+		defer l.done.Done()
+
+		ticker := time.NewTicker(policyLockRenewInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			ok, err := l.service.renewPolicyLock(ctx, l.lease.PolicyID, l.lease.JobID, time.Now().Add(policyLockLease))
+			if err != nil {
+				if ctx.Err() == nil {
+					l.service.logger.WarnContext(ctx, "Ошибка продления блокировки политики",
+						"policy_id", l.lease.PolicyID,
+						"job_id", l.lease.JobID,
+						"error", err.Error())
+				}
+				continue
+			}
+			if !ok {
+				l.service.cancelRunning(l.lease.JobID, CancelCause("потеряна блокировка политики"))
+				return
+			}
+		}
+	}()
+}
+
+// release освобождает блокировку политики
+func (l *policyLock) release(ctx context.Context) {
+	close(l.stop)
+	l.done.Wait()
+
+	ctx = context.WithoutCancel(ctx)
+	if err := l.service.deletePolicyLock(ctx, l.lease.PolicyID, l.lease.JobID); err != nil {
+		l.service.logger.WarnContext(ctx, "Ошибка освобождения блокировки политики",
+			"policy_id", l.lease.PolicyID,
+			"job_id", l.lease.JobID,
+			"error", err.Error())
+	}
+	if err := l.file.Close(); err != nil {
+		l.service.logger.WarnContext(ctx, "Ошибка снятия файловой блокировки политики",
+			"path", l.file.Path(),
+			"error", err.Error())
+	}
+}
+
+// cancelRunning отменяет задачу, выполняющуюся в этом процессе
+func (s *Service) cancelRunning(jobID string, cause error) {
+	s.jobsMu.Lock()
+	cancel, running := s.running[jobID]
+	s.jobsMu.Unlock()
+
+	if running {
+		cancel(cause)
+	}
+}
+
+// policyLocksDir возвращает директорию файлов блокировок политик: рядом с
+// базой данных, чтобы ее видели все процессы, работающие с каталогом
+func (s *Service) policyLocksDir() string {
+	path := s.config.Database.Path
+	if path == "" || path == ":memory:" {
+		return filepath.Join(os.TempDir(), "backupist-locks")
+	}
+	return filepath.Join(filepath.Dir(path), "locks")
+}
+
+// lockOwner возвращает идентификатор процесса-владельца блокировки
+func lockOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}
+
+// sameHost проверяет, что владельцы блокировки работают на одном хосте
+func sameHost(a, b string) bool {
+	hostA, _, _ := strings.Cut(a, ":")
+	hostB, _, _ := strings.Cut(b, ":")
+	return hostA == hostB
+}
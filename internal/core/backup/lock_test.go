@@ -0,0 +1,226 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// holdPolicyLock захватывает блокировку политики для новой задачи, как
+// выполняющийся запуск; возвращает задачу и функцию освобождения,
+// которая вызывается и в конце теста
+func holdPolicyLock(t *testing.T, service *Service, policyID string) (*types.BackupJob, func()) {
+	t.Helper()
+	ctx := context.Background()
+
+	job, err := service.CreatePolicyJob(ctx, policyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, holder, err := service.tryLockPolicy(ctx, policyID, job.ID)
+	if err != nil || lock == nil {
+		t.Fatalf("блокировка свободной политики: %v, занята задачей %q", err, holder)
+	}
+
+	var once sync.Once
+	release := func() { once.Do(func() { lock.release(ctx) }) }
+	t.Cleanup(release)
+	return job, release
+}
+
+// lockAsync вызывает lockPolicy для новой задачи политики в отдельной
+// горутине; полученная блокировка сразу освобождается
+func lockAsync(t *testing.T, service *Service, policy *types.BackupPolicy) (*types.BackupJob, <-chan error) {
+	t.Helper()
+
+	job, err := service.CreatePolicyJob(context.Background(), policy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		lock, err := service.lockPolicy(context.Background(), policy, job)
+		if err == nil {
+			lock.release(context.Background())
+		}
+		done <- err
+	}()
+	return job, done
+}
+
+// waitLock ждет результата lockPolicy из lockAsync
+func waitLock(t *testing.T, done <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatal("блокировка политики не получена")
+		return nil
+	}
+}
+
+func TestTryLockPolicy(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "try", map[string]string{"a.txt": "alpha"}, nil)
+	ctx := context.Background()
+
+	holder, release := holdPolicyLock(t, service, policy.ID)
+
+	lock, busy, err := service.tryLockPolicy(ctx, policy.ID, "other-job")
+	if err != nil || lock != nil || busy != holder.ID {
+		t.Fatalf("блокировка занятой политики: %v, %v, занята задачей %q", lock, err, busy)
+	}
+
+	release()
+	lock, _, err = service.tryLockPolicy(ctx, policy.ID, "other-job")
+	if err != nil || lock == nil {
+		t.Fatalf("блокировка освобожденной политики: %v", err)
+	}
+	lock.release(ctx)
+}
+
+func TestLockPolicySkip(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "skip", map[string]string{"a.txt": "alpha"}, nil)
+
+	holder, _ := holdPolicyLock(t, service, policy.ID)
+
+	_, done := lockAsync(t, service, policy)
+	if err := waitLock(t, done); !errors.Is(err, ErrCancelled) || !strings.Contains(err.Error(), holder.ID) {
+		t.Fatalf("пересекающийся запуск не пропущен: %v", err)
+	}
+}
+
+func TestLockPolicyQueue(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "queue", map[string]string{"a.txt": "alpha"}, func(policy *types.BackupPolicy) {
+		policy.Overlap = types.OverlapQueue
+	})
+
+	_, release := holdPolicyLock(t, service, policy.ID)
+
+	_, done := lockAsync(t, service, policy)
+	select {
+	case err := <-done:
+		t.Fatalf("запуск в очереди не ждал освобождения блокировки: %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	release()
+	if err := waitLock(t, done); err != nil {
+		t.Fatalf("запуск из очереди: %v", err)
+	}
+}
+
+func TestLockPolicyCancelPrevious(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "cancel-previous", map[string]string{"a.txt": "alpha"}, func(policy *types.BackupPolicy) {
+		policy.Overlap = types.OverlapCancelPrevious
+	})
+
+	holder, release := holdPolicyLock(t, service, policy.ID)
+	holderCtx, finish := service.startJob(context.Background(), holder.ID)
+	defer finish()
+
+	job, done := lockAsync(t, service, policy)
+	select {
+	case <-holderCtx.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("предыдущий запуск не отменен")
+	}
+	if cause := context.Cause(holderCtx); !errors.Is(cause, ErrCancelled) || !strings.Contains(cause.Error(), job.ID) {
+		t.Fatalf("причина отмены предыдущего запуска: %v", cause)
+	}
+
+	// Новый запуск получает блокировку, когда отмененный ее освобождает
+	release()
+	if err := waitLock(t, done); err != nil {
+		t.Fatalf("новый запуск: %v", err)
+	}
+}
+
+func TestStalePolicyLock(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "stale", map[string]string{"a.txt": "alpha"}, nil)
+	ctx := context.Background()
+
+	// Процесс на другом хосте упал посреди бэкапа: задача осталась
+	// running, а аренда блокировки истекла
+	crashed, err := service.CreatePolicyJob(ctx, policy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crashed.Status = types.JobStatusRunning
+	if err := service.saveBackupJob(ctx, crashed); err != nil {
+		t.Fatal(err)
+	}
+	acquired := time.Now().Add(-time.Hour)
+	if err := service.savePolicyLock(ctx, &policyLease{
+		PolicyID:   policy.ID,
+		JobID:      crashed.ID,
+		Owner:      "crashed-host:1234",
+		AcquiredAt: acquired,
+		ExpiresAt:  acquired.Add(policyLockLease),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	runTestBackup(t, service, policy.ID)
+
+	stale, err := service.getBackupJob(ctx, crashed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stale.Status != types.JobStatusFailed || !strings.Contains(stale.Error, "crashed-host:1234") {
+		t.Fatalf("задача упавшего процесса: %+v", stale)
+	}
+
+	lease, err := service.getPolicyLock(ctx, policy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease != nil {
+		t.Fatalf("блокировка политики не освобождена после завершения бэкапа: %+v", lease)
+	}
+}
+
+func TestSchedulerQueuesOneRun(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "scheduled", map[string]string{"a.txt": "alpha"}, func(policy *types.BackupPolicy) {
+		policy.Schedule = "* * * * *"
+		policy.Overlap = types.OverlapQueue
+	})
+	ctx := context.Background()
+
+	// Политику держит запуск из другого процесса, а шаги планировщика
+	// наступают один за другим
+	_, release := holdPolicyLock(t, service, policy.ID)
+	scheduler := NewScheduler(service)
+	now := time.Now().Truncate(time.Minute)
+	for range 3 {
+		scheduler.tick(ctx, now)
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	release()
+	scheduler.wg.Wait()
+
+	jobs, _, err := service.listBackupJobs(ctx, JobFilter{PolicyID: policy.ID, Status: types.JobStatusCompleted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, total, err := service.listBackupJobs(ctx, JobFilter{PolicyID: policy.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Задача удерживающего запуска и одна задача из очереди
+	if len(jobs) != 1 || total != 2 {
+		t.Fatalf("запусков по расписанию завершено %d, всего задач %d", len(jobs), total)
+	}
+}
@@ -0,0 +1,462 @@
+package backup
+
+import (
+	storageapi "backupist/pkg/storage"
+	"backupist/pkg/types"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultResyncInterval период повторной записи пропущенных копий по умолчанию
+const defaultResyncInterval = 5 * time.Minute
+
+// targetUploader хранилище, сообщающее результат записи по каждому вложенному хранилищу
+type targetUploader interface {
+	UploadTargets(ctx context.Context, localPath, remotePath string) ([]types.TargetResult, error)
+}
+
+// MultiTarget хранилище в составе MultiStorage
+type MultiTarget struct {
+	Name    string
+	Storage StorageProvider
+}
+
+// MultiStorage зеркалирует бэкапы в несколько хранилищ
+//
+// Запись выполняется параллельно и считается успешной, если копия записана
+// как минимум в quorum хранилищ. Хранилища, в которые запись не удалась,
+// запоминаются, и копия переносится в них фоновой синхронизацией
+// из хранилища, где она есть.
+type MultiStorage struct {
+	targets []MultiTarget
+	quorum  int
+
+	mu       sync.Mutex
+	missing  map[string]map[string]bool // путь -> хранилища без копии
+	onResync func(types.TargetResult)
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewMultiStorage создает мульти-хранилище
+//
+// Кворум вне диапазона 1..len(targets) означает запись во все хранилища.
+func NewMultiStorage(quorum int, targets ...MultiTarget) *MultiStorage {
+	if quorum < 1 || quorum > len(targets) {
+		quorum = len(targets)
+	}
+
+	return &MultiStorage{
+		targets: targets,
+		quorum:  quorum,
+		missing: make(map[string]map[string]bool),
+		stop:    make(chan struct{}),
+	}
+}
+
+// OnResync задает обработчик, вызываемый после переноса пропущенной копии
+func (ms *MultiStorage) OnResync(handler func(types.TargetResult)) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.onResync = handler
+}
+
+// StartResync запускает фоновую синхронизацию пропущенных копий
+func (ms *MultiStorage) StartResync(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultResyncInterval
+	}
+
+	ms.wg.Add(1)
+	go func() {
+		defer ms.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-ms.stop
+			cancel()
+		}()
+
+		for {
+			select {
+			case <-ms.stop:
+				return
+			case <-ticker.C:
+				ms.Resync(ctx)
+			}
+		}
+	}()
+}
+
+// Close останавливает фоновую синхронизацию
+//
+// Хранилища в составе MultiStorage не закрываются: ими владеет фабрика.
+func (ms *MultiStorage) Close() error {
+	ms.stopOnce.Do(func() { close(ms.stop) })
+	ms.wg.Wait()
+	return nil
+}
+
+// Targets возвращает имена хранилищ в составе MultiStorage
+func (ms *MultiStorage) Targets() []string {
+	names := make([]string, len(ms.targets))
+	for i, target := range ms.targets {
+		names[i] = target.Name
+	}
+	return names
+}
+
+// Upload загружает файл во все хранилища параллельно
+func (ms *MultiStorage) Upload(ctx context.Context, localPath, remotePath string) error {
+	_, err := ms.UploadTargets(ctx, localPath, remotePath)
+	return err
+}
+
+// UploadTargets загружает файл во все хранилища и возвращает результат по каждому
+//
+// Если кворум не достигнут, записанные копии удаляются и возвращается ошибка.
+func (ms *MultiStorage) UploadTargets(ctx context.Context, localPath, remotePath string) ([]types.TargetResult, error) {
+	results := make([]types.TargetResult, len(ms.targets))
+
+	var wg sync.WaitGroup
+	for i, target := range ms.targets {
+		wg.Add(1)
+		go func(i int, target MultiTarget) {
+			defer wg.Done()
+
+			startTime := time.Now()
+			checksum, err := uploadVerified(ctx, target.Storage, localPath, remotePath)
+
+			results[i] = types.TargetResult{
+				Target:     target.Name,
+				RemotePath: remotePath,
+				Status:     types.TargetStatusCompleted,
+				Checksum:   checksum,
+				Duration:   time.Since(startTime),
+				UpdatedAt:  time.Now(),
+			}
+			if err != nil {
+				results[i].Status = types.TargetStatusFailed
+				results[i].Error = err.Error()
+			}
+		}(i, target)
+	}
+	wg.Wait()
+
+	var succeeded []int
+	var failed []string
+	var errs []error
+	for i, result := range results {
+		if result.Status == types.TargetStatusCompleted {
+			succeeded = append(succeeded, i)
+		} else {
+			failed = append(failed, result.Target)
+			errs = append(errs, fmt.Errorf("хранилище %s: %s", result.Target, result.Error))
+		}
+	}
+
+	if len(succeeded) < ms.quorum {
+		// Бэкап не состоялся: удаляем неполный набор копий
+		cleanupCtx := context.WithoutCancel(ctx)
+		for _, i := range succeeded {
+			ms.targets[i].Storage.Delete(cleanupCtx, remotePath)
+		}
+
+		return results, fmt.Errorf("копия записана в %d из %d хранилищ, требуется %d: %w",
+			len(succeeded), len(ms.targets), ms.quorum, errors.Join(errs...))
+	}
+
+	if len(failed) > 0 {
+		ms.mu.Lock()
+		ms.markMissingLocked(remotePath, failed...)
+		ms.mu.Unlock()
+	}
+
+	return results, nil
+}
+
+// Download скачивает файл из первого хранилища, где он доступен
+func (ms *MultiStorage) Download(ctx context.Context, remotePath, localPath string) error {
+	var errs []error
+
+	for _, target := range ms.targets {
+		if ms.isMissing(remotePath, target.Name) {
+			continue
+		}
+
+		err := target.Storage.Download(ctx, remotePath, localPath)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("ошибка скачивания из хранилища %s: %w", target.Name, err))
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if len(errs) == 0 {
+		return fmt.Errorf("файл отсутствует во всех хранилищах: %s", remotePath)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Delete удаляет файл из всех хранилищ, где есть его копия
+func (ms *MultiStorage) Delete(ctx context.Context, remotePath string) error {
+	ms.mu.Lock()
+	missing := ms.missing[remotePath]
+	delete(ms.missing, remotePath)
+	ms.mu.Unlock()
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+
+	for _, target := range ms.targets {
+		if missing[target.Name] {
+			continue
+		}
+
+		wg.Add(1)
+		go func(target MultiTarget) {
+			defer wg.Done()
+
+			if err := target.Storage.Delete(ctx, remotePath); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("ошибка удаления из хранилища %s: %w", target.Name, err))
+				mu.Unlock()
+			}
+		}(target)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// List возвращает объединенный список файлов из всех хранилищ
+//
+// Недоступные хранилища пропускаются; ошибка возвращается,
+// только если не ответило ни одно хранилище.
+func (ms *MultiStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	if len(ms.targets) == 0 {
+		return nil, fmt.Errorf("нет доступных хранилищ")
+	}
+
+	lists := make([][]string, len(ms.targets))
+	listErrs := make([]error, len(ms.targets))
+
+	var wg sync.WaitGroup
+	for i, target := range ms.targets {
+		wg.Add(1)
+		go func(i int, target MultiTarget) {
+			defer wg.Done()
+
+			files, err := target.Storage.List(ctx, prefix)
+			if err != nil {
+				listErrs[i] = fmt.Errorf("ошибка получения списка из хранилища %s: %w", target.Name, err)
+				return
+			}
+			lists[i] = files
+		}(i, target)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	var files []string
+	var errs []error
+	for i := range ms.targets {
+		if listErrs[i] != nil {
+			errs = append(errs, listErrs[i])
+			continue
+		}
+		for _, file := range lists[i] {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+
+	if len(errs) == len(ms.targets) {
+		return nil, errors.Join(errs...)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// Exists проверяет наличие файла хотя бы в одном хранилище
+func (ms *MultiStorage) Exists(ctx context.Context, remotePath string) (bool, error) {
+	var errs []error
+
+	for _, target := range ms.targets {
+		exists, err := target.Storage.Exists(ctx, remotePath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ошибка проверки в хранилище %s: %w", target.Name, err))
+			continue
+		}
+		if exists {
+			return true, nil
+		}
+	}
+
+	// Отсутствие файла подтверждается, только если ответили все хранилища
+	if len(errs) > 0 {
+		return false, errors.Join(errs...)
+	}
+
+	return false, nil
+}
+
+// Resync переносит пропущенные копии в хранилища, где запись не удалась
+func (ms *MultiStorage) Resync(ctx context.Context) error {
+	ms.mu.Lock()
+	pending := make(map[string][]string, len(ms.missing))
+	for remotePath, targets := range ms.missing {
+		for target := range targets {
+			pending[remotePath] = append(pending[remotePath], target)
+		}
+	}
+	handler := ms.onResync
+	ms.mu.Unlock()
+
+	var errs []error
+	for remotePath, targets := range pending {
+		for _, target := range targets {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			startTime := time.Now()
+			if err := ms.Replicate(ctx, remotePath, target); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			if handler != nil {
+				handler(types.TargetResult{
+					Target:     target,
+					RemotePath: remotePath,
+					Status:     types.TargetStatusResynced,
+					Duration:   time.Since(startTime),
+					UpdatedAt:  time.Now(),
+				})
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Replicate копирует файл в хранилище targetName из любого хранилища, где есть копия
+func (ms *MultiStorage) Replicate(ctx context.Context, remotePath, targetName string) error {
+	var destination *MultiTarget
+	for i := range ms.targets {
+		if ms.targets[i].Name == targetName {
+			destination = &ms.targets[i]
+		}
+	}
+	if destination == nil {
+		return fmt.Errorf("хранилище не найдено в составе MultiStorage: %s", targetName)
+	}
+
+	tempFile, err := os.CreateTemp("", "backupist-resync-*")
+	if err != nil {
+		return fmt.Errorf("ошибка создания временного файла: %w", err)
+	}
+	tempPath := tempFile.Name()
+	tempFile.Close()
+	defer os.Remove(tempPath)
+
+	var errs []error
+	for _, source := range ms.targets {
+		if source.Name == targetName || ms.isMissing(remotePath, source.Name) {
+			continue
+		}
+
+		if err := source.Storage.Download(ctx, remotePath, tempPath); err != nil {
+			errs = append(errs, fmt.Errorf("ошибка скачивания из хранилища %s: %w", source.Name, err))
+			continue
+		}
+
+		if _, err := uploadVerified(ctx, destination.Storage, tempPath, remotePath); err != nil {
+			return fmt.Errorf("ошибка записи копии %s в хранилище %s: %w", remotePath, targetName, err)
+		}
+
+		ms.mu.Lock()
+		if targets, ok := ms.missing[remotePath]; ok {
+			delete(targets, targetName)
+			if len(targets) == 0 {
+				delete(ms.missing, remotePath)
+			}
+		}
+		ms.mu.Unlock()
+
+		return nil
+	}
+
+	if len(errs) == 0 {
+		return fmt.Errorf("нет хранилища с копией %s", remotePath)
+	}
+	return fmt.Errorf("ошибка получения копии %s: %w", remotePath, errors.Join(errs...))
+}
+
+// MarkMissing отмечает хранилища, в которых нет копии файла
+//
+// Используется для восстановления очереди синхронизации из каталога.
+func (ms *MultiStorage) MarkMissing(remotePath string, targets ...string) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.markMissingLocked(remotePath, targets...)
+}
+
+func (ms *MultiStorage) markMissingLocked(remotePath string, targets ...string) {
+	if ms.missing[remotePath] == nil {
+		ms.missing[remotePath] = make(map[string]bool)
+	}
+	for _, target := range targets {
+		ms.missing[remotePath][target] = true
+	}
+}
+
+func (ms *MultiStorage) isMissing(remotePath, target string) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.missing[remotePath][target]
+}
+
+// uploadVerified загружает файл в хранилище и проверяет записанный объект
+//
+// Если хранилище не поддерживает проверку, возвращается пустая контрольная
+// сумма. При расхождении объект удаляется, чтобы не оставлять поврежденную копию.
+func uploadVerified(ctx context.Context, storage StorageProvider, localPath, remotePath string) (string, error) {
+	if err := storage.Upload(ctx, localPath, remotePath); err != nil {
+		return "", err
+	}
+
+	checksum, err := verifyUpload(ctx, storage, localPath, remotePath)
+	if errors.Is(err, storageapi.ErrVerifyUnsupported) {
+		return "", nil
+	}
+	if err != nil {
+		storage.Delete(context.WithoutCancel(ctx), remotePath)
+		return "", fmt.Errorf("ошибка проверки записанного бэкапа: %w", err)
+	}
+
+	return checksum, nil
+}
@@ -0,0 +1,75 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// webhookTimeout ограничение времени доставки уведомления одному получателю
+const webhookTimeout = 10 * time.Second
+
+// notify отправляет уведомление всем получателям, подписанным на событие
+//
+// Ошибки доставки только логируются: уведомление не должно влиять
+// на результат операции, о которой оно сообщает.
+func (s *Service) notify(ctx context.Context, notification types.Notification) {
+	if notification.Time.IsZero() {
+		notification.Time = time.Now()
+	}
+
+	body, err := json.Marshal(notification)
+	if err != nil {
+		s.logger.WarnContext(ctx, "Ошибка сериализации уведомления", "event", notification.Event, "error", err.Error())
+		return
+	}
+
+	// Уведомление о прерванной операции тоже нужно доставить
+	ctx = context.WithoutCancel(ctx)
+
+	for _, webhook := range s.config.Notifications.Webhooks {
+		if len(webhook.Events) > 0 && !slices.Contains(webhook.Events, notification.Event) {
+			continue
+		}
+
+		if err := sendWebhook(ctx, webhook, body); err != nil {
+			s.logger.WarnContext(ctx, "Ошибка отправки уведомления",
+				"event", notification.Event,
+				"url", webhook.URL,
+				"error", err.Error())
+		}
+	}
+}
+
+// sendWebhook отправляет тело уведомления POST-запросом
+func sendWebhook(ctx context.Context, webhook types.WebhookConfig, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range webhook.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("получатель ответил статусом %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package backup
+
+import (
+	storageapi "backupist/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// openObject открывает объект хранилища для чтения потоком
+//
+// Если хранилище не поддерживает чтение потоком, объект скачивается
+// в tempDir и удаляется при закрытии.
+func openObject(ctx context.Context, storage StorageProvider, remotePath, tempDir string) (io.ReadCloser, error) {
+	if opener, ok := storage.(storageapi.Opener); ok {
+		reader, err := opener.Open(ctx, remotePath)
+		if !errors.Is(err, storageapi.ErrOpenUnsupported) {
+			return reader, err
+		}
+	}
+
+	localPath := filepath.Join(tempDir, path.Base(remotePath))
+	if err := storage.Download(ctx, remotePath, localPath); err != nil {
+		os.Remove(localPath)
+		return nil, fmt.Errorf("ошибка скачивания %s: %w", remotePath, err)
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		os.Remove(localPath)
+		return nil, fmt.Errorf("ошибка открытия скачанного файла: %w", err)
+	}
+
+	return &tempFileReader{File: file}, nil
+}
+
+// tempFileReader скачанный объект, удаляемый при закрытии
+type tempFileReader struct {
+	*os.File
+}
+
+func (tr *tempFileReader) Close() error {
+	err := tr.File.Close()
+	os.Remove(tr.File.Name())
+	return err
+}
+
+// readCloser объединяет поток чтения (например, с ограничением скорости)
+// с закрытием исходного объекта
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,497 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+const (
+	// paritySuffix суффикс файла четности рядом с файлом бэкапа
+	paritySuffix = ".parity"
+
+	// parityMagic сигнатура файла четности
+	parityMagic = "BKPARITY"
+
+	// parityVersion версия формата файла четности
+	parityVersion = 2
+
+	// parityDataShards количество блоков данных в полосе кодирования
+	parityDataShards = 100
+
+	// parityDefaultBlockSize размер блока по умолчанию
+	parityDefaultBlockSize = 256 * 1024
+
+	// parityMinBlockSize минимальный размер блока для маленьких файлов
+	parityMinBlockSize = 4 * 1024
+)
+
+// parityHeader заголовок файла четности
+//
+// Формат файла: сигнатура, длина заголовка (uint32, big endian), заголовок
+// в JSON, таблица SHA-256 блоков данных и блоков четности, блоки четности.
+// Файл бэкапа делится на блоки BlockSize, блоки распределяются по полосам
+// (не больше DataShards в полосе) через одну: блок i попадает в полосу
+// i mod N, где N - количество полос. На каждую полосу вычисляется Percent
+// процентов блоков четности кодом Рида-Соломона. Полоса восстанавливается,
+// если повреждено не больше блоков, чем в ней блоков четности; благодаря
+// чередованию непрерывное повреждение Percent процентов файла делится
+// между полосами поровну и тоже восстанавливается.
+type parityHeader struct {
+	Version      int    `json:"version"`
+	FileSize     int64  `json:"file_size"`
+	BlockSize    int64  `json:"block_size"`
+	DataShards   int    `json:"data_shards"`
+	Percent      int    `json:"percent"`
+	Blocks       int    `json:"blocks"`
+	ParityBlocks int    `json:"parity_blocks"`
+	IndexSHA256  string `json:"index_sha256"` // Контрольная сумма таблицы хэшей
+}
+
+// stripes возвращает количество полос
+func (h *parityHeader) stripes() int {
+	return (h.Blocks + h.DataShards - 1) / h.DataShards
+}
+
+// stripe возвращает количество блоков данных и четности в полосе
+func (h *parityHeader) stripe(i int) (int, int) {
+	// Первые Blocks mod N полос получают на один блок больше
+	data := h.Blocks / h.stripes()
+	if i < h.Blocks%h.stripes() {
+		data++
+	}
+	return data, parityShards(data, h.Percent)
+}
+
+// block возвращает номер блока данных файла бэкапа по номеру в полосе
+func (h *parityHeader) block(i, k int) int {
+	return k*h.stripes() + i
+}
+
+// parityStart возвращает номер первого блока четности полосы
+func (h *parityHeader) parityStart(i int) int {
+	larger := h.Blocks % h.stripes()
+	data := h.Blocks / h.stripes()
+	return min(i, larger)*parityShards(data+1, h.Percent) + max(i-larger, 0)*parityShards(data, h.Percent)
+}
+
+// parityShards возвращает количество блоков четности для полосы из data блоков
+func parityShards(data, percent int) int {
+	return max((data*percent+99)/100, 1)
+}
+
+// ParityReport результат проверки файла бэкапа по данным четности
+type ParityReport struct {
+	Blocks        int  // Блоков данных
+	DamagedBlocks int  // Поврежденных блоков данных
+	DamagedParity int  // Поврежденных блоков четности
+	SizeMismatch  bool // Размер файла отличается от исходного
+	Repairable    bool // Повреждения данных можно исправить
+}
+
+// Damaged проверяет, найдены ли повреждения
+func (r *ParityReport) Damaged() bool {
+	return r.DamagedBlocks > 0 || r.DamagedParity > 0 || r.SizeMismatch
+}
+
+// stripeDamage поврежденные блоки одной полосы
+type stripeDamage struct {
+	stripe int
+	data   []int // Номера блоков данных внутри полосы
+	parity []int // Номера блоков четности внутри полосы
+}
+
+// parityFile открытый файл четности
+type parityFile struct {
+	header     parityHeader
+	index      []byte
+	file       *os.File
+	dataOffset int64
+}
+
+// createParity создает файл четности для файла бэкапа
+func createParity(ctx context.Context, artifactPath, parityPath string, cfg types.ParityConfig) error {
+	artifact, err := os.Open(artifactPath)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия файла бэкапа: %w", err)
+	}
+	defer artifact.Close()
+
+	info, err := artifact.Stat()
+	if err != nil {
+		return fmt.Errorf("ошибка получения информации о файле бэкапа: %w", err)
+	}
+
+	blockSize := parityBlockSize(info.Size(), cfg.BlockSize)
+	header := parityHeader{
+		Version:    parityVersion,
+		FileSize:   info.Size(),
+		BlockSize:  blockSize,
+		DataShards: parityDataShards,
+		Percent:    cfg.Percent,
+		Blocks:     int((info.Size() + blockSize - 1) / blockSize),
+	}
+
+	// Блоки четности пишутся во временный файл, пока не известна таблица хэшей
+	parityData, err := os.CreateTemp(filepath.Dir(parityPath), "parity-*")
+	if err != nil {
+		return fmt.Errorf("ошибка создания временного файла: %w", err)
+	}
+	defer os.Remove(parityData.Name())
+	defer parityData.Close()
+
+	dataHashes := make([]byte, header.Blocks*sha256.Size)
+	var parityHashes []byte
+	encoders := make(map[[2]int]reedsolomon.Encoder)
+
+	for i := 0; i < header.stripes(); i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		data, parity := header.stripe(i)
+		encoder, err := parityEncoder(encoders, data, parity)
+		if err != nil {
+			return err
+		}
+
+		shards := make([][]byte, data+parity)
+		for k := range shards {
+			shards[k] = make([]byte, blockSize)
+		}
+		for k := 0; k < data; k++ {
+			offset := int64(header.block(i, k)) * blockSize
+			if _, err := artifact.ReadAt(shards[k], offset); err != nil && err != io.EOF {
+				return fmt.Errorf("ошибка чтения файла бэкапа: %w", err)
+			}
+		}
+
+		if err := encoder.Encode(shards); err != nil {
+			return fmt.Errorf("ошибка вычисления блоков четности: %w", err)
+		}
+
+		for k, shard := range shards {
+			sum := sha256.Sum256(shard)
+			if k < data {
+				copy(dataHashes[header.block(i, k)*sha256.Size:], sum[:])
+				continue
+			}
+
+			parityHashes = append(parityHashes, sum[:]...)
+			if _, err := parityData.Write(shard); err != nil {
+				return fmt.Errorf("ошибка записи блоков четности: %w", err)
+			}
+			header.ParityBlocks++
+		}
+	}
+
+	index := append(dataHashes, parityHashes...)
+	indexSum := sha256.Sum256(index)
+	header.IndexSHA256 = hex.EncodeToString(indexSum[:])
+
+	headerData, err := json.Marshal(header)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации заголовка четности: %w", err)
+	}
+
+	out, err := os.Create(parityPath)
+	if err != nil {
+		return fmt.Errorf("ошибка создания файла четности: %w", err)
+	}
+	defer out.Close()
+
+	var prefix []byte
+	prefix = append(prefix, parityMagic...)
+	prefix = binary.BigEndian.AppendUint32(prefix, uint32(len(headerData)))
+	prefix = append(prefix, headerData...)
+	prefix = append(prefix, index...)
+
+	if _, err := out.Write(prefix); err != nil {
+		return fmt.Errorf("ошибка записи файла четности: %w", err)
+	}
+	if _, err := parityData.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("ошибка чтения блоков четности: %w", err)
+	}
+	if _, err := io.Copy(out, parityData); err != nil {
+		return fmt.Errorf("ошибка записи файла четности: %w", err)
+	}
+
+	return out.Close()
+}
+
+// parityBlockSize выбирает размер блока: для маленьких файлов блок
+// уменьшается, чтобы в полосе было достаточно блоков для заданного процента
+func parityBlockSize(fileSize, configured int64) int64 {
+	blockSize := configured
+	if blockSize <= 0 {
+		blockSize = parityDefaultBlockSize
+	}
+
+	perShard := (fileSize + parityDataShards - 1) / parityDataShards
+	perShard = (perShard + parityMinBlockSize - 1) / parityMinBlockSize * parityMinBlockSize
+
+	return max(min(blockSize, perShard), parityMinBlockSize)
+}
+
+// parityEncoder возвращает кодировщик для полосы; полосы в файле бывают
+// не больше двух размеров, поэтому кодировщики кэшируются
+func parityEncoder(encoders map[[2]int]reedsolomon.Encoder, data, parity int) (reedsolomon.Encoder, error) {
+	key := [2]int{data, parity}
+	if encoder, ok := encoders[key]; ok {
+		return encoder, nil
+	}
+
+	encoder, err := reedsolomon.New(data, parity)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания кодировщика Рида-Соломона: %w", err)
+	}
+	encoders[key] = encoder
+
+	return encoder, nil
+}
+
+// openParity открывает файл четности и проверяет его заголовок и таблицу хэшей
+func openParity(parityPath string) (*parityFile, error) {
+	file, err := os.Open(parityPath)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия файла четности: %w", err)
+	}
+
+	pf, err := readParityHeader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("файл четности поврежден: %w", err)
+	}
+
+	return pf, nil
+}
+
+// readParityHeader читает заголовок и таблицу хэшей файла четности
+func readParityHeader(file *os.File) (*parityFile, error) {
+	prefix := make([]byte, len(parityMagic)+4)
+	if _, err := io.ReadFull(file, prefix); err != nil {
+		return nil, err
+	}
+	if string(prefix[:len(parityMagic)]) != parityMagic {
+		return nil, fmt.Errorf("неверная сигнатура")
+	}
+
+	headerLen := binary.BigEndian.Uint32(prefix[len(parityMagic):])
+	if headerLen > 1<<20 {
+		return nil, fmt.Errorf("некорректная длина заголовка: %d", headerLen)
+	}
+
+	headerData := make([]byte, headerLen)
+	if _, err := io.ReadFull(file, headerData); err != nil {
+		return nil, err
+	}
+
+	pf := &parityFile{file: file}
+	if err := json.Unmarshal(headerData, &pf.header); err != nil {
+		return nil, fmt.Errorf("ошибка разбора заголовка: %w", err)
+	}
+
+	h := pf.header
+	if h.Version != parityVersion {
+		return nil, fmt.Errorf("неподдерживаемая версия формата: %d", h.Version)
+	}
+	if h.BlockSize <= 0 || h.DataShards <= 0 || h.Percent <= 0 || h.Blocks < 0 || h.ParityBlocks < 0 ||
+		int64(h.Blocks) != (h.FileSize+h.BlockSize-1)/h.BlockSize {
+		return nil, fmt.Errorf("некорректные параметры в заголовке")
+	}
+
+	pf.index = make([]byte, (h.Blocks+h.ParityBlocks)*sha256.Size)
+	if _, err := io.ReadFull(file, pf.index); err != nil {
+		return nil, err
+	}
+
+	indexSum := sha256.Sum256(pf.index)
+	if hex.EncodeToString(indexSum[:]) != h.IndexSHA256 {
+		return nil, fmt.Errorf("таблица хэшей блоков повреждена")
+	}
+
+	pf.dataOffset = int64(len(prefix)) + int64(headerLen) + int64(len(pf.index))
+
+	return pf, nil
+}
+
+// Close закрывает файл четности
+func (pf *parityFile) Close() error {
+	return pf.file.Close()
+}
+
+// dataHash возвращает SHA-256 блока данных
+func (pf *parityFile) dataHash(block int) []byte {
+	return pf.index[block*sha256.Size : (block+1)*sha256.Size]
+}
+
+// parityHash возвращает SHA-256 блока четности
+func (pf *parityFile) parityHash(block int) []byte {
+	offset := (pf.header.Blocks + block) * sha256.Size
+	return pf.index[offset : offset+sha256.Size]
+}
+
+// readBlock читает блок с дополнением нулями; возвращает false, если блок поврежден
+func readBlock(file io.ReaderAt, offset int64, buf, expected []byte) (bool, error) {
+	clear(buf)
+
+	if _, err := file.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return false, err
+	}
+
+	sum := sha256.Sum256(buf)
+	return bytes.Equal(sum[:], expected), nil
+}
+
+// scan проверяет блоки файла бэкапа и блоки четности по таблице хэшей
+func (pf *parityFile) scan(ctx context.Context, artifact *os.File) (*ParityReport, []stripeDamage, error) {
+	h := pf.header
+
+	info, err := artifact.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка получения информации о файле бэкапа: %w", err)
+	}
+
+	report := &ParityReport{
+		Blocks:       h.Blocks,
+		SizeMismatch: info.Size() != h.FileSize,
+		Repairable:   true,
+	}
+
+	var damaged []stripeDamage
+	buf := make([]byte, h.BlockSize)
+
+	for i := 0; i < h.stripes(); i++ {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+
+		data, parity := h.stripe(i)
+		stripe := stripeDamage{stripe: i}
+
+		for k := 0; k < data; k++ {
+			block := h.block(i, k)
+			ok, err := readBlock(artifact, int64(block)*h.BlockSize, buf, pf.dataHash(block))
+			if err != nil {
+				return nil, nil, fmt.Errorf("ошибка чтения файла бэкапа: %w", err)
+			}
+			if !ok {
+				stripe.data = append(stripe.data, k)
+			}
+		}
+
+		for k := 0; k < parity; k++ {
+			block := h.parityStart(i) + k
+			ok, err := readBlock(pf.file, pf.dataOffset+int64(block)*h.BlockSize, buf, pf.parityHash(block))
+			if err != nil {
+				return nil, nil, fmt.Errorf("ошибка чтения файла четности: %w", err)
+			}
+			if !ok {
+				stripe.parity = append(stripe.parity, k)
+			}
+		}
+
+		if len(stripe.data) == 0 && len(stripe.parity) == 0 {
+			continue
+		}
+
+		report.DamagedBlocks += len(stripe.data)
+		report.DamagedParity += len(stripe.parity)
+		if len(stripe.data) > 0 && len(stripe.data)+len(stripe.parity) > parity {
+			report.Repairable = false
+		}
+		damaged = append(damaged, stripe)
+	}
+
+	return report, damaged, nil
+}
+
+// repair восстанавливает поврежденные блоки файла бэкапа на месте
+func (pf *parityFile) repair(ctx context.Context, artifactPath string) (*ParityReport, error) {
+	artifact, err := os.OpenFile(artifactPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия файла бэкапа: %w", err)
+	}
+	defer artifact.Close()
+
+	report, damaged, err := pf.scan(ctx, artifact)
+	if err != nil {
+		return nil, err
+	}
+	if !report.Repairable {
+		return report, fmt.Errorf("повреждено больше блоков, чем можно восстановить: %d из %d", report.DamagedBlocks, report.Blocks)
+	}
+
+	h := pf.header
+	encoders := make(map[[2]int]reedsolomon.Encoder)
+
+	for _, stripe := range damaged {
+		if len(stripe.data) == 0 {
+			continue
+		}
+
+		data, parity := h.stripe(stripe.stripe)
+		encoder, err := parityEncoder(encoders, data, parity)
+		if err != nil {
+			return report, err
+		}
+
+		shards := make([][]byte, data+parity)
+		for k := 0; k < data+parity; k++ {
+			shards[k] = make([]byte, h.BlockSize)
+
+			var file io.ReaderAt = artifact
+			var offset int64
+			if k < data {
+				offset = int64(h.block(stripe.stripe, k)) * h.BlockSize
+			} else {
+				file = pf.file
+				offset = pf.dataOffset + int64(h.parityStart(stripe.stripe)+k-data)*h.BlockSize
+			}
+
+			if _, err := file.ReadAt(shards[k], offset); err != nil && err != io.EOF {
+				return report, fmt.Errorf("ошибка чтения блока: %w", err)
+			}
+		}
+
+		// Поврежденные блоки помечаются как отсутствующие
+		for _, k := range stripe.data {
+			shards[k] = nil
+		}
+		for _, k := range stripe.parity {
+			shards[data+k] = nil
+		}
+
+		if err := encoder.ReconstructData(shards); err != nil {
+			return report, fmt.Errorf("ошибка восстановления полосы %d: %w", stripe.stripe, err)
+		}
+
+		for _, k := range stripe.data {
+			offset := int64(h.block(stripe.stripe, k)) * h.BlockSize
+			length := min(h.BlockSize, h.FileSize-offset)
+			if _, err := artifact.WriteAt(shards[k][:length], offset); err != nil {
+				return report, fmt.Errorf("ошибка записи восстановленного блока: %w", err)
+			}
+		}
+	}
+
+	if err := artifact.Truncate(h.FileSize); err != nil {
+		return report, fmt.Errorf("ошибка восстановления размера файла: %w", err)
+	}
+
+	if err := artifact.Close(); err != nil {
+		return report, fmt.Errorf("ошибка записи файла бэкапа: %w", err)
+	}
+
+	return report, nil
+}
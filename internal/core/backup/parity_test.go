@@ -0,0 +1,68 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"bytes"
+	"context"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParityRepairsBurstDamage(t *testing.T) {
+	dir := t.TempDir()
+	artifactPath := filepath.Join(dir, "backup.tar.gz")
+	parityPath := artifactPath + paritySuffix
+
+	// 256 блоков по 4 КБ - три полосы
+	content := make([]byte, 256*parityMinBlockSize-100)
+	rng := rand.NewChaCha8([32]byte{})
+	rng.Read(content)
+	if err := os.WriteFile(artifactPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	cfg := types.ParityConfig{Percent: 10, BlockSize: parityMinBlockSize}
+	if err := createParity(ctx, artifactPath, parityPath, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Непрерывное повреждение 10% файла: при полосах из соседних блоков
+	// оно целиком попало бы в одну полосу
+	damaged := bytes.Clone(content)
+	burst := len(content) / 10
+	start := 40 * parityMinBlockSize
+	for i := start; i < start+burst; i++ {
+		damaged[i] ^= 0xff
+	}
+	if err := os.WriteFile(artifactPath, damaged, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pf, err := openParity(parityPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pf.Close()
+	if pf.header.stripes() < 2 {
+		t.Fatalf("полос: %d, ожидалось несколько", pf.header.stripes())
+	}
+
+	report, err := pf.repair(ctx, artifactPath)
+	if err != nil {
+		t.Fatalf("восстановление после непрерывного повреждения: %v (%+v)", err, report)
+	}
+	if report.DamagedBlocks == 0 {
+		t.Fatal("повреждение не обнаружено")
+	}
+
+	repaired, err := os.ReadFile(artifactPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(repaired, content) {
+		t.Fatal("восстановленный файл отличается от исходного")
+	}
+}
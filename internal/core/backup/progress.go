@@ -0,0 +1,320 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// progressInterval минимальный интервал между событиями прогресса одного этапа
+	progressInterval = 250 * time.Millisecond
+
+	// eventBuffer размер буфера событий подписчика; при переполнении
+	// отбрасываются самые старые события
+	eventBuffer = 64
+)
+
+// EventBus рассылает события выполнения задач подписчикам
+//
+// Публикация не блокируется медленными подписчиками: прогресс - это
+// снимок состояния, поэтому устаревшие события можно отбросить.
+type EventBus struct {
+	mu     sync.Mutex
+	subs   map[*subscription]struct{}
+	latest map[string]types.JobEvent // Последнее событие прогресса выполняющихся задач
+}
+
+// subscription подписка на события одной задачи или всех задач
+type subscription struct {
+	jobID  string // Пусто - события всех задач
+	events chan types.JobEvent
+}
+
+// NewEventBus создает шину событий задач
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subs:   make(map[*subscription]struct{}),
+		latest: make(map[string]types.JobEvent),
+	}
+}
+
+// Subscribe подписывается на события задачи jobID (пусто - всех задач)
+//
+// Если задача выполняется, первым приходит ее текущий прогресс.
+// Возвращаемая функция отменяет подписку и закрывает канал.
+func (b *EventBus) Subscribe(jobID string) (<-chan types.JobEvent, func()) {
+	sub := &subscription{jobID: jobID, events: make(chan types.JobEvent, eventBuffer)}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	for id, event := range b.latest {
+		if jobID == "" || jobID == id {
+			sub.send(event)
+		}
+	}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return sub.events, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, sub)
+			close(sub.events)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Progress возвращает текущий прогресс выполняющейся задачи или nil
+func (b *EventBus) Progress(jobID string) *types.JobProgress {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event, ok := b.latest[jobID]
+	if !ok {
+		return nil
+	}
+	progress := *event.Progress
+	return &progress
+}
+
+// publish рассылает событие подписчикам
+func (b *EventBus) publish(event types.JobEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case event.Type == types.JobEventProgress:
+		b.latest[event.JobID] = event
+	case JobFinished(event.Status):
+		delete(b.latest, event.JobID)
+	}
+
+	for sub := range b.subs {
+		if sub.jobID == "" || sub.jobID == event.JobID {
+			sub.send(event)
+		}
+	}
+}
+
+// send отправляет событие подписчику, вытесняя самое старое при переполнении буфера
+func (sub *subscription) send(event types.JobEvent) {
+	for {
+		select {
+		case sub.events <- event:
+			return
+		default:
+		}
+
+		select {
+		case <-sub.events:
+		default:
+		}
+	}
+}
+
+// JobFinished проверяет, завершена ли задача в статусе status
+func JobFinished(status types.JobStatus) bool {
+	switch status {
+	case types.JobStatusCompleted, types.JobStatusFailed, types.JobStatusCancelled, types.JobStatusDeleted:
+		return true
+	default:
+		return false
+	}
+}
+
+// Events возвращает шину событий задач сервиса
+func (s *Service) Events() *EventBus {
+	return s.events
+}
+
+// publishStatus публикует смену статуса задачи
+func (s *Service) publishStatus(job *types.BackupJob) {
+	s.events.publish(types.JobEvent{
+		Type:     types.JobEventStatus,
+		JobID:    job.ID,
+		PolicyID: job.PolicyID,
+		Status:   job.Status,
+		Error:    job.Error,
+	})
+}
+
+// progressTracker считает прогресс этапов задачи и публикует его в шину
+type progressTracker struct {
+	bus      *EventBus
+	jobID    string
+	policyID string
+	started  time.Time
+
+	mu          sync.Mutex
+	progress    types.JobProgress
+	phaseStart  time.Time
+	lastPublish time.Time
+}
+
+func newProgressTracker(bus *EventBus, job *types.BackupJob) *progressTracker {
+	return &progressTracker{
+		bus:      bus,
+		jobID:    job.ID,
+		policyID: job.PolicyID,
+		started:  time.Now(),
+	}
+}
+
+// progressKey ключ контекста для учета прогресса задачи
+type progressKey struct{}
+
+// withProgress добавляет учет прогресса к операциям, выполняемым с контекстом
+func withProgress(ctx context.Context, tracker *progressTracker) context.Context {
+	return context.WithValue(ctx, progressKey{}, tracker)
+}
+
+// progressFrom возвращает учет прогресса из контекста; nil, если прогресс не учитывается
+func progressFrom(ctx context.Context) *progressTracker {
+	tracker, _ := ctx.Value(progressKey{}).(*progressTracker)
+	return tracker
+}
+
+// phase начинает этап с известными итогами (0 - неизвестно) и сразу публикует прогресс
+func (pt *progressTracker) phase(phase types.ProgressPhase, totalFiles, totalBytes int64) {
+	if pt == nil {
+		return
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.progress = types.JobProgress{
+		Phase:      phase,
+		TotalFiles: totalFiles,
+		TotalBytes: totalBytes,
+	}
+	pt.phaseStart = time.Now()
+	pt.publishLocked(true)
+}
+
+// file отмечает начало обработки файла
+func (pt *progressTracker) file(name string) {
+	if pt == nil {
+		return
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.progress.FilesProcessed++
+	pt.progress.CurrentFile = name
+	pt.publishLocked(false)
+}
+
+// add учитывает обработанные байты, если этап phase текущий
+//
+// Передача может начаться заново (повтор загрузки), поэтому счетчик
+// не превышает итог этапа.
+func (pt *progressTracker) add(phase types.ProgressPhase, n int64) {
+	if pt == nil || n <= 0 {
+		return
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if pt.progress.Phase != phase {
+		return
+	}
+	pt.progress.BytesProcessed += n
+	if pt.progress.TotalBytes > 0 {
+		pt.progress.BytesProcessed = min(pt.progress.BytesProcessed, pt.progress.TotalBytes)
+	}
+	pt.publishLocked(false)
+}
+
+// publishLocked публикует прогресс; без force - не чаще progressInterval
+func (pt *progressTracker) publishLocked(force bool) {
+	now := time.Now()
+	if !force && now.Sub(pt.lastPublish) < progressInterval {
+		return
+	}
+	pt.lastPublish = now
+
+	progress := pt.progress
+	progress.Elapsed = now.Sub(pt.started)
+
+	if elapsed := now.Sub(pt.phaseStart).Seconds(); elapsed > 0 {
+		progress.BytesPerSecond = float64(progress.BytesProcessed) / elapsed
+	}
+
+	switch {
+	case progress.TotalBytes > 0:
+		progress.PercentComplete = float64(progress.BytesProcessed) / float64(progress.TotalBytes) * 100
+		if progress.BytesPerSecond > 0 {
+			remaining := float64(progress.TotalBytes - progress.BytesProcessed)
+			progress.ETA = time.Duration(remaining / progress.BytesPerSecond * float64(time.Second))
+		}
+	case progress.TotalFiles > 0:
+		progress.PercentComplete = float64(progress.FilesProcessed) / float64(progress.TotalFiles) * 100
+	}
+
+	pt.bus.publish(types.JobEvent{
+		Type:     types.JobEventProgress,
+		JobID:    pt.jobID,
+		PolicyID: pt.policyID,
+		Status:   types.JobStatusRunning,
+		Progress: &progress,
+		Time:     now,
+	})
+}
+
+// progressReader учитывает прочитанные байты в этапе phase задачи из контекста
+func progressReader(ctx context.Context, r io.Reader, phase types.ProgressPhase) io.Reader {
+	tracker := progressFrom(ctx)
+	if tracker == nil {
+		return r
+	}
+
+	return &countingReader{r: r, count: func(n int) { tracker.add(phase, int64(n)) }}
+}
+
+// progressReadSeeker progressReader с сохранением возможности перемотки
+func progressReadSeeker(ctx context.Context, r io.ReadSeeker, phase types.ProgressPhase) io.ReadSeeker {
+	tracker := progressFrom(ctx)
+	if tracker == nil {
+		return r
+	}
+
+	return &countingReadSeeker{
+		countingReader: countingReader{r: r, count: func(n int) { tracker.add(phase, int64(n)) }},
+		seeker:         r,
+	}
+}
+
+// countingReader вызывает count с количеством прочитанных байт
+type countingReader struct {
+	r     io.Reader
+	count func(n int)
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	if n > 0 {
+		cr.count(n)
+	}
+	return n, err
+}
+
+// countingReadSeeker countingReader с поддержкой перемотки
+type countingReadSeeker struct {
+	countingReader
+	seeker io.Seeker
+}
+
+func (cs *countingReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return cs.seeker.Seek(offset, whence)
+}
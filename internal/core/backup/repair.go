@@ -0,0 +1,197 @@
+package backup
+
+import (
+	storageapi "backupist/pkg/storage"
+	"backupist/pkg/types"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// RepairResult результат исправления бэкапа по данным четности
+type RepairResult struct {
+	JobID         string
+	Report        ParityReport // Повреждения, найденные до исправления
+	Repaired      bool         // Бэкап исправлен и записан в хранилище
+	ParityRebuilt bool         // Данные четности пересозданы и записаны в хранилище
+	Checksum      string       // SHA-256 бэкапа после исправления
+}
+
+// repairTarget файл бэкапа или том, исправляемый по данным четности
+type repairTarget struct {
+	remotePath string
+	checksum   string // Ожидаемый SHA-256
+}
+
+// checkParity скачивает файл четности и проверяет по нему скачанный бэкап
+func (s *Service) checkParity(ctx context.Context, storage StorageProvider, remoteParityPath, backupPath, tempDir string) (*ParityReport, error) {
+	parityPath := filepath.Join(tempDir, "backup"+paritySuffix)
+	if err := storage.Download(ctx, remoteParityPath, parityPath); err != nil {
+		return nil, fmt.Errorf("ошибка скачивания данных четности: %w", err)
+	}
+
+	pf, err := openParity(parityPath)
+	if err != nil {
+		return nil, err
+	}
+	defer pf.Close()
+
+	artifact, err := os.Open(backupPath)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия файла бэкапа: %w", err)
+	}
+	defer artifact.Close()
+
+	report, _, err := pf.scan(ctx, artifact)
+	return report, err
+}
+
+// RepairBackup исправляет поврежденный бэкап задачи по данным четности
+//
+// Бэкап и файл четности скачиваются из хранилища, поврежденные блоки
+// восстанавливаются кодом Рида-Соломона, после чего контрольная сумма
+// сравнивается с каталогом и бэкап записывается в хранилище поверх
+// поврежденного. Поврежденные блоки четности пересоздаются. Бэкап,
+// разбитый на тома, исправляется по томам.
+func (s *Service) RepairBackup(ctx context.Context, jobID string) (*RepairResult, error) {
+	job, err := s.getBackupJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != types.JobStatusCompleted || job.BackupPath == "" {
+		return nil, fmt.Errorf("бэкап задачи %s не завершен или удален", job.ID)
+	}
+
+	result, err := s.getBackupResult(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if result.Checksum == "" {
+		return nil, fmt.Errorf("в каталоге нет контрольной суммы бэкапа")
+	}
+
+	storage, err := s.storageForJob(ctx, job)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка определения хранилища бэкапа: %w", err)
+	}
+
+	// Исправляемые объекты: сам бэкап или его тома с данными четности
+	var targets []repairTarget
+	if result.Volumes > 0 {
+		manifest, err := s.readVolumeManifest(ctx, storage, job.BackupPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, volume := range manifest.Volumes {
+			if volume.Parity {
+				targets = append(targets, repairTarget{volumePath(job.BackupPath, volume), volume.Checksum})
+			}
+		}
+	} else if result.ParityPath != "" {
+		targets = append(targets, repairTarget{job.BackupPath, result.Checksum})
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("для бэкапа задачи %s нет данных четности", job.ID)
+	}
+
+	tempDir, err := os.MkdirTemp("", "backupist-repair-*")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания временной директории: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	repair := &RepairResult{JobID: job.ID, Report: ParityReport{Repairable: true}}
+	for _, target := range targets {
+		if err := s.repairObject(ctx, storage, target.remotePath, target.checksum, tempDir, repair); err != nil {
+			return repair, err
+		}
+	}
+
+	if !repair.Report.Damaged() {
+		return repair, nil
+	}
+	if repair.Repaired {
+		repair.Checksum = result.Checksum
+	}
+
+	s.logger.InfoContext(ctx, "Бэкап исправлен по данным четности",
+		"job_id", job.ID,
+		"damaged_blocks", repair.Report.DamagedBlocks,
+		"damaged_parity", repair.Report.DamagedParity,
+		"parity_rebuilt", repair.ParityRebuilt)
+
+	return repair, nil
+}
+
+// repairObject исправляет один файл бэкапа или том по его файлу четности
+// и добавляет найденные повреждения в repair
+func (s *Service) repairObject(ctx context.Context, storage StorageProvider, remotePath, checksum, tempDir string, repair *RepairResult) error {
+	localPath := filepath.Join(tempDir, path.Base(remotePath))
+	defer os.Remove(localPath)
+
+	if err := storage.Download(ctx, remotePath, localPath); err != nil {
+		return fmt.Errorf("ошибка скачивания бэкапа: %w", err)
+	}
+
+	parityPath := localPath + paritySuffix
+	defer os.Remove(parityPath)
+
+	if err := storage.Download(ctx, remotePath+paritySuffix, parityPath); err != nil {
+		return fmt.Errorf("ошибка скачивания данных четности: %w", err)
+	}
+
+	pf, err := openParity(parityPath)
+	if err != nil {
+		return err
+	}
+	defer pf.Close()
+
+	report, err := pf.repair(ctx, localPath)
+	if report != nil {
+		repair.Report.Blocks += report.Blocks
+		repair.Report.DamagedBlocks += report.DamagedBlocks
+		repair.Report.DamagedParity += report.DamagedParity
+		repair.Report.SizeMismatch = repair.Report.SizeMismatch || report.SizeMismatch
+		repair.Report.Repairable = repair.Report.Repairable && report.Repairable
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path.Base(remotePath), err)
+	}
+
+	if report.DamagedBlocks > 0 || report.SizeMismatch {
+		repaired, err := s.calculateChecksum(localPath)
+		if err != nil {
+			return fmt.Errorf("ошибка вычисления контрольной суммы: %w", err)
+		}
+		if repaired != checksum {
+			return fmt.Errorf("%s: контрольная сумма после исправления не совпадает: %s, ожидается %s", path.Base(remotePath), repaired, checksum)
+		}
+
+		if err := storage.Upload(ctx, localPath, remotePath); err != nil {
+			return fmt.Errorf("ошибка записи исправленного бэкапа: %w", err)
+		}
+		if _, err := verifyUpload(ctx, storage, localPath, remotePath); err != nil && !errors.Is(err, storageapi.ErrVerifyUnsupported) {
+			return fmt.Errorf("ошибка проверки записанного бэкапа: %w", err)
+		}
+		repair.Repaired = true
+	}
+
+	if report.DamagedParity > 0 {
+		cfg := types.ParityConfig{Percent: pf.header.Percent, BlockSize: pf.header.BlockSize}
+		rebuiltPath := localPath + ".rebuilt" + paritySuffix
+		defer os.Remove(rebuiltPath)
+
+		if err := createParity(ctx, localPath, rebuiltPath, cfg); err != nil {
+			return fmt.Errorf("ошибка создания данных четности: %w", err)
+		}
+		if err := storage.Upload(ctx, rebuiltPath, remotePath+paritySuffix); err != nil {
+			return fmt.Errorf("ошибка записи данных четности: %w", err)
+		}
+		repair.ParityRebuilt = true
+	}
+
+	return nil
+}
@@ -0,0 +1,493 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ConflictMode что делать с файлом, который уже есть в директории восстановления
+type ConflictMode string
+
+const (
+	ConflictOverwrite      ConflictMode = "overwrite"          // Заменить файл
+	ConflictOverwriteNewer ConflictMode = "overwrite-if-newer" // Заменить, если файл в бэкапе новее
+	ConflictSkip           ConflictMode = "skip"               // Оставить существующий файл
+	ConflictRename         ConflictMode = "rename"             // Восстановить рядом под новым именем
+)
+
+// ParseConflictMode разбирает режим разрешения конфликтов; пустое значение - ConflictOverwrite
+func ParseConflictMode(value string) (ConflictMode, error) {
+	switch mode := ConflictMode(value); mode {
+	case "":
+		return ConflictOverwrite, nil
+	case ConflictOverwrite, ConflictOverwriteNewer, ConflictSkip, ConflictRename:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("неизвестный режим конфликтов: %s (допустимы overwrite, overwrite-if-newer, skip, rename)", value)
+	}
+}
+
+// RestoreAction действие восстановления с файлом
+type RestoreAction string
+
+const (
+	RestoreActionCreate    RestoreAction = "create"
+	RestoreActionOverwrite RestoreAction = "overwrite"
+	RestoreActionRename    RestoreAction = "rename"
+	RestoreActionSkip      RestoreAction = "skip"
+)
+
+// RestoreOptions параметры восстановления файлов из бэкапа
+type RestoreOptions struct {
+	PolicyID string
+	JobID    string       // Бэкап задачи вместо поиска по времени
+	At       time.Time    // Состояние на момент времени; нулевое значение - последний бэкап
+	Include  []string     // Шаблоны путей относительно исходной директории; пусто - все файлы
+	Target   string       // Директория, в которую восстанавливаются файлы
+	Conflict ConflictMode // Пусто - ConflictOverwrite
+	DryRun   bool         // Только перечислить изменения
+
+	// SnapshotDir директория, в которую перед заменой сохраняются
+	// существующие файлы; по умолчанию <Target>.pre-restore-<время>
+	SnapshotDir string
+	NoSnapshot  bool // Не сохранять заменяемые файлы
+
+	// Root директория, за пределы которой восстановление не выходит:
+	// относительные Target и SnapshotDir считаются от нее, а пути вне
+	// нее после разрешения символических ссылок отклоняются; пусто - без
+	// ограничения
+	Root string
+}
+
+// RestoreResult результат восстановления файлов из бэкапа
+type RestoreResult struct {
+	JobID       string          `json:"job_id"`
+	StartedAt   time.Time       `json:"started_at"`             // Время начала восстановленного бэкапа
+	Files       int             `json:"files"`                  // Восстановлено файлов
+	Bytes       int64           `json:"bytes"`                  // Восстановлено байт
+	Overwritten int             `json:"overwritten"`            // Из них заменено существующих
+	Renamed     int             `json:"renamed"`                // Из них записано рядом с существующими
+	Skipped     int             `json:"skipped"`                // Пропущено существующих файлов
+	SnapshotDir string          `json:"snapshot_dir,omitempty"` // Директория с сохраненными заменяемыми файлами
+	Changes     []RestoreChange `json:"changes,omitempty"`      // Изменения пробного запуска
+	Duration    time.Duration   `json:"duration"`
+}
+
+// RestoreChange изменение, которое восстановление вносит в файл
+type RestoreChange struct {
+	Path    string        `json:"path"` // Путь относительно директории восстановления
+	Action  RestoreAction `json:"action"`
+	Size    int64         `json:"size"`
+	NewPath string        `json:"new_path,omitempty"` // Имя, под которым файл восстанавливается в режиме rename
+}
+
+// Restore восстанавливает файлы из бэкапа политики в директорию назначения
+//
+// Выбирается последний завершенный бэкап, начатый не позже opts.At.
+// Из архива извлекаются только записи, подходящие под шаблоны
+// opts.Include, с путями относительно исходной директории политики.
+// Бэкап читается из хранилища потоком и целиком на диск не скачивается.
+// Существующие файлы обрабатываются по opts.Conflict; заменяемые
+// файлы предварительно сохраняются в директорию снимка. Контрольная
+// сумма бэкапа сверяется в конце потока, когда файлы уже записаны, и
+// при несовпадении прежние версии остаются в снимке. Если снимок
+// отключен, а существующие файлы заменяются, бэкап сначала
+// прочитывается целиком для проверки контрольной суммы и только потом
+// распаковывается. Пробный запуск строит список изменений по манифесту
+// из каталога, не скачивая бэкап.
+func (s *Service) Restore(ctx context.Context, opts RestoreOptions) (*RestoreResult, error) {
+	if opts.Target == "" {
+		return nil, fmt.Errorf("не указана директория восстановления")
+	}
+	conflict, err := ParseConflictMode(string(opts.Conflict))
+	if err != nil {
+		return nil, err
+	}
+	include, err := normalizeInclude(opts.Include)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.NoSnapshot && !opts.DryRun && opts.SnapshotDir == "" {
+		opts.SnapshotDir = filepath.Clean(opts.Target) + ".pre-restore-" + time.Now().Format("20060102-150405")
+	}
+	if opts.Root != "" {
+		if err := confineRestore(&opts); err != nil {
+			return nil, err
+		}
+	}
+
+	policy, err := s.getPolicy(ctx, opts.PolicyID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения политики: %w", err)
+	}
+
+	var job *types.BackupJob
+	if opts.JobID != "" {
+		job, err = s.getBackupJob(ctx, opts.JobID)
+		if err != nil {
+			return nil, err
+		}
+		if job.PolicyID != policy.ID || job.Status != types.JobStatusCompleted || job.BackupPath == "" {
+			return nil, fmt.Errorf("задача %s не является завершенным бэкапом политики %s", job.ID, policy.ID)
+		}
+	} else {
+		job, err = s.snapshotAt(ctx, policy.ID, opts.At)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &RestoreResult{JobID: job.ID, StartedAt: job.StartedAt}
+	ex := &extractor{
+		service:  s,
+		destPath: opts.Target,
+		filter:   includeFilter(backupName(job.BackupPath), include),
+		conflict: conflict,
+		dryRun:   opts.DryRun,
+		result:   result,
+	}
+	if !opts.NoSnapshot && !opts.DryRun {
+		ex.snapshotDir = opts.SnapshotDir
+	}
+
+	// По манифесту из каталога проверяем, что восстанавливать есть что,
+	// до скачивания бэкапа
+	files, err := s.getBackupFiles(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(include) > 0 && len(files) > 0 && !anyIncluded(include, files) {
+		return nil, fmt.Errorf("в бэкапе задачи %s нет файлов, подходящих под шаблоны %s", job.ID, strings.Join(include, ", "))
+	}
+
+	start := time.Now()
+
+	// Пробный запуск по манифесту; без манифеста бэкап читается без записи файлов
+	if opts.DryRun && len(files) > 0 {
+		for _, file := range files {
+			if len(include) > 0 && !matchInclude(include, file.Path) {
+				continue
+			}
+			if _, err := ex.plan(filepath.Join(opts.Target, filepath.FromSlash(file.Path)), file.Size, file.ModTime); err != nil {
+				return result, err
+			}
+		}
+		result.Duration = time.Since(start)
+		return result, nil
+	}
+
+	if !opts.DryRun {
+		if err := os.MkdirAll(opts.Target, 0755); err != nil {
+			return nil, fmt.Errorf("ошибка создания директории восстановления: %w", err)
+		}
+	}
+
+	tempDir, err := os.MkdirTemp("", "backupist-restore-*")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания временной директории: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Без снимка замененные файлы не вернуть, поэтому бэкап проверяется
+	// до того, как файлы будут заменены на месте
+	if !opts.DryRun && ex.snapshotDir == "" && (conflict == ConflictOverwrite || conflict == ConflictOverwriteNewer) {
+		if err := s.verifyArchive(ctx, policy, job, tempDir); err != nil {
+			result.Duration = time.Since(start)
+			return result, fmt.Errorf("бэкап не прошел проверку, файлы не изменены: %w", err)
+		}
+	}
+
+	err = s.restoreBackup(ctx, policy, job, tempDir, ex)
+	result.Duration = time.Since(start)
+	if err != nil {
+		return result, err
+	}
+
+	if !opts.DryRun {
+		s.logger.InfoContext(ctx, "Файлы восстановлены из бэкапа",
+			"policy_id", policy.ID,
+			"job_id", job.ID,
+			"target", opts.Target,
+			"files", result.Files,
+			"bytes", result.Bytes,
+			"overwritten", result.Overwritten,
+			"renamed", result.Renamed,
+			"skipped", result.Skipped,
+			"snapshot_dir", result.SnapshotDir,
+			"duration", result.Duration.String())
+	}
+
+	return result, nil
+}
+
+// confineRestore приводит директории восстановления и снимка к путям
+// внутри opts.Root без символических ссылок; путь вне Root - ошибка ErrInvalid
+func confineRestore(opts *RestoreOptions) error {
+	root, err := filepath.EvalSymlinks(opts.Root)
+	if err != nil {
+		return fmt.Errorf("ошибка разрешения корня восстановления: %w", err)
+	}
+
+	confine := func(name, dir string) (string, error) {
+		if dir == "" {
+			return "", nil
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+		resolved, err := resolveExisting(dir)
+		if err != nil {
+			return "", err
+		}
+		if !withinDir(root, resolved) {
+			return "", &catalogError{kind: ErrInvalid, err: fmt.Errorf("%s %s находится вне корня восстановления %s", name, dir, opts.Root)}
+		}
+		return resolved, nil
+	}
+
+	if opts.Target, err = confine("директория восстановления", opts.Target); err != nil {
+		return err
+	}
+	if opts.SnapshotDir, err = confine("директория снимка", opts.SnapshotDir); err != nil {
+		return err
+	}
+	return nil
+}
+
+// snapshotAt возвращает последний завершенный бэкап политики, начатый
+// не позже at; для нулевого at - последний завершенный бэкап
+func (s *Service) snapshotAt(ctx context.Context, policyID string, at time.Time) (*types.BackupJob, error) {
+	jobs, _, err := s.listBackupJobs(ctx, JobFilter{
+		PolicyID:  policyID,
+		Status:    types.JobStatusCompleted,
+		Backups:   true,
+		StartedBy: at,
+		Limit:     1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) > 0 {
+		return jobs[0], nil
+	}
+
+	if !at.IsZero() {
+		return nil, fmt.Errorf("у политики %s нет завершенных бэкапов на %s", policyID, at.Format(time.RFC3339))
+	}
+	return nil, fmt.Errorf("у политики %s нет завершенных бэкапов", policyID)
+}
+
+// restoreBackup восстанавливает бэкап задачи в директорию извлечения ex
+//
+// Контрольная сумма бэкапа проверяется в конце потока, поэтому при ее
+// несовпадении уже извлеченные файлы остаются на диске.
+func (s *Service) restoreBackup(ctx context.Context, policy *types.BackupPolicy, job *types.BackupJob, tempDir string, ex *extractor) error {
+	archive, err := s.openArchive(ctx, policy, job, tempDir)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	if err := ex.extract(ctx, archive); err != nil {
+		return fmt.Errorf("ошибка распаковки бэкапа: %w", err)
+	}
+
+	// Дочитываем поток до конца, чтобы проверить контрольную сумму бэкапа
+	_, err = io.Copy(io.Discard, archive)
+	return err
+}
+
+// verifyArchive прочитывает бэкап задачи целиком, проверяя его контрольную сумму
+func (s *Service) verifyArchive(ctx context.Context, policy *types.BackupPolicy, job *types.BackupJob, tempDir string) error {
+	archive, err := s.openArchive(ctx, policy, job, tempDir)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	_, err = io.Copy(io.Discard, archive)
+	return err
+}
+
+// openArchive открывает бэкап задачи как поток tar.gz
+//
+// Бэкап читается из хранилища потоком, если хранилище это поддерживает,
+// и расшифровывается на лету; бэкап, разбитый на тома, читается по
+// одному тому. Несовпадение контрольной суммы бэкапа возвращается
+// ошибкой чтения в конце потока.
+func (s *Service) openArchive(ctx context.Context, policy *types.BackupPolicy, job *types.BackupJob, tempDir string) (io.ReadCloser, error) {
+	backupResult, err := s.getBackupResult(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !backupResult.Compressed {
+		return nil, fmt.Errorf("чтение поддерживается только для архивированных бэкапов")
+	}
+
+	storage, err := s.storageForJob(ctx, job)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка определения хранилища бэкапа: %w", err)
+	}
+
+	var source io.ReadCloser
+	if backupResult.Volumes > 0 {
+		source, err = s.openVolumes(ctx, storage, job.BackupPath, tempDir)
+	} else {
+		source, err = openObject(ctx, storage, job.BackupPath, tempDir)
+		if err == nil {
+			source = &checksumReader{ReadCloser: source, hash: sha256.New(), expected: backupResult.Checksum}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия бэкапа: %w", err)
+	}
+
+	if !backupResult.Encrypted {
+		return source, nil
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := s.decryptStream(ctx, source, pw, policy.EncryptionPassword); err != nil {
+			pw.CloseWithError(fmt.Errorf("ошибка расшифровки бэкапа: %w", err))
+			return
+		}
+		pw.Close()
+	}()
+
+	return &decryptingReader{PipeReader: pr, source: source, done: done}, nil
+}
+
+// decryptingReader поток, расшифровываемый в отдельной горутине
+type decryptingReader struct {
+	*io.PipeReader
+	source io.Closer
+	done   chan struct{}
+}
+
+// Close останавливает расшифровку и закрывает исходный поток
+func (dr *decryptingReader) Close() error {
+	dr.PipeReader.Close()
+	<-dr.done
+	return dr.source.Close()
+}
+
+// checksumReader сверяет SHA-256 прочитанного потока с ожидаемым в конце потока
+type checksumReader struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string // Пусто - не проверять
+}
+
+func (cr *checksumReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.hash.Write(p[:n])
+
+	if err == io.EOF && cr.expected != "" {
+		if checksum := hex.EncodeToString(cr.hash.Sum(nil)); checksum != cr.expected {
+			return n, fmt.Errorf("контрольная сумма не совпадает: %s, в каталоге %s", checksum, cr.expected)
+		}
+	}
+
+	return n, err
+}
+
+// normalizeInclude приводит шаблоны путей к виду относительно исходной
+// директории и проверяет их синтаксис
+func normalizeInclude(patterns []string) ([]string, error) {
+	var include []string
+	for _, pattern := range patterns {
+		normalized := strings.Trim(path.Clean("/"+pattern), "/")
+		if normalized == "" {
+			return nil, fmt.Errorf("пустой шаблон пути: %q", pattern)
+		}
+		for _, segment := range strings.Split(normalized, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("неверный шаблон пути %q: %w", pattern, err)
+			}
+		}
+		include = append(include, normalized)
+	}
+	return include, nil
+}
+
+// includeFilter возвращает фильтр записей архива бэкапа root: записи
+// извлекаются с путями относительно исходной директории, пустой
+// include выбирает все файлы
+func includeFilter(root string, include []string) entryFilter {
+	return func(name string) (string, bool) {
+		rel, ok := strings.CutPrefix(name, root+"/")
+		if !ok || rel == "" {
+			return "", false
+		}
+		if len(include) > 0 && !matchInclude(include, strings.TrimSuffix(rel, "/")) {
+			return "", false
+		}
+		return rel, true
+	}
+}
+
+// anyIncluded проверяет, подходит ли под шаблоны хотя бы один файл манифеста
+func anyIncluded(include []string, files []types.BackupFile) bool {
+	for _, file := range files {
+		if matchInclude(include, file.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchInclude проверяет, подходит ли под один из шаблонов путь
+// или одна из его родительских директорий
+func matchInclude(include []string, name string) bool {
+	for _, pattern := range include {
+		for dir := name; dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if matchGlob(pattern, dir) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchGlob сопоставляет путь с шаблоном: элементы пути сравниваются
+// по правилам path.Match, ** соответствует любому количеству директорий
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
@@ -0,0 +1,85 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotAt(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "history", map[string]string{"a.txt": "alpha"}, nil)
+	ctx := context.Background()
+
+	first := runTestBackup(t, service, policy.ID)
+	// Имя бэкапа и время создания задачи хранятся с точностью до секунды
+	time.Sleep(1100 * time.Millisecond)
+	second := runTestBackup(t, service, policy.ID)
+
+	latest, err := service.snapshotAt(ctx, policy.ID, time.Time{})
+	if err != nil || latest.ID != second.ID {
+		t.Fatalf("последний бэкап: %v, %v", latest, err)
+	}
+
+	// Момент между бэкапами задан в другом часовом поясе
+	at := first.StartedAt.Add(second.StartedAt.Sub(first.StartedAt) / 2).In(time.FixedZone("UTC+5", 5*3600))
+	job, err := service.snapshotAt(ctx, policy.ID, at)
+	if err != nil || job.ID != first.ID {
+		t.Fatalf("бэкап на %s: %v, %v", at, job, err)
+	}
+
+	if _, err := service.snapshotAt(ctx, policy.ID, first.StartedAt.Add(-time.Second)); err == nil {
+		t.Fatal("найден бэкап, начатый после запрошенного момента")
+	}
+}
+
+func TestRestoreRejectsSymlinkEscape(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "escape", map[string]string{"sub/a.txt": "alpha"}, nil)
+	runTestBackup(t, service, policy.ID)
+
+	// Поддиректория в директории восстановления ведет за ее пределы
+	outside := t.TempDir()
+	target := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(target, "sub")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := service.Restore(context.Background(), RestoreOptions{PolicyID: policy.ID, Target: target})
+	if err == nil {
+		t.Fatal("восстановление через символическую ссылку за пределы директории прошло без ошибки")
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Fatalf("за пределами директории восстановления записаны файлы: %v", entries)
+	}
+}
+
+func TestRestoreWithoutSnapshotVerifiesFirst(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "corrupted", map[string]string{"a.txt": "alpha"}, nil)
+	job := runTestBackup(t, service, policy.ID)
+
+	// Портим последний байт бэкапа: ошибка проявится только в конце потока
+	artifact := filepath.Join(service.config.Storage.LocalPath, job.BackupPath)
+	data, err := os.ReadFile(artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(artifact, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	target := t.TempDir()
+	writeTestFile(t, filepath.Join(target, "a.txt"), "local")
+
+	_, err = service.Restore(context.Background(), RestoreOptions{PolicyID: policy.ID, Target: target, NoSnapshot: true})
+	if err == nil {
+		t.Fatal("восстановление из поврежденного бэкапа прошло без ошибки")
+	}
+	if content, _ := os.ReadFile(filepath.Join(target, "a.txt")); string(content) != "local" {
+		t.Fatalf("файл заменен до проверки бэкапа: %q", content)
+	}
+}
@@ -0,0 +1,248 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// restoreTestOutputLimit сколько последних байт вывода команды проверки сохраняется в каталоге
+	restoreTestOutputLimit = 4096
+
+	// restoreTestMaxErrors сколько расхождений с манифестом попадает в текст ошибки
+	restoreTestMaxErrors = 5
+)
+
+// RunRestoreTest проверяет восстановление последнего бэкапа политики
+//
+// Бэкап восстанавливается во временную директорию, случайная выборка
+// файлов сравнивается с манифестом из каталога (размер, права, SHA-256),
+// затем выполняется команда проверки, выбранная в политике из
+// restore_test.commands конфигурации сервера. Результат
+// записывается в каталог и рассылается в уведомлениях; ошибка
+// возвращается, только если проверку не удалось начать.
+func (s *Service) RunRestoreTest(ctx context.Context, policyID string) (*types.RestoreTestResult, error) {
+	policy, err := s.getPolicy(ctx, policyID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения политики: %w", err)
+	}
+
+	var cfg types.RestoreTestConfig
+	if policy.RestoreTest != nil {
+		cfg = *policy.RestoreTest
+	}
+
+	result := &types.RestoreTestResult{
+		ID:        uuid.New().String(),
+		PolicyID:  policy.ID,
+		Status:    types.VerifyStatusPassed,
+		StartedAt: time.Now(),
+	}
+
+	if err := s.restoreTest(ctx, policy, cfg, result); err != nil {
+		result.Status = types.VerifyStatusFailed
+		result.Error = err.Error()
+	}
+	result.Duration = time.Since(result.StartedAt)
+
+	// Результат сохраняем и при отмене контекста
+	if err := s.saveRestoreTest(context.WithoutCancel(ctx), result); err != nil {
+		s.logger.WarnContext(ctx, "Ошибка сохранения результата проверки восстановления",
+			"policy_id", policy.ID,
+			"error", err.Error())
+	}
+
+	notification := types.Notification{
+		Event:    types.EventRestoreTestPassed,
+		PolicyID: policy.ID,
+		JobID:    result.JobID,
+		Message:  fmt.Sprintf("Проверка восстановления политики %s пройдена за %s", policy.Name, result.Duration.Round(time.Second)),
+		Data:     result,
+	}
+
+	if result.Status == types.VerifyStatusPassed {
+		s.logger.InfoContext(ctx, "Проверка восстановления пройдена",
+			"policy_id", policy.ID,
+			"job_id", result.JobID,
+			"files_checked", result.FilesChecked,
+			"restore_duration", result.RestoreDuration.String(),
+			"duration", result.Duration.String())
+	} else {
+		s.logger.ErrorContext(ctx, "Проверка восстановления не пройдена",
+			"policy_id", policy.ID,
+			"job_id", result.JobID,
+			"error", result.Error)
+
+		notification.Event = types.EventRestoreTestFailed
+		notification.Message = fmt.Sprintf("Проверка восстановления политики %s не пройдена: %s", policy.Name, result.Error)
+	}
+
+	s.notify(ctx, notification)
+
+	return result, nil
+}
+
+// restoreTest восстанавливает последний бэкап политики и проверяет его
+func (s *Service) restoreTest(ctx context.Context, policy *types.BackupPolicy, cfg types.RestoreTestConfig, result *types.RestoreTestResult) error {
+	job, err := s.snapshotAt(ctx, policy.ID, time.Time{})
+	if err != nil {
+		return err
+	}
+	result.JobID = job.ID
+
+	manifest, err := s.getBackupFiles(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+	if len(manifest) == 0 {
+		return fmt.Errorf("в каталоге нет манифеста бэкапа задачи %s", job.ID)
+	}
+
+	tempDir, err := os.MkdirTemp("", "backupist-restore-test-*")
+	if err != nil {
+		return fmt.Errorf("ошибка создания временной директории: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	restoreStart := time.Now()
+	restoreDir := filepath.Join(tempDir, "restore")
+	ex := &extractor{service: s, destPath: restoreDir, result: &RestoreResult{}}
+	if err := s.restoreBackup(ctx, policy, job, tempDir, ex); err != nil {
+		return err
+	}
+	result.RestoreDuration = time.Since(restoreStart)
+
+	// Файлы в архиве лежат в директории с именем бэкапа
+	root := filepath.Join(restoreDir, backupName(job.BackupPath))
+
+	sample := manifest
+	if cfg.SampleFiles > 0 && len(manifest) > cfg.SampleFiles {
+		sample = make([]types.BackupFile, len(manifest))
+		copy(sample, manifest)
+		rand.Shuffle(len(sample), func(i, j int) { sample[i], sample[j] = sample[j], sample[i] })
+		sample = sample[:cfg.SampleFiles]
+	}
+
+	var errs []error
+	for _, file := range sample {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		result.FilesChecked++
+		if err := compareRestoredFile(root, file); err != nil {
+			result.FilesFailed++
+			if len(errs) < restoreTestMaxErrors {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if result.FilesFailed > 0 {
+		return fmt.Errorf("%d из %d файлов не совпадают с манифестом: %w",
+			result.FilesFailed, result.FilesChecked, errors.Join(errs...))
+	}
+
+	if cfg.Command != "" {
+		command, err := s.restoreTestCommand(cfg.Command)
+		if err != nil {
+			return err
+		}
+		output, err := runRestoreCommand(ctx, command, cfg.Timeout, root, job)
+		result.CommandOutput = output
+		if err != nil {
+			return fmt.Errorf("команда проверки завершилась с ошибкой: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateRestoreTest проверяет, что команда проверки восстановления
+// политики разрешена в конфигурации сервера
+func (s *Service) validateRestoreTest(policy *types.BackupPolicy) error {
+	if policy.RestoreTest == nil || policy.RestoreTest.Command == "" {
+		return nil
+	}
+	_, err := s.restoreTestCommand(policy.RestoreTest.Command)
+	return err
+}
+
+// restoreTestCommand возвращает текст команды проверки по имени из конфигурации сервера
+func (s *Service) restoreTestCommand(name string) (string, error) {
+	command, ok := s.config.RestoreTest.Commands[name]
+	if !ok || command == "" {
+		return "", fmt.Errorf("команда проверки восстановления %q не задана в restore_test.commands", name)
+	}
+	return command, nil
+}
+
+// compareRestoredFile сравнивает восстановленный файл с записью манифеста
+func compareRestoredFile(root string, file types.BackupFile) error {
+	restoredPath := filepath.Join(root, filepath.FromSlash(file.Path))
+
+	info, err := os.Lstat(restoredPath)
+	if err != nil {
+		return fmt.Errorf("%s: файл не восстановлен: %w", file.Path, err)
+	}
+
+	if info.Size() != file.Size {
+		return fmt.Errorf("%s: размер %d, в манифесте %d", file.Path, info.Size(), file.Size)
+	}
+
+	if mode := uint32(info.Mode().Perm()); mode != file.Mode {
+		return fmt.Errorf("%s: права %04o, в манифесте %04o", file.Path, mode, file.Mode)
+	}
+
+	sum, err := hashFile(restoredPath, sha256.New(), 0, -1)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file.Path, err)
+	}
+	if checksum := hex.EncodeToString(sum); checksum != file.Checksum {
+		return fmt.Errorf("%s: SHA-256 %s, в манифесте %s", file.Path, checksum, file.Checksum)
+	}
+
+	return nil
+}
+
+// runRestoreCommand выполняет команду проверки в директории восстановленного бэкапа
+//
+// Команде передаются переменные окружения BACKUPIST_RESTORE_DIR,
+// BACKUPIST_POLICY_ID и BACKUPIST_JOB_ID. Возвращается конец вывода команды.
+func runRestoreCommand(ctx context.Context, command string, timeout time.Duration, root string, job *types.BackupJob) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(),
+		"BACKUPIST_RESTORE_DIR="+root,
+		"BACKUPIST_POLICY_ID="+job.PolicyID,
+		"BACKUPIST_JOB_ID="+job.ID,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if len(output) > restoreTestOutputLimit {
+		output = output[len(output)-restoreTestOutputLimit:]
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return string(output), fmt.Errorf("превышено время выполнения %s", timeout)
+	}
+
+	return string(output), err
+}
@@ -0,0 +1,53 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	"backupist/pkg/types"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRestoreTestCommandFromConfig(t *testing.T) {
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.RestoreTest.Commands = map[string]string{
+			"check": `test "$(cat a.txt)" = alpha && echo restored`,
+		}
+	})
+
+	// Текст команды в политике не запускается, а отклоняется при создании
+	err := service.CreatePolicy(context.Background(), &types.BackupPolicy{
+		Name:            "arbitrary",
+		SourcePath:      t.TempDir(),
+		DestinationPath: "backups",
+		RetentionCount:  5,
+		RestoreTest:     &types.RestoreTestConfig{Command: "touch /tmp/pwned"},
+	})
+	if !errors.Is(err, ErrInvalid) {
+		t.Fatalf("политика с командой не из конфигурации: %v", err)
+	}
+
+	policy := createTestPolicy(t, service, "checked", map[string]string{"a.txt": "alpha"}, func(policy *types.BackupPolicy) {
+		policy.RestoreTest = &types.RestoreTestConfig{Command: "check"}
+	})
+	runTestBackup(t, service, policy.ID)
+
+	result, err := service.RunRestoreTest(context.Background(), policy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != types.VerifyStatusPassed || !strings.Contains(result.CommandOutput, "restored") {
+		t.Fatalf("проверка восстановления: %s %q, вывод %q", result.Status, result.Error, result.CommandOutput)
+	}
+
+	// Команду убрали из конфигурации после создания политики
+	delete(service.config.RestoreTest.Commands, "check")
+	result, err = service.RunRestoreTest(context.Background(), policy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != types.VerifyStatusFailed || result.CommandOutput != "" {
+		t.Fatalf("проверка с удаленной командой: %s, вывод %q", result.Status, result.CommandOutput)
+	}
+}
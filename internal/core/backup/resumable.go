@@ -0,0 +1,763 @@
+package backup
+
+import (
+	"backupist/internal/logger"
+	"backupist/pkg/types"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// s3PartSize размер части многочастной загрузки S3
+var s3PartSize int64 = 64 * 1024 * 1024
+
+const (
+	// s3ParallelParts количество параллельно загружаемых частей S3 по умолчанию
+	s3ParallelParts = 4
+	// gcsChunkSize размер части возобновляемой загрузки GCS, кратный 256 КБ
+	gcsChunkSize = 16 * 1024 * 1024
+
+	gcsUploadURL = "https://storage.googleapis.com/upload/storage/v1/b/"
+	gcsScope     = "https://www.googleapis.com/auth/devstorage.read_write"
+
+	// uploadSessionTTL возраст, после которого незавершенная сессия считается
+	// брошенной; столько же живут возобновляемые сессии GCS
+	uploadSessionTTL = 7 * 24 * time.Hour
+	// uploadSessionSweepInterval как часто провайдер ищет брошенные сессии
+	uploadSessionSweepInterval = time.Hour
+)
+
+// errSessionExpired сессия загрузки больше не существует на стороне сервиса
+var errSessionExpired = errors.New("сессия загрузки истекла")
+
+// UploadSession незавершенная загрузка файла в хранилище
+//
+// Для S3 UploadID содержит идентификатор многочастной загрузки,
+// для GCS - адрес возобновляемой сессии.
+//
+// Сессия записывается в каталог до завершения загрузки под ключом из
+// бакета и пути объекта. Повтор загрузки того же файла в тот же путь
+// продолжает ее с записанных частей: и повтор операции (RetryStorage), и
+// загрузка готового бэкапа после перезапуска процесса (pendingUpload).
+// Сессии, не завершенные за uploadSessionTTL, прерываются в хранилище,
+// чтобы загруженные части не занимали место.
+type UploadSession struct {
+	Key       string
+	UploadID  string
+	Size      int64
+	PartSize  int64
+	CreatedAt time.Time
+}
+
+// uploadSessionStore хранилище сессий возобновляемой загрузки
+type uploadSessionStore interface {
+	loadUploadSession(ctx context.Context, key string) (*UploadSession, error)
+	saveUploadSession(ctx context.Context, session *UploadSession) error
+	deleteUploadSession(ctx context.Context, key string) error
+	listUploadSessions(ctx context.Context, prefix string, before time.Time) ([]*UploadSession, error)
+}
+
+// sessionSweeper ограничивает частоту поиска брошенных сессий провайдера
+type sessionSweeper struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// due сообщает, пора ли искать брошенные сессии, и отмечает время поиска
+func (sw *sessionSweeper) due() bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if time.Since(sw.last) < uploadSessionSweepInterval {
+		return false
+	}
+	sw.last = time.Now()
+	return true
+}
+
+// sweepUploadSessions прерывает в хранилище сессии с префиксом ключа prefix,
+// созданные раньше uploadSessionTTL, и удаляет их из каталога
+//
+// Сессия, которую не удалось прервать, остается до следующего поиска.
+func sweepUploadSessions(ctx context.Context, store uploadSessionStore, prefix string, abort func(ctx context.Context, session *UploadSession) error) error {
+	sessions, err := store.listUploadSessions(ctx, prefix, time.Now().Add(-uploadSessionTTL))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, session := range sessions {
+		if err := abort(ctx, session); err != nil {
+			errs = append(errs, fmt.Errorf("ошибка прерывания сессии загрузки %s: %w", session.Key, err))
+			continue
+		}
+		if err := store.deleteUploadSession(ctx, session.Key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// pendingUpload готовый бэкап, загрузка которого не завершена
+//
+// Файлы бэкапа хранятся в pendingUploadsDir до записи задачи в каталог,
+// поэтому загрузка, прерванная ошибкой или завершением процесса,
+// продолжается при следующем запуске политики или планировщика. Сессия
+// загрузки находится хранилищем по пути RemotePath.
+type pendingUpload struct {
+	JobID      string
+	PolicyID   string
+	LocalPath  string
+	ParityPath string
+	RemotePath string
+	Result     *types.BackupResult // Результат бэкапа с манифестом файлов
+	CreatedAt  time.Time
+}
+
+// pendingUploadsDir возвращает директорию готовых бэкапов с незавершенной
+// загрузкой: рядом с базой данных, чтобы они пережили перезапуск процесса
+func (s *Service) pendingUploadsDir() string {
+	path := s.config.Database.Path
+	if path == "" || path == ":memory:" {
+		return filepath.Join(os.TempDir(), "backupist-uploads")
+	}
+	return filepath.Join(filepath.Dir(path), "uploads")
+}
+
+// stagePendingUpload переносит готовый бэкап задачи и данные четности
+// в pendingUploadsDir и записывает загрузку в каталог
+func (s *Service) stagePendingUpload(ctx context.Context, job *types.BackupJob, backupPath, parityPath, remotePath string, result *types.BackupResult) (*pendingUpload, error) {
+	dir := filepath.Join(s.pendingUploadsDir(), job.ID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("ошибка создания директории незавершенных загрузок: %w", err)
+	}
+
+	upload := &pendingUpload{
+		JobID:      job.ID,
+		PolicyID:   job.PolicyID,
+		LocalPath:  filepath.Join(dir, filepath.Base(backupPath)),
+		RemotePath: remotePath,
+		Result:     result,
+		CreatedAt:  time.Now(),
+	}
+	if parityPath != "" {
+		upload.ParityPath = filepath.Join(dir, filepath.Base(parityPath))
+	}
+
+	err := moveFile(backupPath, upload.LocalPath)
+	if err == nil && parityPath != "" {
+		err = moveFile(parityPath, upload.ParityPath)
+	}
+	if err == nil {
+		err = s.savePendingUpload(ctx, upload)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("ошибка сохранения готового бэкапа: %w", err)
+	}
+
+	return upload, nil
+}
+
+// discardPendingUpload удаляет готовый бэкап задачи и запись о его загрузке
+func (s *Service) discardPendingUpload(ctx context.Context, jobID string) {
+	ctx = context.WithoutCancel(ctx)
+	if err := s.deletePendingUpload(ctx, jobID); err != nil {
+		s.logger.WarnContext(ctx, "Ошибка удаления незавершенной загрузки", "job_id", jobID, "error", err.Error())
+		return
+	}
+	os.RemoveAll(filepath.Join(s.pendingUploadsDir(), jobID))
+}
+
+// moveFile переносит файл; между файловыми системами - копированием
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// ResumeUploads продолжает загрузки бэкапов, прерванные ошибкой или
+// завершением процесса
+//
+// Загрузка политики, которую выполняет другая задача, пропускается: задача
+// продолжит ее сама перед новым бэкапом.
+func (s *Service) ResumeUploads(ctx context.Context) error {
+	uploads, err := s.getPendingUploads(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, upload := range uploads {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		lock, _, err := s.tryLockPolicy(ctx, upload.PolicyID, upload.JobID)
+		if err == nil && lock == nil {
+			continue
+		}
+		if err == nil {
+			lock.keep(ctx)
+			err = s.resumeUpload(ctx, upload)
+			lock.release(ctx)
+		}
+		if err != nil {
+			failed++
+			s.logger.WarnContext(ctx, "Ошибка продолжения загрузки бэкапа",
+				"job_id", upload.JobID,
+				"remote_path", upload.RemotePath,
+				"error", err.Error())
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("не удалось продолжить %d из %d загрузок", failed, len(uploads))
+	}
+
+	return nil
+}
+
+// resumePolicyUploads продолжает незавершенные загрузки политики, блокировку
+// которой удерживает вызывающий; ошибки записываются в журнал
+func (s *Service) resumePolicyUploads(ctx context.Context, policyID string) {
+	uploads, err := s.getPendingUploads(ctx, policyID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "Ошибка получения незавершенных загрузок", "policy_id", policyID, "error", err.Error())
+		return
+	}
+
+	for _, upload := range uploads {
+		if err := s.resumeUpload(ctx, upload); err != nil {
+			s.logger.WarnContext(ctx, "Ошибка продолжения загрузки бэкапа",
+				"job_id", upload.JobID,
+				"remote_path", upload.RemotePath,
+				"error", err.Error())
+		}
+	}
+}
+
+// resumeUpload завершает загрузку готового бэкапа и записывает задачу
+// в каталог как завершенную
+//
+// Загрузка, не завершенная за uploadSessionTTL, и загрузка без файла
+// бэкапа удаляются: задача остается в статусе failed.
+func (s *Service) resumeUpload(ctx context.Context, upload *pendingUpload) error {
+	job, err := s.getBackupJob(ctx, upload.JobID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения задачи: %w", err)
+	}
+
+	if time.Since(upload.CreatedAt) > uploadSessionTTL {
+		err = fmt.Errorf("загрузка не завершена за %s", uploadSessionTTL)
+	} else if _, statErr := os.Stat(upload.LocalPath); statErr != nil {
+		err = fmt.Errorf("ошибка доступа к готовому бэкапу: %w", statErr)
+	}
+	if err != nil {
+		s.discardPendingUpload(ctx, upload.JobID)
+		s.failInterrupted(ctx, job, err)
+		return err
+	}
+
+	policy, err := s.getPolicy(ctx, upload.PolicyID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения политики: %w", err)
+	}
+	storage, err := s.storageForJob(ctx, job)
+	if err != nil {
+		return err
+	}
+	if policy.Bandwidth != nil {
+		limiter, err := NewBandwidthLimiter(*policy.Bandwidth)
+		if err != nil {
+			return err
+		}
+		ctx = WithBandwidth(ctx, limiter)
+	}
+
+	s.logger.InfoContext(ctx, "Продолжение загрузки бэкапа",
+		"job_id", job.ID,
+		"remote_path", upload.RemotePath)
+
+	startTime := time.Now()
+	if err := s.uploadBackup(ctx, storage, upload, logger.NewBackupLogger(job.ID, job.PolicyID)); err != nil {
+		s.failInterrupted(ctx, job, err)
+		return err
+	}
+
+	result := upload.Result
+	completedAt := time.Now()
+	job.Status = types.JobStatusCompleted
+	job.Error = ""
+	job.CompletedAt = &completedAt
+	job.BackupPath = result.BackupPath
+	job.FilesProcessed = result.FilesProcessed
+	job.TotalSize = result.TotalSize
+
+	result.JobID = job.ID
+	result.Duration = time.Since(startTime)
+
+	if err := s.saveCompletedBackup(context.WithoutCancel(ctx), job, result); err != nil {
+		return err
+	}
+
+	if len(policy.CopyTargets) > 0 {
+		s.replicateBackup(ctx, policy, job)
+	}
+	s.publishStatus(job)
+
+	return nil
+}
+
+// failInterrupted переводит в статус failed задачу, процесс которой
+// завершился во время загрузки; задачи, завершенные ошибкой, не меняются
+func (s *Service) failInterrupted(ctx context.Context, job *types.BackupJob, err error) {
+	if JobFinished(job.Status) {
+		return
+	}
+	s.markFailed(ctx, job, err)
+}
+
+// resumableUploader провайдер, поддерживающий возобновляемую загрузку
+type resumableUploader interface {
+	setUploadSessions(store uploadSessionStore)
+}
+
+// setUploadSessions подключает хранилище сессий многочастной загрузки
+func (s3 *S3Storage) setUploadSessions(store uploadSessionStore) {
+	s3.sessions = store
+}
+
+// uploadMultipart загружает файл в S3 по частям
+//
+// Идентификатор загрузки сохраняется до ее завершения. При повторе
+// уже записанные части, чья контрольная сумма совпадает с локальной,
+// не загружаются заново.
+func (s3 *S3Storage) uploadMultipart(ctx context.Context, localPath string, size int64, remotePath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия локального файла: %w", err)
+	}
+	defer file.Close()
+
+	core := minio.Core{Client: s3.client}
+	prefix := "s3://" + s3.bucketName + "/"
+	key := prefix + remotePath
+
+	// Ошибка поиска брошенных сессий не мешает загрузке: они будут прерваны позже
+	if s3.sweeper.due() {
+		sweepUploadSessions(ctx, s3.sessions, prefix, func(ctx context.Context, session *UploadSession) error {
+			err := core.AbortMultipartUpload(ctx, s3.bucketName, strings.TrimPrefix(session.Key, prefix), session.UploadID)
+			if err != nil && minio.ToErrorResponse(err).Code != "NoSuchUpload" {
+				return err
+			}
+			return nil
+		})
+	}
+
+	session, err := s3.sessions.loadUploadSession(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	// Файл с тем же именем изменился: начинаем загрузку заново
+	if session != nil && (session.Size != size || session.PartSize != s3PartSize) {
+		core.AbortMultipartUpload(ctx, s3.bucketName, remotePath, session.UploadID)
+		session = nil
+	}
+
+	uploaded := make(map[int]minio.ObjectPart)
+	if session != nil {
+		uploaded, err = s3.listParts(ctx, core, remotePath, session.UploadID)
+		if err != nil {
+			if minio.ToErrorResponse(err).Code != "NoSuchUpload" {
+				return fmt.Errorf("ошибка получения частей загрузки S3: %w", err)
+			}
+			session = nil
+		}
+	}
+
+	if session == nil {
+		uploadID, err := core.NewMultipartUpload(ctx, s3.bucketName, remotePath, minio.PutObjectOptions{
+			ContentType: "application/octet-stream",
+		})
+		if err != nil {
+			return fmt.Errorf("ошибка начала многочастной загрузки в S3: %w", err)
+		}
+
+		session = &UploadSession{
+			Key:       key,
+			UploadID:  uploadID,
+			Size:      size,
+			PartSize:  s3PartSize,
+			CreatedAt: time.Now(),
+		}
+		if err := s3.sessions.saveUploadSession(ctx, session); err != nil {
+			return err
+		}
+	}
+
+	parts, err := s3.uploadParts(ctx, core, file, size, remotePath, session.UploadID, uploaded)
+	if err != nil {
+		s3.abortCancelled(ctx, core, remotePath, session)
+		return err
+	}
+
+	_, err = core.CompleteMultipartUpload(ctx, s3.bucketName, remotePath, session.UploadID, parts, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+	})
+	if err != nil {
+		s3.abortCancelled(ctx, core, remotePath, session)
+		return fmt.Errorf("ошибка завершения многочастной загрузки в S3: %w", err)
+	}
+
+	return s3.sessions.deleteUploadSession(ctx, key)
+}
+
+// abortCancelled прерывает многочастную загрузку, остановленную отменой
+// задачи: она не будет возобновлена, а загруженные части занимают место в бакете
+func (s3 *S3Storage) abortCancelled(ctx context.Context, core minio.Core, remotePath string, session *UploadSession) {
+	if ctx.Err() == nil {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	core.AbortMultipartUpload(ctx, s3.bucketName, remotePath, session.UploadID)
+	s3.sessions.deleteUploadSession(ctx, session.Key)
+}
+
+// uploadParts параллельно загружает недостающие части и возвращает список частей по порядку
+//
+// Количество одновременно загружаемых частей ограничено max_parallel_parts.
+func (s3 *S3Storage) uploadParts(ctx context.Context, core minio.Core, file *os.File, size int64, remotePath, uploadID string, uploaded map[int]minio.ObjectPart) ([]minio.CompletePart, error) {
+	partCount := int((size + s3PartSize - 1) / s3PartSize)
+	parts := make([]minio.CompletePart, partCount)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	semaphore := make(chan struct{}, maxParallelParts(ctx, s3ParallelParts))
+
+	for i := 0; i < partCount; i++ {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(number int, offset, length int64) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			hash := md5.New()
+			if _, err := io.Copy(hash, io.NewSectionReader(file, offset, length)); err != nil {
+				fail(fmt.Errorf("ошибка чтения части %d: %w", number, err))
+				return
+			}
+			sum := hash.Sum(nil)
+
+			// Часть уже записана при прошлой попытке
+			if part, ok := uploaded[number]; ok && part.Size == length && strings.Trim(part.ETag, `"`) == hex.EncodeToString(sum) {
+				parts[number-1] = minio.CompletePart{PartNumber: number, ETag: part.ETag}
+				return
+			}
+
+			body := throttleReader(ctx, io.NewSectionReader(file, offset, length), directionUpload)
+			part, err := core.PutObjectPart(ctx, s3.bucketName, remotePath, uploadID, number, body, length,
+				minio.PutObjectPartOptions{Md5Base64: base64.StdEncoding.EncodeToString(sum)})
+			if err != nil {
+				fail(fmt.Errorf("ошибка загрузки части %d в S3: %w", number, err))
+				return
+			}
+			parts[number-1] = minio.CompletePart{PartNumber: number, ETag: part.ETag}
+		}(i+1, int64(i)*s3PartSize, min(int64(s3PartSize), size-int64(i)*s3PartSize))
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return parts, nil
+}
+
+// listParts возвращает записанные части многочастной загрузки по номерам
+func (s3 *S3Storage) listParts(ctx context.Context, core minio.Core, remotePath, uploadID string) (map[int]minio.ObjectPart, error) {
+	parts := make(map[int]minio.ObjectPart)
+
+	marker := 0
+	for {
+		result, err := core.ListObjectParts(ctx, s3.bucketName, remotePath, uploadID, marker, 1000)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, part := range result.ObjectParts {
+			parts[part.PartNumber] = part
+		}
+
+		if !result.IsTruncated {
+			return parts, nil
+		}
+		marker = result.NextPartNumberMarker
+	}
+}
+
+// setUploadSessions подключает хранилище сессий возобновляемой загрузки
+func (gcs *GCSStorage) setUploadSessions(store uploadSessionStore) {
+	gcs.sessions = store
+}
+
+// uploadResumable загружает файл в GCS через возобновляемую сессию
+//
+// Адрес сессии сохраняется до завершения загрузки. При повторе сервис
+// сообщает, сколько байт уже получено, и загрузка продолжается с этого места.
+func (gcs *GCSStorage) uploadResumable(ctx context.Context, file *os.File, size int64, remotePath string) error {
+	client, _, err := htransport.NewClient(ctx, append(gcs.options, option.WithScopes(gcsScope))...)
+	if err != nil {
+		return fmt.Errorf("ошибка создания HTTP клиента GCS: %w", err)
+	}
+
+	prefix := "gs://" + gcs.bucketName + "/"
+	key := prefix + remotePath
+
+	// Ошибка поиска брошенных сессий не мешает загрузке: они будут прерваны позже
+	if gcs.sweeper.due() {
+		sweepUploadSessions(ctx, gcs.sessions, prefix, func(ctx context.Context, session *UploadSession) error {
+			return gcsDeleteSession(ctx, client, session.UploadID)
+		})
+	}
+
+	session, err := gcs.sessions.loadUploadSession(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if session != nil && (session.Size != size || session.PartSize != gcsChunkSize) {
+		session = nil
+	}
+
+	var offset int64
+	if session != nil {
+		var done bool
+		offset, done, err = gcsSessionStatus(ctx, client, session.UploadID, size)
+		switch {
+		case errors.Is(err, errSessionExpired):
+			session, offset = nil, 0
+		case err != nil:
+			return err
+		case done:
+			return gcs.sessions.deleteUploadSession(ctx, key)
+		}
+	}
+
+	if session == nil {
+		sessionURL, err := gcs.startSession(ctx, client, remotePath, size)
+		if err != nil {
+			return err
+		}
+
+		session = &UploadSession{
+			Key:       key,
+			UploadID:  sessionURL,
+			Size:      size,
+			PartSize:  gcsChunkSize,
+			CreatedAt: time.Now(),
+		}
+		if err := gcs.sessions.saveUploadSession(ctx, session); err != nil {
+			return err
+		}
+	}
+
+	for offset < size {
+		length := min(int64(gcsChunkSize), size-offset)
+
+		body := throttleReader(ctx, io.NewSectionReader(file, offset, length), directionUpload)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, session.UploadID, body)
+		if err != nil {
+			return fmt.Errorf("ошибка создания запроса GCS: %w", err)
+		}
+		req.ContentLength = length
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size))
+
+		next, done, err := gcsUploadResponse(client.Do(req))
+		if err != nil {
+			gcs.cancelSession(ctx, client, session)
+			return fmt.Errorf("ошибка загрузки части в GCS: %w", err)
+		}
+		if done {
+			break
+		}
+		offset = next
+	}
+
+	return gcs.sessions.deleteUploadSession(ctx, key)
+}
+
+// cancelSession удаляет сессию загрузки, остановленную отменой задачи,
+// вместе с уже полученными сервисом данными
+func (gcs *GCSStorage) cancelSession(ctx context.Context, client *http.Client, session *UploadSession) {
+	if ctx.Err() == nil {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	gcsDeleteSession(ctx, client, session.UploadID)
+	gcs.sessions.deleteUploadSession(ctx, session.Key)
+}
+
+// gcsDeleteSession удаляет сессию загрузки GCS вместе с полученными данными
+func gcsDeleteSession(ctx context.Context, client *http.Client, sessionURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, sessionURL, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса GCS: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Сервис отвечает на отмену сессии статусом 499; истекшей сессии уже нет
+	switch resp.StatusCode {
+	case 499, http.StatusNoContent, http.StatusNotFound, http.StatusGone:
+		return nil
+	}
+	return googleapi.CheckResponse(resp)
+}
+
+// startSession создает возобновляемую сессию загрузки и возвращает ее адрес
+func (gcs *GCSStorage) startSession(ctx context.Context, client *http.Client, remotePath string, size int64) (string, error) {
+	endpoint := gcsUploadURL + url.PathEscape(gcs.bucketName) + "/o?uploadType=resumable&name=" + url.QueryEscape(remotePath)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader([]byte("{}")))
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания запроса GCS: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Type", "application/octet-stream")
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания сессии загрузки GCS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return "", fmt.Errorf("ошибка создания сессии загрузки GCS: %w", err)
+	}
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("сервис GCS не вернул адрес сессии загрузки")
+	}
+
+	return location, nil
+}
+
+// gcsSessionStatus запрашивает, сколько байт уже получено сессией загрузки
+func gcsSessionStatus(ctx context.Context, client *http.Client, sessionURL string, size int64) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, sessionURL, http.NoBody)
+	if err != nil {
+		return 0, false, fmt.Errorf("ошибка создания запроса GCS: %w", err)
+	}
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+
+	offset, done, err := gcsUploadResponse(client.Do(req))
+	if err != nil {
+		return 0, false, fmt.Errorf("ошибка получения состояния сессии загрузки GCS: %w", err)
+	}
+
+	return offset, done, nil
+}
+
+// gcsUploadResponse разбирает ответ на запрос к сессии загрузки
+//
+// Возвращает смещение следующей части или признак завершения загрузки.
+func gcsUploadResponse(resp *http.Response, err error) (int64, bool, error) {
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return 0, true, nil
+	case http.StatusPermanentRedirect:
+		// Заголовок Range вида bytes=0-N содержит полученные байты
+		received := resp.Header.Get("Range")
+		if received == "" {
+			return 0, false, nil
+		}
+		last, err := strconv.ParseInt(received[strings.LastIndex(received, "-")+1:], 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("некорректный заголовок Range: %s", received)
+		}
+		return last + 1, false, nil
+	case http.StatusNotFound, http.StatusGone:
+		return 0, false, errSessionExpired
+	}
+
+	return 0, false, googleapi.CheckResponse(resp)
+}
@@ -0,0 +1,344 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	"backupist/pkg/types"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 сервис S3 в памяти с многочастной загрузкой для одного бакета
+//
+// После failAfter принятых частей загрузки следующие части отклоняются,
+// как при обрыве связи; 0 - без ограничения.
+type fakeS3 struct {
+	mu        sync.Mutex
+	objects   map[string][]byte
+	partSizes map[string]int64
+	uploads   map[string]map[int][]byte
+	partPuts  map[int]int
+	failAfter int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	t.Helper()
+
+	fake := &fakeS3{
+		objects:   make(map[string][]byte),
+		partSizes: make(map[string]int64),
+		uploads:   make(map[string]map[int][]byte),
+		partPuts:  make(map[int]int),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+	body, _ := io.ReadAll(r.Body)
+	if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		body = decodeAWSChunked(body)
+	}
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID = strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[uploadID] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", bucket, key, uploadID)
+
+	case r.Method == http.MethodPut && uploadID != "":
+		parts, ok := f.uploads[uploadID]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		if f.failAfter > 0 && len(parts) >= f.failAfter {
+			f.fail(w, http.StatusForbidden, "AccessDenied")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[number] = body
+		f.partPuts[number]++
+		w.Header().Set("ETag", md5ETag(body))
+
+	case r.Method == http.MethodGet && uploadID != "":
+		parts, ok := f.uploads[uploadID]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		fmt.Fprint(w, "<ListPartsResult><IsTruncated>false</IsTruncated>")
+		for number, data := range parts {
+			fmt.Fprintf(w, "<Part><PartNumber>%d</PartNumber><ETag>%s</ETag><Size>%d</Size></Part>", number, md5ETag(data), len(data))
+		}
+		fmt.Fprint(w, "</ListPartsResult>")
+
+	case r.Method == http.MethodPost && uploadID != "":
+		parts := f.uploads[uploadID]
+		numbers := make([]int, 0, len(parts))
+		for number := range parts {
+			numbers = append(numbers, number)
+		}
+		slices.Sort(numbers)
+
+		var object []byte
+		combined := md5.New()
+		for _, number := range numbers {
+			object = append(object, parts[number]...)
+			sum := md5.Sum(parts[number])
+			combined.Write(sum[:])
+		}
+		f.objects[key] = object
+		f.partSizes[key] = int64(len(parts[1]))
+		delete(f.uploads, uploadID)
+
+		etag := fmt.Sprintf(`"%x-%d"`, combined.Sum(nil), len(numbers))
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>%s</ETag></CompleteMultipartUploadResult>", bucket, key, etag)
+
+	case r.Method == http.MethodDelete && uploadID != "":
+		delete(f.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		delete(f.partSizes, key)
+		w.Header().Set("ETag", md5ETag(body))
+
+	case r.Method == http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		size := int64(len(object))
+		if query.Has("partNumber") {
+			size = f.partSizes[key]
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", f.etag(key))
+
+	default:
+		f.fail(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// etag возвращает ETag объекта: MD5 содержимого или составной ETag частей
+func (f *fakeS3) etag(key string) string {
+	object, partSize := f.objects[key], f.partSizes[key]
+	if partSize == 0 {
+		return md5ETag(object)
+	}
+
+	combined := md5.New()
+	var count int
+	for offset := int64(0); offset < int64(len(object)); offset += partSize {
+		sum := md5.Sum(object[offset:min(offset+partSize, int64(len(object)))])
+		combined.Write(sum[:])
+		count++
+	}
+	return fmt.Sprintf(`"%x-%d"`, combined.Sum(nil), count)
+}
+
+// fail отвечает ошибкой S3 с кодом code
+func (f *fakeS3) fail(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+// decodeAWSChunked извлекает данные из тела с подписью по частям:
+// "размер;chunk-signature=...\r\nданные\r\n", последняя часть пустая
+func decodeAWSChunked(body []byte) []byte {
+	var data []byte
+	for {
+		header, rest, ok := bytes.Cut(body, []byte("\r\n"))
+		if !ok {
+			return data
+		}
+		sizeHex, _, _ := bytes.Cut(header, []byte(";"))
+		size, err := strconv.ParseInt(string(sizeHex), 16, 64)
+		if err != nil || size == 0 || int64(len(rest)) < size {
+			return data
+		}
+		data = append(data, rest[:size]...)
+		body = bytes.TrimPrefix(rest[size:], []byte("\r\n"))
+	}
+}
+
+// md5ETag возвращает ETag содержимого в кавычках
+func md5ETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func TestSweepUploadSessions(t *testing.T) {
+	service := newTestService(t, nil)
+	ctx := context.Background()
+
+	expired := time.Now().Add(-uploadSessionTTL - time.Hour)
+	for _, session := range []*UploadSession{
+		{Key: "s3://bucket/a.tar", UploadID: "a", CreatedAt: expired},
+		{Key: "s3://bucket/b.tar", UploadID: "b", CreatedAt: expired},
+		{Key: "s3://bucket/fresh.tar", UploadID: "fresh", CreatedAt: time.Now()},
+		{Key: "s3://other/c.tar", UploadID: "c", CreatedAt: expired},
+	} {
+		if err := service.saveUploadSession(ctx, session); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Прервать сессию b не удалось: она остается до следующего поиска
+	var aborted []string
+	err := sweepUploadSessions(ctx, service, "s3://bucket/", func(ctx context.Context, session *UploadSession) error {
+		aborted = append(aborted, session.UploadID)
+		if session.UploadID == "b" {
+			return errors.New("хранилище недоступно")
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("ошибка прерывания сессии не возвращена")
+	}
+	slices.Sort(aborted)
+	if !slices.Equal(aborted, []string{"a", "b"}) {
+		t.Fatalf("прерваны сессии %v, ожидались только истекшие сессии бакета", aborted)
+	}
+
+	for key, want := range map[string]bool{
+		"s3://bucket/a.tar":     false,
+		"s3://bucket/b.tar":     true,
+		"s3://bucket/fresh.tar": true,
+		"s3://other/c.tar":      true,
+	} {
+		session, err := service.loadUploadSession(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (session != nil) != want {
+			t.Fatalf("сессия %s в каталоге: %v, ожидалось %v", key, session != nil, want)
+		}
+	}
+
+	var sweeper sessionSweeper
+	if !sweeper.due() || sweeper.due() {
+		t.Fatal("поиск брошенных сессий должен выполняться не чаще uploadSessionSweepInterval")
+	}
+}
+
+func TestResumeUploadAfterRestart(t *testing.T) {
+	fake, server := newFakeS3(t)
+	s3PartSize = 16 * 1024
+	t.Cleanup(func() { s3PartSize = 64 * 1024 * 1024 })
+
+	first := newTestService(t, func(cfg *config.Config) {
+		cfg.Storage.Configs["s3"] = types.StorageConfig{
+			Type: types.StorageTypeS3,
+			S3Config: &types.S3Config{
+				Bucket:          "backups",
+				Region:          "us-east-1",
+				AccessKeyID:     "key",
+				SecretAccessKey: "secret",
+				Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+			},
+		}
+	})
+	policy := createTestPolicy(t, first, "resume", nil, func(policy *types.BackupPolicy) {
+		writeTestFile(t, filepath.Join(policy.SourcePath, "data.bin"), string(randomData(t, 100*1024)))
+		policy.DestinationPath = "storage://s3/daily"
+		// Части загружаются по одной, поэтому обрыв после трех частей детерминирован
+		policy.Bandwidth = &types.BandwidthConfig{MaxParallelParts: 1}
+	})
+	ctx := context.Background()
+
+	// Связь обрывается после трех частей: задача завершается ошибкой,
+	// готовый бэкап и сессия загрузки остаются
+	fake.failAfter = 3
+	job, err := first.CreatePolicyJob(ctx, policy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.ExecuteBackup(ctx, job); err == nil || !strings.Contains(err.Error(), "загрузка будет продолжена") {
+		t.Fatalf("бэкап с обрывом загрузки: %v", err)
+	}
+	uploads, err := first.getPendingUploads(ctx, policy.ID)
+	if err != nil || len(uploads) != 1 {
+		t.Fatalf("незавершенные загрузки: %+v, %v", uploads, err)
+	}
+	artifact, err := os.ReadFile(uploads[0].LocalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Новый процесс с тем же каталогом продолжает загрузку с четвертой части
+	fake.failAfter = 0
+	second := NewService(first.config, first.logger)
+	if err := second.Initialize(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { second.Close() })
+
+	if err := second.ResumeUploads(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := int((int64(len(artifact)) + s3PartSize - 1) / s3PartSize)
+	for number := 1; number <= parts; number++ {
+		if fake.partPuts[number] != 1 {
+			t.Fatalf("часть %d загружена %d раз: %v", number, fake.partPuts[number], fake.partPuts)
+		}
+	}
+
+	job, err = second.getBackupJob(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := second.getBackupResult(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != types.JobStatusCompleted || job.Error != "" || !strings.HasPrefix(result.RemoteChecksum, "etag:") {
+		t.Fatalf("задача после продолжения загрузки: %+v, результат %+v", job, result)
+	}
+	sum := sha256.Sum256(fake.objects[job.BackupPath])
+	if !bytes.Equal(fake.objects[job.BackupPath], artifact) || hex.EncodeToString(sum[:]) != result.Checksum {
+		t.Fatalf("объект в хранилище не совпадает с готовым бэкапом")
+	}
+	if indexed, err := second.isJobIndexed(ctx, job.ID); err != nil || !indexed {
+		t.Fatalf("индекс файлов бэкапа: %v, %v", indexed, err)
+	}
+
+	// Готовый бэкап и запись о загрузке удалены
+	if uploads, err := second.getPendingUploads(ctx, ""); err != nil || len(uploads) != 0 {
+		t.Fatalf("незавершенные загрузки после продолжения: %+v, %v", uploads, err)
+	}
+	if _, err := os.Stat(filepath.Join(second.pendingUploadsDir(), job.ID)); !os.IsNotExist(err) {
+		t.Fatalf("готовый бэкап не удален: %v", err)
+	}
+}
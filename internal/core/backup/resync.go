@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"fmt"
+	"time"
+)
+
+// recordResync отмечает в каталоге копию, перенесенную фоновой синхронизацией MultiStorage
+func (s *Service) recordResync(result types.TargetResult) {
+	ctx := context.Background()
+
+	if err := s.updateTargetResult(ctx, result); err != nil {
+		s.logger.WarnContext(ctx, "Ошибка сохранения результата синхронизации",
+			"target", result.Target,
+			"remote_path", result.RemotePath,
+			"error", err.Error())
+		return
+	}
+
+	s.logger.InfoContext(ctx, "Копия бэкапа синхронизирована",
+		"target", result.Target,
+		"remote_path", result.RemotePath,
+		"duration", result.Duration.String())
+}
+
+// ResyncTargets переносит копии, которые не удалось записать, по данным каталога
+//
+// В отличие от фоновой синхронизации MultiStorage, очередь берется из БД
+// и переживает перезапуск приложения.
+func (s *Service) ResyncTargets(ctx context.Context) error {
+	targets, err := s.getFailedTargets(ctx)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err := s.resyncTarget(ctx, target); err != nil {
+			failed++
+			s.logger.WarnContext(ctx, "Ошибка синхронизации копии бэкапа",
+				"job_id", target.JobID,
+				"target", target.Result.Target,
+				"remote_path", target.Result.RemotePath,
+				"error", err.Error())
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("не удалось синхронизировать %d из %d копий", failed, len(targets))
+	}
+
+	return nil
+}
+
+// resyncTarget переносит одну копию бэкапа в хранилище MultiStorage политики
+func (s *Service) resyncTarget(ctx context.Context, target failedTarget) error {
+	policy, err := s.getPolicy(ctx, target.PolicyID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения политики: %w", err)
+	}
+
+	storage, _, err := s.resolveDestination(policy.DestinationPath)
+	if err != nil {
+		return fmt.Errorf("ошибка определения хранилища политики: %w", err)
+	}
+
+	multi, ok := multiStorageOf(storage)
+	if !ok {
+		return fmt.Errorf("хранилище политики не является MultiStorage")
+	}
+
+	startTime := time.Now()
+	if err := multi.Replicate(ctx, target.Result.RemotePath, target.Result.Target); err != nil {
+		return err
+	}
+
+	s.recordResync(types.TargetResult{
+		Target:     target.Result.Target,
+		RemotePath: target.Result.RemotePath,
+		Status:     types.TargetStatusResynced,
+		Duration:   time.Since(startTime),
+		UpdatedAt:  time.Now(),
+	})
+
+	return nil
+}
+
+// multiStorageOf возвращает MultiStorage под обертками префикса и ограничения
+// скорости; пути копий в результатах записаны относительно самого MultiStorage
+func multiStorageOf(storage StorageProvider) (*MultiStorage, bool) {
+	for {
+		switch st := storage.(type) {
+		case *MultiStorage:
+			return st, true
+		case *prefixedStorage:
+			storage = st.storage
+		case *bandwidthStorage:
+			storage = st.storage
+		default:
+			return nil, false
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	"backupist/pkg/types"
+	"path/filepath"
+	"testing"
+)
+
+func TestMultiStorageOfPrefixed(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.NewConfig()
+	cfg.Storage.Configs = map[string]types.StorageConfig{
+		"mirror": {
+			Type: types.StorageTypeMulti,
+			MultiConfig: &types.MultiConfig{
+				Targets: []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")},
+				Quorum:  "all",
+			},
+		},
+	}
+	factory := NewStorageFactory(cfg)
+	t.Cleanup(func() { factory.Close() })
+
+	// Хранилище по умолчанию с путем оборачивает MultiStorage префиксом
+	storage, err := factory.CreateStorage("storage://mirror/daily")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := storage.(*prefixedStorage); !ok {
+		t.Fatalf("ожидалось хранилище с префиксом, получено %T", storage)
+	}
+	if _, ok := multiStorageOf(storage); !ok {
+		t.Fatal("MultiStorage под префиксом не найден")
+	}
+
+	if _, ok := multiStorageOf(NewLocalStorage(dir)); ok {
+		t.Fatal("локальное хранилище принято за MultiStorage")
+	}
+}
@@ -0,0 +1,258 @@
+package backup
+
+import (
+	storageapi "backupist/pkg/storage"
+	"backupist/pkg/types"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/sftp"
+	"google.golang.org/api/googleapi"
+)
+
+// RetryHandler вызывается перед повтором операции с хранилищем
+type RetryHandler func(op string, attempt int, delay time.Duration, err error)
+
+// RetryStorage повторяет операции хранилища при временных ошибках
+//
+// Пауза между попытками растет экспоненциально и выбирается случайно
+// в диапазоне от половины до полного значения, чтобы параллельные
+// задачи не повторяли запросы одновременно. Провайдеры S3 и GCS
+// продолжают прерванную загрузку с последней записанной части.
+type RetryStorage struct {
+	storage StorageProvider
+	config  types.RetryConfig
+	onRetry RetryHandler
+}
+
+// NewRetryStorage оборачивает хранилище повтором операций
+func NewRetryStorage(storage StorageProvider, cfg types.RetryConfig, onRetry RetryHandler) *RetryStorage {
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.InitialDelay <= 0 {
+		cfg.InitialDelay = time.Second
+	}
+	if cfg.MaxDelay < cfg.InitialDelay {
+		cfg.MaxDelay = cfg.InitialDelay
+	}
+	if cfg.Multiplier < 1 {
+		cfg.Multiplier = 2
+	}
+
+	return &RetryStorage{
+		storage: storage,
+		config:  cfg,
+		onRetry: onRetry,
+	}
+}
+
+// Upload загружает файл с повторами
+func (rs *RetryStorage) Upload(ctx context.Context, localPath, remotePath string) error {
+	return rs.do(ctx, "upload", func() error {
+		return rs.storage.Upload(ctx, localPath, remotePath)
+	})
+}
+
+// Download скачивает файл с повторами
+func (rs *RetryStorage) Download(ctx context.Context, remotePath, localPath string) error {
+	return rs.do(ctx, "download", func() error {
+		return rs.storage.Download(ctx, remotePath, localPath)
+	})
+}
+
+// Delete удаляет файл с повторами
+func (rs *RetryStorage) Delete(ctx context.Context, remotePath string) error {
+	return rs.do(ctx, "delete", func() error {
+		return rs.storage.Delete(ctx, remotePath)
+	})
+}
+
+// List возвращает список файлов с повторами
+func (rs *RetryStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	var files []string
+	err := rs.do(ctx, "list", func() error {
+		var err error
+		files, err = rs.storage.List(ctx, prefix)
+		return err
+	})
+	return files, err
+}
+
+// Exists проверяет наличие файла с повторами
+func (rs *RetryStorage) Exists(ctx context.Context, path string) (bool, error) {
+	var exists bool
+	err := rs.do(ctx, "exists", func() error {
+		var err error
+		exists, err = rs.storage.Exists(ctx, path)
+		return err
+	})
+	return exists, err
+}
+
+// Verify проверяет записанный объект с повторами
+func (rs *RetryStorage) Verify(ctx context.Context, localPath, remotePath string) (string, error) {
+	verifier, ok := rs.storage.(storageapi.Verifier)
+	if !ok {
+		return "", storageapi.ErrVerifyUnsupported
+	}
+
+	var checksum string
+	err := rs.do(ctx, "verify", func() error {
+		var err error
+		checksum, err = verifier.Verify(ctx, localPath, remotePath)
+		return err
+	})
+	return checksum, err
+}
+
+// Open открывает объект для чтения потоком с повторами
+//
+// Повторяется только открытие: ошибки при чтении возвращаются как есть.
+func (rs *RetryStorage) Open(ctx context.Context, remotePath string) (io.ReadCloser, error) {
+	opener, ok := rs.storage.(storageapi.Opener)
+	if !ok {
+		return nil, storageapi.ErrOpenUnsupported
+	}
+
+	var reader io.ReadCloser
+	err := rs.do(ctx, "open", func() error {
+		var err error
+		reader, err = opener.Open(ctx, remotePath)
+		return err
+	})
+	return reader, err
+}
+
+// Close закрывает обернутое хранилище, если оно держит подключения
+func (rs *RetryStorage) Close() error {
+	if closer, ok := rs.storage.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// do выполняет операцию, повторяя ее при временных ошибках
+func (rs *RetryStorage) do(ctx context.Context, op string, fn func() error) error {
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || !isRetryable(err) || attempt >= rs.config.MaxAttempts {
+			break
+		}
+
+		delay := rs.backoff(attempt)
+		if rs.onRetry != nil {
+			rs.onRetry(op, attempt, delay, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+
+	if err != nil && rs.config.MaxAttempts > 1 && isRetryable(err) {
+		return fmt.Errorf("операция %s не выполнена после %d попыток: %w", op, rs.config.MaxAttempts, err)
+	}
+
+	return err
+}
+
+// backoff возвращает паузу перед повтором с номером attempt
+func (rs *RetryStorage) backoff(attempt int) time.Duration {
+	delay := float64(rs.config.InitialDelay) * math.Pow(rs.config.Multiplier, float64(attempt-1))
+	if delay > float64(rs.config.MaxDelay) {
+		delay = float64(rs.config.MaxDelay)
+	}
+
+	// Случайная пауза от половины до полного значения
+	return time.Duration(delay/2 + rand.Float64()*delay/2)
+}
+
+// isRetryable определяет, имеет ли смысл повторить операцию после ошибки
+//
+// Повторяются ответы сервисов 5xx, таймаут запроса и ограничение частоты,
+// сетевые ошибки и обрыв соединения. Не повторяются отмена контекста,
+// ошибки локальной файловой системы, результаты проверки целостности,
+// остальные ответы 4xx, отказ в доступе и отсутствие файла на SFTP,
+// ошибки плагинов и прочие неизвестные ошибки.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, storageapi.ErrChecksumMismatch) || errors.Is(err, storageapi.ErrVerifyUnsupported) {
+		return false
+	}
+
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		return false
+	}
+
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return false
+	}
+
+	var s3Err minio.ErrorResponse
+	if errors.As(err, &s3Err) && s3Err.StatusCode != 0 {
+		return retryableStatus(s3Err.StatusCode)
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.Code)
+	}
+
+	var azureErr *azcore.ResponseError
+	if errors.As(err, &azureErr) {
+		return retryableStatus(azureErr.StatusCode)
+	}
+
+	var webdavErr *webDAVStatusError
+	if errors.As(err, &webdavErr) {
+		return retryableStatus(webdavErr.status)
+	}
+
+	// Соединение SFTP потеряно - повторяем, остальные статусы сервера окончательные
+	if errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, sftp.ErrSSHFxNoConnection) {
+		return true
+	}
+	var sftpErr *sftp.StatusError
+	if errors.As(err, &sftpErr) {
+		return false
+	}
+
+	// Плагин сам решает, как повторять операции; завершившийся процесс не поднимется
+	var pluginErr *storageapi.PluginError
+	if errors.As(err, &pluginErr) || errors.Is(err, storageapi.ErrPluginExited) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryableStatus определяет, является ли HTTP статус временной ошибкой:
+// таймаут, превышение частоты запросов или ошибка сервера
+func retryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
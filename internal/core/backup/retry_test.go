@@ -0,0 +1,59 @@
+package backup
+
+import (
+	storageapi "backupist/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/sftp"
+	"google.golang.org/api/googleapi"
+)
+
+func TestIsRetryable(t *testing.T) {
+	wrap := func(err error) error { return fmt.Errorf("ошибка загрузки файла: %w", err) }
+	connReset := &url.Error{Op: "Put", URL: "https://example.com", Err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}}
+
+	for name, tt := range map[string]struct {
+		err  error
+		want bool
+	}{
+		"отмена":                {wrap(context.Canceled), false},
+		"локальный файл":        {wrap(&fs.PathError{Op: "open", Path: "/tmp/x", Err: fs.ErrNotExist}), false},
+		"контрольная сумма":     {wrap(storageapi.ErrChecksumMismatch), false},
+		"S3 503":                {wrap(minio.ErrorResponse{StatusCode: http.StatusServiceUnavailable}), true},
+		"S3 403":                {wrap(minio.ErrorResponse{StatusCode: http.StatusForbidden}), false},
+		"GCS без статуса":       {wrap(&googleapi.Error{}), false},
+		"S3 301":                {wrap(minio.ErrorResponse{StatusCode: http.StatusMovedPermanently}), false},
+		"GCS 429":               {wrap(&googleapi.Error{Code: http.StatusTooManyRequests}), true},
+		"GCS 404":               {wrap(&googleapi.Error{Code: http.StatusNotFound}), false},
+		"Azure 500":             {wrap(&azcore.ResponseError{StatusCode: http.StatusInternalServerError}), true},
+		"Azure 403":             {wrap(&azcore.ResponseError{StatusCode: http.StatusForbidden}), false},
+		"WebDAV 502":            {wrap(&webDAVStatusError{status: http.StatusBadGateway}), true},
+		"WebDAV 401":            {wrap(&webDAVStatusError{status: http.StatusUnauthorized}), false},
+		"WebDAV 403":            {wrap(&webDAVStatusError{status: http.StatusForbidden}), false},
+		"WebDAV 404":            {wrap(&webDAVStatusError{status: http.StatusNotFound}), false},
+		"SFTP нет доступа":      {wrap(&sftp.StatusError{Code: uint32(sftp.ErrSSHFxPermissionDenied)}), false},
+		"SFTP нет файла":        {wrap(&sftp.StatusError{Code: uint32(sftp.ErrSSHFxNoSuchFile)}), false},
+		"SFTP нет доступа (os)": {wrap(fs.ErrPermission), false},
+		"SFTP обрыв":            {wrap(sftp.ErrSSHFxConnectionLost), true},
+		"плагин":                {wrap(&storageapi.PluginError{Code: storageapi.PluginErrorOperation, Message: "quota"}), false},
+		"плагин завершился":     {wrap(fmt.Errorf("%w: %w", storageapi.ErrPluginExited, io.ErrUnexpectedEOF)), false},
+		"сеть":                  {wrap(connReset), true},
+		"обрыв потока":          {wrap(io.ErrUnexpectedEOF), true},
+		"неизвестная":           {errors.New("неизвестная ошибка"), false},
+	} {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: isRetryable(%v) = %v, ожидалось %v", name, tt.err, got, tt.want)
+		}
+	}
+}
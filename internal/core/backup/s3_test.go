@@ -0,0 +1,110 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	"backupist/pkg/types"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestS3NamedConfigDefaultRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	cfg := config.NewConfig()
+	cfg.Storage.S3Region = "eu-central-1"
+	cfg.Storage.Configs = map[string]types.StorageConfig{
+		"archive": {
+			Type: types.StorageTypeS3,
+			S3Config: &types.S3Config{
+				Bucket:          "archive",
+				AccessKeyID:     "key",
+				SecretAccessKey: "secret",
+				Endpoint:        "127.0.0.1:9000",
+			},
+		},
+	}
+	factory := NewStorageFactory(cfg)
+	t.Cleanup(func() { factory.Close() })
+
+	// Именованная конфигурация без региона проходит валидацию
+	if _, err := factory.CreateStorage("storage://archive/daily"); err != nil {
+		t.Fatalf("хранилище из именованной конфигурации без региона: %v", err)
+	}
+
+	s3Config, err := factory.resolveS3Config(&url.URL{Scheme: "s3", Host: "archive", RawQuery: "profile=archive"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s3Config.Region != "eu-central-1" {
+		t.Fatalf("регион профиля без региона: %q, ожидался регион из глобальной конфигурации", s3Config.Region)
+	}
+
+	cfg.Storage.S3Region = ""
+	if region := factory.defaultS3Region(); region != "us-east-1" {
+		t.Fatalf("регион по умолчанию: %q", region)
+	}
+}
+
+func TestURLEndpointKeepsCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	cfg := config.NewConfig()
+	cfg.Storage.S3AccessKey = "global-key"
+	cfg.Storage.S3SecretKey = "global-secret"
+	cfg.Storage.S3Endpoint = "s3.internal:9000"
+	cfg.Storage.AzureAccountName = "account"
+	cfg.Storage.AzureAccountKey = "a2V5"
+	cfg.Storage.Configs = map[string]types.StorageConfig{
+		"archive": {
+			Type: types.StorageTypeS3,
+			S3Config: &types.S3Config{
+				AccessKeyID:     "archive-key",
+				SecretAccessKey: "archive-secret",
+				Endpoint:        "archive.example.com",
+			},
+		},
+	}
+	factory := NewStorageFactory(cfg)
+	t.Cleanup(func() { factory.Close() })
+
+	// Глобальные ключи не уходят на адрес, указанный автором политики
+	for _, rawURL := range []string{
+		"s3://bucket/daily?endpoint=attacker.example.com",
+		"s3://bucket/daily?profile=archive&endpoint=attacker.example.com",
+		"azure://container/daily?endpoint=https://attacker.example.com",
+	} {
+		u, _ := url.Parse(rawURL)
+		var err error
+		if u.Scheme == "s3" {
+			_, err = factory.resolveS3Config(u)
+		} else {
+			_, err = factory.resolveAzureConfig(u)
+		}
+		if err == nil {
+			t.Errorf("%s: адрес сервиса из URL принят с ключами из конфигурации", rawURL)
+		}
+	}
+
+	// Адрес из именованной конфигурации и глобальный адрес принимаются
+	for rawURL, want := range map[string]string{
+		"s3://bucket/daily?profile=archive&endpoint=archive.example.com": "archive-key",
+		"s3://bucket/daily?endpoint=s3.internal:9000":                    "global-key",
+	} {
+		u, _ := url.Parse(rawURL)
+		s3Config, err := factory.resolveS3Config(u)
+		if err != nil || s3Config.AccessKeyID != want {
+			t.Errorf("%s: %+v, %v", rawURL, s3Config, err)
+		}
+	}
+
+	// Без учетных данных адрес может быть любым
+	cfg.Storage.S3AccessKey = ""
+	u, _ := url.Parse("s3://public/data?endpoint=mirror.example.com")
+	if s3Config, err := factory.resolveS3Config(u); err != nil || s3Config.Endpoint != "mirror.example.com" {
+		t.Errorf("публичный бакет на другом адресе: %+v, %v", s3Config, err)
+	}
+}
@@ -0,0 +1,234 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	"backupist/pkg/types"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adhocore/gronx"
+)
+
+// Scheduler выполняет задачи сервиса по cron-расписанию
+//
+// Раз в минуту проверяет расписания политик, проверок восстановления
+// и регулярной проверки бэкапов. Политики читаются из каталога на каждом шаге, поэтому
+// новые и измененные политики подхватываются без перезапуска.
+// Задача не запускается повторно, пока не завершился предыдущий запуск;
+// пересечение запусков бэкапа политики определяется ее режимом overlap, а
+// запуск не добавляется, пока предыдущий запуск политики ждет своей очереди.
+type Scheduler struct {
+	service *Service
+	gron    *gronx.Gronx
+
+	mu      sync.Mutex
+	running map[string]bool
+	wg      sync.WaitGroup
+
+	// queued ID последней задачи, запущенной по расписанию политики, пока
+	// она выполняется; пусто - задача еще создается
+	queued map[string]string
+}
+
+// NewScheduler создает планировщик задач сервиса
+func NewScheduler(service *Service) *Scheduler {
+	return &Scheduler{
+		service: service,
+		gron:    gronx.New(),
+		running: make(map[string]bool),
+		queued:  make(map[string]string),
+	}
+}
+
+// Run выполняет задачи по расписанию до отмены контекста
+//
+// После отмены контекста ожидает завершения запущенных задач.
+func (sc *Scheduler) Run(ctx context.Context) error {
+	if err := config.ValidateVerifyConfig(&sc.service.config.Verify); err != nil {
+		return fmt.Errorf("ошибка валидации расписания проверки бэкапов: %w", err)
+	}
+
+	sc.service.logger.InfoContext(ctx, "Планировщик запущен")
+
+	// Копии, не записанные в хранилища до перезапуска, переносим сразу,
+	sc.start(ctx, "resync", sc.service.ResyncTargets)
+	// и продолжаем прерванные загрузки бэкапов
+	sc.start(ctx, "resume-uploads", sc.service.ResumeUploads)
+
+	for {
+		next := time.Now().Truncate(time.Minute).Add(time.Minute)
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			sc.wg.Wait()
+			sc.service.logger.InfoContext(ctx, "Планировщик остановлен")
+			return nil
+		case <-timer.C:
+			sc.tick(ctx, next)
+		}
+	}
+}
+
+// tick запускает задачи, расписание которых наступило в момент now
+func (sc *Scheduler) tick(ctx context.Context, now time.Time) {
+	policies, err := sc.service.getAllPolicies(ctx)
+	if err != nil {
+		sc.service.logger.ErrorContext(ctx, "Ошибка получения политик для планировщика", "error", err.Error())
+	}
+
+	for _, policy := range policies {
+		if policy.Schedule == "" || !sc.isDue(ctx, policy.Schedule, now) {
+			continue
+		}
+
+		// Пересечение с предыдущим запуском, в том числе из другого
+		// процесса, разрешает блокировка политики; в режиме queue ожидающие
+		// запуски копились бы на каждом шаге, поэтому ждать может только один
+		if !sc.queuePolicy(ctx, policy.ID) {
+			sc.service.logger.WarnContext(ctx, "Предыдущий запуск политики ожидает очереди, запуск пропущен", "policy_id", policy.ID)
+			continue
+		}
+		policyID := policy.ID
+		sc.spawn(ctx, "policy:"+policyID, func(ctx context.Context) error {
+			return sc.runPolicy(ctx, policyID)
+		})
+	}
+
+	for _, policy := range policies {
+		if policy.RestoreTest == nil || policy.RestoreTest.Schedule == "" || !sc.isDue(ctx, policy.RestoreTest.Schedule, now) {
+			continue
+		}
+
+		policyID := policy.ID
+		sc.start(ctx, "restore-test:"+policyID, func(ctx context.Context) error {
+			result, err := sc.service.RunRestoreTest(ctx, policyID)
+			if err != nil {
+				return err
+			}
+			if result.Status != types.VerifyStatusPassed {
+				return fmt.Errorf("проверка восстановления не пройдена: %s", result.Error)
+			}
+			return nil
+		})
+	}
+
+	verify := sc.service.config.Verify
+	if verify.Schedule != "" && sc.isDue(ctx, verify.Schedule, now) {
+		sc.start(ctx, "verify", func(ctx context.Context) error {
+			verifications, err := sc.service.VerifyBackups(ctx, VerifyOptions{
+				Sample: verify.Sample,
+				Deep:   verify.Deep,
+			})
+			if err != nil {
+				return err
+			}
+
+			var failed int
+			for _, verification := range verifications {
+				if verification.Status != types.VerifyStatusPassed {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("не прошли проверку %d из %d бэкапов", failed, len(verifications))
+			}
+			return nil
+		})
+	}
+}
+
+// queuePolicy отмечает запуск политики по расписанию; false - предыдущий
+// запуск еще не начал выполнение и ждет блокировки политики
+func (sc *Scheduler) queuePolicy(ctx context.Context, policyID string) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if jobID, ok := sc.queued[policyID]; ok {
+		if jobID == "" {
+			return false
+		}
+		job, err := sc.service.getBackupJob(ctx, jobID)
+		if err == nil && job.Status == types.JobStatusPending {
+			return false
+		}
+	}
+
+	sc.queued[policyID] = ""
+	return true
+}
+
+// runPolicy создает задачу политики и выполняет бэкап; пока задача не
+// завершилась, ее ID хранится в sc.queued
+func (sc *Scheduler) runPolicy(ctx context.Context, policyID string) error {
+	job, err := sc.service.CreatePolicyJob(ctx, policyID)
+
+	sc.mu.Lock()
+	if err != nil {
+		delete(sc.queued, policyID)
+	} else {
+		sc.queued[policyID] = job.ID
+	}
+	sc.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		sc.mu.Lock()
+		if sc.queued[policyID] == job.ID {
+			delete(sc.queued, policyID)
+		}
+		sc.mu.Unlock()
+	}()
+
+	_, err = sc.service.ExecuteBackup(ctx, job)
+	return err
+}
+
+// isDue проверяет, наступило ли время по cron-выражению
+func (sc *Scheduler) isDue(ctx context.Context, expr string, now time.Time) bool {
+	due, err := sc.gron.IsDue(expr, now)
+	if err != nil {
+		sc.service.logger.WarnContext(ctx, "Некорректное cron-выражение", "schedule", expr, "error", err.Error())
+		return false
+	}
+	return due
+}
+
+// start запускает задачу в отдельной горутине, если она еще не выполняется
+func (sc *Scheduler) start(ctx context.Context, name string, task func(context.Context) error) {
+	sc.mu.Lock()
+	if sc.running[name] {
+		sc.mu.Unlock()
+		sc.service.logger.WarnContext(ctx, "Предыдущий запуск задачи еще не завершен, запуск пропущен", "task", name)
+		return
+	}
+	sc.running[name] = true
+	sc.mu.Unlock()
+
+	sc.spawn(ctx, name, func(ctx context.Context) error {
+		defer func() {
+			sc.mu.Lock()
+			delete(sc.running, name)
+			sc.mu.Unlock()
+		}()
+		return task(ctx)
+	})
+}
+
+// spawn запускает задачу в отдельной горутине без проверки предыдущего запуска
+func (sc *Scheduler) spawn(ctx context.Context, name string, task func(context.Context) error) {
+	sc.wg.Add(1)
+	go func() {
+		defer sc.wg.Done()
+
+		sc.service.logger.InfoContext(ctx, "Запуск задачи по расписанию", "task", name)
+		if err := task(ctx); err != nil {
+			sc.service.logger.ErrorContext(ctx, "Ошибка задачи по расписанию", "task", name, "error", err.Error())
+		}
+	}()
+}
@@ -0,0 +1,402 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VerifyOptions параметры проверки сохраненных бэкапов
+type VerifyOptions struct {
+	PolicyID string // Проверять бэкапы только этой политики
+	JobID    string // Проверить бэкап одной задачи
+	Sample   int    // Проверить столько случайных бэкапов, 0 - все
+	Deep     bool   // Расшифровать бэкап и проверить каждую запись архива
+}
+
+// VerifyBackups проверяет сохраненные бэкапы: скачивает их из хранилища
+// и сравнивает SHA-256 с контрольной суммой из каталога
+//
+// В режиме выборки проверяются Sample случайных бэкапов, что ограничивает
+// объем скачиваемых данных при регулярном запуске. Результат каждой
+// проверки записывается в каталог; ошибка возвращается, только если
+// проверку не удалось начать.
+func (s *Service) VerifyBackups(ctx context.Context, opts VerifyOptions) ([]*types.BackupVerification, error) {
+	jobs, err := s.verifiableJobs(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Sample > 0 && len(jobs) > opts.Sample {
+		rand.Shuffle(len(jobs), func(i, j int) { jobs[i], jobs[j] = jobs[j], jobs[i] })
+		jobs = jobs[:opts.Sample]
+	}
+
+	var verifications []*types.BackupVerification
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return verifications, ctx.Err()
+		}
+
+		verification := s.VerifyBackup(ctx, job, opts.Deep)
+		verifications = append(verifications, verification)
+	}
+
+	return verifications, nil
+}
+
+// verifiableJobs возвращает завершенные задачи, бэкапы которых нужно проверить
+func (s *Service) verifiableJobs(ctx context.Context, opts VerifyOptions) ([]*types.BackupJob, error) {
+	if opts.JobID != "" {
+		job, err := s.getBackupJob(ctx, opts.JobID)
+		if err != nil {
+			return nil, err
+		}
+		if job.Status != types.JobStatusCompleted || job.BackupPath == "" {
+			return nil, fmt.Errorf("бэкап задачи %s не завершен или удален", job.ID)
+		}
+		return []*types.BackupJob{job}, nil
+	}
+
+	var policies []*types.BackupPolicy
+	if opts.PolicyID != "" {
+		policy, err := s.getPolicy(ctx, opts.PolicyID)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	} else {
+		var err error
+		if policies, err = s.getAllPolicies(ctx); err != nil {
+			return nil, fmt.Errorf("ошибка получения политик: %w", err)
+		}
+	}
+
+	var jobs []*types.BackupJob
+	for _, policy := range policies {
+		history, _, err := s.listBackupJobs(ctx, JobFilter{PolicyID: policy.ID, Status: types.JobStatusCompleted, Backups: true})
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, history...)
+	}
+
+	return jobs, nil
+}
+
+// VerifyBackup проверяет бэкап задачи и записывает результат в каталог
+func (s *Service) VerifyBackup(ctx context.Context, job *types.BackupJob, deep bool) *types.BackupVerification {
+	startTime := time.Now()
+
+	verification := &types.BackupVerification{
+		ID:         uuid.New().String(),
+		JobID:      job.ID,
+		PolicyID:   job.PolicyID,
+		BackupPath: job.BackupPath,
+		Status:     types.VerifyStatusPassed,
+		Deep:       deep,
+	}
+
+	if err := s.verifyBackup(ctx, job, verification); err != nil {
+		verification.Status = types.VerifyStatusFailed
+		verification.Error = err.Error()
+
+		s.logger.ErrorContext(ctx, "Бэкап не прошел проверку",
+			"job_id", job.ID,
+			"backup_path", job.BackupPath,
+			"error", err.Error())
+	} else {
+		s.logger.InfoContext(ctx, "Бэкап прошел проверку",
+			"job_id", job.ID,
+			"backup_path", job.BackupPath,
+			"deep", deep)
+	}
+
+	verification.Duration = time.Since(startTime)
+	verification.VerifiedAt = time.Now()
+
+	// Результат сохраняем и при отмене контекста
+	if err := s.saveBackupVerification(context.WithoutCancel(ctx), verification); err != nil {
+		s.logger.WarnContext(ctx, "Ошибка сохранения результата проверки бэкапа",
+			"job_id", job.ID,
+			"error", err.Error())
+	}
+
+	return verification
+}
+
+// verifyBackup прочитывает бэкап из хранилища и проверяет его контрольную
+// сумму, а в глубоком режиме - проверяет данные четности, расшифровывает
+// бэкап и читает каждую запись архива
+//
+// Бэкап читается потоком: тома скачиваются по одному, расшифрованные данные
+// передаются в проверку архива через канал, не записываясь на диск.
+func (s *Service) verifyBackup(ctx context.Context, job *types.BackupJob, verification *types.BackupVerification) error {
+	result, err := s.getBackupResult(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+	if result.Checksum == "" {
+		return fmt.Errorf("в каталоге нет контрольной суммы бэкапа")
+	}
+
+	var password string
+	if verification.Deep && result.Encrypted {
+		policy, err := s.getPolicy(ctx, job.PolicyID)
+		if err != nil {
+			return fmt.Errorf("ошибка получения политики для расшифровки: %w", err)
+		}
+		password = policy.EncryptionPassword
+	}
+
+	storage, err := s.storageForJob(ctx, job)
+	if err != nil {
+		return fmt.Errorf("ошибка определения хранилища бэкапа: %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "backupist-verify-*")
+	if err != nil {
+		return fmt.Errorf("ошибка создания временной директории: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if result.Volumes > 0 {
+		return s.verifyVolumes(ctx, storage, job, result, password, tempDir, verification)
+	}
+
+	// Файл бэкапа остается на диске: по нему данные четности определяют
+	// поврежденные блоки
+	backupPath := filepath.Join(tempDir, "backup")
+	if err := storage.Download(ctx, job.BackupPath, backupPath); err != nil {
+		return fmt.Errorf("ошибка скачивания бэкапа: %w", err)
+	}
+
+	file, err := os.Open(backupPath)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия бэкапа: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	stream := io.TeeReader(file, hash)
+	entries, readErr := s.readBackup(ctx, stream, result, password, verification.Deep)
+
+	// Контрольная сумма считается по всему файлу, даже если архив не прочитан
+	if _, err := io.Copy(io.Discard, stream); err != nil {
+		return fmt.Errorf("ошибка чтения бэкапа: %w", err)
+	}
+	verification.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	if verification.Checksum != result.Checksum {
+		err := fmt.Errorf("контрольная сумма не совпадает: %s, в каталоге %s", verification.Checksum, result.Checksum)
+		if result.ParityPath == "" {
+			return err
+		}
+
+		// По данным четности определяем, какие блоки повреждены и можно ли их исправить
+		report, parityErr := s.checkParity(ctx, storage, result.ParityPath, backupPath, tempDir)
+		if parityErr != nil {
+			return fmt.Errorf("%w; %w", err, parityErr)
+		}
+		verification.Damaged = report.DamagedBlocks
+		verification.Repairable = report.Repairable
+		if report.Repairable {
+			return fmt.Errorf("%w; повреждено блоков: %d из %d, бэкап можно исправить командой repair",
+				err, report.DamagedBlocks, report.Blocks)
+		}
+		return fmt.Errorf("%w; повреждено блоков: %d из %d, данных четности недостаточно для исправления",
+			err, report.DamagedBlocks, report.Blocks)
+	}
+
+	// Поврежденные данные четности не помогут при следующем повреждении бэкапа
+	if verification.Deep && result.ParityPath != "" {
+		report, err := s.checkParity(ctx, storage, result.ParityPath, backupPath, tempDir)
+		if err != nil {
+			return err
+		}
+		if report.DamagedParity > 0 {
+			verification.Repairable = true
+			return fmt.Errorf("повреждено блоков четности: %d, данные четности можно пересоздать командой repair", report.DamagedParity)
+		}
+	}
+
+	if readErr != nil {
+		return readErr
+	}
+	verification.Entries = entries
+
+	return nil
+}
+
+// verifyVolumes проверяет бэкап, разбитый на тома, читая тома по одному
+//
+// Каждый том сверяется с манифестом. Для поврежденных томов по данным
+// четности определяется, можно ли их исправить; в глубоком режиме
+// проверяются и данные четности неповрежденных томов. После первого
+// поврежденного тома остальные тома только сверяются с манифестом.
+func (s *Service) verifyVolumes(ctx context.Context, storage StorageProvider, job *types.BackupJob, result *types.BackupResult, password, tempDir string, verification *types.BackupVerification) error {
+	reader, err := s.openVolumes(ctx, storage, job.BackupPath, tempDir)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	scan := &volumeScan{
+		ctx:          ctx,
+		service:      s,
+		storage:      storage,
+		manifestPath: job.BackupPath,
+		tempDir:      tempDir,
+		verification: verification,
+		repairable:   true,
+	}
+	reader.inspect = scan.inspect
+
+	hash := sha256.New()
+	stream := io.TeeReader(reader, hash)
+	entries, readErr := s.readBackup(ctx, stream, result, password, verification.Deep)
+	if readErr == nil {
+		if _, err := io.Copy(io.Discard, stream); err != nil {
+			readErr = fmt.Errorf("ошибка чтения бэкапа: %w", err)
+		}
+	}
+
+	if err := reader.inspectRemaining(); err != nil {
+		return err
+	}
+	if err := scan.err(); err != nil {
+		return err
+	}
+	if readErr != nil {
+		return readErr
+	}
+
+	verification.Checksum = hex.EncodeToString(hash.Sum(nil))
+	if verification.Checksum != result.Checksum {
+		return fmt.Errorf("контрольная сумма не совпадает: %s, в каталоге %s", verification.Checksum, result.Checksum)
+	}
+	verification.Entries = entries
+
+	return nil
+}
+
+// volumeScan собирает результаты проверки томов бэкапа
+type volumeScan struct {
+	ctx          context.Context
+	service      *Service
+	storage      StorageProvider
+	manifestPath string
+	tempDir      string
+	verification *types.BackupVerification
+
+	damaged       []string
+	damagedParity int
+	repairable    bool
+}
+
+// inspect проверяет скачанный том: для поврежденного тома определяет по
+// данным четности, можно ли его исправить, а в глубоком режиме проверяет
+// данные четности неповрежденного тома
+func (vs *volumeScan) inspect(volume types.BackupVolume, localPath string, intact bool) error {
+	remotePath := volumePath(vs.manifestPath, volume)
+
+	switch {
+	case !intact:
+		vs.damaged = append(vs.damaged, strconv.Itoa(volume.Number))
+		if !volume.Parity {
+			vs.repairable = false
+			break
+		}
+
+		report, err := vs.service.checkParity(vs.ctx, vs.storage, remotePath+paritySuffix, localPath, vs.tempDir)
+		if err != nil {
+			vs.repairable = false
+			break
+		}
+		vs.verification.Damaged += report.DamagedBlocks
+		vs.repairable = vs.repairable && report.Repairable
+
+	case vs.verification.Deep && volume.Parity:
+		report, err := vs.service.checkParity(vs.ctx, vs.storage, remotePath+paritySuffix, localPath, vs.tempDir)
+		if err != nil {
+			return fmt.Errorf("том %d: %w", volume.Number, err)
+		}
+		vs.damagedParity += report.DamagedParity
+	}
+
+	return nil
+}
+
+// err возвращает ошибку с отчетом о поврежденных томах и данных четности
+func (vs *volumeScan) err() error {
+	if len(vs.damaged) > 0 {
+		err := fmt.Errorf("повреждены тома: %s", strings.Join(vs.damaged, ", "))
+		vs.verification.Repairable = vs.repairable
+		if vs.repairable {
+			return fmt.Errorf("%w; повреждено блоков: %d, бэкап можно исправить командой repair", err, vs.verification.Damaged)
+		}
+		return fmt.Errorf("%w; данных четности недостаточно для исправления", err)
+	}
+
+	if vs.damagedParity > 0 {
+		vs.verification.Repairable = true
+		return fmt.Errorf("повреждено блоков четности: %d, данные четности можно пересоздать командой repair", vs.damagedParity)
+	}
+
+	return nil
+}
+
+// readBackup прочитывает поток бэкапа до конца архива; в глубоком режиме
+// поток расшифровывается на лету, и каждая запись архива читается по мере
+// скачивания
+func (s *Service) readBackup(ctx context.Context, r io.Reader, result *types.BackupResult, password string, deep bool) (int, error) {
+	if !deep {
+		_, err := io.Copy(io.Discard, r)
+		return 0, err
+	}
+
+	archive := r
+	if result.Encrypted {
+		pr, pw := io.Pipe()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if err := s.decryptStream(ctx, r, pw, password); err != nil {
+				pw.CloseWithError(fmt.Errorf("ошибка расшифровки бэкапа: %w", err))
+				return
+			}
+			pw.Close()
+		}()
+		defer func() {
+			pr.Close()
+			<-done
+		}()
+		archive = pr
+	}
+
+	var entries int
+	if result.Compressed {
+		var err error
+		if entries, err = s.validateArchive(ctx, archive); err != nil {
+			return 0, err
+		}
+	}
+
+	// Расшифровка завершается, только когда прочитан весь поток
+	if _, err := io.Copy(io.Discard, archive); err != nil {
+		return 0, err
+	}
+
+	return entries, nil
+}
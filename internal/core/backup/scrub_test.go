@@ -0,0 +1,206 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// storedBackup возвращает путь файла бэкапа задачи в локальном хранилище
+func storedBackup(t *testing.T, service *Service, job *types.BackupJob) string {
+	t.Helper()
+
+	job, err := service.getBackupJob(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(service.config.Storage.LocalPath, filepath.FromSlash(job.BackupPath))
+}
+
+// savedVerification возвращает статус и ошибку проверки из каталога
+func savedVerification(t *testing.T, service *Service, id string) (string, string) {
+	t.Helper()
+
+	var status, message string
+	err := service.db.QueryRowContext(context.Background(),
+		`SELECT status, error FROM backup_verifications WHERE id = ?`, id).Scan(&status, &message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status, message
+}
+
+func TestVerifyBackupPassed(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "passed", map[string]string{"a.txt": "alpha", "dir/b.txt": "beta"}, func(policy *types.BackupPolicy) {
+		policy.EncryptionEnabled = true
+		policy.EncryptionPassword = "secret"
+	})
+	job := runTestBackup(t, service, policy.ID)
+
+	for _, deep := range []bool{false, true} {
+		verifications, err := service.VerifyBackups(context.Background(), VerifyOptions{JobID: job.ID, Deep: deep})
+		if err != nil {
+			t.Fatal(err)
+		}
+		verification := verifications[0]
+
+		stored, err := os.ReadFile(storedBackup(t, service, job))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(stored)
+		if verification.Status != types.VerifyStatusPassed || verification.Checksum != hex.EncodeToString(sum[:]) {
+			t.Fatalf("проверка (deep %v): %+v", deep, verification)
+		}
+
+		// Глубокая проверка читает записи расшифрованного архива
+		if deep && verification.Entries < 2 || !deep && verification.Entries != 0 {
+			t.Fatalf("записей архива при проверке (deep %v): %d", deep, verification.Entries)
+		}
+		if status, _ := savedVerification(t, service, verification.ID); status != string(types.VerifyStatusPassed) {
+			t.Fatalf("статус проверки в каталоге: %s", status)
+		}
+	}
+}
+
+func TestVerifyBackupChecksumMismatch(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "mismatch", map[string]string{"a.txt": "alpha"}, nil)
+	job := runTestBackup(t, service, policy.ID)
+
+	path := storedBackup(t, service, job)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	verifications, err := service.VerifyBackups(context.Background(), VerifyOptions{PolicyID: policy.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verifications) != 1 || verifications[0].Status != types.VerifyStatusFailed {
+		t.Fatalf("проверка поврежденного бэкапа: %+v", verifications)
+	}
+
+	status, message := savedVerification(t, service, verifications[0].ID)
+	if status != string(types.VerifyStatusFailed) || !strings.Contains(message, "контрольная сумма не совпадает") {
+		t.Fatalf("результат в каталоге: %s, %q", status, message)
+	}
+}
+
+func TestVerifyBackupSample(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "sample", map[string]string{"a.txt": "alpha"}, nil)
+
+	// Имя бэкапа задается с точностью до секунды
+	for i := range 3 {
+		if i > 0 {
+			time.Sleep(1100 * time.Millisecond)
+		}
+		runTestBackup(t, service, policy.ID)
+	}
+
+	all, err := service.VerifyBackups(context.Background(), VerifyOptions{PolicyID: policy.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sample, err := service.VerifyBackups(context.Background(), VerifyOptions{PolicyID: policy.ID, Sample: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || len(sample) != 2 || sample[0].JobID == sample[1].JobID {
+		t.Fatalf("проверено бэкапов: все - %d, выборка - %d", len(all), len(sample))
+	}
+}
+
+func TestVerifyBackupDeepCorruptedArchive(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "corrupted", map[string]string{"a.txt": "alpha"}, nil)
+	job := runTestBackup(t, service, policy.ID)
+
+	// Целый gzip с поврежденным tar внутри; контрольная сумма в каталоге
+	// совпадает, поэтому ошибку находит только глубокая проверка
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(bytes.Repeat([]byte("not a tar header"), 64))
+	gz.Close()
+	if err := os.WriteFile(storedBackup(t, service, job), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	if _, err := service.db.Exec(`UPDATE backup_results SET checksum = ? WHERE job_id = ?`, hex.EncodeToString(sum[:]), job.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	shallow, err := service.VerifyBackups(context.Background(), VerifyOptions{JobID: job.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shallow[0].Status != types.VerifyStatusPassed {
+		t.Fatalf("проверка контрольной суммы: %+v", shallow[0])
+	}
+
+	deep, err := service.VerifyBackups(context.Background(), VerifyOptions{JobID: job.ID, Deep: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deep[0].Status != types.VerifyStatusFailed || !strings.Contains(deep[0].Error, "ошибка чтения tar заголовка") {
+		t.Fatalf("глубокая проверка поврежденного архива: %+v", deep[0])
+	}
+}
+
+func TestVerifyVolumeBackup(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createVolumePolicy(t, service, "volumes", func(policy *types.BackupPolicy) {
+		policy.EncryptionEnabled = true
+		policy.EncryptionPassword = "secret"
+	})
+	job := runTestBackup(t, service, policy.ID)
+
+	deep, err := service.VerifyBackups(context.Background(), VerifyOptions{JobID: job.ID, Deep: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deep[0].Status != types.VerifyStatusPassed || deep[0].Entries < 2 {
+		t.Fatalf("глубокая проверка бэкапа на томах: %+v", deep[0])
+	}
+
+	// Повреждены два тома: проверка сообщает о каждом
+	job, err = service.getBackupJob(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, _ := readVolumes(t, service, job)
+	for _, volume := range manifest.Volumes[1:] {
+		path := filepath.Join(service.config.Storage.LocalPath, volumePath(job.BackupPath, volume))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[0] ^= 0xff
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	damaged, err := service.VerifyBackups(context.Background(), VerifyOptions{JobID: job.ID, Deep: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if damaged[0].Status != types.VerifyStatusFailed || !strings.Contains(damaged[0].Error, "повреждены тома: 2, 3") {
+		t.Fatalf("проверка поврежденных томов: %+v", damaged[0])
+	}
+}
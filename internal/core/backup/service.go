@@ -3,15 +3,19 @@ package backup
 import (
 	"backupist/internal/core/config"
 	"backupist/internal/logger"
+	storageapi "backupist/pkg/storage"
 	"backupist/pkg/types"
 	"context"
 	"crypto/sha256"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -24,27 +28,37 @@ type Service struct {
 	logger  *logger.StructuredLogger
 	db      *sql.DB
 	storage StorageProvider
+	factory *StorageFactory
+	events  *EventBus
+
+	// Задачи, выполняющиеся в этом процессе, и отмена их контекста
+	jobsMu  sync.Mutex
+	running map[string]context.CancelCauseFunc
 }
 
 // StorageProvider интерфейс для провайдеров хранения
-type StorageProvider interface {
-	Upload(ctx context.Context, localPath, remotePath string) error
-	Download(ctx context.Context, remotePath, localPath string) error
-	Delete(ctx context.Context, remotePath string) error
-	List(ctx context.Context, prefix string) ([]string, error)
-	Exists(ctx context.Context, path string) (bool, error)
-}
+//
+// Определен в pkg/storage, чтобы сторонние модули могли реализовывать
+// хранилища без доступа к внутренним пакетам.
+type StorageProvider = storageapi.Provider
 
 // NewService создает новый сервис бэкапа
 func NewService(cfg *config.Config, log *logger.StructuredLogger) *Service {
 	return &Service{
-		config: cfg,
-		logger: log,
+		config:  cfg,
+		logger:  log,
+		events:  NewEventBus(),
+		running: make(map[string]context.CancelCauseFunc),
 	}
 }
 
 // Initialize инициализирует сервис (подключение к БД, настройка хранилища)
 func (s *Service) Initialize(ctx context.Context) error {
+	// Проверка параметров уведомлений
+	if err := config.ValidateNotificationConfig(&s.config.Notifications); err != nil {
+		return fmt.Errorf("ошибка валидации уведомлений: %w", err)
+	}
+
 	// Инициализация базы данных
 	if err := s.initDatabase(); err != nil {
 		return fmt.Errorf("ошибка инициализации БД: %w", err)
@@ -59,12 +73,34 @@ func (s *Service) Initialize(ctx context.Context) error {
 	return nil
 }
 
+// Close освобождает ресурсы сервиса: подключения к хранилищам, процессы плагинов и БД
+func (s *Service) Close() error {
+	var errs []error
+
+	if s.factory != nil {
+		if err := s.factory.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if s.db != nil {
+		if err := s.db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("ошибка закрытия БД: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // CreateBackupJob создает новую задачу бэкапа
 func (s *Service) CreateBackupJob(ctx context.Context, policy *types.BackupPolicy) (*types.BackupJob, error) {
 	// Валидация политики
 	if err := config.ValidateBackupPolicy(policy); err != nil {
 		return nil, fmt.Errorf("ошибка валидации политики: %w", err)
 	}
+	if err := s.validateRestoreTest(policy); err != nil {
+		return nil, fmt.Errorf("ошибка валидации политики: %w", err)
+	}
 
 	// Генерация ID
 	if policy.ID == "" {
@@ -78,9 +114,14 @@ func (s *Service) CreateBackupJob(ctx context.Context, policy *types.BackupPolic
 
 	// Создание задачи
 	job := &types.BackupJob{
-		ID:       uuid.New().String(),
-		PolicyID: policy.ID,
-		Status:   types.JobStatusPending,
+		ID:        uuid.New().String(),
+		PolicyID:  policy.ID,
+		Status:    types.JobStatusPending,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.saveBackupJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("ошибка сохранения задачи: %w", err)
 	}
 
 	s.logger.InfoContext(ctx, "Создана задача бэкапа",
@@ -91,14 +132,86 @@ func (s *Service) CreateBackupJob(ctx context.Context, policy *types.BackupPolic
 	return job, nil
 }
 
+// RunPolicy создает задачу для сохраненной политики и выполняет бэкап
+//
+// Используется при запуске по расписанию, когда политика уже есть в каталоге.
+func (s *Service) RunPolicy(ctx context.Context, policyID string) (*types.BackupResult, error) {
+	job, err := s.CreatePolicyJob(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ExecuteBackup(ctx, job)
+}
+
+// CreatePolicyJob создает задачу бэкапа для сохраненной политики
+//
+// Задача остается в статусе pending до вызова ExecuteBackup.
+func (s *Service) CreatePolicyJob(ctx context.Context, policyID string) (*types.BackupJob, error) {
+	job := &types.BackupJob{
+		ID:        uuid.New().String(),
+		PolicyID:  policyID,
+		Status:    types.JobStatusPending,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.saveBackupJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("ошибка сохранения задачи: %w", err)
+	}
+
+	return job, nil
+}
+
 // ExecuteBackup выполняет бэкап
+//
+// Задачу можно отменить через CancelJob или отменой контекста: она
+// завершится в статусе cancelled, а частично загруженные объекты будут
+// удалены из хранилища. Причина отмены берется из context.Cause.
 func (s *Service) ExecuteBackup(ctx context.Context, job *types.BackupJob) (*types.BackupResult, error) {
 	backupLogger := logger.NewBackupLogger(job.ID, job.PolicyID)
 
+	ctx, finish := s.startJob(ctx, job.ID)
+	defer finish()
+
+	// Отмена запрошена до запуска
+	if ctx.Err() != nil {
+		cause := cancelError(ctx)
+		if err := s.markCancelled(ctx, job, cause); err != nil {
+			return nil, err
+		}
+		return nil, cause
+	}
+
 	// Получение политики
 	policy, err := s.getPolicy(ctx, job.PolicyID)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка получения политики: %w", err)
+		return nil, s.markFailed(ctx, job, fmt.Errorf("ошибка получения политики: %w", err))
+	}
+
+	// Запуски политики не пересекаются: ретеншн и временные файлы общие
+	lock, err := s.lockPolicy(ctx, policy, job)
+	if errors.Is(err, ErrCancelled) {
+		if markErr := s.markCancelled(ctx, job, err); markErr != nil {
+			return nil, markErr
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, s.markFailed(ctx, job, err)
+	}
+	defer lock.release(ctx)
+
+	// Загрузки прошлых запусков политики, прерванные ошибкой или
+	// завершением процесса, завершаются до нового бэкапа
+	s.resumePolicyUploads(ctx, policy.ID)
+
+	// Ограничение скорости политики действует вместе с глобальным
+	if policy.Bandwidth != nil {
+		limiter, err := NewBandwidthLimiter(*policy.Bandwidth)
+		if err != nil {
+			return nil, s.markFailed(ctx, job, err)
+		}
+		ctx = WithBandwidth(ctx, limiter)
 	}
 
 	backupLogger.LogBackupStart(ctx, policy.SourcePath, policy.DestinationPath)
@@ -106,14 +219,23 @@ func (s *Service) ExecuteBackup(ctx context.Context, job *types.BackupJob) (*typ
 	// Обновление статуса задачи
 	job.Status = types.JobStatusRunning
 	job.StartedAt = time.Now()
+	job.Destination = s.destinationAddress(policy.DestinationPath)
+
+	if err := s.saveBackupJob(ctx, job); err != nil {
+		return nil, s.markFailed(ctx, job, fmt.Errorf("ошибка сохранения задачи: %w", err))
+	}
+	s.publishStatus(job)
+
+	// Прогресс этапов публикуется в шину событий
+	tracker := newProgressTracker(s.events, job)
+	ctx = withProgress(ctx, tracker)
 
 	startTime := time.Now()
 
 	// Основная логика бэкапа
-	result, err := s.performBackup(ctx, policy, backupLogger)
+	result, err := s.performBackup(ctx, job, policy, backupLogger)
 	if err != nil {
-		job.Status = types.JobStatusFailed
-		job.Error = err.Error()
+		err = s.markFailed(ctx, job, err)
 		backupLogger.LogBackupError(ctx, err, "backup_execution")
 		return nil, err
 	}
@@ -122,10 +244,25 @@ func (s *Service) ExecuteBackup(ctx context.Context, job *types.BackupJob) (*typ
 	completedAt := time.Now()
 	job.Status = types.JobStatusCompleted
 	job.CompletedAt = &completedAt
+	job.BackupPath = result.BackupPath
+	job.FilesProcessed = result.FilesProcessed
+	job.TotalSize = result.TotalSize
 
 	result.JobID = job.ID
 	result.Duration = time.Since(startTime)
 
+	// Бэкап уже загружен, поэтому отмена после загрузки его не отменяет
+	if err := s.saveCompletedBackup(context.WithoutCancel(ctx), job, result); err != nil {
+		return nil, err
+	}
+
+	// Копирование во вторичные хранилища
+	if len(policy.CopyTargets) > 0 {
+		tracker.phase(types.ProgressPhaseReplicate, 0, 0)
+		result.Copies = s.replicateBackup(ctx, policy, job)
+	}
+	s.publishStatus(job)
+
 	backupLogger.LogBackupComplete(ctx, logger.BackupResult{
 		BackupPath:     result.BackupPath,
 		FilesProcessed: result.FilesProcessed,
@@ -138,13 +275,40 @@ func (s *Service) ExecuteBackup(ctx context.Context, job *types.BackupJob) (*typ
 	return result, nil
 }
 
+// saveCompletedBackup записывает в каталог завершенную задачу, результат,
+// манифест и копии по хранилищам, после чего удаляет готовый бэкап,
+// сохраненный на случай прерванной загрузки
+func (s *Service) saveCompletedBackup(ctx context.Context, job *types.BackupJob, result *types.BackupResult) error {
+	if err := s.saveBackupJob(ctx, job); err != nil {
+		return fmt.Errorf("ошибка сохранения задачи: %w", err)
+	}
+	if err := s.saveBackupResult(ctx, result); err != nil {
+		return fmt.Errorf("ошибка сохранения результата: %w", err)
+	}
+	if err := s.saveBackupFiles(ctx, job.ID, result.Files); err != nil {
+		return fmt.Errorf("ошибка сохранения манифеста бэкапа: %w", err)
+	}
+	if err := s.saveTargetResults(ctx, job.ID, result.Targets); err != nil {
+		return fmt.Errorf("ошибка сохранения результатов по хранилищам: %w", err)
+	}
+
+	s.discardPendingUpload(ctx, job.ID)
+	return nil
+}
+
 // performBackup выполняет основную логику бэкапа
-func (s *Service) performBackup(ctx context.Context, policy *types.BackupPolicy, logger *logger.BackupLogger) (*types.BackupResult, error) {
+func (s *Service) performBackup(ctx context.Context, job *types.BackupJob, policy *types.BackupPolicy, logger *logger.BackupLogger) (*types.BackupResult, error) {
 	result := &types.BackupResult{
 		Compressed: policy.ArchiveEnabled,
 		Encrypted:  policy.EncryptionEnabled,
 	}
 
+	// Определение хранилища назначения до начала подготовки бэкапа
+	storage, remotePrefix, err := s.resolveDestination(policy.DestinationPath)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка определения хранилища назначения: %w", err)
+	}
+
 	// Создание временной директории для подготовки
 	tempDir, err := os.MkdirTemp("", "backup-*")
 	if err != nil {
@@ -152,7 +316,10 @@ func (s *Service) performBackup(ctx context.Context, policy *types.BackupPolicy,
 	}
 	defer os.RemoveAll(tempDir)
 
+	progress := progressFrom(ctx)
+
 	// Сканирование исходной директории
+	progress.phase(types.ProgressPhaseScan, 0, 0)
 	files, totalSize, err := s.scanDirectory(ctx, policy.SourcePath)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка сканирования директории: %w", err)
@@ -166,13 +333,39 @@ func (s *Service) performBackup(ctx context.Context, policy *types.BackupPolicy,
 	backupPath := filepath.Join(tempDir, backupName)
 
 	// Копирование файлов
-	if err := s.copyFiles(ctx, policy.SourcePath, backupPath, files, logger); err != nil {
+	progress.phase(types.ProgressPhaseCopy, int64(len(files)), totalSize)
+	result.Files, err = s.copyFiles(ctx, policy.SourcePath, backupPath, files, logger)
+	if err != nil {
 		return nil, fmt.Errorf("ошибка копирования файлов: %w", err)
 	}
 
+	// Разбиение на тома: архив шифруется и загружается потоком, по тому за раз
+	if policy.VolumeSize > 0 {
+		progress.phase(types.ProgressPhaseArchive, int64(len(files)), totalSize)
+		remotePath := path.Join(remotePrefix, backupName)
+		manifest, err := s.uploadVolumes(ctx, policy, storage, backupPath, remotePath)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка записи томов бэкапа: %w", err)
+		}
+
+		result.BackupPath = remotePath + volumeManifestSuffix
+		result.Checksum = manifest.Checksum
+		result.CompressedSize = manifest.Size
+		result.CompressionRatio = float64(result.TotalSize) / float64(manifest.Size)
+		result.Volumes = len(manifest.Volumes)
+
+		progress.phase(types.ProgressPhaseRetention, 0, 0)
+		if err := s.cleanupOldBackups(ctx, policy); err != nil {
+			logger.Error("Ошибка очистки старых бэкапов", "error", err)
+		}
+
+		return result, nil
+	}
+
 	// Архивирование (если включено)
 	if policy.ArchiveEnabled {
 		archivePath := backupPath + ".tar.gz"
+		progress.phase(types.ProgressPhaseArchive, int64(len(files)), totalSize)
 		if err := s.createArchive(ctx, backupPath, archivePath); err != nil {
 			return nil, fmt.Errorf("ошибка архивирования: %w", err)
 		}
@@ -190,6 +383,8 @@ func (s *Service) performBackup(ctx context.Context, policy *types.BackupPolicy,
 	// Шифрование (если включено)
 	if policy.EncryptionEnabled {
 		encryptedPath := backupPath + ".enc"
+		size, _ := s.getFileSize(backupPath)
+		progress.phase(types.ProgressPhaseEncrypt, 0, size)
 		if err := s.encryptFile(ctx, backupPath, encryptedPath, policy.EncryptionPassword); err != nil {
 			return nil, fmt.Errorf("ошибка шифрования: %w", err)
 		}
@@ -203,15 +398,40 @@ func (s *Service) performBackup(ctx context.Context, policy *types.BackupPolicy,
 	}
 	result.Checksum = checksum
 
-	// Загрузка в хранилище
-	remotePath := s.generateRemotePath(policy, backupName)
-	if err := s.storage.Upload(ctx, backupPath, remotePath); err != nil {
-		return nil, fmt.Errorf("ошибка загрузки в хранилище: %w", err)
+	// Данные четности для восстановления поврежденных блоков бэкапа
+	var parityPath string
+	if policy.Parity != nil {
+		parityPath = backupPath + paritySuffix
+		if err := createParity(ctx, backupPath, parityPath, *policy.Parity); err != nil {
+			return nil, fmt.Errorf("ошибка создания данных четности: %w", err)
+		}
 	}
 
-	result.BackupPath = remotePath
+	// Готовый бэкап переносится к каталогу до загрузки: загрузка, прерванная
+	// ошибкой или завершением процесса, продолжится при следующем запуске
+	upload, err := s.stagePendingUpload(ctx, job, backupPath, parityPath, path.Join(remotePrefix, backupName), result)
+	if err != nil {
+		return nil, err
+	}
+
+	// Загрузка в хранилище
+	uploadSize, _ := s.getFileSize(upload.LocalPath)
+	if upload.ParityPath != "" {
+		paritySize, _ := s.getFileSize(upload.ParityPath)
+		uploadSize += paritySize
+	}
+	progress.phase(types.ProgressPhaseUpload, 0, uploadSize)
+	if err := s.uploadBackup(ctx, storage, upload, logger); err != nil {
+		// Отмененная задача не продолжается
+		if ctx.Err() != nil {
+			s.discardPendingUpload(ctx, job.ID)
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w; загрузка будет продолжена при следующем запуске политики", err)
+	}
 
 	// Очистка старых бэкапов согласно политике retention
+	progress.phase(types.ProgressPhaseRetention, 0, 0)
 	if err := s.cleanupOldBackups(ctx, policy); err != nil {
 		logger.Error("Ошибка очистки старых бэкапов", "error", err)
 		// Не прерываем выполнение, только логируем
@@ -220,10 +440,76 @@ func (s *Service) performBackup(ctx context.Context, policy *types.BackupPolicy,
 	return result, nil
 }
 
+// uploadBackup загружает готовый бэкап и данные четности в хранилище
+// и проверяет записанный объект
+//
+// Путь в хранилище, контрольная сумма объекта и результаты по хранилищам
+// записываются в upload.Result.
+func (s *Service) uploadBackup(ctx context.Context, storage StorageProvider, upload *pendingUpload, logger *logger.BackupLogger) error {
+	backupPath, parityPath, remotePath := upload.LocalPath, upload.ParityPath, upload.RemotePath
+	result := upload.Result
+
+	var targets []types.TargetResult
+	var err error
+	if uploader, ok := storage.(targetUploader); ok {
+		targets, err = uploader.UploadTargets(ctx, backupPath, remotePath)
+		for _, target := range targets {
+			if target.Status != types.TargetStatusCompleted {
+				logger.Warn("Копия бэкапа не записана в хранилище",
+					"target", target.Target,
+					"error", target.Error)
+			}
+		}
+	} else {
+		err = storage.Upload(ctx, backupPath, remotePath)
+	}
+	if err != nil {
+		s.discardPartial(ctx, storage, remotePath)
+		return fmt.Errorf("ошибка загрузки в хранилище: %w", err)
+	}
+
+	// Копии в MultiStorage проверены при записи. Без результатов по
+	// хранилищам (обычное хранилище, в том числе под префиксом) записанный
+	// объект проверяется по контрольной сумме хранилища
+	result.Targets = targets
+	for _, target := range targets {
+		if target.Status == types.TargetStatusCompleted && target.Checksum != "" {
+			result.RemoteChecksum = target.Checksum
+			break
+		}
+	}
+	if len(targets) == 0 {
+		remoteChecksum, err := verifyUpload(ctx, storage, backupPath, remotePath)
+		switch {
+		case errors.Is(err, storageapi.ErrVerifyUnsupported):
+			logger.Warn("Хранилище не поддерживает проверку записанного бэкапа", "error", err)
+		case err != nil:
+			storage.Delete(context.WithoutCancel(ctx), remotePath)
+			return fmt.Errorf("ошибка проверки записанного бэкапа: %w", err)
+		default:
+			result.RemoteChecksum = remoteChecksum
+		}
+	}
+
+	result.BackupPath = remotePath
+
+	if parityPath != "" {
+		if err := storage.Upload(ctx, parityPath, remotePath+paritySuffix); err != nil {
+			s.discardPartial(ctx, storage, remotePath+paritySuffix)
+			storage.Delete(context.WithoutCancel(ctx), remotePath)
+			return fmt.Errorf("ошибка загрузки данных четности: %w", err)
+		}
+		result.ParityPath = remotePath + paritySuffix
+	}
+
+	return nil
+}
+
 // scanDirectory сканирует директорию и возвращает список файлов
 func (s *Service) scanDirectory(ctx context.Context, path string) ([]string, int64, error) {
 	var files []string
 	var totalSize int64
+	progress := progressFrom(ctx)
 
 	err := filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -233,6 +519,8 @@ func (s *Service) scanDirectory(ctx context.Context, path string) ([]string, int
 		if !info.IsDir() {
 			files = append(files, filePath)
 			totalSize += info.Size()
+			progress.file(filePath)
+			progress.add(types.ProgressPhaseScan, info.Size())
 		}
 
 		// Проверка отмены контекста
@@ -248,57 +536,93 @@ func (s *Service) scanDirectory(ctx context.Context, path string) ([]string, int
 	return files, totalSize, err
 }
 
-// copyFiles копирует файлы в целевую директорию
-func (s *Service) copyFiles(ctx context.Context, sourcePath, destPath string, files []string, logger *logger.BackupLogger) error {
+// copyFiles копирует файлы в целевую директорию и возвращает манифест скопированных файлов
+func (s *Service) copyFiles(ctx context.Context, sourcePath, destPath string, files []string, logger *logger.BackupLogger) ([]types.BackupFile, error) {
+	manifest := make([]types.BackupFile, 0, len(files))
+	progress := progressFrom(ctx)
+
 	for i, file := range files {
 		// Вычисление относительного пути
 		relPath, err := filepath.Rel(sourcePath, file)
 		if err != nil {
-			return fmt.Errorf("ошибка вычисления относительного пути: %w", err)
+			return nil, fmt.Errorf("ошибка вычисления относительного пути: %w", err)
 		}
 
 		destFile := filepath.Join(destPath, relPath)
 
 		// Создание директории для файла
 		if err := os.MkdirAll(filepath.Dir(destFile), 0755); err != nil {
-			return fmt.Errorf("ошибка создания директории: %w", err)
+			return nil, fmt.Errorf("ошибка создания директории: %w", err)
 		}
 
 		// Копирование файла
-		if err := s.copyFile(file, destFile); err != nil {
-			return fmt.Errorf("ошибка копирования файла %s: %w", file, err)
+		entry, err := s.copyFile(file, destFile)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка копирования файла %s: %w", file, err)
 		}
+		entry.Path = filepath.ToSlash(relPath)
+		entry.SourcePath = file
+		manifest = append(manifest, entry)
 
-		// Логирование прогресса
+		// Логирование и публикация прогресса
 		logger.LogBackupProgress(ctx, int64(i+1), int64(len(files)), file)
+		progress.file(entry.Path)
+		progress.add(types.ProgressPhaseCopy, entry.Size)
 
 		// Проверка отмены контекста
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return nil, ctx.Err()
 		default:
 		}
 	}
 
-	return nil
+	return manifest, nil
 }
 
-// copyFile копирует один файл
-func (s *Service) copyFile(src, dst string) error {
+// copyFile копирует один файл с сохранением прав доступа и времени изменения
+// и возвращает запись манифеста с его размером и SHA-256
+func (s *Service) copyFile(src, dst string) (types.BackupFile, error) {
 	source, err := os.Open(src)
 	if err != nil {
-		return err
+		return types.BackupFile{}, err
 	}
 	defer source.Close()
 
-	destination, err := os.Create(dst)
+	info, err := source.Stat()
 	if err != nil {
-		return err
+		return types.BackupFile{}, err
+	}
+
+	destination, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return types.BackupFile{}, err
 	}
 	defer destination.Close()
 
-	_, err = io.Copy(destination, source)
-	return err
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(destination, hash), source)
+	if err != nil {
+		return types.BackupFile{}, err
+	}
+	if err := destination.Close(); err != nil {
+		return types.BackupFile{}, err
+	}
+
+	// Права при создании урезаются umask, поэтому выставляем их явно
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return types.BackupFile{}, err
+	}
+	if err := os.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+		return types.BackupFile{}, err
+	}
+
+	return types.BackupFile{
+		Size:     size,
+		Mode:     uint32(info.Mode().Perm()),
+		ModTime:  info.ModTime(),
+		Checksum: fmt.Sprintf("%x", hash.Sum(nil)),
+	}, nil
 }
 
 // generateBackupName генерирует имя файла бэкапа
@@ -308,22 +632,6 @@ func (s *Service) generateBackupName(policy *types.BackupPolicy) string {
 	return fmt.Sprintf("%s-%s", baseName, timestamp)
 }
 
-// generateRemotePath генерирует путь в удаленном хранилище
-func (s *Service) generateRemotePath(policy *types.BackupPolicy, backupName string) string {
-	// Извлекаем путь из destination
-	if strings.HasPrefix(policy.DestinationPath, "s3://") ||
-		strings.HasPrefix(policy.DestinationPath, "gcs://") {
-		parts := strings.SplitN(policy.DestinationPath, "/", 4)
-		if len(parts) >= 4 {
-			return fmt.Sprintf("%s/%s", parts[3], backupName)
-		}
-		return backupName
-	}
-
-	// Локальный путь
-	return filepath.Join(policy.DestinationPath, backupName)
-}
-
 // calculateChecksum вычисляет SHA-256 контрольную сумму файла
 func (s *Service) calculateChecksum(filePath string) (string, error) {
 	file, err := os.Open(filePath)
@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	"backupist/internal/logger"
+	"backupist/pkg/types"
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestService сервис с временными каталогом и локальным хранилищем
+// без повторов операций; configure может изменить конфигурацию
+func newTestService(t *testing.T, configure func(cfg *config.Config)) *Service {
+	t.Helper()
+	dir := t.TempDir()
+
+	cfg := config.NewConfig()
+	cfg.Database.Path = filepath.Join(dir, "backup.db")
+	cfg.Storage.LocalPath = filepath.Join(dir, "storage")
+	cfg.Storage.Configs = map[string]types.StorageConfig{
+		"local": {Type: types.StorageTypeLocal, LocalPath: cfg.Storage.LocalPath},
+	}
+	cfg.Storage.Retry.MaxAttempts = 1
+	if configure != nil {
+		configure(cfg)
+	}
+
+	log := logger.NewStructuredLoggerWithConfig("test", &logger.LogConfig{Level: slog.LevelError})
+	service := NewService(cfg, log)
+	if err := service.Initialize(context.Background()); err != nil {
+		t.Fatalf("инициализация сервиса: %v", err)
+	}
+	t.Cleanup(func() { service.Close() })
+
+	return service
+}
+
+// createTestPolicy создает политику с исходной директорией из files;
+// configure может изменить политику до сохранения
+func createTestPolicy(t *testing.T, service *Service, name string, files map[string]string, configure func(*types.BackupPolicy)) *types.BackupPolicy {
+	t.Helper()
+
+	source := filepath.Join(t.TempDir(), name)
+	for rel, content := range files {
+		writeTestFile(t, filepath.Join(source, filepath.FromSlash(rel)), content)
+	}
+
+	policy := &types.BackupPolicy{
+		Name:            name,
+		SourcePath:      source,
+		DestinationPath: "backups",
+		RetentionCount:  5,
+		ArchiveEnabled:  true,
+	}
+	if configure != nil {
+		configure(policy)
+	}
+	if err := service.CreatePolicy(context.Background(), policy); err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+// runTestBackup выполняет бэкап политики и возвращает завершенную задачу
+func runTestBackup(t *testing.T, service *Service, policyID string) *types.BackupJob {
+	t.Helper()
+	ctx := context.Background()
+
+	job, err := service.CreatePolicyJob(ctx, policyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.ExecuteBackup(ctx, job); err != nil {
+		t.Fatalf("бэкап политики: %v", err)
+	}
+	return job
+}
+
+func TestBackupVerifiedInPrefixedStorage(t *testing.T) {
+	root := t.TempDir()
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.Storage.URL = "file://" + filepath.ToSlash(root) + "/storage"
+		cfg.Storage.Default = ""
+	})
+	if _, ok := service.storage.(*prefixedStorage); !ok {
+		t.Fatalf("хранилище по умолчанию %T, ожидалось хранилище с префиксом", service.storage)
+	}
+	policy := createTestPolicy(t, service, "prefixed", map[string]string{"a.txt": "alpha"}, nil)
+
+	job, err := service.CreatePolicyJob(context.Background(), policy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := service.ExecuteBackup(context.Background(), job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Обычное хранилище под префиксом не возвращает результатов по
+	// хранилищам, и записанный бэкап проверяется по контрольной сумме
+	if len(result.Targets) != 0 || result.RemoteChecksum == "" {
+		t.Fatalf("бэкап в хранилище с префиксом не проверен: targets %v, checksum %q", result.Targets, result.RemoteChecksum)
+	}
+}
+
+func TestExecuteBackupMarksEarlyFailure(t *testing.T) {
+	service := newTestService(t, nil)
+	ctx := context.Background()
+
+	// Задача удаленной политики
+	orphan := &types.BackupJob{ID: uuid.New().String(), PolicyID: uuid.New().String(), Status: types.JobStatusPending, CreatedAt: time.Now()}
+	if err := service.saveBackupJob(ctx, orphan); err != nil {
+		t.Fatal(err)
+	}
+
+	// Блокировку политики нельзя захватить: на месте директории блокировок файл
+	policy := createTestPolicy(t, service, "unlockable", map[string]string{"a.txt": "alpha"}, nil)
+	writeTestFile(t, service.policyLocksDir(), "not a directory")
+	locked, err := service.CreatePolicyJob(ctx, policy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, job := range []*types.BackupJob{orphan, locked} {
+		if _, err := service.ExecuteBackup(ctx, job); err == nil {
+			t.Fatalf("задача %s выполнена без ошибки", job.ID)
+		}
+		saved, err := service.getBackupJob(ctx, job.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved.Status != types.JobStatusFailed || saved.Error == "" || saved.CompletedAt == nil {
+			t.Fatalf("задача %s осталась в статусе %s: %q", job.ID, saved.Status, saved.Error)
+		}
+	}
+}
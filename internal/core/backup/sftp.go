@@ -0,0 +1,365 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	"backupist/pkg/types"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// defaultSFTPPort порт SSH по умолчанию
+const defaultSFTPPort = 22
+
+// SFTPStorage реализация хранилища на удаленном сервере по SFTP
+type SFTPStorage struct {
+	sshClient *ssh.Client
+	client    *sftp.Client
+	basePath  string
+}
+
+// ParseSFTPURL разбирает адрес вида sftp://user@host:port/path
+//
+// Дополнительные параметры передаются в query: key (путь к приватному ключу)
+// и known_hosts (путь к файлу known_hosts). Если ключ не указан,
+// используется SSH агент.
+func ParseSFTPURL(rawURL string) (*types.SFTPConfig, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("неверный формат SFTP URL: %w", err)
+	}
+
+	if u.Scheme != "sftp" {
+		return nil, fmt.Errorf("неверная схема SFTP URL: %s", u.Scheme)
+	}
+
+	cfg := &types.SFTPConfig{
+		Host:           u.Hostname(),
+		Port:           defaultSFTPPort,
+		BasePath:       u.Path,
+		PrivateKeyPath: u.Query().Get("key"),
+		KnownHostsPath: u.Query().Get("known_hosts"),
+	}
+
+	if u.User != nil {
+		cfg.User = u.User.Username()
+	}
+
+	if port := u.Port(); port != "" {
+		cfg.Port, err = strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный порт в SFTP URL: %s", port)
+		}
+	}
+
+	cfg.UseAgent = cfg.PrivateKeyPath == ""
+
+	return cfg, nil
+}
+
+// NewSFTPStorage создает новый экземпляр SFTP хранилища
+func NewSFTPStorage(cfg *types.SFTPConfig) (*SFTPStorage, error) {
+	if err := config.ValidateSFTPConfig(cfg); err != nil {
+		return nil, fmt.Errorf("ошибка валидации конфигурации SFTP: %w", err)
+	}
+
+	authMethods, closeAgent, err := sftpAuthMethods(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAgent()
+
+	hostKeyCallback, err := sftpHostKeyCallback(cfg.KnownHostsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = defaultSFTPPort
+	}
+
+	sshClient, err := ssh.Dial("tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(port)), &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            authMethods,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         30 * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подключения к SSH серверу: %w", err)
+	}
+
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("ошибка создания SFTP клиента: %w", err)
+	}
+
+	return &SFTPStorage{
+		sshClient: sshClient,
+		client:    client,
+		basePath:  cfg.BasePath,
+	}, nil
+}
+
+// sftpAuthMethods формирует методы аутентификации: приватный ключ и/или SSH агент
+func sftpAuthMethods(cfg *types.SFTPConfig) ([]ssh.AuthMethod, func(), error) {
+	var methods []ssh.AuthMethod
+	closeAgent := func() {}
+
+	if cfg.PrivateKeyPath != "" {
+		keyData, err := os.ReadFile(cfg.PrivateKeyPath)
+		if err != nil {
+			return nil, closeAgent, fmt.Errorf("ошибка чтения приватного ключа: %w", err)
+		}
+
+		var signer ssh.Signer
+		if cfg.KeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(keyData, []byte(cfg.KeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(keyData)
+		}
+		if err != nil {
+			return nil, closeAgent, fmt.Errorf("ошибка разбора приватного ключа: %w", err)
+		}
+
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	if cfg.UseAgent {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return nil, closeAgent, fmt.Errorf("SSH агент недоступен: переменная SSH_AUTH_SOCK не задана")
+		}
+
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, closeAgent, fmt.Errorf("ошибка подключения к SSH агенту: %w", err)
+		}
+		closeAgent = func() { conn.Close() }
+
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	return methods, closeAgent, nil
+}
+
+// sftpHostKeyCallback создает проверку ключа хоста по файлу known_hosts
+func sftpHostKeyCallback(knownHostsPath string) (ssh.HostKeyCallback, error) {
+	if knownHostsPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка определения домашней директории: %w", err)
+		}
+		knownHostsPath = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	callback, err := knownhosts.New(knownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки known_hosts: %w", err)
+	}
+
+	return callback, nil
+}
+
+// fullPath возвращает путь на удаленном сервере
+func (ss *SFTPStorage) fullPath(remotePath string) string {
+	return path.Join(ss.basePath, filepath.ToSlash(remotePath))
+}
+
+// Upload загружает файл на SFTP сервер
+//
+// Файл записывается под временным именем и переименовывается после
+// успешной записи, чтобы прерванная загрузка не оставляла поврежденный бэкап.
+func (ss *SFTPStorage) Upload(ctx context.Context, localPath, remotePath string) error {
+	fullPath := ss.fullPath(remotePath)
+
+	// Создаем директорию если она не существует
+	if err := ss.client.MkdirAll(path.Dir(fullPath)); err != nil {
+		return fmt.Errorf("ошибка создания директории на SFTP сервере: %w", err)
+	}
+
+	src, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия исходного файла: %w", err)
+	}
+	defer src.Close()
+
+	tempPath := fmt.Sprintf("%s.tmp-%s", fullPath, uuid.New().String())
+	dst, err := ss.client.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("ошибка создания временного файла на SFTP сервере: %w", err)
+	}
+
+	if _, err := dst.ReadFrom(&contextReader{ctx: ctx, r: throttleReader(ctx, src, directionUpload)}); err != nil {
+		dst.Close()
+		ss.client.Remove(tempPath)
+		return fmt.Errorf("ошибка загрузки файла на SFTP сервер: %w", err)
+	}
+
+	if err := dst.Close(); err != nil {
+		ss.client.Remove(tempPath)
+		return fmt.Errorf("ошибка закрытия файла на SFTP сервере: %w", err)
+	}
+
+	if err := ss.rename(tempPath, fullPath); err != nil {
+		ss.client.Remove(tempPath)
+		return fmt.Errorf("ошибка переименования файла на SFTP сервере: %w", err)
+	}
+
+	return nil
+}
+
+// rename атомарно переименовывает файл, если сервер поддерживает posix-rename
+func (ss *SFTPStorage) rename(oldPath, newPath string) error {
+	if err := ss.client.PosixRename(oldPath, newPath); err == nil {
+		return nil
+	}
+
+	// Обычный SFTP rename не перезаписывает существующий файл
+	if err := ss.client.Remove(newPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return ss.client.Rename(oldPath, newPath)
+}
+
+// Download скачивает файл с SFTP сервера
+func (ss *SFTPStorage) Download(ctx context.Context, remotePath, localPath string) error {
+	// Создаем директорию для локального файла
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("ошибка создания локальной директории: %w", err)
+	}
+
+	src, err := ss.client.Open(ss.fullPath(remotePath))
+	if err != nil {
+		return fmt.Errorf("ошибка открытия файла на SFTP сервере: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("ошибка создания локального файла: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := src.WriteTo(&contextWriter{ctx: ctx, w: throttleWriter(ctx, dst, directionDownload)}); err != nil {
+		return fmt.Errorf("ошибка скачивания файла с SFTP сервера: %w", err)
+	}
+
+	return nil
+}
+
+// Open открывает файл на SFTP сервере для чтения потоком
+func (ss *SFTPStorage) Open(ctx context.Context, remotePath string) (io.ReadCloser, error) {
+	file, err := ss.client.Open(ss.fullPath(remotePath))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия файла на SFTP сервере: %w", err)
+	}
+
+	reader := &contextReader{ctx: ctx, r: throttleReader(ctx, file, directionDownload)}
+	return &readCloser{Reader: reader, Closer: file}, nil
+}
+
+// Delete удаляет файл с SFTP сервера
+func (ss *SFTPStorage) Delete(ctx context.Context, remotePath string) error {
+	return ss.client.Remove(ss.fullPath(remotePath))
+}
+
+// List возвращает список файлов в директории на SFTP сервере
+func (ss *SFTPStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	var files []string
+
+	walker := ss.client.Walk(ss.fullPath(prefix))
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return nil, fmt.Errorf("ошибка обхода директории на SFTP сервере: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		if walker.Stat().IsDir() {
+			continue
+		}
+
+		// Получаем путь относительно базовой директории
+		relPath := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), ss.basePath), "/")
+		files = append(files, relPath)
+	}
+
+	return files, nil
+}
+
+func (ss *SFTPStorage) Exists(ctx context.Context, remotePath string) (bool, error) {
+	_, err := ss.client.Stat(ss.fullPath(remotePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("ошибка проверки существования файла на SFTP сервере: %w", err)
+	}
+	return true, nil
+}
+
+// Close закрывает SFTP сессию и SSH соединение
+func (ss *SFTPStorage) Close() error {
+	ss.client.Close()
+	return ss.sshClient.Close()
+}
+
+// Verify сравнивает размер записанного файла с локальным
+//
+// SFTP не предоставляет контрольных сумм файлов, а повторное чтение
+// файла по сети обходится дорого, поэтому проверяется только размер.
+func (ss *SFTPStorage) Verify(ctx context.Context, localPath, remotePath string) (string, error) {
+	info, err := ss.client.Stat(ss.fullPath(remotePath))
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения информации о файле на SFTP сервере: %w", err)
+	}
+
+	return verifySize(localPath, remotePath, info.Size())
+}
+
+// contextReader прерывает чтение при отмене контекста
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// contextWriter прерывает запись при отмене контекста
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw *contextWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(p)
+}
@@ -0,0 +1,353 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testSFTPServer SSH сервер с подсистемой sftp над временной директорией
+type testSFTPServer struct {
+	root    string // Корень хранилища на "удаленной" стороне
+	config  *types.SFTPConfig
+	hostKey ssh.Signer
+}
+
+func newTestSFTPServer(t *testing.T) *testSFTPServer {
+	t.Helper()
+	dir := t.TempDir()
+
+	hostKey := newTestSigner(t)
+	keyPath := filepath.Join(dir, "id_ed25519")
+	writeTestPrivateKey(t, keyPath)
+	clientKey := readTestSigner(t, keyPath)
+
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "backup" && string(key.Marshal()) == string(clientKey.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	serverConfig.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSFTP(conn, serverConfig)
+		}
+	}()
+
+	// known_hosts с ключом сервера
+	addr := listener.Addr().(*net.TCPAddr)
+	knownHostsPath := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr.String())}, hostKey.PublicKey())
+	if err := os.WriteFile(knownHostsPath, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	root := filepath.Join(dir, "remote")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testSFTPServer{
+		root:    root,
+		hostKey: hostKey,
+		config: &types.SFTPConfig{
+			Host:           "127.0.0.1",
+			Port:           addr.Port,
+			User:           "backup",
+			BasePath:       root,
+			PrivateKeyPath: keyPath,
+			KnownHostsPath: knownHostsPath,
+		},
+	}
+}
+
+// serveTestSFTP обслуживает SSH соединение: единственная подсистема - sftp
+func serveTestSFTP(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "только session")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func() {
+			for request := range channelRequests {
+				ok := request.Type == "subsystem" && len(request.Payload) > 4 && string(request.Payload[4:]) == "sftp"
+				request.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+
+				server, err := sftp.NewServer(channel)
+				if err != nil {
+					channel.Close()
+					return
+				}
+				server.Serve()
+				server.Close()
+				return
+			}
+		}()
+	}
+}
+
+// newTestSigner генерирует ключ ed25519 для SSH
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// writeTestPrivateKey записывает новый приватный ключ в формате OpenSSH
+func writeTestPrivateKey(t *testing.T, path string) {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestSigner(t *testing.T, path string) ssh.Signer {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// remoteFiles возвращает файлы на "удаленной" стороне относительно корня
+func remoteFiles(t *testing.T, root string) []string {
+	t.Helper()
+
+	var files []string
+	filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			rel, _ := filepath.Rel(root, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	slices.Sort(files)
+	return files
+}
+
+func TestSFTPUpload(t *testing.T) {
+	server := newTestSFTPServer(t)
+	storage, err := NewSFTPStorage(server.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	ctx := context.Background()
+
+	local := filepath.Join(t.TempDir(), "backup.tar")
+	writeTestFile(t, local, "new backup")
+
+	// Загрузка перезаписывает существующий файл через временное имя
+	writeTestFile(t, filepath.Join(server.root, "daily", "backup.tar"), "old backup")
+	if err := storage.Upload(ctx, local, "daily/backup.tar"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(server.root, "daily", "backup.tar"))
+	if err != nil || string(data) != "new backup" {
+		t.Fatalf("загруженный файл: %q, %v", data, err)
+	}
+	if files := remoteFiles(t, server.root); !slices.Equal(files, []string{"daily/backup.tar"}) {
+		t.Fatalf("файлы на сервере после загрузки: %v", files)
+	}
+
+	// Прерванная загрузка не трогает прежний файл и не оставляет временного
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	writeTestFile(t, local, "broken backup")
+	if err := storage.Upload(cancelled, local, "daily/backup.tar"); err == nil {
+		t.Fatal("загрузка с отмененным контекстом завершилась без ошибки")
+	}
+	data, _ = os.ReadFile(filepath.Join(server.root, "daily", "backup.tar"))
+	if string(data) != "new backup" {
+		t.Fatalf("прерванная загрузка изменила файл: %q", data)
+	}
+	if files := remoteFiles(t, server.root); !slices.Equal(files, []string{"daily/backup.tar"}) {
+		t.Fatalf("файлы на сервере после прерванной загрузки: %v", files)
+	}
+
+	downloaded := filepath.Join(t.TempDir(), "restored.tar")
+	if err := storage.Download(ctx, "daily/backup.tar", downloaded); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(downloaded); string(data) != "new backup" {
+		t.Fatalf("скачанный файл: %q", data)
+	}
+}
+
+func TestSFTPUploadTempName(t *testing.T) {
+	server := newTestSFTPServer(t)
+	storage, err := NewSFTPStorage(server.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+
+	// Данные идут через FIFO: пока он открыт, загрузка не завершена
+	fifo := filepath.Join(t.TempDir(), "backup.fifo")
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		t.Skipf("FIFO недоступен: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- storage.Upload(context.Background(), fifo, "backup.tar") }()
+
+	writer, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write([]byte("first part"))
+
+	var files []string
+	deadline := time.Now().Add(5 * time.Second)
+	for len(files) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		files = remoteFiles(t, server.root)
+	}
+	if len(files) != 1 || !strings.HasPrefix(files[0], "backup.tar.tmp-") {
+		t.Fatalf("во время загрузки на сервере: %v; ожидался только временный файл", files)
+	}
+
+	writer.Write([]byte(", second part"))
+	writer.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if files := remoteFiles(t, server.root); !slices.Equal(files, []string{"backup.tar"}) {
+		t.Fatalf("после загрузки на сервере: %v", files)
+	}
+}
+
+func TestSFTPExistsAndList(t *testing.T) {
+	server := newTestSFTPServer(t)
+	storage, err := NewSFTPStorage(server.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	ctx := context.Background()
+
+	writeTestFile(t, filepath.Join(server.root, "a", "one.tar"), "1")
+	writeTestFile(t, filepath.Join(server.root, "a", "nested", "two.tar"), "2")
+	writeTestFile(t, filepath.Join(server.root, "b", "three.tar"), "3")
+
+	for remotePath, want := range map[string]bool{"a/one.tar": true, "a/missing.tar": false, "missing/one.tar": false} {
+		exists, err := storage.Exists(ctx, remotePath)
+		if err != nil || exists != want {
+			t.Fatalf("Exists(%s) = %v, %v; ожидалось %v", remotePath, exists, err, want)
+		}
+	}
+
+	files, err := storage.List(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(files)
+	if !slices.Equal(files, []string{"a/nested/two.tar", "a/one.tar"}) {
+		t.Fatalf("List(a) = %v", files)
+	}
+
+	if err := storage.Delete(ctx, "a/one.tar"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := storage.Exists(ctx, "a/one.tar"); exists {
+		t.Fatal("файл существует после удаления")
+	}
+}
+
+func TestSFTPKnownHosts(t *testing.T) {
+	server := newTestSFTPServer(t)
+
+	// Ключ сервера не совпадает с записанным в known_hosts
+	other := newTestSigner(t)
+	line := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(server.config.Host, strconv.Itoa(server.config.Port)))}, other.PublicKey())
+	if err := os.WriteFile(server.config.KnownHostsPath, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSFTPStorage(server.config); err == nil || !strings.Contains(err.Error(), "key mismatch") {
+		t.Fatalf("подключение к серверу с другим ключом: %v", err)
+	}
+
+	// Сервера нет в known_hosts
+	if err := os.WriteFile(server.config.KnownHostsPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSFTPStorage(server.config); err == nil || !strings.Contains(err.Error(), "key is unknown") {
+		t.Fatalf("подключение к неизвестному серверу: %v", err)
+	}
+}
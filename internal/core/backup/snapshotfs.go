@@ -0,0 +1,501 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// snapshotTreeCacheSize сколько деревьев файлов бэкапов держать в памяти
+const snapshotTreeCacheSize = 16
+
+// snapshotFS файловая система WebDAV только для чтения над бэкапами
+//
+// Структура: /<политика>/<время бэкапа>/<путь файла относительно
+// исходной директории>. Директории строятся по индексу файлов из
+// каталога, содержимое файлов читается из архива бэкапа при открытии.
+type snapshotFS struct {
+	service *Service
+
+	mu    sync.Mutex
+	trees map[string]*snapshotTree // По ID задачи; индекс завершенного бэкапа не меняется
+}
+
+// newSnapshotFS создает файловую систему бэкапов
+func newSnapshotFS(service *Service) *snapshotFS {
+	return &snapshotFS{
+		service: service,
+		trees:   make(map[string]*snapshotTree),
+	}
+}
+
+// snapshotTree дерево файлов одного бэкапа
+type snapshotTree struct {
+	files map[string]*types.BackupFile // По пути относительно исходной директории
+	dirs  map[string][]os.FileInfo     // Содержимое директорий; "" - корень бэкапа
+}
+
+// snapshotNode разрешенный путь файловой системы бэкапов
+type snapshotNode struct {
+	info     *snapshotInfo
+	children []os.FileInfo // Для директорий
+
+	// Для файлов бэкапа
+	job  *types.BackupJob
+	file *types.BackupFile
+}
+
+func (fs *snapshotFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return os.ErrPermission
+}
+
+func (fs *snapshotFS) RemoveAll(ctx context.Context, name string) error {
+	return os.ErrPermission
+}
+
+func (fs *snapshotFS) Rename(ctx context.Context, oldName, newName string) error {
+	return os.ErrPermission
+}
+
+func (fs *snapshotFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	node, err := fs.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return node.info, nil
+}
+
+func (fs *snapshotFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, os.ErrPermission
+	}
+
+	node, err := fs.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.info.IsDir() {
+		return &snapshotDir{info: node.info, children: node.children}, nil
+	}
+
+	// Политика из листинга не содержит пароля шифрования
+	policy, err := fs.service.getPolicy(ctx, node.job.PolicyID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения политики: %w", err)
+	}
+
+	return &snapshotFile{
+		ctx:     ctx,
+		service: fs.service,
+		policy:  policy,
+		job:     node.job,
+		file:    node.file,
+		info:    node.info,
+	}, nil
+}
+
+// resolve находит путь в файловой системе бэкапов
+func (fs *snapshotFS) resolve(ctx context.Context, name string) (*snapshotNode, error) {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	var segments []string
+	if name != "" {
+		segments = strings.Split(name, "/")
+	}
+
+	policies, err := fs.policyDirs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		node := &snapshotNode{info: dirInfo("/", time.Time{})}
+		for _, dir := range policies {
+			node.children = append(node.children, dir.info)
+			if dir.info.modTime.After(node.info.modTime) {
+				node.info.modTime = dir.info.modTime
+			}
+		}
+		return node, nil
+	}
+
+	policyDir, ok := findSnapshotDir(policies, segments[0])
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	snapshots, err := fs.snapshotDirs(ctx, policyDir.policy)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 1 {
+		node := &snapshotNode{info: policyDir.info}
+		for _, dir := range snapshots {
+			node.children = append(node.children, dir.info)
+		}
+		return node, nil
+	}
+
+	snapshotDir, ok := findSnapshotDir(snapshots, segments[1])
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	tree, err := fs.tree(ctx, snapshotDir.job)
+	if err != nil {
+		return nil, err
+	}
+
+	rel := strings.Join(segments[2:], "/")
+	if file, ok := tree.files[rel]; ok {
+		return &snapshotNode{
+			info: fileInfo(path.Base(rel), file),
+			job:  snapshotDir.job,
+			file: file,
+		}, nil
+	}
+	if children, ok := tree.dirs[rel]; ok {
+		info := snapshotDir.info
+		if rel != "" {
+			info = dirInfo(path.Base(rel), snapshotDir.job.StartedAt)
+		}
+		return &snapshotNode{info: info, children: children}, nil
+	}
+
+	return nil, os.ErrNotExist
+}
+
+// snapshotDirEntry директория политики или бэкапа
+type snapshotDirEntry struct {
+	info   *snapshotInfo
+	policy *types.BackupPolicy
+	job    *types.BackupJob
+}
+
+// findSnapshotDir ищет директорию по имени
+func findSnapshotDir(dirs []snapshotDirEntry, name string) (snapshotDirEntry, bool) {
+	for _, dir := range dirs {
+		if dir.info.name == name {
+			return dir, true
+		}
+	}
+	return snapshotDirEntry{}, false
+}
+
+// policyDirs возвращает директории политик
+//
+// Директория называется по имени политики; если имена совпадают, к
+// имени добавляется начало ID политики.
+func (fs *snapshotFS) policyDirs(ctx context.Context) ([]snapshotDirEntry, error) {
+	policies, err := fs.service.getAllPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []snapshotDirEntry
+	names := uniqueDirNames(len(policies), func(i int) (string, string) {
+		return policies[i].Name, policies[i].ID
+	})
+	for i, policy := range policies {
+		dirs = append(dirs, snapshotDirEntry{info: dirInfo(names[i], policy.UpdatedAt), policy: policy})
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].info.name < dirs[j].info.name })
+
+	return dirs, nil
+}
+
+// snapshotDirs возвращает директории завершенных бэкапов политики
+//
+// Директория называется по времени начала бэкапа: 2026-10-18_12-48-52.
+func (fs *snapshotFS) snapshotDirs(ctx context.Context, policy *types.BackupPolicy) ([]snapshotDirEntry, error) {
+	jobs, _, err := fs.service.listBackupJobs(ctx, JobFilter{PolicyID: policy.ID, Status: types.JobStatusCompleted, Backups: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []snapshotDirEntry
+	names := uniqueDirNames(len(jobs), func(i int) (string, string) {
+		return jobs[i].StartedAt.Local().Format("2006-01-02_15-04-05"), jobs[i].ID
+	})
+	for i, job := range jobs {
+		dirs = append(dirs, snapshotDirEntry{info: dirInfo(names[i], job.StartedAt), job: job})
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].info.name < dirs[j].info.name })
+
+	return dirs, nil
+}
+
+// uniqueDirNames возвращает имена директорий без "/" и повторов; к
+// повторяющимся именам добавляется начало ID
+func uniqueDirNames(n int, item func(i int) (name, id string)) []string {
+	names := make([]string, n)
+	count := make(map[string]int)
+	for i := range names {
+		name, _ := item(i)
+		name = strings.ReplaceAll(strings.TrimSpace(name), "/", "_")
+		if name == "" || name == "." || name == ".." {
+			name = "_"
+		}
+		names[i] = name
+		count[name]++
+	}
+
+	for i, name := range names {
+		if count[name] > 1 {
+			_, id := item(i)
+			if len(id) > 8 {
+				id = id[:8]
+			}
+			names[i] = name + "_" + id
+		}
+	}
+
+	return names
+}
+
+// tree возвращает дерево файлов бэкапа задачи
+func (fs *snapshotFS) tree(ctx context.Context, job *types.BackupJob) (*snapshotTree, error) {
+	fs.mu.Lock()
+	tree, ok := fs.trees[job.ID]
+	fs.mu.Unlock()
+	if ok {
+		return tree, nil
+	}
+
+	files, err := fs.service.backupIndex(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	tree = &snapshotTree{
+		files: make(map[string]*types.BackupFile, len(files)),
+		dirs:  map[string][]os.FileInfo{"": nil},
+	}
+	for i := range files {
+		file := &files[i]
+		tree.files[file.Path] = file
+
+		// Добавляем файл и недостающие родительские директории
+		var child os.FileInfo = fileInfo(path.Base(file.Path), file)
+		for dir := path.Dir(file.Path); ; dir = path.Dir(dir) {
+			if dir == "." {
+				dir = ""
+			}
+			_, exists := tree.dirs[dir]
+			tree.dirs[dir] = append(tree.dirs[dir], child)
+			if exists || dir == "" {
+				break
+			}
+			child = dirInfo(path.Base(dir), job.StartedAt)
+		}
+	}
+
+	for _, children := range tree.dirs {
+		sort.Slice(children, func(i, j int) bool { return children[i].Name() < children[j].Name() })
+	}
+
+	fs.mu.Lock()
+	if len(fs.trees) >= snapshotTreeCacheSize {
+		for jobID := range fs.trees {
+			delete(fs.trees, jobID)
+			break
+		}
+	}
+	fs.trees[job.ID] = tree
+	fs.mu.Unlock()
+
+	return tree, nil
+}
+
+// snapshotInfo сведения о файле или директории файловой системы бэкапов
+type snapshotInfo struct {
+	name     string
+	size     int64
+	mode     os.FileMode
+	modTime  time.Time
+	checksum string
+}
+
+// dirInfo возвращает сведения о директории
+func dirInfo(name string, modTime time.Time) *snapshotInfo {
+	return &snapshotInfo{name: name, mode: os.ModeDir | 0555, modTime: modTime}
+}
+
+// fileInfo возвращает сведения о файле бэкапа; файлы доступны только для чтения
+func fileInfo(name string, file *types.BackupFile) *snapshotInfo {
+	return &snapshotInfo{
+		name:     name,
+		size:     file.Size,
+		mode:     os.FileMode(file.Mode).Perm() &^ 0222,
+		modTime:  file.ModTime,
+		checksum: file.Checksum,
+	}
+}
+
+func (fi *snapshotInfo) Name() string       { return fi.name }
+func (fi *snapshotInfo) Size() int64        { return fi.size }
+func (fi *snapshotInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *snapshotInfo) ModTime() time.Time { return fi.modTime }
+func (fi *snapshotInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *snapshotInfo) Sys() any           { return nil }
+
+// ContentType определяет тип содержимого по расширению, не читая файл из бэкапа
+func (fi *snapshotInfo) ContentType(ctx context.Context) (string, error) {
+	if contentType := mime.TypeByExtension(path.Ext(fi.name)); contentType != "" {
+		return contentType, nil
+	}
+	return "application/octet-stream", nil
+}
+
+// ETag возвращает SHA-256 файла из индекса
+func (fi *snapshotInfo) ETag(ctx context.Context) (string, error) {
+	if fi.checksum == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + fi.checksum + `"`, nil
+}
+
+// snapshotDir открытая директория файловой системы бэкапов
+type snapshotDir struct {
+	info     *snapshotInfo
+	children []os.FileInfo
+	offset   int
+}
+
+func (d *snapshotDir) Readdir(count int) ([]os.FileInfo, error) {
+	rest := d.children[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.children)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(rest) {
+		count = len(rest)
+	}
+	d.offset += count
+	return rest[:count], nil
+}
+
+func (d *snapshotDir) Stat() (os.FileInfo, error) { return d.info, nil }
+func (d *snapshotDir) Close() error               { return nil }
+
+func (d *snapshotDir) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("%s является директорией", d.info.name)
+}
+
+func (d *snapshotDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, fmt.Errorf("%s является директорией", d.info.name)
+}
+
+func (d *snapshotDir) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+// snapshotFile открытый файл бэкапа
+//
+// Поток файла из архива открывается при первом чтении. Переход вперед
+// пропускает данные потока, переход назад открывает поток заново,
+// поэтому последовательное чтение и докачка не читают архив повторно.
+type snapshotFile struct {
+	ctx     context.Context
+	service *Service
+	policy  *types.BackupPolicy
+	job     *types.BackupJob
+	file    *types.BackupFile
+	info    *snapshotInfo
+
+	offset int64         // Позиция чтения
+	reader io.ReadCloser // Поток файла из архива; nil - не открыт
+	pos    int64         // Позиция потока
+}
+
+// Read читает файл из архива; ошибки пишутся в лог, так как HTTP-сервер
+// после отправки заголовков ответа их не сообщает
+func (f *snapshotFile) Read(p []byte) (int, error) {
+	n, err := f.read(p)
+	if err != nil && err != io.EOF {
+		f.service.logger.WarnContext(f.ctx, "Ошибка чтения файла бэкапа",
+			"job_id", f.job.ID,
+			"path", f.file.Path,
+			"error", err)
+	}
+	return n, err
+}
+
+func (f *snapshotFile) read(p []byte) (int, error) {
+	if f.offset >= f.info.size {
+		return 0, io.EOF
+	}
+
+	if f.reader != nil && f.pos > f.offset {
+		f.reader.Close()
+		f.reader = nil
+	}
+	if f.reader == nil {
+		reader, err := f.service.openEntry(f.ctx, f.policy, f.job, f.file)
+		if err != nil {
+			return 0, err
+		}
+		f.reader, f.pos = reader, 0
+	}
+	if f.pos < f.offset {
+		skipped, err := io.CopyN(io.Discard, f.reader, f.offset-f.pos)
+		f.pos += skipped
+		if err != nil {
+			return 0, fmt.Errorf("ошибка чтения файла %s: %w", f.file.Path, err)
+		}
+	}
+
+	n, err := f.reader.Read(p)
+	f.pos += int64(n)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *snapshotFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	default:
+		return 0, fmt.Errorf("неверный параметр whence: %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("отрицательная позиция в файле: %d", offset)
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *snapshotFile) Close() error {
+	if f.reader == nil {
+		return nil
+	}
+	err := f.reader.Close()
+	f.reader = nil
+	return err
+}
+
+func (f *snapshotFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, fmt.Errorf("%s не является директорией", f.info.name)
+}
+
+func (f *snapshotFile) Stat() (os.FileInfo, error) { return f.info, nil }
+
+func (f *snapshotFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
@@ -0,0 +1,183 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	"backupist/pkg/types"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// snapshotServerShutdownTimeout сколько ждать завершения запросов при остановке сервера
+const snapshotServerShutdownTimeout = 10 * time.Second
+
+// ServeSnapshots запускает WebDAV-сервер только для чтения над бэкапами
+// всех политик и работает до отмены ctx
+//
+// Каждая политика доступна как директория с бэкапами, файлы
+// расшифровываются и распаковываются из архива при чтении. Сервер
+// можно подключить в файловом менеджере как сетевой диск или открыть
+// в браузере. Без сертификата TLS сервер слушает только loopback-адрес,
+// чтобы токен не передавался по сети открытым текстом.
+func (s *Service) ServeSnapshots(ctx context.Context, cfg types.SnapshotServerConfig) error {
+	if err := config.ValidateSnapshotServerConfig(&cfg); err != nil {
+		return fmt.Errorf("ошибка валидации параметров сервера: %w", err)
+	}
+
+	server := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           s.SnapshotHandler(cfg.Token),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if cfg.TLSCert != "" {
+			errCh <- server.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+			return
+		}
+		errCh <- server.ListenAndServe()
+	}()
+
+	s.logger.InfoContext(ctx, "Сервер просмотра бэкапов запущен",
+		"listen", cfg.Listen,
+		"tls", cfg.TLSCert != "")
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("ошибка запуска сервера: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), snapshotServerShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("ошибка остановки сервера: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "Сервер просмотра бэкапов остановлен")
+	return nil
+}
+
+// SnapshotHandler возвращает HTTP-обработчик WebDAV только для чтения
+// над бэкапами, доступный по токену token
+func (s *Service) SnapshotHandler(token string) http.Handler {
+	fs := newSnapshotFS(s)
+	dav := &webdav.Handler{
+		FileSystem: fs,
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				s.logger.WarnContext(r.Context(), "Ошибка запроса к серверу бэкапов",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"error", err)
+			}
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, token) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="backupist", charset="UTF-8"`)
+			http.Error(w, "требуется авторизация", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			// Браузеру вместо ошибки для директории отдаем ее листинг
+			if info, err := fs.Stat(r.Context(), r.URL.Path); err == nil && info.IsDir() {
+				s.serveDirListing(w, r, fs)
+				return
+			}
+		case http.MethodOptions, "PROPFIND":
+		default:
+			w.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND")
+			http.Error(w, "сервер доступен только для чтения", http.StatusMethodNotAllowed)
+			return
+		}
+
+		dav.ServeHTTP(w, r)
+	})
+}
+
+// authorized проверяет токен запроса: в заголовке Bearer или в пароле
+// Basic-авторизации (имя пользователя не проверяется)
+func authorized(r *http.Request, token string) bool {
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		_, provided, ok = r.BasicAuth()
+	}
+	return ok && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+// dirListingTemplate HTML-листинг директории для браузера
+var dirListingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Path}}</title></head>
+<body>
+<h1>{{.Path}}</h1>
+<table>
+{{if ne .Path "/"}}<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{end}}{{range .Entries}}<tr><td><a href="{{.Href}}">{{.Name}}</a></td><td align="right">{{.Size}}</td><td>{{.ModTime}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// serveDirListing отдает HTML-листинг директории
+func (s *Service) serveDirListing(w http.ResponseWriter, r *http.Request, fs *snapshotFS) {
+	node, err := fs.resolve(r.Context(), r.URL.Path)
+	if err != nil {
+		http.Error(w, "ошибка чтения директории", http.StatusInternalServerError)
+		return
+	}
+
+	// Относительные ссылки листинга работают только для пути со слешем в конце
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	type entry struct {
+		Name, Href, Size, ModTime string
+	}
+	data := struct {
+		Path    string
+		Entries []entry
+	}{Path: path.Clean(r.URL.Path)}
+
+	for _, child := range node.children {
+		e := entry{Name: child.Name(), Href: (&url.URL{Path: child.Name()}).String()}
+		if child.IsDir() {
+			e.Name += "/"
+			e.Href += "/"
+		} else {
+			e.Size = fmt.Sprint(child.Size())
+		}
+		if !child.ModTime().IsZero() {
+			e.ModTime = child.ModTime().Local().Format("2006-01-02 15:04")
+		}
+		data.Entries = append(data.Entries, e)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	if err := dirListingTemplate.Execute(w, data); err != nil {
+		s.logger.WarnContext(r.Context(), "Ошибка вывода листинга директории",
+			"path", r.URL.Path,
+			"error", err)
+	}
+}
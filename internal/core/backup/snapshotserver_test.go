@@ -0,0 +1,318 @@
+package backup
+
+import (
+	"backupist/pkg/types"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSnapshotToken = "0123456789abcdef"
+
+// newSnapshotServer запускает сервер просмотра бэкапов над зашифрованным
+// бэкапом политики и возвращает сервер, задачу бэкапа и содержимое файла
+// data/large.bin
+func newSnapshotServer(t *testing.T) (*httptest.Server, *types.BackupJob, []byte) {
+	t.Helper()
+
+	service := newTestService(t, nil)
+	policy := createTestPolicy(t, service, "docs", map[string]string{"notes.txt": "alpha"}, func(policy *types.BackupPolicy) {
+		policy.EncryptionEnabled = true
+		policy.EncryptionPassword = "secret"
+	})
+	large := randomData(t, 200<<10)
+	writeTestFile(t, filepath.Join(policy.SourcePath, "data", "large.bin"), string(large))
+
+	job := runTestBackup(t, service, policy.ID)
+	job, err := service.getBackupJob(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(service.SnapshotHandler(testSnapshotToken))
+	t.Cleanup(server.Close)
+
+	return server, job, large
+}
+
+// snapshotRequest выполняет запрос к серверу бэкапов с токеном Bearer
+func snapshotRequest(t *testing.T, method, url string, header http.Header) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = header.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	if req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+testSnapshotToken)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestSnapshotServerAuth(t *testing.T) {
+	server, _, _ := newSnapshotServer(t)
+
+	for name, auth := range map[string]func(*http.Request){
+		"без токена":            func(*http.Request) {},
+		"неверный Bearer":       func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong-token-0000000") },
+		"неверный пароль Basic": func(r *http.Request) { r.SetBasicAuth("user", "wrong-token-0000000") },
+		"токен как имя Basic":   func(r *http.Request) { r.SetBasicAuth(testSnapshotToken, "") },
+	} {
+		req, err := http.NewRequest("PROPFIND", server.URL+"/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth(req)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || !strings.HasPrefix(resp.Header.Get("WWW-Authenticate"), "Basic") {
+			t.Fatalf("%s: статус %d, WWW-Authenticate %q", name, resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+		}
+	}
+
+	// Токен в пароле Basic-авторизации при любом имени пользователя
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("anyone", testSnapshotToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Basic-авторизация с токеном: статус %d", resp.StatusCode)
+	}
+
+	if resp, _ := snapshotRequest(t, http.MethodGet, server.URL+"/", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Bearer-авторизация: статус %d", resp.StatusCode)
+	}
+}
+
+func TestSnapshotServerReadOnly(t *testing.T) {
+	server, job, large := newSnapshotServer(t)
+	file := server.URL + "/docs/" + job.StartedAt.Local().Format("2006-01-02_15-04-05") + "/data/large.bin"
+
+	for _, tt := range []struct {
+		method string
+		header http.Header
+	}{
+		{http.MethodPut, nil},
+		{http.MethodDelete, nil},
+		{"MKCOL", nil},
+		{"MOVE", http.Header{"Destination": {server.URL + "/docs/moved.bin"}}},
+		{"COPY", http.Header{"Destination": {server.URL + "/docs/copy.bin"}}},
+		{"LOCK", nil},
+		{"PROPPATCH", nil},
+	} {
+		resp, _ := snapshotRequest(t, tt.method, file, tt.header)
+		if resp.StatusCode != http.StatusMethodNotAllowed || !strings.Contains(resp.Header.Get("Allow"), "PROPFIND") {
+			t.Fatalf("%s: статус %d, Allow %q", tt.method, resp.StatusCode, resp.Header.Get("Allow"))
+		}
+	}
+
+	// Файл по-прежнему читается целиком
+	resp, body := snapshotRequest(t, http.MethodGet, file, nil)
+	if resp.StatusCode != http.StatusOK || body != string(large) {
+		t.Fatalf("чтение файла после запросов на запись: статус %d, %d байт", resp.StatusCode, len(body))
+	}
+}
+
+func TestSnapshotServerPropfind(t *testing.T) {
+	server, job, _ := newSnapshotServer(t)
+	snapshot := job.StartedAt.Local().Format("2006-01-02_15-04-05")
+
+	for _, tt := range []struct {
+		path string
+		want []string
+	}{
+		{"/", []string{"<D:href>/docs/</D:href>"}},
+		{"/docs/", []string{"<D:href>/docs/" + snapshot + "/</D:href>"}},
+		{"/docs/" + snapshot + "/", []string{
+			"<D:href>/docs/" + snapshot + "/notes.txt</D:href>",
+			"<D:href>/docs/" + snapshot + "/data/</D:href>",
+		}},
+		{"/docs/" + snapshot + "/data/", []string{
+			"<D:href>/docs/" + snapshot + "/data/large.bin</D:href>",
+			"<D:getcontentlength>204800</D:getcontentlength>",
+		}},
+	} {
+		resp, body := snapshotRequest(t, "PROPFIND", server.URL+tt.path, http.Header{"Depth": {"1"}})
+		if resp.StatusCode != http.StatusMultiStatus {
+			t.Fatalf("PROPFIND %s: статус %d", tt.path, resp.StatusCode)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(body, want) {
+				t.Fatalf("PROPFIND %s: нет %s в ответе:\n%s", tt.path, want, body)
+			}
+		}
+	}
+
+	if resp, _ := snapshotRequest(t, "PROPFIND", server.URL+"/docs/missing/", http.Header{"Depth": {"0"}}); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("PROPFIND несуществующего бэкапа: статус %d", resp.StatusCode)
+	}
+}
+
+func TestSnapshotServerRange(t *testing.T) {
+	server, job, large := newSnapshotServer(t)
+	file := server.URL + "/docs/" + job.StartedAt.Local().Format("2006-01-02_15-04-05") + "/data/large.bin"
+
+	for _, tt := range []struct {
+		rng        string
+		start, end int
+	}{
+		{"bytes=100000-100099", 100000, 100100},
+		{"bytes=0-9", 0, 10},
+		{"bytes=-16", len(large) - 16, len(large)},
+	} {
+		resp, body := snapshotRequest(t, http.MethodGet, file, http.Header{"Range": {tt.rng}})
+		if resp.StatusCode != http.StatusPartialContent || body != string(large[tt.start:tt.end]) {
+			t.Fatalf("Range %s: статус %d, %d байт", tt.rng, resp.StatusCode, len(body))
+		}
+	}
+}
+
+// writeTestCertificate создает самоподписанный сертификат для 127.0.0.1
+// и возвращает пути файлов сертификата и ключа и пул с сертификатом
+func writeTestCertificate(t *testing.T) (string, string, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "backupist"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "server.crt")
+	keyPath := filepath.Join(dir, "server.key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return certPath, keyPath, pool
+}
+
+func TestServeSnapshotsTLS(t *testing.T) {
+	service := newTestService(t, nil)
+	certPath, keyPath, pool := writeTestCertificate(t)
+
+	// Без TLS сервер не слушает сетевой адрес, сертификат задается с ключом
+	for name, cfg := range map[string]types.SnapshotServerConfig{
+		"все интерфейсы без TLS": {Listen: ":0", Token: testSnapshotToken},
+		"сетевой адрес без TLS":  {Listen: "192.0.2.1:8090", Token: testSnapshotToken},
+		"сертификат без ключа":   {Listen: "0.0.0.0:0", Token: testSnapshotToken, TLSCert: certPath},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := service.ServeSnapshots(ctx, cfg)
+		cancel()
+		if err == nil || !strings.Contains(err.Error(), "tls_cert") {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- service.ServeSnapshots(ctx, types.SnapshotServerConfig{Listen: addr, Token: testSnapshotToken, TLSCert: certPath, TLSKey: keyPath})
+	}()
+	defer func() {
+		cancel()
+		if err := <-errCh; err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	req, err := http.NewRequest(http.MethodGet, "https://"+addr+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testSnapshotToken)
+
+	// Сервер запускается в отдельной горутине
+	var resp *http.Response
+	for range 50 {
+		if resp, err = client.Do(req); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.TLS == nil || !bytes.Contains(body, []byte("<h1>/</h1>")) {
+		t.Fatalf("листинг по HTTPS: статус %d, TLS %v", resp.StatusCode, resp.TLS != nil)
+	}
+}
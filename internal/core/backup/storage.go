@@ -1,12 +1,25 @@
 package backup
 
 import (
+	"backupist/internal/core/config"
+	storageapi "backupist/pkg/storage"
+	"backupist/pkg/types"
+	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/minio/minio-go/v7"
@@ -15,39 +28,60 @@ import (
 	"google.golang.org/api/option"
 )
 
-// initStorage инициализирует провайдер хранилища на основе конфигурации
+// initStorage инициализирует фабрику хранилищ и провайдер хранилища по умолчанию
 func (s *Service) initStorage() error {
-	switch s.config.Storage.Type {
-	case "local":
-		s.storage = NewLocalStorage(s.config.Storage.LocalPath)
-	case "s3":
-		client, err := NewS3Storage(
-			s.config.Storage.S3Endpoint,
-			s.config.Storage.S3AccessKey,
-			s.config.Storage.S3SecretKey,
-			s.config.Storage.S3BucketName,
-			s.config.Storage.S3UseSSL,
-		)
-		if err != nil {
-			return fmt.Errorf("ошибка инициализации S3 хранилища: %w", err)
-		}
-		s.storage = client
-	case "gcs":
-		client, err := NewGCSStorage(
-			s.config.Storage.GCSBucketName,
-			s.config.Storage.GCSCredentialsPath,
-		)
-		if err != nil {
-			return fmt.Errorf("ошибка инициализации GCS хранилища: %w", err)
+	s.factory = NewStorageFactory(s.config)
+	s.factory.OnResync(s.recordResync)
+	s.factory.OnRetry(s.logRetry)
+	s.factory.setUploadSessions(s)
+
+	bandwidth, err := NewBandwidthLimiter(s.config.Storage.Bandwidth)
+	if err != nil {
+		return err
+	}
+	s.factory.SetBandwidth(bandwidth)
+
+	// Явный URL задает хранилище любого типа, в том числе стороннего
+	storageURL := s.config.Storage.URL
+
+	if storageURL == "" {
+		switch s.config.Storage.Type {
+		case "local":
+			s.storage = s.factory.limit(NewLocalStorage(s.config.Storage.LocalPath))
+			return nil
+		case "s3":
+			storageURL = "s3://" + s.config.Storage.S3BucketName
+		case "gcs":
+			storageURL = "gcs://" + s.config.Storage.GCSBucketName
+		case "sftp":
+			storageURL = s.config.Storage.SFTPURL
+		case "webdav":
+			storageURL = s.config.Storage.WebDAVURL
+		case "azure":
+			storageURL = "azure://" + s.config.Storage.AzureContainer
+		default:
+			return fmt.Errorf("для хранилища типа %s необходимо указать storage.url", s.config.Storage.Type)
 		}
-		s.storage = client
-	default:
-		return fmt.Errorf("неподдерживаемый тип хранилища: %s", s.config.Storage.Type)
 	}
 
+	storage, err := s.factory.CreateStorage(storageURL)
+	if err != nil {
+		return fmt.Errorf("ошибка инициализации %s хранилища: %w", strings.ToUpper(s.config.Storage.Type), err)
+	}
+	s.storage = storage
+
 	return nil
 }
 
+// logRetry записывает в лог повтор операции с хранилищем
+func (s *Service) logRetry(op string, attempt int, delay time.Duration, err error) {
+	s.logger.Warn("Временная ошибка хранилища, операция будет повторена",
+		"operation", op,
+		"attempt", attempt,
+		"delay", delay.String(),
+		"error", err.Error())
+}
+
 // LocalStorage реализация локального хранилища
 type LocalStorage struct {
 	basePath string
@@ -84,7 +118,7 @@ func (ls *LocalStorage) Upload(ctx context.Context, localPath, remotePath string
 	defer dst.Close()
 
 	// Копируем данные
-	_, err = io.Copy(dst, src)
+	_, err = io.Copy(dst, throttleReader(ctx, src, directionUpload))
 	if err != nil {
 		return fmt.Errorf("ошибка копирования файла: %w", err)
 	}
@@ -116,7 +150,7 @@ func (ls *LocalStorage) Download(ctx context.Context, remotePath, localPath stri
 	defer dst.Close()
 
 	// Копируем данные
-	_, err = io.Copy(dst, src)
+	_, err = io.Copy(dst, throttleReader(ctx, src, directionDownload))
 	if err != nil {
 		return fmt.Errorf("ошибка копирования файла: %w", err)
 	}
@@ -124,6 +158,16 @@ func (ls *LocalStorage) Download(ctx context.Context, remotePath, localPath stri
 	return nil
 }
 
+// Open открывает файл локального хранилища для чтения потоком
+func (ls *LocalStorage) Open(ctx context.Context, remotePath string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(ls.basePath, remotePath))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия файла в хранилище: %w", err)
+	}
+
+	return &readCloser{Reader: throttleReader(ctx, file, directionDownload), Closer: file}, nil
+}
+
 // Delete удаляет файл из локального хранилища
 func (ls *LocalStorage) Delete(ctx context.Context, remotePath string) error {
 	fullPath := filepath.Join(ls.basePath, remotePath)
@@ -167,10 +211,31 @@ func (ls *LocalStorage) Exists(ctx context.Context, remotePath string) (bool, er
 	return true, nil
 }
 
+// Verify повторно хэширует записанный файл и сравнивает его с локальным
+func (ls *LocalStorage) Verify(ctx context.Context, localPath, remotePath string) (string, error) {
+	local, err := hashFile(localPath, sha256.New(), 0, -1)
+	if err != nil {
+		return "", err
+	}
+
+	remote, err := hashFile(filepath.Join(ls.basePath, remotePath), sha256.New(), 0, -1)
+	if err != nil {
+		return "", fmt.Errorf("ошибка чтения записанного файла: %w", err)
+	}
+
+	if !bytes.Equal(local, remote) {
+		return "", checksumMismatch(remotePath, "sha256", local, remote)
+	}
+
+	return "sha256:" + hex.EncodeToString(remote), nil
+}
+
 // S3Storage реализация S3-совместимого хранилища
 type S3Storage struct {
 	client     *minio.Client
 	bucketName string
+	sessions   uploadSessionStore
+	sweeper    sessionSweeper
 }
 
 // NewS3Storage создает новый экземпляр S3 хранилища
@@ -189,10 +254,59 @@ func NewS3Storage(endpoint, accessKey, secretKey, bucketName string, useSSL bool
 	}, nil
 }
 
+// NewS3StorageFromConfig создает S3 хранилище из проверенной конфигурации
+//
+// Если адрес сервиса не указан, используется Amazon S3.
+func NewS3StorageFromConfig(cfg *types.S3Config) (*S3Storage, error) {
+	if err := config.ValidateS3Config(cfg); err != nil {
+		return nil, fmt.Errorf("ошибка валидации конфигурации S3: %w", err)
+	}
+
+	endpoint, useSSL := cfg.Endpoint, cfg.UseSSL
+	if endpoint == "" {
+		endpoint, useSSL = "s3.amazonaws.com", true
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken),
+		Secure: useSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания MinIO клиента: %w", err)
+	}
+
+	return &S3Storage{
+		client:     client,
+		bucketName: cfg.Bucket,
+	}, nil
+}
+
 // Upload загружает файл в S3
+//
+// Файлы больше части загружаются по частям с сохранением сессии,
+// чтобы прерванная загрузка продолжилась с последней записанной части.
 func (s3 *S3Storage) Upload(ctx context.Context, localPath, remotePath string) error {
-	_, err := s3.client.FPutObject(ctx, s3.bucketName, remotePath, localPath, minio.PutObjectOptions{
+	if s3.sessions != nil {
+		if info, err := os.Stat(localPath); err == nil && info.Size() > s3PartSize {
+			return s3.uploadMultipart(ctx, localPath, info.Size(), remotePath)
+		}
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия локального файла: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("ошибка получения информации о файле: %w", err)
+	}
+
+	_, err = s3.client.PutObject(ctx, s3.bucketName, remotePath, throttleReader(ctx, file, directionUpload), info.Size(), minio.PutObjectOptions{
 		ContentType: "application/octet-stream",
+		NumThreads:  uint(maxParallelParts(ctx, s3ParallelParts)),
 	})
 	if err != nil {
 		return fmt.Errorf("ошибка загрузки файла в S3: %w", err)
@@ -208,7 +322,33 @@ func (s3 *S3Storage) Download(ctx context.Context, remotePath, localPath string)
 		return fmt.Errorf("ошибка создания локальной директории: %w", err)
 	}
 
-	return s3.client.FGetObject(ctx, s3.bucketName, remotePath, localPath, minio.GetObjectOptions{})
+	object, err := s3.client.GetObject(ctx, s3.bucketName, remotePath, minio.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("ошибка открытия объекта S3: %w", err)
+	}
+	defer object.Close()
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("ошибка создания локального файла: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, throttleReader(ctx, object, directionDownload)); err != nil {
+		return fmt.Errorf("ошибка скачивания файла из S3: %w", err)
+	}
+
+	return nil
+}
+
+// Open открывает объект S3 для чтения потоком
+func (s3 *S3Storage) Open(ctx context.Context, remotePath string) (io.ReadCloser, error) {
+	object, err := s3.client.GetObject(ctx, s3.bucketName, remotePath, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия объекта S3: %w", err)
+	}
+
+	return &readCloser{Reader: throttleReader(ctx, object, directionDownload), Closer: object}, nil
 }
 
 // Delete удаляет файл из S3
@@ -246,10 +386,134 @@ func (s3 *S3Storage) Exists(ctx context.Context, remotePath string) (bool, error
 	return true, nil
 }
 
+// Verify сравнивает контрольную сумму объекта S3 с локальным файлом
+//
+// Если сервис хранит контрольную сумму всего объекта (x-amz-checksum-sha256
+// или x-amz-checksum-crc32c), сравнивается она. Иначе сравнивается ETag:
+// для объектов, загруженных по частям, он равен MD5 от MD5 частей
+// с суффиксом -N; размер части определяется по первой части объекта.
+// При шифровании SSE-KMS и SSE-C ETag не связан с содержимым, такие
+// объекты не проверяются.
+func (s3 *S3Storage) Verify(ctx context.Context, localPath, remotePath string) (string, error) {
+	info, err := s3.client.StatObject(ctx, s3.bucketName, remotePath, minio.StatObjectOptions{Checksum: true})
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения информации об объекте S3: %w", err)
+	}
+
+	if _, err := verifySize(localPath, remotePath, info.Size); err != nil {
+		return "", err
+	}
+
+	if checksum, ok, err := verifyS3Checksum(localPath, remotePath, info); ok || err != nil {
+		return checksum, err
+	}
+
+	if sse := info.Metadata.Get("X-Amz-Server-Side-Encryption"); sse == "aws:kms" || sse == "aws:kms:dsse" ||
+		info.Metadata.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "" {
+		return "", fmt.Errorf("%w: ETag объекта с шифрованием на стороне сервиса", storageapi.ErrVerifyUnsupported)
+	}
+
+	etag := strings.Trim(info.ETag, `"`)
+	digest, count, multipart := strings.Cut(etag, "-")
+	remote, err := hex.DecodeString(digest)
+	if err != nil || len(remote) != md5.Size {
+		return "", fmt.Errorf("%w: нестандартный ETag %s", storageapi.ErrVerifyUnsupported, etag)
+	}
+
+	var local []byte
+	if !multipart {
+		if local, err = hashFile(localPath, md5.New(), 0, -1); err != nil {
+			return "", err
+		}
+	} else if local, err = s3.multipartETag(ctx, localPath, remotePath, info.Size, count); err != nil {
+		return "", err
+	}
+
+	if !bytes.Equal(local, remote) {
+		return "", checksumMismatch(remotePath, "etag", local, remote)
+	}
+
+	return "etag:" + etag, nil
+}
+
+// verifyS3Checksum сравнивает контрольную сумму всего объекта из заголовков x-amz-checksum-*
+//
+// Возвращает ok = false, если сервис не хранит такую сумму или хранит
+// только составную сумму по частям.
+func verifyS3Checksum(localPath, remotePath string, info minio.ObjectInfo) (string, bool, error) {
+	if info.ChecksumMode != "" && info.ChecksumMode != "FULL_OBJECT" {
+		return "", false, nil
+	}
+
+	candidates := []struct {
+		algorithm string
+		value     string
+		hash      func() hash.Hash
+	}{
+		{"sha256", info.ChecksumSHA256, sha256.New},
+		{"crc32c", info.ChecksumCRC32C, func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) }},
+	}
+
+	for _, c := range candidates {
+		if c.value == "" || strings.Contains(c.value, "-") {
+			continue
+		}
+
+		remote, err := base64.StdEncoding.DecodeString(c.value)
+		if err != nil {
+			continue
+		}
+
+		local, err := hashFile(localPath, c.hash(), 0, -1)
+		if err != nil {
+			return "", true, err
+		}
+		if !bytes.Equal(local, remote) {
+			return "", true, checksumMismatch(remotePath, c.algorithm, local, remote)
+		}
+
+		return c.algorithm + ":" + hex.EncodeToString(remote), true, nil
+	}
+
+	return "", false, nil
+}
+
+// multipartETag вычисляет ETag локального файла для объекта, загруженного по частям
+func (s3 *S3Storage) multipartETag(ctx context.Context, localPath, remotePath string, size int64, count string) ([]byte, error) {
+	partCount, err := strconv.Atoi(count)
+	if err != nil || partCount < 1 {
+		return nil, fmt.Errorf("%w: некорректное количество частей в ETag: %s", storageapi.ErrVerifyUnsupported, count)
+	}
+
+	first, err := s3.client.StatObject(ctx, s3.bucketName, remotePath, minio.StatObjectOptions{PartNumber: 1})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения информации о части объекта S3: %w", err)
+	}
+
+	partSize := first.Size
+	if partSize <= 0 || (size+partSize-1)/partSize != int64(partCount) {
+		return nil, fmt.Errorf("%w: части объекта разного размера", storageapi.ErrVerifyUnsupported)
+	}
+
+	combined := md5.New()
+	for offset := int64(0); offset < size; offset += partSize {
+		sum, err := hashFile(localPath, md5.New(), offset, min(partSize, size-offset))
+		if err != nil {
+			return nil, err
+		}
+		combined.Write(sum)
+	}
+
+	return combined.Sum(nil), nil
+}
+
 // GCSStorage реализация Google Cloud Storage
 type GCSStorage struct {
 	client     *storage.Client
 	bucketName string
+	options    []option.ClientOption
+	sessions   uploadSessionStore
+	sweeper    sessionSweeper
 }
 
 // NewGCSStorage создает новый экземпляр GCS хранилища
@@ -259,8 +523,11 @@ func NewGCSStorage(bucketName, credentialsPath string) (*GCSStorage, error) {
 	var client *storage.Client
 	var err error
 
+	var opts []option.ClientOption
+
 	if credentialsPath != "" {
-		client, err = storage.NewClient(ctx, option.WithCredentialsFile(credentialsPath))
+		opts = append(opts, option.WithCredentialsFile(credentialsPath))
+		client, err = storage.NewClient(ctx, opts...)
 	} else {
 		// Используем Application Default Credentials
 		client, err = storage.NewClient(ctx)
@@ -273,10 +540,37 @@ func NewGCSStorage(bucketName, credentialsPath string) (*GCSStorage, error) {
 	return &GCSStorage{
 		client:     client,
 		bucketName: bucketName,
+		options:    opts,
+	}, nil
+}
+
+// NewGCSStorageFromConfig создает GCS хранилище из проверенной конфигурации
+func NewGCSStorageFromConfig(cfg *types.GCSConfig) (*GCSStorage, error) {
+	if err := config.ValidateGCSConfig(cfg); err != nil {
+		return nil, fmt.Errorf("ошибка валидации конфигурации GCS: %w", err)
+	}
+
+	opts := []option.ClientOption{option.WithCredentialsFile(cfg.CredentialsPath)}
+	if cfg.ServiceAccountJSON != "" {
+		opts = []option.ClientOption{option.WithCredentialsJSON([]byte(cfg.ServiceAccountJSON))}
+	}
+
+	client, err := storage.NewClient(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания GCS клиента: %w", err)
+	}
+
+	return &GCSStorage{
+		client:     client,
+		bucketName: cfg.Bucket,
+		options:    opts,
 	}, nil
 }
 
 // Upload загружает файл в GCS
+//
+// Файлы больше части загружаются через возобновляемую сессию,
+// которая сохраняется между попытками и перезапусками процесса.
 func (gcs *GCSStorage) Upload(ctx context.Context, localPath, remotePath string) error {
 	// Открываем локальный файл
 	file, err := os.Open(localPath)
@@ -285,14 +579,24 @@ func (gcs *GCSStorage) Upload(ctx context.Context, localPath, remotePath string)
 	}
 	defer file.Close()
 
+	if gcs.sessions != nil {
+		if info, err := file.Stat(); err == nil && info.Size() > gcsChunkSize {
+			return gcs.uploadResumable(ctx, file, info.Size(), remotePath)
+		}
+	}
+
 	// Создаем writer для GCS объекта
 	obj := gcs.client.Bucket(gcs.bucketName).Object(remotePath)
 	writer := obj.NewWriter(ctx)
-	defer writer.Close()
 
 	// Копируем данные
-	_, err = io.Copy(writer, file)
-	if err != nil {
+	if _, err = io.Copy(writer, throttleReader(ctx, file, directionUpload)); err != nil {
+		writer.Close()
+		return fmt.Errorf("ошибка загрузки файла в GCS: %w", err)
+	}
+
+	// Ошибка записи объекта возвращается только при закрытии writer
+	if err := writer.Close(); err != nil {
 		return fmt.Errorf("ошибка загрузки файла в GCS: %w", err)
 	}
 
@@ -322,7 +626,7 @@ func (gcs *GCSStorage) Download(ctx context.Context, remotePath, localPath strin
 	defer file.Close()
 
 	// Копируем данные
-	_, err = io.Copy(file, reader)
+	_, err = io.Copy(file, throttleReader(ctx, reader, directionDownload))
 	if err != nil {
 		return fmt.Errorf("ошибка скачивания файла из GCS: %w", err)
 	}
@@ -330,6 +634,16 @@ func (gcs *GCSStorage) Download(ctx context.Context, remotePath, localPath strin
 	return nil
 }
 
+// Open открывает объект GCS для чтения потоком
+func (gcs *GCSStorage) Open(ctx context.Context, remotePath string) (io.ReadCloser, error) {
+	reader, err := gcs.client.Bucket(gcs.bucketName).Object(remotePath).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия GCS объекта: %w", err)
+	}
+
+	return &readCloser{Reader: throttleReader(ctx, reader, directionDownload), Closer: reader}, nil
+}
+
 // Delete удаляет файл из GCS
 func (gcs *GCSStorage) Delete(ctx context.Context, remotePath string) error {
 	obj := gcs.client.Bucket(gcs.bucketName).Object(remotePath)
@@ -358,107 +672,48 @@ func (gcs *GCSStorage) List(ctx context.Context, prefix string) ([]string, error
 	return objects, nil
 }
 
-func (gcs *GCSStorage) Exists(ctx context.Context, remotePath string) (bool, error) {
-	obj := gcs.client.Bucket(gcs.bucketName).Object(remotePath)
-	_, err := obj.Attrs(ctx)
+// Verify сравнивает CRC32C и, если сервис его хранит, MD5 объекта с локальным файлом
+//
+// У составных объектов GCS нет MD5, для них проверяется только CRC32C.
+func (gcs *GCSStorage) Verify(ctx context.Context, localPath, remotePath string) (string, error) {
+	attrs, err := gcs.client.Bucket(gcs.bucketName).Object(remotePath).Attrs(ctx)
 	if err != nil {
-		if err == storage.ErrObjectNotExist {
-			return false, nil
-		}
-		return false, fmt.Errorf("ошибка проверки существования объекта в GCS: %w", err)
+		return "", fmt.Errorf("ошибка получения информации об объекте GCS: %w", err)
 	}
-	return true, nil
-}
 
-// StorageFactory фабрика для создания провайдеров хранилища
-type StorageFactory struct{}
-
-// CreateStorage создает провайдер хранилища на основе URL
-func (sf *StorageFactory) CreateStorage(storageURL string) (StorageProvider, error) {
-	if strings.HasPrefix(storageURL, "s3://") {
-		// Парсим S3 URL: s3://bucket/path
-		parts := strings.SplitN(strings.TrimPrefix(storageURL, "s3://"), "/", 2)
-		if len(parts) < 1 {
-			return nil, fmt.Errorf("неверный формат S3 URL: %s", storageURL)
-		}
-
-		// Эти параметры должны браться из конфигурации
-		return NewS3Storage("s3.amazonaws.com", "", "", parts[0], true)
+	local, err := hashFile(localPath, crc32.New(crc32.MakeTable(crc32.Castagnoli)), 0, -1)
+	if err != nil {
+		return "", err
 	}
 
-	if strings.HasPrefix(storageURL, "gcs://") {
-		// Парсим GCS URL: gcs://bucket/path
-		parts := strings.SplitN(strings.TrimPrefix(storageURL, "gcs://"), "/", 2)
-		if len(parts) < 1 {
-			return nil, fmt.Errorf("неверный формат GCS URL: %s", storageURL)
-		}
-
-		return NewGCSStorage(parts[0], "")
+	remote := binary.BigEndian.AppendUint32(nil, attrs.CRC32C)
+	if !bytes.Equal(local, remote) {
+		return "", checksumMismatch(remotePath, "crc32c", local, remote)
 	}
 
-	// Для локального пути
-	return NewLocalStorage(storageURL), nil
-}
-
-// MultiStorage позволяет работать с несколькими хранилищами одновременно
-type MultiStorage struct {
-	storages []StorageProvider
-}
-
-// NewMultiStorage создает новый мульти-хранилище
-func NewMultiStorage(storages ...StorageProvider) *MultiStorage {
-	return &MultiStorage{
-		storages: storages,
+	if len(attrs.MD5) == 0 {
+		return "crc32c:" + hex.EncodeToString(remote), nil
 	}
-}
 
-// Upload загружает файл во все хранилища
-func (ms *MultiStorage) Upload(ctx context.Context, localPath, remotePath string) error {
-	for i, storage := range ms.storages {
-		if err := storage.Upload(ctx, localPath, remotePath); err != nil {
-			return fmt.Errorf("ошибка загрузки в хранилище %d: %w", i, err)
-		}
+	localMD5, err := hashFile(localPath, md5.New(), 0, -1)
+	if err != nil {
+		return "", err
 	}
-	return nil
-}
-
-// Download скачивает файл из первого доступного хранилища
-func (ms *MultiStorage) Download(ctx context.Context, remotePath, localPath string) error {
-	var lastErr error
-
-	for i, storage := range ms.storages {
-		if err := storage.Download(ctx, remotePath, localPath); err == nil {
-			return nil
-		} else {
-			lastErr = fmt.Errorf("ошибка скачивания из хранилища %d: %w", i, err)
-		}
+	if !bytes.Equal(localMD5, attrs.MD5) {
+		return "", checksumMismatch(remotePath, "md5", localMD5, attrs.MD5)
 	}
 
-	return lastErr
+	return "md5:" + hex.EncodeToString(attrs.MD5), nil
 }
 
-// Delete удаляет файл из всех хранилищ
-func (ms *MultiStorage) Delete(ctx context.Context, remotePath string) error {
-	var errors []error
-
-	for i, storage := range ms.storages {
-		if err := storage.Delete(ctx, remotePath); err != nil {
-			errors = append(errors, fmt.Errorf("ошибка удаления из хранилища %d: %w", i, err))
+func (gcs *GCSStorage) Exists(ctx context.Context, remotePath string) (bool, error) {
+	obj := gcs.client.Bucket(gcs.bucketName).Object(remotePath)
+	_, err := obj.Attrs(ctx)
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return false, nil
 		}
+		return false, fmt.Errorf("ошибка проверки существования объекта в GCS: %w", err)
 	}
-
-	if len(errors) > 0 {
-		return fmt.Errorf("ошибки при удалении: %v", errors)
-	}
-
-	return nil
-}
-
-// List возвращает список файлов из первого хранилища
-func (ms *MultiStorage) List(ctx context.Context, prefix string) ([]string, error) {
-	if len(ms.storages) == 0 {
-		return nil, fmt.Errorf("нет доступных хранилищ")
-	}
-
-	return ms.storages[0].List(ctx, prefix)
+	return true, nil
 }
@@ -0,0 +1,71 @@
+package backup
+
+import (
+	storageapi "backupist/pkg/storage"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// verifyUpload проверяет записанный объект, если хранилище это поддерживает
+func verifyUpload(ctx context.Context, storage StorageProvider, localPath, remotePath string) (string, error) {
+	verifier, ok := storage.(storageapi.Verifier)
+	if !ok {
+		return "", storageapi.ErrVerifyUnsupported
+	}
+
+	return verifier.Verify(ctx, localPath, remotePath)
+}
+
+// hashFile вычисляет хэш участка файла; length < 0 - до конца файла
+func hashFile(localPath string, h hash.Hash, offset, length int64) ([]byte, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия файла: %w", err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if offset > 0 || length >= 0 {
+		if length < 0 {
+			info, err := file.Stat()
+			if err != nil {
+				return nil, fmt.Errorf("ошибка получения информации о файле: %w", err)
+			}
+			length = info.Size() - offset
+		}
+		reader = io.NewSectionReader(file, offset, length)
+	}
+
+	if _, err := io.Copy(h, reader); err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла: %w", err)
+	}
+
+	return h.Sum(nil), nil
+}
+
+// checksumMismatch формирует ошибку расхождения контрольных сумм
+func checksumMismatch(remotePath, algorithm string, local, remote []byte) error {
+	return fmt.Errorf("%w: %s: %s %s, в хранилище %s", storageapi.ErrChecksumMismatch,
+		remotePath, algorithm, hex.EncodeToString(local), hex.EncodeToString(remote))
+}
+
+// verifySize сравнивает размер объекта в хранилище с размером локального файла
+//
+// Используется хранилищами, которые не сообщают контрольную сумму объекта.
+func verifySize(localPath, remotePath string, remoteSize int64) (string, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения информации о файле: %w", err)
+	}
+
+	if info.Size() != remoteSize {
+		return "", fmt.Errorf("%w: %s: размер %d, в хранилище %d", storageapi.ErrChecksumMismatch,
+			remotePath, info.Size(), remoteSize)
+	}
+
+	return fmt.Sprintf("size:%d", remoteSize), nil
+}
@@ -0,0 +1,505 @@
+package backup
+
+import (
+	storageapi "backupist/pkg/storage"
+	"backupist/pkg/types"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// volumeManifestSuffix суффикс манифеста бэкапа, разбитого на тома
+	volumeManifestSuffix = ".manifest.json"
+
+	// volumeManifestVersion версия формата манифеста томов
+	volumeManifestVersion = 1
+)
+
+// volumePath возвращает путь тома в хранилище; тома лежат рядом с манифестом
+func volumePath(manifestPath string, volume types.BackupVolume) string {
+	return path.Join(path.Dir(manifestPath), volume.Path)
+}
+
+// backupName возвращает имя бэкапа по его пути в хранилище; под этим
+// именем в архиве лежит директория с файлами бэкапа
+func backupName(remotePath string) string {
+	return strings.TrimSuffix(path.Base(remotePath), volumeManifestSuffix)
+}
+
+// uploadVolumes архивирует директорию бэкапа, шифрует поток и режет его на тома
+//
+// Тома загружаются в хранилище по мере заполнения, поэтому на диске
+// одновременно лежит не больше одного тома. Манифест загружается
+// последним: без него бэкап не считается записанным, а при ошибке
+// уже загруженные тома удаляются.
+func (s *Service) uploadVolumes(ctx context.Context, policy *types.BackupPolicy, storage StorageProvider, backupPath, remotePath string) (*types.VolumeManifest, error) {
+	vw := &volumeWriter{
+		ctx:        ctx,
+		service:    s,
+		storage:    storage,
+		localDir:   filepath.Dir(backupPath),
+		remotePath: remotePath,
+		size:       policy.VolumeSize,
+		parity:     policy.Parity,
+	}
+
+	checksum := sha256.New()
+	out := io.MultiWriter(checksum, vw)
+
+	var err error
+	if policy.EncryptionEnabled {
+		err = s.writeEncryptedArchive(ctx, backupPath, out, policy.EncryptionPassword)
+	} else {
+		err = s.writeArchive(ctx, backupPath, "", out)
+	}
+	if err == nil {
+		err = vw.Close()
+	}
+	if err != nil {
+		vw.abort()
+		return nil, err
+	}
+
+	manifest := &types.VolumeManifest{
+		Version:    volumeManifestVersion,
+		Size:       vw.total,
+		Checksum:   hex.EncodeToString(checksum.Sum(nil)),
+		VolumeSize: policy.VolumeSize,
+		Compressed: policy.ArchiveEnabled,
+		Encrypted:  policy.EncryptionEnabled,
+		Volumes:    vw.volumes,
+		CreatedAt:  time.Now(),
+	}
+
+	manifestPath := remotePath + volumeManifestSuffix
+	if err := s.writeVolumeManifest(ctx, storage, manifest, filepath.Join(vw.localDir, path.Base(manifestPath)), manifestPath); err != nil {
+		vw.abort()
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// writeEncryptedArchive записывает в поток зашифрованный tar.gz архив директории
+func (s *Service) writeEncryptedArchive(ctx context.Context, sourcePath string, w io.Writer, password string) error {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		err := s.writeArchive(ctx, sourcePath, "", pw)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	err := s.encryptStream(ctx, pr, w, password)
+	// Если шифрование прервалось, архивация получит ошибку записи и завершится
+	pr.CloseWithError(err)
+
+	if archiveErr := <-done; err == nil && archiveErr != nil {
+		err = archiveErr
+	}
+
+	return err
+}
+
+// writeVolumeManifest записывает манифест томов и загружает его в хранилище
+func (s *Service) writeVolumeManifest(ctx context.Context, storage StorageProvider, manifest *types.VolumeManifest, localPath, remotePath string) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации манифеста томов: %w", err)
+	}
+
+	if err := os.WriteFile(localPath, data, 0644); err != nil {
+		return fmt.Errorf("ошибка записи манифеста томов: %w", err)
+	}
+	defer os.Remove(localPath)
+
+	if err := storage.Upload(ctx, localPath, remotePath); err != nil {
+		return fmt.Errorf("ошибка загрузки манифеста томов: %w", err)
+	}
+
+	return nil
+}
+
+// readVolumeManifest скачивает и разбирает манифест томов
+func (s *Service) readVolumeManifest(ctx context.Context, storage StorageProvider, manifestPath string) (*types.VolumeManifest, error) {
+	tempFile, err := os.CreateTemp("", "backupist-manifest-*")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания временного файла: %w", err)
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	if err := storage.Download(ctx, manifestPath, tempFile.Name()); err != nil {
+		return nil, fmt.Errorf("ошибка скачивания манифеста томов: %w", err)
+	}
+
+	data, err := os.ReadFile(tempFile.Name())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения манифеста томов: %w", err)
+	}
+
+	manifest := &types.VolumeManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("ошибка разбора манифеста томов: %w", err)
+	}
+	if manifest.Version != volumeManifestVersion {
+		return nil, fmt.Errorf("неподдерживаемая версия манифеста томов: %d", manifest.Version)
+	}
+
+	return manifest, nil
+}
+
+// backupObjects возвращает пути всех объектов бэкапа в хранилище
+//
+// Файлы четности и тома идут первыми, сам бэкап или манифест томов -
+// последним, чтобы при удалении по списку прерванная операция оставляла
+// манифест и ее можно было повторить.
+func (s *Service) backupObjects(ctx context.Context, storage StorageProvider, remotePath string) ([]string, error) {
+	var objects []string
+
+	if strings.HasSuffix(remotePath, volumeManifestSuffix) {
+		manifest, err := s.readVolumeManifest(ctx, storage, remotePath)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, volume := range manifest.Volumes {
+			objects = append(objects, volumePath(remotePath, volume))
+			if volume.Parity {
+				objects = append(objects, volumePath(remotePath, volume)+paritySuffix)
+			}
+		}
+	} else if exists, err := storage.Exists(ctx, remotePath+paritySuffix); err != nil {
+		return nil, fmt.Errorf("ошибка проверки файла четности: %w", err)
+	} else if exists {
+		objects = append(objects, remotePath+paritySuffix)
+	}
+
+	return append(objects, remotePath), nil
+}
+
+// openVolumes открывает бэкап, разбитый на тома, для чтения потоком:
+// тома скачиваются в tempDir по одному по мере чтения
+func (s *Service) openVolumes(ctx context.Context, storage StorageProvider, manifestPath, tempDir string) (*volumeReader, error) {
+	manifest, err := s.readVolumeManifest(ctx, storage, manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &volumeReader{
+		ctx:          ctx,
+		service:      s,
+		storage:      storage,
+		manifestPath: manifestPath,
+		manifest:     manifest,
+		localDir:     tempDir,
+		checksum:     sha256.New(),
+	}, nil
+}
+
+// volumeWriter режет поток бэкапа на тома фиксированного размера; каждый
+// заполненный том загружается в хранилище и удаляется с диска
+type volumeWriter struct {
+	ctx        context.Context
+	service    *Service
+	storage    StorageProvider
+	localDir   string
+	remotePath string
+	size       int64
+	parity     *types.ParityConfig
+
+	file     *os.File
+	hash     hash.Hash
+	written  int64 // Записано в текущий том
+	total    int64
+	volumes  []types.BackupVolume
+	uploaded []string
+}
+
+// Write записывает данные, начиная новый том при заполнении текущего
+func (vw *volumeWriter) Write(p []byte) (int, error) {
+	var written int
+
+	for len(p) > 0 {
+		if vw.file == nil {
+			if err := vw.openVolume(); err != nil {
+				return written, err
+			}
+		}
+
+		chunk := p[:min(int64(len(p)), vw.size-vw.written)]
+		n, err := vw.file.Write(chunk)
+		vw.hash.Write(chunk[:n])
+		vw.written += int64(n)
+		vw.total += int64(n)
+		written += n
+		if err != nil {
+			return written, fmt.Errorf("ошибка записи тома: %w", err)
+		}
+		p = p[n:]
+
+		if vw.written == vw.size {
+			if err := vw.closeVolume(); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// Close загружает последний неполный том
+func (vw *volumeWriter) Close() error {
+	if vw.file == nil {
+		return nil
+	}
+	return vw.closeVolume()
+}
+
+// volumeName возвращает имя тома с номером number
+func (vw *volumeWriter) volumeName(number int) string {
+	return fmt.Sprintf("%s.%03d", path.Base(vw.remotePath), number)
+}
+
+// openVolume создает локальный файл следующего тома
+func (vw *volumeWriter) openVolume() error {
+	name := vw.volumeName(len(vw.volumes) + 1)
+
+	file, err := os.Create(filepath.Join(vw.localDir, name))
+	if err != nil {
+		return fmt.Errorf("ошибка создания тома: %w", err)
+	}
+
+	vw.file = file
+	vw.hash = sha256.New()
+	vw.written = 0
+
+	return nil
+}
+
+// closeVolume загружает заполненный том и файл четности к нему
+func (vw *volumeWriter) closeVolume() error {
+	localPath := vw.file.Name()
+	defer os.Remove(localPath)
+
+	if err := vw.file.Close(); err != nil {
+		vw.file = nil
+		return fmt.Errorf("ошибка записи тома: %w", err)
+	}
+	vw.file = nil
+
+	volume := types.BackupVolume{
+		Number:   len(vw.volumes) + 1,
+		Path:     filepath.Base(localPath),
+		Size:     vw.written,
+		Checksum: hex.EncodeToString(vw.hash.Sum(nil)),
+	}
+	remotePath := path.Join(path.Dir(vw.remotePath), volume.Path)
+
+	if err := vw.storage.Upload(vw.ctx, localPath, remotePath); err != nil {
+		vw.service.discardPartial(vw.ctx, vw.storage, remotePath)
+		return fmt.Errorf("ошибка загрузки тома %d: %w", volume.Number, err)
+	}
+	vw.uploaded = append(vw.uploaded, remotePath)
+
+	// Проверка записанного тома по контрольной сумме хранилища
+	if _, err := verifyUpload(vw.ctx, vw.storage, localPath, remotePath); err != nil && !errors.Is(err, storageapi.ErrVerifyUnsupported) {
+		return fmt.Errorf("ошибка проверки записанного тома %d: %w", volume.Number, err)
+	}
+
+	if vw.parity != nil {
+		parityPath := localPath + paritySuffix
+		defer os.Remove(parityPath)
+
+		if err := createParity(vw.ctx, localPath, parityPath, *vw.parity); err != nil {
+			return fmt.Errorf("ошибка создания данных четности тома %d: %w", volume.Number, err)
+		}
+		if err := vw.storage.Upload(vw.ctx, parityPath, remotePath+paritySuffix); err != nil {
+			vw.service.discardPartial(vw.ctx, vw.storage, remotePath+paritySuffix)
+			return fmt.Errorf("ошибка загрузки данных четности тома %d: %w", volume.Number, err)
+		}
+		vw.uploaded = append(vw.uploaded, remotePath+paritySuffix)
+		volume.Parity = true
+	}
+
+	vw.volumes = append(vw.volumes, volume)
+
+	vw.service.logger.InfoContext(vw.ctx, "Том бэкапа загружен",
+		"volume", volume.Number,
+		"remote_path", remotePath,
+		"size", volume.Size)
+
+	return nil
+}
+
+// abort удаляет загруженные тома после ошибки
+func (vw *volumeWriter) abort() {
+	if vw.file != nil {
+		vw.file.Close()
+		os.Remove(vw.file.Name())
+		vw.file = nil
+	}
+
+	ctx := context.WithoutCancel(vw.ctx)
+	for _, remotePath := range vw.uploaded {
+		if err := vw.storage.Delete(ctx, remotePath); err != nil {
+			vw.service.logger.WarnContext(ctx, "Ошибка удаления тома незавершенного бэкапа",
+				"remote_path", remotePath,
+				"error", err.Error())
+		}
+	}
+}
+
+// volumeReader читает тома бэкапа по порядку
+//
+// Следующий том скачивается, когда прочитан предыдущий, и до чтения
+// сверяется с контрольной суммой из манифеста. В конце потока
+// проверяется контрольная сумма всего бэкапа.
+type volumeReader struct {
+	ctx          context.Context
+	service      *Service
+	storage      StorageProvider
+	manifestPath string
+	manifest     *types.VolumeManifest
+	localDir     string
+	checksum     hash.Hash
+
+	// inspect вызывается для каждого скачанного тома до его чтения;
+	// intact - контрольная сумма тома совпала с манифестом
+	inspect func(volume types.BackupVolume, localPath string, intact bool) error
+
+	next int
+	file *os.File
+}
+
+// Read читает данные текущего тома, переходя к следующему в конце тома
+func (vr *volumeReader) Read(p []byte) (int, error) {
+	for {
+		if vr.file == nil {
+			if vr.next == len(vr.manifest.Volumes) {
+				if checksum := hex.EncodeToString(vr.checksum.Sum(nil)); checksum != vr.manifest.Checksum {
+					return 0, fmt.Errorf("контрольная сумма бэкапа не совпадает: %s, в манифесте %s", checksum, vr.manifest.Checksum)
+				}
+				return 0, io.EOF
+			}
+
+			if err := vr.openVolume(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := vr.file.Read(p)
+		vr.checksum.Write(p[:n])
+
+		if err == io.EOF {
+			vr.closeVolume()
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("ошибка чтения тома: %w", err)
+		}
+
+		return n, nil
+	}
+}
+
+// openVolume скачивает следующий том и проверяет его контрольную сумму
+func (vr *volumeReader) openVolume() error {
+	volume := vr.manifest.Volumes[vr.next]
+	vr.next++
+
+	localPath, checksum, err := vr.fetchVolume(volume)
+	if err != nil {
+		return err
+	}
+
+	if vr.inspect != nil {
+		if err := vr.inspect(volume, localPath, checksum == volume.Checksum); err != nil {
+			os.Remove(localPath)
+			return err
+		}
+	}
+	if checksum != volume.Checksum {
+		os.Remove(localPath)
+		return fmt.Errorf("том %d поврежден: SHA-256 %s, в манифесте %s", volume.Number, checksum, volume.Checksum)
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		os.Remove(localPath)
+		return fmt.Errorf("ошибка открытия тома %d: %w", volume.Number, err)
+	}
+	vr.file = file
+
+	return nil
+}
+
+// inspectRemaining скачивает непрочитанные тома и передает их inspect,
+// не читая данных; так проверка находит все поврежденные тома, а не
+// только первый
+func (vr *volumeReader) inspectRemaining() error {
+	for vr.next < len(vr.manifest.Volumes) {
+		volume := vr.manifest.Volumes[vr.next]
+		vr.next++
+
+		localPath, checksum, err := vr.fetchVolume(volume)
+		if err != nil {
+			return err
+		}
+		err = vr.inspect(volume, localPath, checksum == volume.Checksum)
+		os.Remove(localPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetchVolume скачивает том в локальную директорию и вычисляет его SHA-256
+func (vr *volumeReader) fetchVolume(volume types.BackupVolume) (string, string, error) {
+	localPath := filepath.Join(vr.localDir, volume.Path)
+
+	if err := vr.storage.Download(vr.ctx, volumePath(vr.manifestPath, volume), localPath); err != nil {
+		os.Remove(localPath)
+		return "", "", fmt.Errorf("ошибка скачивания тома %d: %w", volume.Number, err)
+	}
+
+	checksum, err := vr.service.calculateChecksum(localPath)
+	if err != nil {
+		os.Remove(localPath)
+		return "", "", fmt.Errorf("ошибка вычисления контрольной суммы тома %d: %w", volume.Number, err)
+	}
+
+	return localPath, checksum, nil
+}
+
+// closeVolume закрывает и удаляет прочитанный том
+func (vr *volumeReader) closeVolume() {
+	vr.file.Close()
+	os.Remove(vr.file.Name())
+	vr.file = nil
+}
+
+// Close удаляет недочитанный том
+func (vr *volumeReader) Close() error {
+	if vr.file != nil {
+		vr.closeVolume()
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	"backupist/pkg/types"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestVolumeWriter режет поток на тома size байт в локальное хранилище root
+func newTestVolumeWriter(t *testing.T, service *Service, root string, size int64) *volumeWriter {
+	t.Helper()
+
+	return &volumeWriter{
+		ctx:        context.Background(),
+		service:    service,
+		storage:    NewLocalStorage(root),
+		localDir:   t.TempDir(),
+		remotePath: "daily/backup",
+		size:       size,
+	}
+}
+
+// randomData возвращает size случайных байт, которые не сжимаются
+func randomData(t *testing.T, size int) []byte {
+	t.Helper()
+
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVolumeWriterBoundaries(t *testing.T) {
+	service := newTestService(t, nil)
+
+	for name, tt := range map[string]struct {
+		size  int
+		sizes []int64
+	}{
+		"ровно три тома":   {300, []int64{100, 100, 100}},
+		"неполный том":     {301, []int64{100, 100, 100, 1}},
+		"меньше тома":      {99, []int64{99}},
+		"пустой поток":     {0, nil},
+		"граница в записи": {250, []int64{100, 100, 50}},
+	} {
+		root := t.TempDir()
+		vw := newTestVolumeWriter(t, service, root, 100)
+		data := randomData(t, tt.size)
+
+		// Запись кусками, которые не совпадают с границами томов
+		for chunk := range slices.Chunk(data, 70) {
+			if n, err := vw.Write(chunk); err != nil || n != len(chunk) {
+				t.Fatalf("%s: Write = %d, %v", name, n, err)
+			}
+		}
+		if err := vw.Close(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		var sizes []int64
+		var joined []byte
+		for i, volume := range vw.volumes {
+			sizes = append(sizes, volume.Size)
+
+			stored, err := os.ReadFile(filepath.Join(root, "daily", volume.Path))
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			sum := sha256.Sum256(stored)
+			if volume.Number != i+1 || volume.Path != vw.volumeName(i+1) || volume.Checksum != hex.EncodeToString(sum[:]) {
+				t.Fatalf("%s: том %+v", name, volume)
+			}
+			joined = append(joined, stored...)
+		}
+		if !slices.Equal(sizes, tt.sizes) || vw.total != int64(tt.size) || !bytes.Equal(joined, data) {
+			t.Fatalf("%s: размеры томов %v, всего %d байт", name, sizes, vw.total)
+		}
+
+		// Локальные файлы томов удаляются после загрузки
+		if entries, _ := os.ReadDir(vw.localDir); len(entries) != 0 {
+			t.Fatalf("%s: на диске остались тома: %v", name, entries)
+		}
+	}
+}
+
+// createVolumePolicy создает политику, бэкап которой занимает три тома
+// минимального размера
+func createVolumePolicy(t *testing.T, service *Service, name string, configure func(*types.BackupPolicy)) *types.BackupPolicy {
+	t.Helper()
+
+	policy := createTestPolicy(t, service, name, map[string]string{"small.txt": "alpha"}, func(policy *types.BackupPolicy) {
+		policy.VolumeSize = config.MinVolumeSize
+		if configure != nil {
+			configure(policy)
+		}
+	})
+	data := randomData(t, 5*config.MinVolumeSize/2)
+	if err := os.WriteFile(filepath.Join(policy.SourcePath, "large.bin"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+// readVolumes возвращает манифест бэкапа задачи и содержимое его томов подряд
+func readVolumes(t *testing.T, service *Service, job *types.BackupJob) (*types.VolumeManifest, []byte) {
+	t.Helper()
+
+	storage := NewLocalStorage(service.config.Storage.LocalPath)
+	manifest, err := service.readVolumeManifest(context.Background(), storage, job.BackupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var joined []byte
+	for _, volume := range manifest.Volumes {
+		data, err := os.ReadFile(filepath.Join(service.config.Storage.LocalPath, volumePath(job.BackupPath, volume)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		joined = append(joined, data...)
+	}
+	return manifest, joined
+}
+
+func TestVolumeBackupManifest(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createVolumePolicy(t, service, "volumes", func(policy *types.BackupPolicy) {
+		policy.EncryptionEnabled = true
+		policy.EncryptionPassword = "secret"
+	})
+
+	job := runTestBackup(t, service, policy.ID)
+	job, err := service.getBackupJob(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(job.BackupPath, volumeManifestSuffix) {
+		t.Fatalf("путь бэкапа на томах: %s", job.BackupPath)
+	}
+
+	manifest, joined := readVolumes(t, service, job)
+	sum := sha256.Sum256(joined)
+	if manifest.Size != int64(len(joined)) || manifest.Checksum != hex.EncodeToString(sum[:]) {
+		t.Fatalf("манифест: размер %d, SHA-256 %s; тома: %d байт, %x", manifest.Size, manifest.Checksum, len(joined), sum)
+	}
+	if len(manifest.Volumes) != 3 || !manifest.Encrypted || manifest.VolumeSize != config.MinVolumeSize {
+		t.Fatalf("манифест: %+v", manifest)
+	}
+	for _, volume := range manifest.Volumes[:len(manifest.Volumes)-1] {
+		if volume.Size != config.MinVolumeSize {
+			t.Fatalf("том %d размером %d, ожидался полный том", volume.Number, volume.Size)
+		}
+	}
+}
+
+func TestVolumeStreamingRestore(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createVolumePolicy(t, service, "restore", func(policy *types.BackupPolicy) {
+		policy.EncryptionEnabled = true
+		policy.EncryptionPassword = "secret"
+	})
+	job := runTestBackup(t, service, policy.ID)
+	job, err := service.getBackupJob(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Поток томов совпадает с томами по порядку, и тома не копятся на диске
+	_, joined := readVolumes(t, service, job)
+	tempDir := t.TempDir()
+	reader, err := service.openVolumes(context.Background(), NewLocalStorage(service.config.Storage.LocalPath), job.BackupPath, tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamed, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || !bytes.Equal(streamed, joined) {
+		t.Fatalf("чтение томов потоком: %d байт из %d, %v", len(streamed), len(joined), err)
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+		t.Fatalf("прочитанные тома остались на диске: %v", entries)
+	}
+
+	target := t.TempDir()
+	result, err := service.Restore(context.Background(), RestoreOptions{PolicyID: policy.ID, Target: target})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"small.txt", "large.bin"} {
+		want, _ := os.ReadFile(filepath.Join(policy.SourcePath, name))
+		got, err := os.ReadFile(filepath.Join(target, name))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("восстановленный %s: %d байт из %d, %v", name, len(got), len(want), err)
+		}
+	}
+	if result.Files != 2 {
+		t.Fatalf("восстановлено файлов: %d", result.Files)
+	}
+}
+
+func TestVolumeDamageDetected(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createVolumePolicy(t, service, "damaged", nil)
+	job := runTestBackup(t, service, policy.ID)
+	job, err := service.getBackupJob(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, _ := readVolumes(t, service, job)
+	second := filepath.Join(service.config.Storage.LocalPath, volumePath(job.BackupPath, manifest.Volumes[1]))
+	original, err := os.ReadFile(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readAll := func() error {
+		reader, err := service.openVolumes(context.Background(), NewLocalStorage(service.config.Storage.LocalPath), job.BackupPath, t.TempDir())
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		_, err = io.Copy(io.Discard, reader)
+		return err
+	}
+
+	damaged := bytes.Clone(original)
+	damaged[len(damaged)/2] ^= 0xff
+	if err := os.WriteFile(second, damaged, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := readAll(); err == nil || !strings.Contains(err.Error(), "том 2 поврежден") {
+		t.Fatalf("чтение с поврежденным томом: %v", err)
+	}
+	if _, err := service.Restore(context.Background(), RestoreOptions{PolicyID: policy.ID, Target: t.TempDir()}); err == nil {
+		t.Fatal("восстановление с поврежденным томом прошло без ошибки")
+	}
+
+	if err := os.Remove(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := readAll(); err == nil || !strings.Contains(err.Error(), "ошибка скачивания тома 2") {
+		t.Fatalf("чтение без тома: %v", err)
+	}
+}
+
+func TestVolumeBackupRetention(t *testing.T) {
+	service := newTestService(t, nil)
+	policy := createVolumePolicy(t, service, "retention", func(policy *types.BackupPolicy) {
+		policy.RetentionCount = 1
+		policy.Parity = &types.ParityConfig{Percent: 10}
+	})
+	ctx := context.Background()
+
+	first := runTestBackup(t, service, policy.ID)
+	first, err := service.getBackupJob(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Тома с файлами четности перечисляются первыми, манифест - последним
+	storage := NewLocalStorage(service.config.Storage.LocalPath)
+	objects, err := service.backupObjects(ctx, storage, first.BackupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := listStorage(t, service.config.Storage.LocalPath)
+	if len(objects) != 7 || objects[len(objects)-1] != first.BackupPath || !slices.Equal(sorted(objects), stored) {
+		t.Fatalf("объекты бэкапа %v, в хранилище %v", objects, stored)
+	}
+
+	// Очистка перед записью третьего бэкапа оставляет один предыдущий;
+	// имя бэкапа задается с точностью до секунды
+	for range 2 {
+		time.Sleep(1100 * time.Millisecond)
+		runTestBackup(t, service, policy.ID)
+	}
+
+	// От первого бэкапа в хранилище не осталось ни тома, ни манифеста
+	deleted, err := service.getBackupJob(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.Status != "deleted" {
+		t.Fatalf("статус первого бэкапа после очистки: %s", deleted.Status)
+	}
+	remaining := listStorage(t, service.config.Storage.LocalPath)
+	for _, object := range remaining {
+		if strings.HasPrefix(path.Base(object), backupName(first.BackupPath)) {
+			t.Fatalf("после очистки остался объект первого бэкапа %s", object)
+		}
+	}
+	if len(remaining) != 2*len(objects) {
+		t.Fatalf("в хранилище после очистки: %v", remaining)
+	}
+}
+
+// listStorage возвращает отсортированные пути файлов локального хранилища
+func listStorage(t *testing.T, root string) []string {
+	t.Helper()
+
+	var files []string
+	err := filepath.WalkDir(root, func(file string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, file)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// sorted возвращает отсортированную копию списка
+func sorted(items []string) []string {
+	items = slices.Clone(items)
+	slices.Sort(items)
+	return items
+}
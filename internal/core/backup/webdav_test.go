@@ -0,0 +1,145 @@
+package backup
+
+import (
+	"backupist/internal/core/config"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+// newTestWebDAVServer WebDAV сервер над временной директорией; принимает
+// пользователя alice с паролем secret и bearer токен token
+func newTestWebDAVServer(t *testing.T) (root string, server *httptest.Server) {
+	t.Helper()
+
+	root = t.TempDir()
+	handler := &webdav.Handler{
+		FileSystem: webdav.Dir(root),
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, basic := r.BasicAuth()
+		switch {
+		case basic && user == "alice" && password == "secret":
+		case r.Header.Get("Authorization") == "Bearer token":
+		default:
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return root, server
+}
+
+// newTestWebDAVFactory фабрика хранилищ без повторов операций и с общим
+// bearer токеном WebDAV
+func newTestWebDAVFactory(t *testing.T) *StorageFactory {
+	t.Helper()
+
+	cfg := config.NewConfig()
+	cfg.Storage.Retry.MaxAttempts = 1
+	cfg.Storage.WebDAVBearerToken = "token"
+
+	factory := NewStorageFactory(cfg)
+	t.Cleanup(func() { factory.Close() })
+	return factory
+}
+
+func TestWebDAVStorage(t *testing.T) {
+	root, server := newTestWebDAVServer(t)
+	host := strings.TrimPrefix(server.URL, "http://")
+	ctx := context.Background()
+
+	local := filepath.Join(t.TempDir(), "backup.tar")
+	writeTestFile(t, local, "backup data")
+
+	// Пароль в URL и общий bearer токен в конфигурации не конфликтуют
+	for _, storageURL := range []string{
+		"webdav://alice:secret@" + host + "/basic",
+		"webdav://" + host + "/bearer",
+	} {
+		storage, err := newTestWebDAVFactory(t).CreateStorage(storageURL)
+		if err != nil {
+			t.Fatalf("%s: %v", storageURL, err)
+		}
+
+		if err := storage.Upload(ctx, local, "daily/2026/backup.tar"); err != nil {
+			t.Fatalf("%s: %v", storageURL, err)
+		}
+		exists, err := storage.Exists(ctx, "daily/2026/backup.tar")
+		if err != nil || !exists {
+			t.Fatalf("%s: Exists загруженного файла = %v, %v", storageURL, exists, err)
+		}
+		if exists, err := storage.Exists(ctx, "daily/missing.tar"); err != nil || exists {
+			t.Fatalf("%s: Exists отсутствующего файла = %v, %v", storageURL, exists, err)
+		}
+
+		downloaded := filepath.Join(t.TempDir(), "restored.tar")
+		if err := storage.Download(ctx, "daily/2026/backup.tar", downloaded); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile(downloaded); string(data) != "backup data" {
+			t.Fatalf("%s: скачанный файл %q", storageURL, data)
+		}
+	}
+
+	if files := remoteFiles(t, root); !slices.Equal(files, []string{"basic/daily/2026/backup.tar", "bearer/daily/2026/backup.tar"}) {
+		t.Fatalf("файлы на сервере: %v", files)
+	}
+}
+
+func TestWebDAVListAndDelete(t *testing.T) {
+	root, server := newTestWebDAVServer(t)
+	storage, err := newTestWebDAVFactory(t).CreateStorage("webdav://alice:secret@" + strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	writeTestFile(t, filepath.Join(root, "a", "one.tar"), "1")
+	writeTestFile(t, filepath.Join(root, "a", "nested", "two.tar"), "2")
+	writeTestFile(t, filepath.Join(root, "b", "three.tar"), "3")
+
+	files, err := storage.List(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(files)
+	if !slices.Equal(files, []string{"a/nested/two.tar", "a/one.tar"}) {
+		t.Fatalf("List(a) = %v", files)
+	}
+
+	if err := storage.Delete(ctx, "a/one.tar"); err != nil {
+		t.Fatal(err)
+	}
+	if files := remoteFiles(t, root); !slices.Equal(files, []string{"a/nested/two.tar", "b/three.tar"}) {
+		t.Fatalf("файлы на сервере после удаления: %v", files)
+	}
+}
+
+func TestWebDAVUnauthorized(t *testing.T) {
+	_, server := newTestWebDAVServer(t)
+	storage, err := newTestWebDAVFactory(t).CreateStorage("webdav://alice:wrong@" + strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ошибка доступа не выдается за отсутствие файла
+	_, err = storage.Exists(context.Background(), "backup.tar")
+	var statusErr *webDAVStatusError
+	if !errors.As(err, &statusErr) || statusErr.status != http.StatusUnauthorized {
+		t.Fatalf("Exists с неверным паролем: %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
@@ -27,20 +28,58 @@ type Config struct {
 		Type      string `mapstructure:"type" yaml:"type"`
 		LocalPath string `mapstructure:"local_path" yaml:"local_path"`
 
+		// URL хранилища по умолчанию; обязателен для сторонних хранилищ и плагинов
+		URL string `mapstructure:"url" yaml:"url"`
+
 		// S3 конфигурация
 		S3Endpoint   string `mapstructure:"s3_endpoint" yaml:"s3_endpoint"`
 		S3AccessKey  string `mapstructure:"s3_access_key" yaml:"s3_access_key"`
 		S3SecretKey  string `mapstructure:"s3_secret_key" yaml:"s3_secret_key"`
 		S3BucketName string `mapstructure:"s3_bucket_name" yaml:"s3_bucket_name"`
 		S3UseSSL     bool   `mapstructure:"s3_use_ssl" yaml:"s3_use_ssl"`
+		S3Region     string `mapstructure:"s3_region" yaml:"s3_region"`
 
 		// GCS конфигурация
 		GCSBucketName      string `mapstructure:"gcs_bucket_name" yaml:"gcs_bucket_name"`
 		GCSCredentialsPath string `mapstructure:"gcs_credentials_path" yaml:"gcs_credentials_path"`
 
+		// SFTP конфигурация (адрес в формате sftp://user@host:port/path)
+		SFTPURL            string `mapstructure:"sftp_url" yaml:"sftp_url"`
+		SFTPKeyPath        string `mapstructure:"sftp_key_path" yaml:"sftp_key_path"`
+		SFTPKeyPassphrase  string `mapstructure:"sftp_key_passphrase" yaml:"sftp_key_passphrase"`
+		SFTPUseAgent       bool   `mapstructure:"sftp_use_agent" yaml:"sftp_use_agent"`
+		SFTPKnownHostsPath string `mapstructure:"sftp_known_hosts_path" yaml:"sftp_known_hosts_path"`
+
+		// WebDAV конфигурация (адрес в формате webdav://host/path или webdavs://host/path)
+		WebDAVURL            string `mapstructure:"webdav_url" yaml:"webdav_url"`
+		WebDAVUsername       string `mapstructure:"webdav_username" yaml:"webdav_username"`
+		WebDAVPassword       string `mapstructure:"webdav_password" yaml:"webdav_password"`
+		WebDAVBearerToken    string `mapstructure:"webdav_bearer_token" yaml:"webdav_bearer_token"`
+		WebDAVChunkSize      int64  `mapstructure:"webdav_chunk_size" yaml:"webdav_chunk_size"`
+		WebDAVChunkUploadURL string `mapstructure:"webdav_chunk_upload_url" yaml:"webdav_chunk_upload_url"`
+
+		// Azure Blob Storage конфигурация
+		AzureAccountName string `mapstructure:"azure_account_name" yaml:"azure_account_name"`
+		AzureAccountKey  string `mapstructure:"azure_account_key" yaml:"azure_account_key"`
+		AzureSASToken    string `mapstructure:"azure_sas_token" yaml:"azure_sas_token"`
+		AzureContainer   string `mapstructure:"azure_container" yaml:"azure_container"`
+		AzureEndpoint    string `mapstructure:"azure_endpoint" yaml:"azure_endpoint"`
+		AzureAccessTier  string `mapstructure:"azure_access_tier" yaml:"azure_access_tier"`
+		AzureBlockSize   int64  `mapstructure:"azure_block_size" yaml:"azure_block_size"`
+		AzureConcurrency int    `mapstructure:"azure_concurrency" yaml:"azure_concurrency"`
+
 		// Дополнительные поля
 		Default string                         `mapstructure:"default" yaml:"default"`
 		Configs map[string]types.StorageConfig `mapstructure:"configs" yaml:"configs"`
+
+		// Внешние плагины хранилищ по схеме URL
+		Plugins map[string]types.StoragePluginConfig `mapstructure:"plugins" yaml:"plugins"`
+
+		// Повтор операций с удаленными хранилищами при временных ошибках
+		Retry types.RetryConfig `mapstructure:"retry" yaml:"retry"`
+
+		// Общие ограничения скорости для всех передач; не действуют на плагины
+		Bandwidth types.BandwidthConfig `mapstructure:"bandwidth" yaml:"bandwidth"`
 	} `mapstructure:"storage" yaml:"storage"`
 
 	Encryption struct {
@@ -56,6 +95,21 @@ type Config struct {
 		DefaultAlgorithm string `mapstructure:"default_algorithm" yaml:"default_algorithm"`
 		Level            int    `mapstructure:"level" yaml:"level"`
 	} `mapstructure:"compression" yaml:"compression"`
+
+	// Регулярная проверка сохраненных бэкапов (команда daemon)
+	Verify types.VerifyConfig `mapstructure:"verify" yaml:"verify"`
+
+	// Команды проверки восстановления, на которые могут ссылаться политики
+	RestoreTest types.RestoreTestCommandsConfig `mapstructure:"restore_test" yaml:"restore_test"`
+
+	// Уведомления о событиях, например о результатах проверки восстановления
+	Notifications types.NotificationConfig `mapstructure:"notifications" yaml:"notifications"`
+
+	// WebDAV-сервер для просмотра бэкапов (команда serve-snapshots)
+	SnapshotServer types.SnapshotServerConfig `mapstructure:"snapshot_server" yaml:"snapshot_server"`
+
+	// REST API сервер (cmd/server)
+	API types.APIServerConfig `mapstructure:"api" yaml:"api"`
 }
 
 // NewConfig создает новую конфигурацию с значениями по умолчанию
@@ -76,17 +130,41 @@ func NewConfig() *Config {
 			File:   "",
 		},
 		Storage: struct {
-			Type               string                         `mapstructure:"type" yaml:"type"`
-			LocalPath          string                         `mapstructure:"local_path" yaml:"local_path"`
-			S3Endpoint         string                         `mapstructure:"s3_endpoint" yaml:"s3_endpoint"`
-			S3AccessKey        string                         `mapstructure:"s3_access_key" yaml:"s3_access_key"`
-			S3SecretKey        string                         `mapstructure:"s3_secret_key" yaml:"s3_secret_key"`
-			S3BucketName       string                         `mapstructure:"s3_bucket_name" yaml:"s3_bucket_name"`
-			S3UseSSL           bool                           `mapstructure:"s3_use_ssl" yaml:"s3_use_ssl"`
-			GCSBucketName      string                         `mapstructure:"gcs_bucket_name" yaml:"gcs_bucket_name"`
-			GCSCredentialsPath string                         `mapstructure:"gcs_credentials_path" yaml:"gcs_credentials_path"`
-			Default            string                         `mapstructure:"default" yaml:"default"`
-			Configs            map[string]types.StorageConfig `mapstructure:"configs" yaml:"configs"`
+			Type                 string                               `mapstructure:"type" yaml:"type"`
+			LocalPath            string                               `mapstructure:"local_path" yaml:"local_path"`
+			URL                  string                               `mapstructure:"url" yaml:"url"`
+			S3Endpoint           string                               `mapstructure:"s3_endpoint" yaml:"s3_endpoint"`
+			S3AccessKey          string                               `mapstructure:"s3_access_key" yaml:"s3_access_key"`
+			S3SecretKey          string                               `mapstructure:"s3_secret_key" yaml:"s3_secret_key"`
+			S3BucketName         string                               `mapstructure:"s3_bucket_name" yaml:"s3_bucket_name"`
+			S3UseSSL             bool                                 `mapstructure:"s3_use_ssl" yaml:"s3_use_ssl"`
+			S3Region             string                               `mapstructure:"s3_region" yaml:"s3_region"`
+			GCSBucketName        string                               `mapstructure:"gcs_bucket_name" yaml:"gcs_bucket_name"`
+			GCSCredentialsPath   string                               `mapstructure:"gcs_credentials_path" yaml:"gcs_credentials_path"`
+			SFTPURL              string                               `mapstructure:"sftp_url" yaml:"sftp_url"`
+			SFTPKeyPath          string                               `mapstructure:"sftp_key_path" yaml:"sftp_key_path"`
+			SFTPKeyPassphrase    string                               `mapstructure:"sftp_key_passphrase" yaml:"sftp_key_passphrase"`
+			SFTPUseAgent         bool                                 `mapstructure:"sftp_use_agent" yaml:"sftp_use_agent"`
+			SFTPKnownHostsPath   string                               `mapstructure:"sftp_known_hosts_path" yaml:"sftp_known_hosts_path"`
+			WebDAVURL            string                               `mapstructure:"webdav_url" yaml:"webdav_url"`
+			WebDAVUsername       string                               `mapstructure:"webdav_username" yaml:"webdav_username"`
+			WebDAVPassword       string                               `mapstructure:"webdav_password" yaml:"webdav_password"`
+			WebDAVBearerToken    string                               `mapstructure:"webdav_bearer_token" yaml:"webdav_bearer_token"`
+			WebDAVChunkSize      int64                                `mapstructure:"webdav_chunk_size" yaml:"webdav_chunk_size"`
+			WebDAVChunkUploadURL string                               `mapstructure:"webdav_chunk_upload_url" yaml:"webdav_chunk_upload_url"`
+			AzureAccountName     string                               `mapstructure:"azure_account_name" yaml:"azure_account_name"`
+			AzureAccountKey      string                               `mapstructure:"azure_account_key" yaml:"azure_account_key"`
+			AzureSASToken        string                               `mapstructure:"azure_sas_token" yaml:"azure_sas_token"`
+			AzureContainer       string                               `mapstructure:"azure_container" yaml:"azure_container"`
+			AzureEndpoint        string                               `mapstructure:"azure_endpoint" yaml:"azure_endpoint"`
+			AzureAccessTier      string                               `mapstructure:"azure_access_tier" yaml:"azure_access_tier"`
+			AzureBlockSize       int64                                `mapstructure:"azure_block_size" yaml:"azure_block_size"`
+			AzureConcurrency     int                                  `mapstructure:"azure_concurrency" yaml:"azure_concurrency"`
+			Default              string                               `mapstructure:"default" yaml:"default"`
+			Configs              map[string]types.StorageConfig       `mapstructure:"configs" yaml:"configs"`
+			Plugins              map[string]types.StoragePluginConfig `mapstructure:"plugins" yaml:"plugins"`
+			Retry                types.RetryConfig                    `mapstructure:"retry" yaml:"retry"`
+			Bandwidth            types.BandwidthConfig                `mapstructure:"bandwidth" yaml:"bandwidth"`
 		}{
 			Type:      "local",
 			LocalPath: getDefaultBackupPath(),
@@ -98,6 +176,12 @@ func NewConfig() *Config {
 					LocalPath: getDefaultBackupPath(),
 				},
 			},
+			Retry: types.RetryConfig{
+				MaxAttempts:  5,
+				InitialDelay: time.Second,
+				MaxDelay:     time.Minute,
+				Multiplier:   2,
+			},
 		},
 		Encryption: struct {
 			DefaultAlgorithm string `mapstructure:"default_algorithm" yaml:"default_algorithm"`
@@ -125,6 +209,12 @@ func NewConfig() *Config {
 			DefaultAlgorithm: "gzip",
 			Level:            6,
 		},
+		SnapshotServer: types.SnapshotServerConfig{
+			Listen: "127.0.0.1:8090",
+		},
+		API: types.APIServerConfig{
+			Listen: "127.0.0.1:8080",
+		},
 	}
 }
 
@@ -1,11 +1,15 @@
 package config
 
 import (
+	"backupist/pkg/storage"
 	"backupist/pkg/types"
 	"fmt"
+	"net"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/adhocore/gronx"
 	"github.com/go-playground/validator"
@@ -27,6 +31,28 @@ func ValidateBackupPolicy(policy *types.BackupPolicy) error {
 	if err := validate.Struct(policy); err != nil {
 		return formatValidationError(err)
 	}
+	if policy.Bandwidth != nil {
+		if err := ValidateBandwidthConfig(policy.Bandwidth); err != nil {
+			return err
+		}
+	}
+	if policy.VolumeSize < 0 {
+		return fmt.Errorf("размер тома не может быть отрицательным")
+	}
+	if policy.VolumeSize > 0 {
+		if !policy.ArchiveEnabled {
+			return fmt.Errorf("разбиение на тома поддерживается только для архивированных бэкапов")
+		}
+		if policy.VolumeSize < MinVolumeSize {
+			return fmt.Errorf("размер тома должен быть не меньше %d байт", MinVolumeSize)
+		}
+	}
+	switch policy.Overlap {
+	case "", types.OverlapSkip, types.OverlapQueue, types.OverlapCancelPrevious:
+	default:
+		return fmt.Errorf("неизвестный режим перекрытия запусков %q: допустимы %s, %s, %s",
+			policy.Overlap, types.OverlapSkip, types.OverlapQueue, types.OverlapCancelPrevious)
+	}
 	return nil
 }
 
@@ -59,14 +85,17 @@ func validateDirectory(fl validator.FieldLevel) bool {
 // validateStorageType проверяет тип хранилища
 func validateStorageType(fl validator.FieldLevel) bool {
 	storageType := fl.Field().String()
-	validTypes := []string{"local", "s3", "gcs"}
+	validTypes := []string{"local", "s3", "gcs", "sftp", "webdav", "azure", "multi"}
 
 	for _, validType := range validTypes {
 		if storageType == validType {
 			return true
 		}
 	}
-	return false
+
+	// Сторонние хранилища, зарегистрированные через pkg/storage
+	_, ok := storage.Lookup(storageType)
+	return ok
 }
 
 // formatValidationError форматирует ошибки валидации в понятный вид
@@ -167,6 +196,383 @@ func ValidateGCSConfig(config *types.GCSConfig) error {
 	return nil
 }
 
+// ValidateSFTPConfig валидирует конфигурацию SFTP
+func ValidateSFTPConfig(config *types.SFTPConfig) error {
+	if config == nil {
+		return fmt.Errorf("конфигурация SFTP не может быть пустой")
+	}
+
+	if config.Host == "" {
+		return fmt.Errorf("хост SFTP обязателен")
+	}
+
+	if config.User == "" {
+		return fmt.Errorf("имя пользователя SFTP обязательно")
+	}
+
+	if config.Port < 0 || config.Port > 65535 {
+		return fmt.Errorf("некорректный порт SFTP: %d", config.Port)
+	}
+
+	if config.PrivateKeyPath == "" && !config.UseAgent {
+		return fmt.Errorf("необходимо указать приватный ключ или разрешить использование SSH агента")
+	}
+
+	if config.PrivateKeyPath != "" {
+		if _, err := os.Stat(config.PrivateKeyPath); os.IsNotExist(err) {
+			return fmt.Errorf("файл приватного ключа не найден: %s", config.PrivateKeyPath)
+		}
+	}
+
+	return nil
+}
+
+// ValidateWebDAVConfig валидирует конфигурацию WebDAV
+func ValidateWebDAVConfig(config *types.WebDAVConfig) error {
+	if config == nil {
+		return fmt.Errorf("конфигурация WebDAV не может быть пустой")
+	}
+
+	if config.URL == "" {
+		return fmt.Errorf("адрес WebDAV сервера обязателен")
+	}
+
+	if !strings.HasPrefix(config.URL, "http://") && !strings.HasPrefix(config.URL, "https://") {
+		return fmt.Errorf("адрес WebDAV сервера должен начинаться с http:// или https://")
+	}
+
+	if config.Password != "" && config.BearerToken != "" {
+		return fmt.Errorf("необходимо указать либо пароль, либо bearer токен WebDAV")
+	}
+
+	if config.ChunkSize < 0 {
+		return fmt.Errorf("размер части WebDAV не может быть отрицательным")
+	}
+
+	return nil
+}
+
+// ValidateAzureConfig валидирует конфигурацию Azure Blob Storage
+func ValidateAzureConfig(config *types.AzureConfig) error {
+	if config == nil {
+		return fmt.Errorf("конфигурация Azure не может быть пустой")
+	}
+
+	if config.Container == "" {
+		return fmt.Errorf("имя контейнера Azure обязательно")
+	}
+
+	if !isValidAzureContainerName(config.Container) {
+		return fmt.Errorf("некорректное имя контейнера Azure")
+	}
+
+	if config.AccountName == "" && config.Endpoint == "" {
+		return fmt.Errorf("необходимо указать имя учетной записи Azure или адрес сервиса")
+	}
+
+	if config.AccountKey == "" && config.SASToken == "" {
+		return fmt.Errorf("необходимо указать ключ учетной записи или SAS токен Azure")
+	}
+
+	if config.AccountKey != "" && config.AccountName == "" {
+		return fmt.Errorf("для аутентификации по ключу необходимо имя учетной записи Azure")
+	}
+
+	switch config.AccessTier {
+	case "", "Hot", "Cool", "Cold", "Archive":
+	default:
+		return fmt.Errorf("неподдерживаемый уровень доступа Azure: %s", config.AccessTier)
+	}
+
+	if config.BlockSize < 0 || config.BlockSize > 4000*1024*1024 {
+		return fmt.Errorf("размер блока Azure должен быть от 0 до 4000 МБ")
+	}
+
+	if config.Concurrency < 0 {
+		return fmt.Errorf("количество параллельных загрузок Azure не может быть отрицательным")
+	}
+
+	return nil
+}
+
+// ValidateMultiConfig валидирует конфигурацию зеркалирования в несколько хранилищ
+func ValidateMultiConfig(config *types.MultiConfig) error {
+	if config == nil {
+		return fmt.Errorf("конфигурация multi не может быть пустой")
+	}
+
+	if len(config.Targets) == 0 {
+		return fmt.Errorf("необходимо указать хотя бы одно хранилище")
+	}
+
+	seen := make(map[string]bool, len(config.Targets))
+	for _, target := range config.Targets {
+		if target == "" {
+			return fmt.Errorf("адрес хранилища не может быть пустым")
+		}
+		if seen[target] {
+			return fmt.Errorf("хранилище указано несколько раз: %s", target)
+		}
+		seen[target] = true
+	}
+
+	if _, err := MultiQuorum(config); err != nil {
+		return err
+	}
+
+	if config.ResyncInterval < 0 {
+		return fmt.Errorf("период повторной синхронизации не может быть отрицательным")
+	}
+
+	return nil
+}
+
+// MultiQuorum возвращает количество копий, необходимое для успешной записи
+func MultiQuorum(config *types.MultiConfig) (int, error) {
+	switch config.Quorum {
+	case "", "all":
+		return len(config.Targets), nil
+	case "any":
+		return 1, nil
+	}
+
+	quorum, err := strconv.Atoi(config.Quorum)
+	if err != nil || quorum < 1 || quorum > len(config.Targets) {
+		return 0, fmt.Errorf("кворум должен быть all, any или числом от 1 до %d: %s", len(config.Targets), config.Quorum)
+	}
+
+	return quorum, nil
+}
+
+// ValidateBandwidthConfig валидирует ограничения скорости передачи данных
+func ValidateBandwidthConfig(config *types.BandwidthConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	if _, err := ParseRate(config.UploadRate); err != nil {
+		return err
+	}
+	if _, err := ParseRate(config.DownloadRate); err != nil {
+		return err
+	}
+
+	if config.MaxParallelParts < 0 {
+		return fmt.Errorf("количество параллельно загружаемых частей не может быть отрицательным")
+	}
+
+	for i, window := range config.Schedule {
+		for _, day := range window.Days {
+			if _, err := ParseWeekday(day); err != nil {
+				return fmt.Errorf("окно расписания %d: %w", i+1, err)
+			}
+		}
+		if _, err := ParseDayTime(window.Start); err != nil {
+			return fmt.Errorf("окно расписания %d: %w", i+1, err)
+		}
+		if _, err := ParseDayTime(window.End); err != nil {
+			return fmt.Errorf("окно расписания %d: %w", i+1, err)
+		}
+		if _, err := ParseRate(window.UploadRate); err != nil {
+			return fmt.Errorf("окно расписания %d: %w", i+1, err)
+		}
+		if _, err := ParseRate(window.DownloadRate); err != nil {
+			return fmt.Errorf("окно расписания %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateVerifyConfig валидирует параметры регулярной проверки бэкапов
+func ValidateVerifyConfig(config *types.VerifyConfig) error {
+	if err := validate.Struct(config); err != nil {
+		return formatValidationError(err)
+	}
+	return nil
+}
+
+// ValidateNotificationConfig валидирует параметры уведомлений
+func ValidateNotificationConfig(config *types.NotificationConfig) error {
+	if err := validate.Struct(config); err != nil {
+		return formatValidationError(err)
+	}
+	return nil
+}
+
+// ValidateSnapshotServerConfig валидирует параметры WebDAV-сервера для просмотра бэкапов
+func ValidateSnapshotServerConfig(config *types.SnapshotServerConfig) error {
+	if err := validate.Struct(config); err != nil {
+		return formatValidationError(err)
+	}
+	host, _, err := net.SplitHostPort(config.Listen)
+	if err != nil {
+		return fmt.Errorf("некорректный адрес сервера %q: ожидается host:port", config.Listen)
+	}
+	if (config.TLSCert == "") != (config.TLSKey == "") {
+		return fmt.Errorf("для TLS нужны сертификат и ключ: задайте tls_cert и tls_key")
+	}
+	// Без TLS токен можно перехватить в сети
+	if config.TLSCert == "" && !isLoopbackHost(host) {
+		return fmt.Errorf("сервер на адресе %q доступен по сети: задайте tls_cert и tls_key или слушайте loopback-адрес, например 127.0.0.1", config.Listen)
+	}
+	return nil
+}
+
+// isLoopbackHost проверяет, что хост адреса доступен только с этого компьютера
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ValidateAPIServerConfig валидирует параметры REST API сервера
+func ValidateAPIServerConfig(config *types.APIServerConfig) error {
+	if err := validate.Struct(config); err != nil {
+		return formatValidationError(err)
+	}
+	if _, _, err := net.SplitHostPort(config.Listen); err != nil {
+		return fmt.Errorf("некорректный адрес сервера %q: ожидается host:port", config.Listen)
+	}
+	if config.Auth.Disabled && config.Auth.OIDC != nil {
+		return fmt.Errorf("аутентификация отключена, но настроен OIDC: уберите auth.disabled или auth.oidc")
+	}
+	return nil
+}
+
+// MinVolumeSize минимальный размер тома бэкапа
+const MinVolumeSize = 1 << 20
+
+// rateUnits множители единиц размера и скорости; KB, MB, GB, TB десятичные, KiB, MiB, GiB, TiB двоичные
+var rateUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1000,
+	"kb":  1000,
+	"kib": 1 << 10,
+	"m":   1000 * 1000,
+	"mb":  1000 * 1000,
+	"mib": 1 << 20,
+	"g":   1000 * 1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"gib": 1 << 30,
+	"t":   1000 * 1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"tib": 1 << 40,
+}
+
+// ParseRate разбирает скорость вида 10MB/s в байты в секунду
+//
+// Пустая строка, 0 и unlimited означают отсутствие ограничения (0).
+func ParseRate(rate string) (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(rate))
+	value = strings.TrimSuffix(value, "/s")
+
+	if value == "" || value == "0" || value == "unlimited" {
+		return 0, nil
+	}
+
+	amount, known, err := parseUnits(value)
+	if !known {
+		return 0, fmt.Errorf("неизвестная единица скорости: %s", rate)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("некорректная скорость: %s", rate)
+	}
+
+	return amount, nil
+}
+
+// ParseSize разбирает размер вида 4G или 700MiB в байты
+//
+// Пустая строка и 0 означают отсутствие значения (0).
+func ParseSize(size string) (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(size))
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+
+	amount, known, err := parseUnits(value)
+	if !known {
+		return 0, fmt.Errorf("неизвестная единица размера: %s", size)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("некорректный размер: %s", size)
+	}
+
+	return amount, nil
+}
+
+// parseUnits разбирает число с единицей из rateUnits; known - единица известна
+func parseUnits(value string) (int64, bool, error) {
+	number := strings.TrimRightFunc(value, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	multiplier, ok := rateUnits[strings.TrimSpace(value[len(number):])]
+	if !ok {
+		return 0, false, nil
+	}
+
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil || amount < 0 {
+		return 0, true, fmt.Errorf("некорректное число: %s", number)
+	}
+
+	return int64(amount * multiplier), true, nil
+}
+
+// ParseDayTime разбирает время суток ЧЧ:ММ в смещение от полуночи
+func ParseDayTime(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("некорректное время, ожидается ЧЧ:ММ: %s", value)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// ParseWeekday разбирает день недели: mon, tue, ..., sun или полное английское название
+func ParseWeekday(value string) (time.Weekday, error) {
+	day := strings.ToLower(strings.TrimSpace(value))
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := strings.ToLower(weekday.String())
+		if day == name || day == name[:3] {
+			return weekday, nil
+		}
+	}
+
+	return 0, fmt.Errorf("некорректный день недели: %s", value)
+}
+
+// ParseRoleBinding разбирает роль доступа к API: role (на все политики)
+// или role:policy-id (на одну политику)
+func ParseRoleBinding(value string) (types.RoleBinding, error) {
+	role, policyID, _ := strings.Cut(strings.TrimSpace(value), ":")
+	binding := types.RoleBinding{Role: types.Role(strings.ToLower(role)), PolicyID: strings.TrimSpace(policyID)}
+
+	switch binding.Role {
+	case types.RoleViewer, types.RoleOperator, types.RoleAdmin:
+	default:
+		return types.RoleBinding{}, fmt.Errorf("неизвестная роль %q: ожидается viewer, operator или admin", role)
+	}
+	if binding.PolicyID == "*" {
+		binding.PolicyID = ""
+	}
+
+	return binding, nil
+}
+
+// isValidAzureContainerName проверяет корректность имени контейнера Azure
+func isValidAzureContainerName(container string) bool {
+	if len(container) < 3 || len(container) > 63 {
+		return false
+	}
+
+	// Имя должно состоять только из строчных букв, цифр и одиночных дефисов
+	matched, _ := regexp.MatchString(`^[a-z0-9]+(-[a-z0-9]+)*$`, container)
+	return matched
+}
+
 // isValidS3BucketName проверяет корректность имени S3 bucket
 func isValidS3BucketName(bucket string) bool {
 	// Основные правила для имен S3 bucket
@@ -0,0 +1,227 @@
+package rest
+
+import (
+	"backupist/internal/core/backup"
+	"backupist/pkg/types"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Способы аутентификации в журнале аудита
+const (
+	authMethodToken = "token"
+	authMethodOIDC  = "oidc"
+	authMethodNone  = "none"
+)
+
+// roleRank порядок ролей: старшая роль включает права младших
+var roleRank = map[types.Role]int{
+	types.RoleViewer:   1,
+	types.RoleOperator: 2,
+	types.RoleAdmin:    3,
+}
+
+// Identity субъект запроса: API-токен или пользователь OIDC
+type Identity struct {
+	Subject string
+	Method  string
+	Roles   []types.RoleBinding
+}
+
+// allows проверяет, есть ли у субъекта роль не ниже role на политику
+// policyID; пустой policyID - действие над всеми политиками, для него
+// подходят только роли без привязки к политике
+func (id *Identity) allows(role types.Role, policyID string) bool {
+	for _, binding := range id.Roles {
+		if roleRank[binding.Role] < roleRank[role] {
+			continue
+		}
+		if binding.PolicyID == "" || (policyID != "" && binding.PolicyID == policyID) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsAny проверяет, есть ли у субъекта роль не ниже role хотя бы на одну политику
+func (id *Identity) allowsAny(role types.Role) bool {
+	for _, binding := range id.Roles {
+		if roleRank[binding.Role] >= roleRank[role] {
+			return true
+		}
+	}
+	return false
+}
+
+// policies возвращает политики, на которые у субъекта есть роль не
+// ниже role; all - роль на все политики
+func (id *Identity) policies(role types.Role) (policyIDs []string, all bool) {
+	policyIDs = []string{}
+	for _, binding := range id.Roles {
+		if roleRank[binding.Role] < roleRank[role] {
+			continue
+		}
+		if binding.PolicyID == "" {
+			return nil, true
+		}
+		policyIDs = append(policyIDs, binding.PolicyID)
+	}
+	return policyIDs, false
+}
+
+// access состояние проверки доступа запроса; по нему пишется запись аудита
+type access struct {
+	identity *Identity
+	role     types.Role // Роль, которую требует маршрут
+	policyID string     // Политика, к которой проверен доступ
+}
+
+// accessKey ключ контекста для состояния доступа запроса
+type accessKey struct{}
+
+// requestAccess возвращает состояние доступа запроса
+func requestAccess(r *http.Request) *access {
+	return r.Context().Value(accessKey{}).(*access)
+}
+
+// authenticate определяет субъект запроса по заголовку Authorization
+//
+// Значение с префиксом API-токена проверяется по каталогу токенов,
+// остальные - как JWT провайдера OIDC.
+func (s *Server) authenticate(r *http.Request) (*Identity, error) {
+	if s.auth.Disabled {
+		return &Identity{
+			Subject: "anonymous",
+			Method:  authMethodNone,
+			Roles:   []types.RoleBinding{{Role: types.RoleAdmin}},
+		}, nil
+	}
+
+	scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	credentials = strings.TrimSpace(credentials)
+	if !strings.EqualFold(scheme, "Bearer") || credentials == "" {
+		return nil, unauthorized("нужен заголовок Authorization: Bearer <токен>")
+	}
+
+	if strings.HasPrefix(credentials, backup.APITokenPrefix) {
+		token, err := s.service.AuthenticateAPIToken(r.Context(), credentials)
+		if err != nil {
+			if errors.Is(err, backup.ErrUnauthenticated) {
+				return nil, unauthorized("%v", err)
+			}
+			return nil, err
+		}
+		return &Identity{Subject: token.Name, Method: authMethodToken, Roles: token.Roles}, nil
+	}
+
+	if s.oidc == nil {
+		return nil, unauthorized("неизвестный API-токен")
+	}
+	identity, err := s.oidc.verify(r.Context(), credentials)
+	if err != nil {
+		return nil, unauthorized("%v", err)
+	}
+	return identity, nil
+}
+
+// unauthorized возвращает ошибку отсутствующей или неверной аутентификации
+func unauthorized(format string, args ...any) error {
+	return &apiError{status: http.StatusUnauthorized, message: fmt.Sprintf(format, args...)}
+}
+
+// forbidden возвращает ошибку недостаточной роли
+func forbidden(role types.Role, policyID string) error {
+	scope := "на все политики"
+	if policyID != "" {
+		scope = "на политику " + policyID
+	}
+	return &apiError{status: http.StatusForbidden, message: fmt.Sprintf("нужна роль %s %s", role, scope)}
+}
+
+// authorize проверяет роль маршрута на политику policyID и запоминает
+// политику для записи аудита
+func (s *Server) authorize(r *http.Request, policyID string) error {
+	acc := requestAccess(r)
+	acc.policyID = policyID
+	if !acc.identity.allows(acc.role, policyID) {
+		return forbidden(acc.role, policyID)
+	}
+	return nil
+}
+
+// authorizedJob возвращает задачу из пути запроса, проверив роль на ее политику
+func (s *Server) authorizedJob(r *http.Request) (*types.BackupJob, error) {
+	job, err := s.service.GetJob(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorize(r, job.PolicyID); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// audit записывает запрос в журнал аудита
+//
+// Ошибка записи только логируется: действие уже выполнено, и ответ
+// клиенту от нее не зависит.
+func (s *Server) audit(r *http.Request, rt route, acc *access, status int, err error) {
+	entry := &types.AuditEntry{
+		Action:     rt.action,
+		PolicyID:   acc.policyID,
+		ResourceID: r.PathValue("id"),
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Status:     status,
+		RemoteAddr: r.RemoteAddr,
+		AuthMethod: authMethodNone,
+	}
+	if acc.identity != nil {
+		entry.Actor = acc.identity.Subject
+		entry.AuthMethod = acc.identity.Method
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if err := s.service.RecordAudit(context.WithoutCancel(r.Context()), entry); err != nil {
+		s.logger.ErrorContext(r.Context(), "Ошибка записи в журнал аудита",
+			"action", entry.Action,
+			"actor", entry.Actor,
+			"error", err)
+	}
+}
+
+// statusRecorder запоминает статус ответа для журнала аудита
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(p []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(p)
+}
+
+// Flush нужен потоку событий задачи
+func (sr *statusRecorder) Flush() {
+	if flusher, ok := sr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
@@ -0,0 +1,247 @@
+package rest
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+func TestAuthRequired(t *testing.T) {
+	api := newTestAPI(t)
+
+	for _, token := range []string{"", "bkp_unknown", "not-a-jwt"} {
+		api.doAs(token, http.MethodGet, "/api/v1/policies", nil, http.StatusUnauthorized, nil)
+	}
+
+	request := api.newRequest(http.MethodGet, "/api/v1/policies", nil)
+	request.Header.Del("Authorization")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if got := response.Header.Get("WWW-Authenticate"); got == "" {
+		t.Fatal("в ответе 401 нет заголовка WWW-Authenticate")
+	}
+}
+
+func TestAuthDisabled(t *testing.T) {
+	api := newTestAPIWithConfig(t, types.APIServerConfig{Auth: types.APIAuthConfig{Disabled: true}})
+
+	api.doAs("", http.MethodGet, "/api/v1/policies", nil, http.StatusOK, nil)
+}
+
+func TestPolicyRoles(t *testing.T) {
+	api := newTestAPI(t)
+	own := api.createPolicy("own", map[string]string{"a.txt": "alpha"})
+	other := api.createPolicy("other", map[string]string{"b.txt": "bravo"})
+
+	var otherJob types.BackupJob
+	api.do(http.MethodPost, "/api/v1/backups", BackupRequest{PolicyID: other.ID}, http.StatusAccepted, &otherJob)
+	api.waitJob(otherJob.ID)
+
+	viewer := api.createToken("viewer", "viewer:"+own.ID)
+	operator := api.createToken("operator", "operator:"+own.ID)
+
+	// Оператор своей политики запускает бэкап, наблюдатель - только смотрит
+	var job types.BackupJob
+	api.doAs(viewer, http.MethodPost, "/api/v1/backups", BackupRequest{PolicyID: own.ID}, http.StatusForbidden, nil)
+	api.doAs(operator, http.MethodPost, "/api/v1/backups", BackupRequest{PolicyID: own.ID}, http.StatusAccepted, &job)
+	api.doAs(operator, http.MethodPost, "/api/v1/backups", BackupRequest{PolicyID: other.ID}, http.StatusForbidden, nil)
+	api.waitJob(job.ID)
+
+	var policies PolicyPage
+	api.doAs(viewer, http.MethodGet, "/api/v1/policies", nil, http.StatusOK, &policies)
+	if policies.Total != 1 || policies.Items[0].ID != own.ID {
+		t.Fatalf("наблюдателю видны политики: %+v", policies)
+	}
+	api.doAs(viewer, http.MethodGet, "/api/v1/policies/"+other.ID, nil, http.StatusForbidden, nil)
+
+	var jobs JobPage
+	api.doAs(viewer, http.MethodGet, "/api/v1/jobs", nil, http.StatusOK, &jobs)
+	if jobs.Total != 1 || jobs.Items[0].ID != job.ID {
+		t.Fatalf("наблюдателю видны задачи: %+v", jobs)
+	}
+	api.doAs(viewer, http.MethodGet, "/api/v1/jobs?policy_id="+other.ID, nil, http.StatusForbidden, nil)
+	api.doAs(viewer, http.MethodGet, "/api/v1/jobs/"+otherJob.ID, nil, http.StatusForbidden, nil)
+	api.doAs(viewer, http.MethodGet, "/api/v1/backups/"+job.ID, nil, http.StatusOK, nil)
+
+	// Права на задачу проверяются раньше разбора тела запроса
+	api.doAs(viewer, http.MethodPost, "/api/v1/jobs/"+job.ID+"/cancel", "{", http.StatusForbidden, nil)
+	api.doAs(operator, http.MethodPost, "/api/v1/jobs/"+otherJob.ID+"/cancel", "{", http.StatusForbidden, nil)
+	api.doAs(operator, http.MethodPost, "/api/v1/jobs/unknown/cancel", "{", http.StatusNotFound, nil)
+
+	target := RestoreRequest{Target: "restore", DryRun: true}
+	api.doAs(viewer, http.MethodPost, "/api/v1/backups/"+job.ID+"/restore", target, http.StatusForbidden, nil)
+	api.doAs(operator, http.MethodPost, "/api/v1/backups/"+job.ID+"/restore", target, http.StatusOK, nil)
+	api.doAs(operator, http.MethodPost, "/api/v1/backups/"+otherJob.ID+"/restore", target, http.StatusForbidden, nil)
+
+	// Создание политик и журнал аудита требуют роли admin на все политики
+	api.doAs(operator, http.MethodPost, "/api/v1/policies", PolicyRequest{}, http.StatusForbidden, nil)
+	api.doAs(operator, http.MethodGet, "/api/v1/audit", nil, http.StatusForbidden, nil)
+	api.doAs(operator, http.MethodDelete, "/api/v1/policies/"+other.ID, nil, http.StatusForbidden, nil)
+}
+
+func TestRevokedAndExpiredTokens(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	revoked := api.createToken("revoked", "viewer")
+	api.doAs(revoked, http.MethodGet, "/api/v1/policies", nil, http.StatusOK, nil)
+	if _, err := api.service.RevokeAPIToken(ctx, "revoked"); err != nil {
+		t.Fatal(err)
+	}
+	api.doAs(revoked, http.MethodGet, "/api/v1/policies", nil, http.StatusUnauthorized, nil)
+
+	_, expired, err := api.service.CreateAPIToken(ctx, "expired", []types.RoleBinding{{Role: types.RoleViewer}}, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	api.doAs(expired, http.MethodGet, "/api/v1/policies", nil, http.StatusUnauthorized, nil)
+
+	tokens, err := api.service.ListAPITokens(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		if token.Name == "revoked" && (token.RevokedAt == nil || token.LastUsedAt == nil) {
+			t.Fatalf("отозванный токен: %+v", token)
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	api := newTestAPI(t)
+	policy := api.createPolicy("audited", nil)
+	viewer := api.createToken("viewer", "viewer")
+
+	api.doAs(viewer, http.MethodDelete, "/api/v1/policies/"+policy.ID, nil, http.StatusForbidden, nil)
+	api.doAs("", http.MethodGet, "/api/v1/policies", nil, http.StatusUnauthorized, nil)
+	api.do(http.MethodDelete, "/api/v1/policies/"+policy.ID, nil, http.StatusNoContent, nil)
+
+	var page AuditPage
+	api.do(http.MethodGet, "/api/v1/audit?action=policy.delete", nil, http.StatusOK, &page)
+	if page.Total != 2 {
+		t.Fatalf("записи аудита policy.delete: %+v", page)
+	}
+	allowed, denied := page.Items[0], page.Items[1]
+	if allowed.Actor != "admin" || allowed.Status != http.StatusNoContent || allowed.PolicyID != policy.ID {
+		t.Fatalf("запись разрешенного удаления: %+v", allowed)
+	}
+	if denied.Actor != "viewer" || denied.Status != http.StatusForbidden || denied.Error == "" {
+		t.Fatalf("запись запрещенного удаления: %+v", denied)
+	}
+
+	api.do(http.MethodGet, "/api/v1/audit?actor=viewer", nil, http.StatusOK, &page)
+	if page.Total != 1 {
+		t.Fatalf("записи аудита субъекта viewer: %+v", page)
+	}
+	api.doAs(viewer, http.MethodGet, "/api/v1/audit", nil, http.StatusForbidden, nil)
+}
+
+// testProvider провайдер OIDC с документом discovery и набором ключей
+type testProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	signer jose.Signer
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &testProvider{t: t, signer: signer}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   provider.server.URL,
+			"jwks_uri": provider.server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Algorithm: string(jose.RS256), Use: "sig"},
+		}})
+	})
+	provider.server = httptest.NewServer(mux)
+	t.Cleanup(provider.server.Close)
+
+	return provider
+}
+
+// token выпускает JWT; expiry 0 - без срока действия
+func (p *testProvider) token(audience string, expiry time.Duration, roles ...string) string {
+	p.t.Helper()
+
+	claims := jwt.Claims{
+		Issuer:   p.server.URL,
+		Subject:  "alice",
+		Audience: jwt.Audience{audience},
+		IssuedAt: jwt.NewNumericDate(time.Now()),
+	}
+	if expiry != 0 {
+		claims.Expiry = jwt.NewNumericDate(time.Now().Add(expiry))
+	}
+
+	raw, err := jwt.Signed(p.signer).Claims(claims).Claims(map[string]any{"roles": roles}).Serialize()
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return raw
+}
+
+func TestOIDC(t *testing.T) {
+	provider := newTestProvider(t)
+	api := newTestAPIWithConfig(t, types.APIServerConfig{Auth: types.APIAuthConfig{
+		OIDC: &types.OIDCConfig{Issuer: provider.server.URL, Audience: "backupist"},
+	}})
+	policy := api.createPolicy("oidc", nil)
+
+	viewer := provider.token("backupist", time.Hour, "backupist:viewer:"+policy.ID, "unrelated-role")
+	api.doAs(viewer, http.MethodGet, "/api/v1/policies/"+policy.ID, nil, http.StatusOK, nil)
+	api.doAs(viewer, http.MethodDelete, "/api/v1/policies/"+policy.ID, nil, http.StatusForbidden, nil)
+
+	// Роль без префикса может принадлежать другому приложению и прав не дает
+	api.doAs(provider.token("backupist", time.Hour, "admin"), http.MethodGet, "/api/v1/policies/"+policy.ID, nil, http.StatusForbidden, nil)
+
+	api.doAs(provider.token("other", time.Hour, "admin"), http.MethodGet, "/api/v1/policies", nil, http.StatusUnauthorized, nil)
+	api.doAs(provider.token("backupist", -time.Hour, "admin"), http.MethodGet, "/api/v1/policies", nil, http.StatusUnauthorized, nil)
+	api.doAs(provider.token("backupist", 0, "admin"), http.MethodGet, "/api/v1/policies", nil, http.StatusUnauthorized, nil)
+
+	var page AuditPage
+	api.do(http.MethodGet, "/api/v1/audit?actor=alice", nil, http.StatusOK, &page)
+	if page.Total != 3 || page.Items[0].AuthMethod != authMethodOIDC {
+		t.Fatalf("записи аудита пользователя OIDC: %+v", page)
+	}
+}
+
+func TestOIDCRolePrefix(t *testing.T) {
+	provider := newTestProvider(t)
+	api := newTestAPIWithConfig(t, types.APIServerConfig{Auth: types.APIAuthConfig{
+		OIDC: &types.OIDCConfig{Issuer: provider.server.URL, Audience: "backupist", RolePrefix: "acme/backup/"},
+	}})
+
+	api.doAs(provider.token("backupist", time.Hour, "acme/backup/admin"), http.MethodGet, "/api/v1/audit", nil, http.StatusOK, nil)
+	api.doAs(provider.token("backupist", time.Hour, "backupist:admin"), http.MethodGet, "/api/v1/audit", nil, http.StatusForbidden, nil)
+}
@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"backupist/internal/core/backup"
+	"backupist/pkg/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// eventStreamType тип содержимого потока Server-Sent Events
+	eventStreamType = "text/event-stream"
+
+	// heartbeatInterval интервал комментариев, не дающих прокси закрыть простаивающий поток
+	heartbeatInterval = 15 * time.Second
+)
+
+// jobEvents GET /api/v1/jobs/{id}/events
+//
+// Первым событием отдается текущий статус задачи, затем события
+// прогресса и смены статуса по мере выполнения. Поток закрывается
+// после события о завершении задачи.
+func (s *Server) jobEvents(w http.ResponseWriter, r *http.Request) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("соединение не поддерживает потоковую передачу")
+	}
+
+	// Подписка до чтения задачи: событие о завершении, случившемся
+	// между ними, не будет пропущено
+	jobID := r.PathValue("id")
+	events, unsubscribe := s.service.Events().Subscribe(jobID)
+	defer unsubscribe()
+
+	job, err := s.service.GetJob(r.Context(), jobID)
+	if err != nil {
+		return err
+	}
+	if err := s.authorize(r, job.PolicyID); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", eventStreamType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	current := types.JobEvent{
+		Type:     types.JobEventStatus,
+		JobID:    job.ID,
+		PolicyID: job.PolicyID,
+		Status:   job.Status,
+		Progress: job.Progress,
+		Error:    job.Error,
+		Time:     time.Now(),
+	}
+	if err := writeEvent(w, current); err != nil || backup.JobFinished(job.Status) {
+		return nil
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-s.streams:
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return nil
+			}
+		case event := <-events:
+			if err := writeEvent(w, event); err != nil {
+				return nil
+			}
+			if event.Type == types.JobEventStatus && backup.JobFinished(event.Status) {
+				return nil
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent записывает событие задачи в формате Server-Sent Events
+//
+// Ошибки записи означают, что клиент отключился; ответ с ошибкой
+// после начала потока отдать уже нельзя, поэтому обработчик просто
+// завершается.
+func writeEvent(w http.ResponseWriter, event types.JobEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
@@ -0,0 +1,51 @@
+package rest
+
+import (
+	"backupist/pkg/types"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// runOverlapping запускает бэкап политики и, когда он начал загрузку,
+// второй запуск той же политики; возвращает обе задачи и результат первой
+func (a *testAPI) runOverlapping(policyID string) (first, second types.BackupJob, firstErr <-chan error) {
+	a.t.Helper()
+
+	job, err := a.service.CreatePolicyJob(context.Background(), policyID)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	events, unsubscribe := a.service.Events().Subscribe(job.ID)
+	defer unsubscribe()
+
+	firstErr = a.startBackup(job)
+	a.waitUpload(events)
+
+	a.do(http.MethodPost, "/api/v1/backups", BackupRequest{PolicyID: policyID}, http.StatusAccepted, &second)
+	return *job, second, firstErr
+}
+
+func TestOverlapSkip(t *testing.T) {
+	api := newTestAPI(t)
+	policy := api.slowPolicy("skip", 512<<10, "")
+
+	first, second, done := api.runOverlapping(policy.ID)
+
+	skipped := api.waitJob(second.ID)
+	if skipped.Status != types.JobStatusCancelled || !strings.Contains(skipped.Error, first.ID) {
+		t.Fatalf("пересекающийся запуск не пропущен: %+v", skipped)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("первый запуск: %v", err)
+	}
+
+	// После завершения блокировка свободна
+	var job types.BackupJob
+	api.do(http.MethodPost, "/api/v1/backups", BackupRequest{PolicyID: policy.ID}, http.StatusAccepted, &job)
+	if job = api.waitJob(job.ID); job.Status != types.JobStatusCompleted {
+		t.Fatalf("запуск после освобождения блокировки: %+v", job)
+	}
+}
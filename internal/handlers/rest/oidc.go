@@ -0,0 +1,229 @@
+package rest
+
+import (
+	"backupist/internal/core/config"
+	"backupist/pkg/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	// jwksRefreshInterval минимальный интервал между загрузками ключей
+	// провайдера: токен с неизвестным kid не должен вызывать запрос на каждый вызов
+	jwksRefreshInterval = time.Minute
+
+	// jwtLeeway допустимое расхождение часов при проверке exp и nbf
+	jwtLeeway = time.Minute
+
+	// oidcDefaultRolePrefix префикс ролей backupist в claim по умолчанию
+	oidcDefaultRolePrefix = "backupist:"
+)
+
+// jwtAlgorithms допустимые алгоритмы подписи JWT
+var jwtAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// oidcVerifier проверяет JWT провайдера OpenID Connect по его ключам
+type oidcVerifier struct {
+	config types.OIDCConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	keys    *jose.JSONWebKeySet
+	fetched time.Time
+}
+
+func newOIDCVerifier(cfg types.OIDCConfig) *oidcVerifier {
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "sub"
+	}
+	if cfg.RolePrefix == "" {
+		cfg.RolePrefix = oidcDefaultRolePrefix
+	}
+
+	return &oidcVerifier{config: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// verify проверяет подпись, издателя, аудиторию и срок действия JWT
+// и возвращает субъект с ролями из claim
+func (v *oidcVerifier) verify(ctx context.Context, raw string) (*Identity, error) {
+	token, err := jwt.ParseSigned(raw, jwtAlgorithms)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный JWT: %w", err)
+	}
+
+	key, err := v.key(ctx, token.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims jwt.Claims
+	var custom map[string]any
+	if err := token.Claims(key, &claims, &custom); err != nil {
+		return nil, fmt.Errorf("ошибка проверки подписи JWT: %w", err)
+	}
+
+	if claims.Expiry == nil {
+		return nil, errors.New("в JWT нет срока действия exp")
+	}
+	expected := jwt.Expected{
+		Issuer:      v.config.Issuer,
+		AnyAudience: jwt.Audience{v.config.Audience},
+		Time:        time.Now(),
+	}
+	if err := claims.ValidateWithLeeway(expected, jwtLeeway); err != nil {
+		return nil, fmt.Errorf("JWT не прошел проверку: %w", err)
+	}
+
+	subject, _ := custom[v.config.UsernameClaim].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("в JWT нет claim %s", v.config.UsernameClaim)
+	}
+
+	identity := &Identity{Subject: subject, Method: authMethodOIDC}
+	for _, value := range claimStrings(custom[v.config.RolesClaim]) {
+		// Роли без префикса относятся к другим приложениям провайдера
+		role, ok := strings.CutPrefix(value, v.config.RolePrefix)
+		if !ok {
+			continue
+		}
+		binding, err := config.ParseRoleBinding(role)
+		if err != nil {
+			continue
+		}
+		identity.Roles = append(identity.Roles, binding)
+	}
+
+	return identity, nil
+}
+
+// claimStrings возвращает значения claim: список строк или строку через пробел
+func claimStrings(value any) []string {
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value)
+	case []any:
+		var values []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// key возвращает открытый ключ провайдера с идентификатором kid
+//
+// Ключи кешируются; неизвестный kid означает смену ключей провайдером,
+// и набор загружается заново, но не чаще jwksRefreshInterval.
+func (v *oidcVerifier) key(ctx context.Context, kid string) (any, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.keys != nil {
+		if key, ok := findKey(v.keys, kid); ok {
+			return key, nil
+		}
+		if time.Since(v.fetched) < jwksRefreshInterval {
+			return nil, fmt.Errorf("неизвестный ключ подписи JWT %q", kid)
+		}
+	}
+
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	v.fetched = time.Now()
+
+	if key, ok := findKey(keys, kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("неизвестный ключ подписи JWT %q", kid)
+}
+
+// findKey ищет ключ подписи по kid; без kid подходит единственный ключ набора
+func findKey(keys *jose.JSONWebKeySet, kid string) (any, bool) {
+	if kid == "" {
+		if len(keys.Keys) == 1 {
+			return keys.Keys[0].Key, true
+		}
+		return nil, false
+	}
+
+	for _, key := range keys.Key(kid) {
+		if key.Use == "" || key.Use == "sig" {
+			return key.Key, true
+		}
+	}
+	return nil, false
+}
+
+// fetchKeys загружает набор ключей провайдера; адрес набора берется из
+// конфигурации или из документа /.well-known/openid-configuration
+func (v *oidcVerifier) fetchKeys(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	jwksURL := v.config.JWKSURL
+	if jwksURL == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		discoveryURL := strings.TrimSuffix(v.config.Issuer, "/") + "/.well-known/openid-configuration"
+		if err := v.getJSON(ctx, discoveryURL, &discovery); err != nil {
+			return nil, fmt.Errorf("ошибка получения конфигурации OIDC: %w", err)
+		}
+		if discovery.Issuer != v.config.Issuer {
+			return nil, fmt.Errorf("провайдер OIDC сообщает издателя %q вместо %q", discovery.Issuer, v.config.Issuer)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, errors.New("в конфигурации OIDC нет jwks_uri")
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := v.getJSON(ctx, jwksURL, &keys); err != nil {
+		return nil, fmt.Errorf("ошибка получения ключей OIDC: %w", err)
+	}
+
+	return &keys, nil
+}
+
+// getJSON выполняет GET-запрос и декодирует JSON-ответ
+func (v *oidcVerifier) getJSON(ctx context.Context, url string, value any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s ответил статусом %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(value)
+}
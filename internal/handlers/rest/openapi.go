@@ -0,0 +1,249 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// openAPIVersion версия API в спецификации
+const openAPIVersion = "1.0.0"
+
+// pathParamPattern параметр пути шаблона http.ServeMux: {id}
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// OpenAPI возвращает спецификацию OpenAPI 3 API
+//
+// Спецификация строится по таблице маршрутов: пути, параметры и
+// коды ответов берутся из нее, схемы тел запросов и ответов - из
+// Go-типов по их тегам json.
+func (s *Server) OpenAPI() map[string]any {
+	schemas := newSchemaRegistry()
+	errorSchema := schemas.schema(reflect.TypeOf(ErrorResponse{}))
+
+	paths := make(map[string]map[string]any)
+	for _, rt := range s.routes {
+		scope := "на политику"
+		if rt.global {
+			scope = "на все политики"
+		}
+		operation := map[string]any{
+			"summary":     rt.summary,
+			"description": fmt.Sprintf("Требуется роль %s %s.", rt.role, scope),
+			"operationId": operationID(rt),
+			"tags":        []string{rt.tag},
+		}
+
+		var parameters []map[string]any
+		for _, match := range pathParamPattern.FindAllStringSubmatch(rt.path, -1) {
+			parameters = append(parameters, map[string]any{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+		for _, param := range rt.query {
+			parameters = append(parameters, map[string]any{
+				"name":        param.name,
+				"in":          "query",
+				"description": param.description,
+				"schema":      map[string]any{"type": param.kind},
+			})
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+
+		if rt.request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": schemas.schema(reflect.TypeOf(rt.request))},
+				},
+			}
+		}
+
+		success := map[string]any{"description": http.StatusText(rt.status)}
+		if rt.result != nil {
+			content := rt.content
+			if content == "" {
+				content = "application/json"
+			}
+			success["content"] = map[string]any{
+				content: map[string]any{"schema": schemas.schema(reflect.TypeOf(rt.result))},
+			}
+		}
+		operation["responses"] = map[string]any{
+			strconv.Itoa(rt.status): success,
+			"default": map[string]any{
+				"description": "Ошибка",
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": errorSchema},
+				},
+			},
+		}
+
+		if paths[rt.path] == nil {
+			paths[rt.path] = make(map[string]any)
+		}
+		paths[rt.path][strings.ToLower(rt.method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "backupist API",
+			"version": openAPIVersion,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "API-токен (bkp_...) или JWT провайдера OIDC",
+				},
+			},
+		},
+		"security": []map[string]any{{"bearerAuth": []string{}}},
+	}
+}
+
+// operationID возвращает идентификатор операции: GET /api/v1/jobs/{id} -> getJobsId
+func operationID(rt route) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(rt.method))
+	for _, segment := range strings.Split(strings.TrimPrefix(rt.path, "/api/v1/"), "/") {
+		segment = strings.Trim(segment, "{}")
+		for _, part := range strings.FieldsFunc(segment, func(r rune) bool { return r == '_' || r == '.' }) {
+			runes := []rune(part)
+			runes[0] = unicode.ToUpper(runes[0])
+			b.WriteString(string(runes))
+		}
+	}
+	return b.String()
+}
+
+// schemaRegistry схемы именованных структур для components/schemas
+type schemaRegistry struct {
+	schemas map[string]any
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: make(map[string]any)}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema возвращает JSON-схему типа; именованные структуры
+// регистрируются в components/schemas и возвращаются ссылкой
+func (sr *schemaRegistry) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == reflect.TypeOf(time.Duration(0)):
+		return map[string]any{"type": "integer", "format": "int64", "description": "Длительность в наносекундах"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		format := "int32"
+		if t.Bits() == 64 {
+			format = "int64"
+		}
+		return map[string]any{"type": "integer", "format": format}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": sr.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": sr.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sr.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := sr.schemas[name]; !ok {
+			sr.schemas[name] = nil // Защита от рекурсии
+			sr.schemas[name] = sr.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema возвращает схему структуры по тегам json; поля
+// встроенных структур без тега поднимаются на уровень структуры
+func (sr *schemaRegistry) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	sr.addProperties(t, properties)
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+func (sr *schemaRegistry) addProperties(t reflect.Type, properties map[string]any) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded = append(embedded, field.Type)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = sr.schema(field.Type)
+	}
+
+	// Поля самой структуры перекрывают одноименные поля встроенных
+	for _, et := range embedded {
+		for et.Kind() == reflect.Pointer {
+			et = et.Elem()
+		}
+		if et.Kind() != reflect.Struct {
+			continue
+		}
+		inner := make(map[string]any)
+		sr.addProperties(et, inner)
+		for name, schema := range inner {
+			if _, ok := properties[name]; !ok {
+				properties[name] = schema
+			}
+		}
+	}
+}
+
+// schemaName возвращает имя схемы типа с заглавной буквы
+func schemaName(t reflect.Type) string {
+	runes := []rune(t.Name())
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,562 @@
+package rest
+
+import (
+	"backupist/internal/core/backup"
+	"backupist/pkg/types"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// route метод API: по нему регистрируется обработчик и строится
+// описание метода в спецификации OpenAPI
+type route struct {
+	method  string
+	path    string // Шаблон http.ServeMux, параметры пути в фигурных скобках
+	summary string
+	tag     string
+	action  string     // Действие в журнале аудита
+	role    types.Role // Роль, которую требует маршрут
+	global  bool       // Действие над всеми политиками: подходят только роли без привязки к политике
+	query   []queryParam
+	request any    // Пример типа тела запроса; nil - без тела
+	status  int    // Код успешного ответа
+	result  any    // Пример типа тела ответа; nil - без тела
+	content string // Тип содержимого ответа; пусто - application/json
+	handler handlerFunc
+}
+
+// queryParam параметр строки запроса
+type queryParam struct {
+	name        string
+	kind        string // Тип в OpenAPI: string, integer, boolean
+	description string
+}
+
+// Параметры пагинации, общие для списков
+var pageQuery = []queryParam{
+	{name: "limit", kind: "integer", description: "Размер страницы, от 1 до 1000 (по умолчанию 50)"},
+	{name: "offset", kind: "integer", description: "Сколько элементов пропустить"},
+}
+
+// PolicyPage страница списка политик
+type PolicyPage struct {
+	Items  []*types.BackupPolicy `json:"items"`
+	Total  int                   `json:"total"`
+	Limit  int                   `json:"limit"`
+	Offset int                   `json:"offset"`
+}
+
+// JobPage страница списка задач или бэкапов
+type JobPage struct {
+	Items  []*types.BackupJob `json:"items"`
+	Total  int                `json:"total"`
+	Limit  int                `json:"limit"`
+	Offset int                `json:"offset"`
+}
+
+// AuditPage страница журнала аудита
+type AuditPage struct {
+	Items  []*types.AuditEntry `json:"items"`
+	Total  int                 `json:"total"`
+	Limit  int                 `json:"limit"`
+	Offset int                 `json:"offset"`
+}
+
+// PolicyRequest тело запроса создания политики
+//
+// В отличие от types.BackupPolicy принимает пароль шифрования.
+type PolicyRequest struct {
+	types.BackupPolicy
+	EncryptionPassword string `json:"encryption_password,omitempty"`
+}
+
+// BackupRequest тело запроса запуска бэкапа
+type BackupRequest struct {
+	PolicyID string `json:"policy_id"`
+}
+
+// CancelRequest тело запроса отмены задачи; тело можно не передавать
+type CancelRequest struct {
+	Reason string `json:"reason,omitempty"` // Причина отмены, записывается в ошибку задачи
+}
+
+// BackupDetails бэкап: задача и результат ее выполнения
+type BackupDetails struct {
+	Job    *types.BackupJob    `json:"job"`
+	Result *types.BackupResult `json:"result,omitempty"`
+}
+
+// RestoreRequest тело запроса восстановления бэкапа
+//
+// Директории восстановления и снимка должны находиться внутри
+// api.restore_root; относительные пути считаются от него.
+type RestoreRequest struct {
+	Target      string   `json:"target"`                 // Директория восстановления
+	Include     []string `json:"include,omitempty"`      // Шаблоны путей; пусто - все файлы
+	Conflict    string   `json:"conflict,omitempty"`     // overwrite, overwrite-if-newer, skip, rename
+	DryRun      bool     `json:"dry_run,omitempty"`      // Только вернуть список изменений
+	SnapshotDir string   `json:"snapshot_dir,omitempty"` // Куда сохранить заменяемые файлы
+	NoSnapshot  bool     `json:"no_snapshot,omitempty"`  // Не сохранять заменяемые файлы
+}
+
+// apiRoutes возвращает таблицу маршрутов API
+func (s *Server) apiRoutes() []route {
+	statusQuery := queryParam{name: "status", kind: "string", description: "Статус задачи: pending, running, completed, failed, cancelled, deleted"}
+	policyQuery := queryParam{name: "policy_id", kind: "string", description: "ID политики"}
+
+	return []route{
+		{
+			method: http.MethodGet, path: "/api/v1/policies", tag: "policies",
+			action: "policy.list", role: types.RoleViewer,
+			summary: "Список политик бэкапа",
+			query:   pageQuery,
+			status:  http.StatusOK, result: PolicyPage{},
+			handler: s.listPolicies,
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/policies", tag: "policies",
+			action: "policy.create", role: types.RoleAdmin, global: true,
+			summary: "Создать политику бэкапа",
+			request: PolicyRequest{},
+			status:  http.StatusCreated, result: types.BackupPolicy{},
+			handler: s.createPolicy,
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/policies/{id}", tag: "policies",
+			action: "policy.get", role: types.RoleViewer,
+			summary: "Политика бэкапа",
+			status:  http.StatusOK, result: types.BackupPolicy{},
+			handler: s.getPolicy,
+		},
+		{
+			method: http.MethodDelete, path: "/api/v1/policies/{id}", tag: "policies",
+			action: "policy.delete", role: types.RoleOperator,
+			summary: "Удалить политику и записи о ее бэкапах (файлы в хранилище сохраняются)",
+			status:  http.StatusNoContent,
+			handler: s.deletePolicy,
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/backups", tag: "backups",
+			action: "backup.list", role: types.RoleViewer,
+			summary: "Список бэкапов (по умолчанию завершенных), начиная с новых",
+			query:   append([]queryParam{policyQuery, statusQuery}, pageQuery...),
+			status:  http.StatusOK, result: JobPage{},
+			handler: s.listBackups,
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/backups", tag: "backups",
+			action: "backup.create", role: types.RoleOperator,
+			summary: "Запустить бэкап политики; выполнение отслеживается через /api/v1/jobs/{id}",
+			request: BackupRequest{},
+			status:  http.StatusAccepted, result: types.BackupJob{},
+			handler: s.createBackup,
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/backups/{id}", tag: "backups",
+			action: "backup.get", role: types.RoleViewer,
+			summary: "Бэкап: задача и результат",
+			status:  http.StatusOK, result: BackupDetails{},
+			handler: s.getBackup,
+		},
+		{
+			method: http.MethodDelete, path: "/api/v1/backups/{id}", tag: "backups",
+			action: "backup.delete", role: types.RoleOperator,
+			summary: "Удалить бэкап из хранилища и каталога",
+			status:  http.StatusNoContent,
+			handler: s.deleteBackup,
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/backups/{id}/restore", tag: "backups",
+			action: "backup.restore", role: types.RoleOperator,
+			summary: "Восстановить файлы из бэкапа",
+			request: RestoreRequest{},
+			status:  http.StatusOK, result: backup.RestoreResult{},
+			handler: s.restoreBackup,
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/jobs", tag: "jobs",
+			action: "job.list", role: types.RoleViewer,
+			summary: "Список задач бэкапа, начиная с новых",
+			query:   append([]queryParam{policyQuery, statusQuery}, pageQuery...),
+			status:  http.StatusOK, result: JobPage{},
+			handler: s.listJobs,
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/jobs/{id}", tag: "jobs",
+			action: "job.get", role: types.RoleViewer,
+			summary: "Статус задачи бэкапа",
+			status:  http.StatusOK, result: types.BackupJob{},
+			handler: s.getJob,
+		},
+		{
+			method: http.MethodPost, path: "/api/v1/jobs/{id}/cancel", tag: "jobs",
+			action: "job.cancel", role: types.RoleOperator,
+			summary: "Отменить задачу бэкапа: загрузка прерывается, частично записанные объекты удаляются",
+			request: CancelRequest{},
+			status:  http.StatusAccepted, result: types.BackupJob{},
+			handler: s.cancelJob,
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/jobs/{id}/events", tag: "jobs",
+			action: "job.events", role: types.RoleViewer,
+			summary: "Поток событий задачи (Server-Sent Events): прогресс и смена статуса; закрывается после завершения задачи",
+			status:  http.StatusOK, result: types.JobEvent{}, content: eventStreamType,
+			handler: s.jobEvents,
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/audit", tag: "audit",
+			action: "audit.list", role: types.RoleAdmin, global: true,
+			summary: "Журнал аудита API, начиная с новых записей",
+			query: append([]queryParam{
+				{name: "actor", kind: "string", description: "Имя токена или пользователь OIDC"},
+				policyQuery,
+				{name: "action", kind: "string", description: "Действие, например backup.restore"},
+			}, pageQuery...),
+			status: http.StatusOK, result: AuditPage{},
+			handler: s.listAudit,
+		},
+		{
+			method: http.MethodGet, path: "/api/v1/openapi.json", tag: "meta",
+			action: "openapi.get", role: types.RoleViewer,
+			summary: "Спецификация OpenAPI 3 этого API",
+			status:  http.StatusOK, result: map[string]any{},
+			handler: s.openAPI,
+		},
+	}
+}
+
+// listPolicies GET /api/v1/policies
+func (s *Server) listPolicies(w http.ResponseWriter, r *http.Request) error {
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		return err
+	}
+
+	policies, err := s.service.ListPolicies(r.Context())
+	if err != nil {
+		return err
+	}
+
+	// Только политики, на которые у субъекта есть роль
+	acc := requestAccess(r)
+	visible := policies[:0]
+	for _, policy := range policies {
+		if acc.identity.allows(acc.role, policy.ID) {
+			visible = append(visible, policy)
+		}
+	}
+	policies = visible
+
+	page := PolicyPage{Items: []*types.BackupPolicy{}, Total: len(policies), Limit: limit, Offset: offset}
+	if offset < len(policies) {
+		page.Items = policies[offset:min(offset+limit, len(policies))]
+	}
+
+	return writeJSON(w, http.StatusOK, page)
+}
+
+// createPolicy POST /api/v1/policies
+func (s *Server) createPolicy(w http.ResponseWriter, r *http.Request) error {
+	var request PolicyRequest
+	if err := readJSON(r, &request); err != nil {
+		return err
+	}
+
+	policy := request.BackupPolicy
+	policy.ID = ""
+	policy.EncryptionPassword = request.EncryptionPassword
+	if err := s.service.CreatePolicy(r.Context(), &policy); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, policy)
+}
+
+// getPolicy GET /api/v1/policies/{id}
+func (s *Server) getPolicy(w http.ResponseWriter, r *http.Request) error {
+	if err := s.authorize(r, r.PathValue("id")); err != nil {
+		return err
+	}
+
+	policy, err := s.service.GetPolicy(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, policy)
+}
+
+// deletePolicy DELETE /api/v1/policies/{id}
+func (s *Server) deletePolicy(w http.ResponseWriter, r *http.Request) error {
+	if err := s.authorize(r, r.PathValue("id")); err != nil {
+		return err
+	}
+	if err := s.service.DeletePolicy(r.Context(), r.PathValue("id")); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// listBackups GET /api/v1/backups
+func (s *Server) listBackups(w http.ResponseWriter, r *http.Request) error {
+	filter, err := jobFilter(r)
+	if err != nil {
+		return err
+	}
+	filter.Backups = true
+	if filter.Status == "" {
+		filter.Status = types.JobStatusCompleted
+	}
+	return s.writeJobs(w, r, filter)
+}
+
+// listJobs GET /api/v1/jobs
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request) error {
+	filter, err := jobFilter(r)
+	if err != nil {
+		return err
+	}
+	return s.writeJobs(w, r, filter)
+}
+
+// jobFilter читает фильтр и пагинацию списка задач
+func jobFilter(r *http.Request) (backup.JobFilter, error) {
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		return backup.JobFilter{}, err
+	}
+
+	status := types.JobStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", types.JobStatusPending, types.JobStatusRunning, types.JobStatusCompleted,
+		types.JobStatusFailed, types.JobStatusCancelled, types.JobStatusDeleted:
+	default:
+		return backup.JobFilter{}, badRequest("неизвестный статус задачи: %s", status)
+	}
+
+	return backup.JobFilter{
+		PolicyID: r.URL.Query().Get("policy_id"),
+		Status:   status,
+		Limit:    limit,
+		Offset:   offset,
+	}, nil
+}
+
+// writeJobs отдает страницу задач по фильтру, ограниченному политиками,
+// на которые у субъекта есть роль
+func (s *Server) writeJobs(w http.ResponseWriter, r *http.Request, filter backup.JobFilter) error {
+	acc := requestAccess(r)
+	if filter.PolicyID != "" {
+		if err := s.authorize(r, filter.PolicyID); err != nil {
+			return err
+		}
+	} else if policyIDs, all := acc.identity.policies(acc.role); !all {
+		filter.PolicyIDs = policyIDs
+	}
+
+	jobs, total, err := s.service.ListJobs(r.Context(), filter)
+	if err != nil {
+		return err
+	}
+	if jobs == nil {
+		jobs = []*types.BackupJob{}
+	}
+
+	return writeJSON(w, http.StatusOK, JobPage{Items: jobs, Total: total, Limit: filter.Limit, Offset: filter.Offset})
+}
+
+// createBackup POST /api/v1/backups
+func (s *Server) createBackup(w http.ResponseWriter, r *http.Request) error {
+	var request BackupRequest
+	if err := readJSON(r, &request); err != nil {
+		return err
+	}
+	if request.PolicyID == "" {
+		return badRequest("не указан policy_id")
+	}
+	if err := s.authorize(r, request.PolicyID); err != nil {
+		return err
+	}
+
+	if _, err := s.service.GetPolicy(r.Context(), request.PolicyID); err != nil {
+		return err
+	}
+
+	job, err := s.service.CreatePolicyJob(r.Context(), request.PolicyID)
+	if err != nil {
+		return err
+	}
+
+	s.goBackground(func(ctx context.Context) {
+		if _, err := s.service.ExecuteBackup(ctx, job); err != nil {
+			s.logger.ErrorContext(ctx, "Ошибка бэкапа, запущенного через API",
+				"job_id", job.ID,
+				"policy_id", job.PolicyID,
+				"error", err)
+		}
+	})
+
+	return writeJSON(w, http.StatusAccepted, job)
+}
+
+// getBackup GET /api/v1/backups/{id}
+func (s *Server) getBackup(w http.ResponseWriter, r *http.Request) error {
+	job, err := s.authorizedJob(r)
+	if err != nil {
+		return err
+	}
+
+	details := BackupDetails{Job: job}
+	if job.Status == types.JobStatusCompleted {
+		if details.Result, err = s.service.GetBackupResult(r.Context(), job.ID); err != nil {
+			return err
+		}
+	}
+
+	return writeJSON(w, http.StatusOK, details)
+}
+
+// deleteBackup DELETE /api/v1/backups/{id}
+func (s *Server) deleteBackup(w http.ResponseWriter, r *http.Request) error {
+	job, err := s.completedBackup(r)
+	if err != nil {
+		return err
+	}
+	if err := s.service.DeleteBackup(r.Context(), job.ID); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// restoreBackup POST /api/v1/backups/{id}/restore
+func (s *Server) restoreBackup(w http.ResponseWriter, r *http.Request) error {
+	if s.restoreRoot == "" {
+		return &apiError{status: http.StatusForbidden, message: "восстановление через API отключено: не задан api.restore_root"}
+	}
+
+	var request RestoreRequest
+	if err := readJSON(r, &request); err != nil {
+		return err
+	}
+	if strings.TrimSpace(request.Target) == "" {
+		return badRequest("не указана директория восстановления target")
+	}
+	if request.SnapshotDir != "" && request.NoSnapshot {
+		return badRequest("snapshot_dir и no_snapshot нельзя указывать вместе")
+	}
+
+	conflict, err := backup.ParseConflictMode(request.Conflict)
+	if err != nil {
+		return badRequest("%v", err)
+	}
+
+	job, err := s.completedBackup(r)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.service.Restore(r.Context(), backup.RestoreOptions{
+		PolicyID:    job.PolicyID,
+		JobID:       job.ID,
+		Include:     request.Include,
+		Target:      request.Target,
+		Conflict:    conflict,
+		DryRun:      request.DryRun,
+		SnapshotDir: request.SnapshotDir,
+		NoSnapshot:  request.NoSnapshot,
+		Root:        s.restoreRoot,
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, result)
+}
+
+// completedBackup возвращает задачу из пути запроса с сохраненным
+// бэкапом; для незавершенной или удаленной задачи - ошибку со статусом 409
+func (s *Server) completedBackup(r *http.Request) (*types.BackupJob, error) {
+	job, err := s.authorizedJob(r)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != types.JobStatusCompleted || job.BackupPath == "" {
+		return nil, &apiError{
+			status:  http.StatusConflict,
+			message: fmt.Sprintf("бэкап задачи %s в статусе %s, ожидается completed", job.ID, job.Status),
+		}
+	}
+	return job, nil
+}
+
+// getJob GET /api/v1/jobs/{id}
+func (s *Server) getJob(w http.ResponseWriter, r *http.Request) error {
+	job, err := s.authorizedJob(r)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, job)
+}
+
+// cancelJob POST /api/v1/jobs/{id}/cancel
+//
+// Задача завершается асинхронно: статус cancelled появляется в задаче
+// и в ее потоке событий, когда выполнение остановится.
+func (s *Server) cancelJob(w http.ResponseWriter, r *http.Request) error {
+	job, err := s.authorizedJob(r)
+	if err != nil {
+		return err
+	}
+
+	var request CancelRequest
+	if r.ContentLength != 0 {
+		if err := readJSON(r, &request); err != nil {
+			return err
+		}
+	}
+
+	reason := "через API, " + requestAccess(r).identity.Subject
+	if request.Reason != "" {
+		reason = request.Reason + " (" + reason + ")"
+	}
+
+	job, err = s.service.CancelJob(r.Context(), job.ID, reason)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusAccepted, job)
+}
+
+// listAudit GET /api/v1/audit
+func (s *Server) listAudit(w http.ResponseWriter, r *http.Request) error {
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	entries, total, err := s.service.ListAudit(r.Context(), backup.AuditFilter{
+		Actor:    query.Get("actor"),
+		PolicyID: query.Get("policy_id"),
+		Action:   query.Get("action"),
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		return err
+	}
+	if entries == nil {
+		entries = []*types.AuditEntry{}
+	}
+
+	return writeJSON(w, http.StatusOK, AuditPage{Items: entries, Total: total, Limit: limit, Offset: offset})
+}
+
+// openAPI GET /api/v1/openapi.json
+func (s *Server) openAPI(w http.ResponseWriter, r *http.Request) error {
+	return writeJSON(w, http.StatusOK, s.OpenAPI())
+}
@@ -0,0 +1,231 @@
+// Package rest реализует REST API сервера бэкапов (cmd/server)
+//
+// Обработчики оборачивают backup.Service и отдают модели из pkg/types
+// в JSON. Таблица маршрутов одновременно служит источником
+// спецификации OpenAPI, которая отдается по /api/v1/openapi.json.
+//
+// Каждый запрос аутентифицируется (API-токен или JWT провайдера OIDC),
+// проверяется по роли маршрута и записывается в журнал аудита.
+package rest
+
+import (
+	"backupist/internal/core/backup"
+	"backupist/internal/logger"
+	"backupist/pkg/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Ограничения пагинации списков
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 1000
+)
+
+// Server HTTP-обработчик REST API
+type Server struct {
+	service *backup.Service
+	logger  *logger.StructuredLogger
+	mux     *http.ServeMux
+	routes  []route
+	auth    types.APIAuthConfig
+	oidc    *oidcVerifier // nil - OIDC не настроен
+
+	// Директория, внутри которой разрешено восстановление; пусто -
+	// восстановление через API отключено
+	restoreRoot string
+
+	// Контекст фоновых задач (бэкапов, запущенных через API);
+	// отменяется при закрытии сервера
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+
+	// Закрывается при остановке сервера, чтобы завершить потоки событий
+	streams     chan struct{}
+	closeStream sync.Once
+}
+
+// NewServer создает REST API сервер над сервисом бэкапа
+func NewServer(service *backup.Service, cfg types.APIServerConfig, log *logger.StructuredLogger) *Server {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	s := &Server{
+		service:     service,
+		logger:      log,
+		mux:         http.NewServeMux(),
+		auth:        cfg.Auth,
+		restoreRoot: cfg.RestoreRoot,
+		ctx:         ctx,
+		cancel:      cancel,
+		streams:     make(chan struct{}),
+	}
+	if cfg.Auth.OIDC != nil {
+		s.oidc = newOIDCVerifier(*cfg.Auth.OIDC)
+	}
+
+	s.routes = s.apiRoutes()
+	for _, rt := range s.routes {
+		s.mux.Handle(rt.method+" "+rt.path, s.handle(rt))
+	}
+
+	return s
+}
+
+// ServeHTTP обрабатывает запрос к API
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close завершает потоки событий, отменяет фоновые задачи и ждет их завершения
+//
+// Бэкапы, запущенные через API, завершаются в статусе cancelled.
+func (s *Server) Close() {
+	s.CloseStreams()
+	s.cancel(backup.CancelCause("остановка сервера"))
+	s.wg.Wait()
+}
+
+// CloseStreams завершает открытые потоки событий задач
+//
+// Потоки не завершаются сами, поэтому перед http.Server.Shutdown их
+// нужно закрыть: server.RegisterOnShutdown(api.CloseStreams).
+func (s *Server) CloseStreams() {
+	s.closeStream.Do(func() { close(s.streams) })
+}
+
+// goBackground запускает фоновую задачу в контексте сервера
+func (s *Server) goBackground(fn func(ctx context.Context)) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		fn(s.ctx)
+	}()
+}
+
+// apiError ошибка запроса с HTTP-статусом
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string { return e.message }
+
+// badRequest возвращает ошибку некорректного запроса
+func badRequest(format string, args ...any) error {
+	return &apiError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+// ErrorResponse тело ответа с ошибкой
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// handlerFunc обработчик API; ошибка преобразуется в ответ ErrorResponse
+type handlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// handle оборачивает обработчик маршрута: аутентифицирует запрос,
+// проверяет роль, пишет запись аудита и отдает ошибки в JSON со
+// статусом 400 для некорректных запросов, 404 для отсутствующих
+// объектов, 409 для действий, недопустимых в текущем статусе, статусом
+// apiError для ошибок обработчика и 500 для остальных
+func (s *Server) handle(rt route) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w}
+		acc := &access{role: rt.role}
+		r = r.WithContext(context.WithValue(r.Context(), accessKey{}, acc))
+
+		identity, err := s.authenticate(r)
+		if err == nil {
+			acc.identity = identity
+
+			// Маршруты над всеми политиками проверяются здесь, остальные -
+			// в обработчике, когда известна политика; здесь же отсекаются
+			// субъекты без подходящей роли ни на одну политику
+			switch {
+			case rt.global:
+				err = s.authorize(r, "")
+			case !identity.allowsAny(rt.role):
+				err = forbidden(rt.role, "")
+			}
+			if err == nil {
+				err = rt.handler(recorder, r)
+			}
+		}
+
+		if err != nil {
+			s.writeError(recorder, r, err)
+		}
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		s.audit(r, rt, acc, recorder.status, err)
+	})
+}
+
+// writeError отдает ошибку обработчика в JSON
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	var apiErr *apiError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.status
+	case errors.Is(err, backup.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, backup.ErrInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(err, backup.ErrConflict):
+		status = http.StatusConflict
+	}
+
+	if status == http.StatusInternalServerError {
+		s.logger.ErrorContext(r.Context(), "Ошибка обработки запроса API",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"error", err)
+	}
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="backupist"`)
+	}
+
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+// writeJSON отдает value в JSON со статусом status
+func writeJSON(w http.ResponseWriter, status int, value any) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(value)
+}
+
+// readJSON читает тело запроса в value; неизвестные поля считаются ошибкой
+func readJSON(r *http.Request, value any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		return badRequest("некорректное тело запроса: %v", err)
+	}
+	return nil
+}
+
+// pageParams читает параметры пагинации limit и offset
+func pageParams(r *http.Request) (limit, offset int, err error) {
+	limit, offset = defaultPageLimit, 0
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, 0, badRequest("параметр limit должен быть числом от 1 до %d", maxPageLimit)
+		}
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			return 0, 0, badRequest("параметр offset должен быть неотрицательным числом")
+		}
+	}
+
+	return limit, offset, nil
+}
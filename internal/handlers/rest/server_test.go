@@ -0,0 +1,785 @@
+package rest
+
+import (
+	"backupist/internal/core/backup"
+	"backupist/internal/core/config"
+	"backupist/internal/logger"
+	"backupist/pkg/types"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testAPI REST API над сервисом с временными каталогом и хранилищем
+type testAPI struct {
+	t       *testing.T
+	service *backup.Service
+	server  *httptest.Server
+	dir     string
+	token   string // API-токен с ролью admin на все политики
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	return newTestAPIWithConfig(t, types.APIServerConfig{})
+}
+
+func newTestAPIWithConfig(t *testing.T, apiConfig types.APIServerConfig) *testAPI {
+	t.Helper()
+	dir := t.TempDir()
+
+	cfg := config.NewConfig()
+	cfg.Database.Path = filepath.Join(dir, "backup.db")
+	cfg.Storage.LocalPath = filepath.Join(dir, "storage")
+	cfg.Storage.Configs = map[string]types.StorageConfig{
+		"local": {Type: types.StorageTypeLocal, LocalPath: cfg.Storage.LocalPath},
+	}
+
+	log := logger.NewStructuredLoggerWithConfig("test", &logger.LogConfig{Level: slog.LevelError})
+	service := backup.NewService(cfg, log)
+	if err := service.Initialize(context.Background()); err != nil {
+		t.Fatalf("инициализация сервиса: %v", err)
+	}
+
+	// Тесты восстанавливают файлы во временную директорию API
+	if apiConfig.RestoreRoot == "" {
+		apiConfig.RestoreRoot = dir
+	}
+	api := NewServer(service, apiConfig, log)
+	server := httptest.NewServer(api)
+	t.Cleanup(func() {
+		server.Close()
+		api.Close()
+		service.Close()
+	})
+
+	testAPI := &testAPI{t: t, service: service, server: server, dir: dir}
+	testAPI.token = testAPI.createToken("admin", "admin")
+	return testAPI
+}
+
+// createToken создает API-токен с ролями в формате role[:policy-id]
+func (a *testAPI) createToken(name string, roles ...string) string {
+	a.t.Helper()
+
+	var bindings []types.RoleBinding
+	for _, role := range roles {
+		binding, err := config.ParseRoleBinding(role)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		bindings = append(bindings, binding)
+	}
+
+	_, value, err := a.service.CreateAPIToken(context.Background(), name, bindings, 0)
+	if err != nil {
+		a.t.Fatalf("создание API-токена: %v", err)
+	}
+	return value
+}
+
+// newRequest создает запрос к API с токеном администратора
+func (a *testAPI) newRequest(method, path string, body io.Reader) *http.Request {
+	a.t.Helper()
+
+	request, err := http.NewRequest(method, a.server.URL+path, body)
+	if err != nil {
+		a.t.Fatalf("создание запроса: %v", err)
+	}
+	request.Header.Set("Authorization", "Bearer "+a.token)
+	return request
+}
+
+// do выполняет запрос с токеном администратора и декодирует JSON-ответ
+// в result, если он не nil
+func (a *testAPI) do(method, path string, body any, wantStatus int, result any) {
+	a.t.Helper()
+	a.doAs(a.token, method, path, body, wantStatus, result)
+}
+
+// doAs выполняет запрос с токеном token (пусто - без аутентификации)
+func (a *testAPI) doAs(token, method, path string, body any, wantStatus int, result any) {
+	a.t.Helper()
+
+	var reader *bytes.Reader
+	switch body := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(body))
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatalf("сериализация тела: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request := a.newRequest(method, path, reader)
+	if token != a.token {
+		request.Header.Del("Authorization")
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		a.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer response.Body.Close()
+
+	var raw bytes.Buffer
+	raw.ReadFrom(response.Body)
+	if response.StatusCode != wantStatus {
+		a.t.Fatalf("%s %s: статус %d, ожидался %d: %s", method, path, response.StatusCode, wantStatus, raw.String())
+	}
+	if result != nil {
+		if err := json.Unmarshal(raw.Bytes(), result); err != nil {
+			a.t.Fatalf("%s %s: разбор ответа: %v: %s", method, path, err, raw.String())
+		}
+	}
+}
+
+// createPolicy создает политику с исходной директорией из files
+func (a *testAPI) createPolicy(name string, files map[string]string) types.BackupPolicy {
+	a.t.Helper()
+
+	source := filepath.Join(a.dir, "src-"+name)
+	for rel, content := range files {
+		path := filepath.Join(source, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			a.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			a.t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(source, 0755); err != nil {
+		a.t.Fatal(err)
+	}
+
+	var policy types.BackupPolicy
+	a.do(http.MethodPost, "/api/v1/policies", map[string]any{
+		"name":             name,
+		"source_path":      source,
+		"destination_path": "backups",
+		"retention_count":  5,
+		"archive_enabled":  true,
+	}, http.StatusCreated, &policy)
+
+	return policy
+}
+
+// waitJob ждет завершения задачи бэкапа
+func (a *testAPI) waitJob(jobID string) types.BackupJob {
+	a.t.Helper()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		var job types.BackupJob
+		a.do(http.MethodGet, "/api/v1/jobs/"+jobID, nil, http.StatusOK, &job)
+		if job.Status != types.JobStatusPending && job.Status != types.JobStatusRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			a.t.Fatalf("задача %s не завершилась, статус %s", jobID, job.Status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	api := newTestAPI(t)
+
+	var created []types.BackupPolicy
+	for i := range 3 {
+		created = append(created, api.createPolicy(fmt.Sprintf("policy-%d", i), nil))
+	}
+	if created[0].ID == "" || created[0].CreatedAt.IsZero() {
+		t.Fatalf("у созданной политики нет ID или времени создания: %+v", created[0])
+	}
+
+	var page PolicyPage
+	api.do(http.MethodGet, "/api/v1/policies?limit=2", nil, http.StatusOK, &page)
+	if page.Total != 3 || len(page.Items) != 2 || page.Limit != 2 {
+		t.Fatalf("первая страница: total %d, items %d, limit %d", page.Total, len(page.Items), page.Limit)
+	}
+	api.do(http.MethodGet, "/api/v1/policies?limit=2&offset=2", nil, http.StatusOK, &page)
+	if page.Total != 3 || len(page.Items) != 1 {
+		t.Fatalf("вторая страница: total %d, items %d", page.Total, len(page.Items))
+	}
+	api.do(http.MethodGet, "/api/v1/policies?offset=10", nil, http.StatusOK, &page)
+	if page.Items == nil || len(page.Items) != 0 {
+		t.Fatalf("страница за концом списка должна быть пустой: %+v", page.Items)
+	}
+
+	var policy types.BackupPolicy
+	api.do(http.MethodGet, "/api/v1/policies/"+created[1].ID, nil, http.StatusOK, &policy)
+	if policy.Name != "policy-1" {
+		t.Fatalf("получена политика %q, ожидалась policy-1", policy.Name)
+	}
+
+	var apiErr ErrorResponse
+	api.do(http.MethodGet, "/api/v1/policies/unknown", nil, http.StatusNotFound, &apiErr)
+	if apiErr.Error == "" {
+		t.Fatal("нет текста ошибки в ответе 404")
+	}
+
+	api.do(http.MethodDelete, "/api/v1/policies/"+created[1].ID, nil, http.StatusNoContent, nil)
+	api.do(http.MethodGet, "/api/v1/policies/"+created[1].ID, nil, http.StatusNotFound, nil)
+	api.do(http.MethodDelete, "/api/v1/policies/"+created[1].ID, nil, http.StatusNotFound, nil)
+}
+
+func TestCreatePolicyValidation(t *testing.T) {
+	api := newTestAPI(t)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"некорректный JSON", `{"name":`},
+		{"неизвестное поле", `{"name":"p","unknown":1}`},
+		{"нет исходной директории", `{"name":"p","source_path":"/nonexistent/dir","destination_path":"b","retention_count":1}`},
+		{"нет имени", fmt.Sprintf(`{"source_path":%q,"destination_path":"b","retention_count":1}`, api.dir)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var apiErr ErrorResponse
+			api.do(http.MethodPost, "/api/v1/policies", tt.body, http.StatusBadRequest, &apiErr)
+			if apiErr.Error == "" {
+				t.Fatal("нет текста ошибки в ответе 400")
+			}
+		})
+	}
+}
+
+func TestPolicyPasswordNotExposed(t *testing.T) {
+	api := newTestAPI(t)
+
+	var policy map[string]any
+	api.do(http.MethodPost, "/api/v1/policies", map[string]any{
+		"name":                "secret",
+		"source_path":         api.dir,
+		"destination_path":    "backups",
+		"retention_count":     1,
+		"archive_enabled":     true,
+		"encryption_enabled":  true,
+		"encryption_password": "correct horse battery staple",
+	}, http.StatusCreated, &policy)
+
+	for _, path := range []string{"/api/v1/policies/" + policy["id"].(string), "/api/v1/policies"} {
+		response, err := http.DefaultClient.Do(api.newRequest(http.MethodGet, path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body bytes.Buffer
+		body.ReadFrom(response.Body)
+		response.Body.Close()
+		if strings.Contains(body.String(), "correct horse") {
+			t.Fatalf("%s: пароль шифрования в ответе: %s", path, body.String())
+		}
+	}
+}
+
+func TestBackupLifecycle(t *testing.T) {
+	api := newTestAPI(t)
+	policy := api.createPolicy("docs", map[string]string{
+		"a.txt":     "alpha",
+		"sub/b.txt": "bravo",
+	})
+
+	var job types.BackupJob
+	api.do(http.MethodPost, "/api/v1/backups", BackupRequest{PolicyID: policy.ID}, http.StatusAccepted, &job)
+	if job.ID == "" || job.PolicyID != policy.ID {
+		t.Fatalf("некорректная задача: %+v", job)
+	}
+	if job = api.waitJob(job.ID); job.Status != types.JobStatusCompleted {
+		t.Fatalf("бэкап завершился со статусом %s: %s", job.Status, job.Error)
+	}
+
+	var backups JobPage
+	api.do(http.MethodGet, "/api/v1/backups?policy_id="+policy.ID, nil, http.StatusOK, &backups)
+	if backups.Total != 1 || len(backups.Items) != 1 || backups.Items[0].ID != job.ID {
+		t.Fatalf("список бэкапов: %+v", backups)
+	}
+
+	var details BackupDetails
+	api.do(http.MethodGet, "/api/v1/backups/"+job.ID, nil, http.StatusOK, &details)
+	if details.Result == nil || details.Result.FilesProcessed != 2 || details.Result.Checksum == "" {
+		t.Fatalf("результат бэкапа: %+v", details.Result)
+	}
+
+	target := filepath.Join(api.dir, "restore")
+
+	var dryRun backup.RestoreResult
+	api.do(http.MethodPost, "/api/v1/backups/"+job.ID+"/restore", RestoreRequest{Target: target, DryRun: true}, http.StatusOK, &dryRun)
+	if dryRun.Files != 2 || len(dryRun.Changes) != 2 {
+		t.Fatalf("пробное восстановление: %+v", dryRun)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("пробное восстановление создало директорию %s", target)
+	}
+
+	var restored backup.RestoreResult
+	api.do(http.MethodPost, "/api/v1/backups/"+job.ID+"/restore", RestoreRequest{Target: target, Include: []string{"sub/**"}}, http.StatusOK, &restored)
+	if restored.Files != 1 {
+		t.Fatalf("восстановлено файлов: %d, ожидался 1", restored.Files)
+	}
+	if data, err := os.ReadFile(filepath.Join(target, "sub", "b.txt")); err != nil || string(data) != "bravo" {
+		t.Fatalf("восстановленный файл: %q, %v", data, err)
+	}
+
+	api.do(http.MethodPost, "/api/v1/backups/"+job.ID+"/restore", RestoreRequest{Target: target, Conflict: "merge"}, http.StatusBadRequest, nil)
+	api.do(http.MethodPost, "/api/v1/backups/"+job.ID+"/restore", RestoreRequest{}, http.StatusBadRequest, nil)
+
+	api.do(http.MethodDelete, "/api/v1/backups/"+job.ID, nil, http.StatusNoContent, nil)
+	api.do(http.MethodGet, "/api/v1/backups?policy_id="+policy.ID, nil, http.StatusOK, &backups)
+	if backups.Total != 0 {
+		t.Fatalf("удаленный бэкап остался в списке: %+v", backups)
+	}
+	api.do(http.MethodGet, "/api/v1/backups?status=deleted", nil, http.StatusOK, &backups)
+	if backups.Total != 1 {
+		t.Fatalf("удаленный бэкап не найден по статусу: %+v", backups)
+	}
+	api.do(http.MethodDelete, "/api/v1/backups/"+job.ID, nil, http.StatusConflict, nil)
+	api.do(http.MethodPost, "/api/v1/backups/"+job.ID+"/restore", RestoreRequest{Target: target}, http.StatusConflict, nil)
+}
+
+func TestRestoreConfinedToRoot(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "restore-root")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	api := newTestAPIWithConfig(t, types.APIServerConfig{RestoreRoot: root})
+	policy := api.createPolicy("confined", map[string]string{"a.txt": "alpha"})
+
+	var job types.BackupJob
+	api.do(http.MethodPost, "/api/v1/backups", BackupRequest{PolicyID: policy.ID}, http.StatusAccepted, &job)
+	if job = api.waitJob(job.ID); job.Status != types.JobStatusCompleted {
+		t.Fatalf("бэкап завершился со статусом %s: %s", job.Status, job.Error)
+	}
+	restorePath := "/api/v1/backups/" + job.ID + "/restore"
+
+	// Пути вне корня, в том числе через .. и символическую ссылку
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	for _, request := range []RestoreRequest{
+		{Target: outside},
+		{Target: "../escape"},
+		{Target: "link/restore"},
+		{Target: "restore", SnapshotDir: outside},
+		{Target: root},
+	} {
+		api.do(http.MethodPost, restorePath, request, http.StatusBadRequest, nil)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Fatalf("за пределами корня восстановления записаны файлы: %v", entries)
+	}
+
+	// Относительный путь считается от корня
+	var restored backup.RestoreResult
+	api.do(http.MethodPost, restorePath, RestoreRequest{Target: "restore"}, http.StatusOK, &restored)
+	if data, err := os.ReadFile(filepath.Join(root, "restore", "a.txt")); err != nil || string(data) != "alpha" {
+		t.Fatalf("восстановленный файл: %q, %v", data, err)
+	}
+
+	// Без корня восстановление через API отключено
+	log := logger.NewStructuredLoggerWithConfig("test", &logger.LogConfig{Level: slog.LevelError})
+	disabled := NewServer(api.service, types.APIServerConfig{Auth: types.APIAuthConfig{Disabled: true}}, log)
+	t.Cleanup(disabled.Close)
+	body, _ := json.Marshal(RestoreRequest{Target: "restore"})
+	recorder := httptest.NewRecorder()
+	disabled.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, restorePath, bytes.NewReader(body)))
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("восстановление без api.restore_root: статус %d", recorder.Code)
+	}
+}
+
+func TestBackupStorageRecordedOnJob(t *testing.T) {
+	api := newTestAPI(t)
+	source := filepath.Join(api.dir, "src-elsewhere")
+	if err := os.MkdirAll(source, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "a.txt"), []byte("alpha"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	destination := filepath.Join(api.dir, "elsewhere")
+
+	var policy types.BackupPolicy
+	api.do(http.MethodPost, "/api/v1/policies", map[string]any{
+		"name":             "elsewhere",
+		"source_path":      source,
+		"destination_path": destination,
+		"retention_count":  5,
+		"archive_enabled":  true,
+	}, http.StatusCreated, &policy)
+
+	var job types.BackupJob
+	api.do(http.MethodPost, "/api/v1/backups", BackupRequest{PolicyID: policy.ID}, http.StatusAccepted, &job)
+	if job = api.waitJob(job.ID); job.Status != types.JobStatusCompleted || job.Destination != destination {
+		t.Fatalf("задача бэкапа: %+v", job)
+	}
+	if _, err := os.Stat(filepath.Join("/", job.BackupPath)); err != nil {
+		t.Fatalf("файл бэкапа в назначении: %v", err)
+	}
+
+	// После смены назначения политики бэкап удаляется из хранилища,
+	// записанного в задачу при загрузке
+	db, err := sql.Open("sqlite3", filepath.Join(api.dir, "backup.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`UPDATE backup_policies SET destination_path = ? WHERE id = ?`, "backups", policy.ID); err != nil {
+		t.Fatal(err)
+	}
+	api.do(http.MethodDelete, "/api/v1/backups/"+job.ID, nil, http.StatusNoContent, nil)
+	if _, err := os.Stat(filepath.Join("/", job.BackupPath)); !os.IsNotExist(err) {
+		t.Fatalf("файл бэкапа остался в назначении: %v", err)
+	}
+}
+
+func TestCreateBackupErrors(t *testing.T) {
+	api := newTestAPI(t)
+
+	api.do(http.MethodPost, "/api/v1/backups", BackupRequest{}, http.StatusBadRequest, nil)
+	api.do(http.MethodPost, "/api/v1/backups", BackupRequest{PolicyID: "unknown"}, http.StatusNotFound, nil)
+	api.do(http.MethodGet, "/api/v1/backups/unknown", nil, http.StatusNotFound, nil)
+	api.do(http.MethodGet, "/api/v1/jobs/unknown", nil, http.StatusNotFound, nil)
+}
+
+func TestJobsFilterAndPagination(t *testing.T) {
+	api := newTestAPI(t)
+	first := api.createPolicy("first", map[string]string{"f.txt": "1"})
+	second := api.createPolicy("second", map[string]string{"s.txt": "2"})
+
+	for _, policyID := range []string{first.ID, first.ID, second.ID} {
+		var job types.BackupJob
+		api.do(http.MethodPost, "/api/v1/backups", BackupRequest{PolicyID: policyID}, http.StatusAccepted, &job)
+		api.waitJob(job.ID)
+	}
+
+	var page JobPage
+	api.do(http.MethodGet, "/api/v1/jobs", nil, http.StatusOK, &page)
+	if page.Total != 3 || page.Limit != defaultPageLimit {
+		t.Fatalf("все задачи: total %d, limit %d", page.Total, page.Limit)
+	}
+	api.do(http.MethodGet, "/api/v1/jobs?policy_id="+first.ID+"&limit=1", nil, http.StatusOK, &page)
+	if page.Total != 2 || len(page.Items) != 1 || page.Items[0].PolicyID != first.ID {
+		t.Fatalf("задачи политики: %+v", page)
+	}
+	newest := page.Items[0].ID
+	api.do(http.MethodGet, "/api/v1/jobs?policy_id="+first.ID+"&limit=1&offset=1", nil, http.StatusOK, &page)
+	if len(page.Items) != 1 || page.Items[0].ID == newest {
+		t.Fatalf("вторая страница задач политики: %+v", page)
+	}
+	api.do(http.MethodGet, "/api/v1/jobs?status=failed", nil, http.StatusOK, &page)
+	if page.Total != 0 {
+		t.Fatalf("неожиданные задачи со статусом failed: %+v", page)
+	}
+
+	for _, query := range []string{"status=unknown", "limit=0", "limit=abc", "limit=1001", "offset=-1"} {
+		api.do(http.MethodGet, "/api/v1/jobs?"+query, nil, http.StatusBadRequest, nil)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	api := newTestAPI(t)
+
+	var spec struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	api.do(http.MethodGet, "/api/v1/openapi.json", nil, http.StatusOK, &spec)
+
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Fatalf("версия спецификации %q", spec.OpenAPI)
+	}
+
+	// Каждый маршрут описан в спецификации
+	server := &Server{}
+	for _, rt := range server.apiRoutes() {
+		operation, ok := spec.Paths[rt.path][strings.ToLower(rt.method)]
+		if !ok {
+			t.Errorf("в спецификации нет %s %s", rt.method, rt.path)
+			continue
+		}
+		responses, _ := operation["responses"].(map[string]any)
+		if _, ok := responses[fmt.Sprint(rt.status)]; !ok {
+			t.Errorf("%s %s: нет ответа %d", rt.method, rt.path, rt.status)
+		}
+	}
+
+	// Все ссылки на схемы разрешаются
+	data, _ := json.Marshal(spec)
+	for _, ref := range strings.Split(string(data), `"$ref":"#/components/schemas/`)[1:] {
+		name, _, _ := strings.Cut(ref, `"`)
+		if _, ok := spec.Components.Schemas[name]; !ok {
+			t.Errorf("схема %s не описана в components", name)
+		}
+	}
+
+	// Пароль принимается в запросе создания политики, но не отдается в модели политики
+	if _, ok := spec.Components.Schemas["PolicyRequest"]["properties"].(map[string]any)["encryption_password"]; !ok {
+		t.Error("в PolicyRequest нет encryption_password")
+	}
+	if _, ok := spec.Components.Schemas["BackupPolicy"]["properties"].(map[string]any)["encryption_password"]; ok {
+		t.Error("в BackupPolicy есть encryption_password")
+	}
+}
+
+// readEvents читает поток событий задачи до его закрытия сервером
+func (a *testAPI) readEvents(jobID string) []types.JobEvent {
+	a.t.Helper()
+
+	response, err := http.DefaultClient.Do(a.newRequest(http.MethodGet, "/api/v1/jobs/"+jobID+"/events", nil))
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		a.t.Fatalf("поток событий: статус %d", response.StatusCode)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != eventStreamType {
+		a.t.Fatalf("поток событий: Content-Type %q", contentType)
+	}
+
+	var events []types.JobEvent
+	var name string
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			var event types.JobEvent
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				a.t.Fatalf("разбор события: %v: %s", err, line)
+			}
+			if string(event.Type) != name {
+				a.t.Fatalf("тип события %q не совпадает с полем event %q", event.Type, name)
+			}
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		a.t.Fatalf("чтение потока событий: %v", err)
+	}
+
+	return events
+}
+
+func TestJobEvents(t *testing.T) {
+	api := newTestAPI(t)
+	policy := api.createPolicy("events", map[string]string{
+		"a.txt":     strings.Repeat("a", 1<<20),
+		"sub/b.txt": "bravo",
+	})
+
+	var job types.BackupJob
+	api.do(http.MethodPost, "/api/v1/backups", BackupRequest{PolicyID: policy.ID}, http.StatusAccepted, &job)
+
+	// Поток закрывается сервером после завершения задачи
+	events := api.readEvents(job.ID)
+	if len(events) == 0 {
+		t.Fatal("нет событий задачи")
+	}
+	last := events[len(events)-1]
+	if last.Type != types.JobEventStatus || last.Status != types.JobStatusCompleted {
+		t.Fatalf("последнее событие: %+v", last)
+	}
+	for _, event := range events {
+		if event.JobID != job.ID || event.PolicyID != policy.ID {
+			t.Fatalf("событие другой задачи: %+v", event)
+		}
+		if event.Type == types.JobEventProgress && (event.Progress == nil || event.Progress.Phase == "") {
+			t.Fatalf("событие прогресса без этапа: %+v", event)
+		}
+	}
+
+	// Для завершенной задачи отдается только ее статус
+	events = api.readEvents(job.ID)
+	if len(events) != 1 || events[0].Status != types.JobStatusCompleted {
+		t.Fatalf("события завершенной задачи: %+v", events)
+	}
+
+	api.do(http.MethodGet, "/api/v1/jobs/unknown/events", nil, http.StatusNotFound, nil)
+}
+
+func TestJobEventsProgress(t *testing.T) {
+	api := newTestAPI(t)
+	policy := api.createPolicy("progress", map[string]string{
+		"a.txt": "alpha",
+		"b.txt": "bravo",
+	})
+
+	job, err := api.service.CreatePolicyJob(context.Background(), policy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Подписка до запуска: приходят все этапы по порядку
+	events, unsubscribe := api.service.Events().Subscribe(job.ID)
+	defer unsubscribe()
+	if _, err := api.service.ExecuteBackup(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+
+	var phases []types.ProgressPhase
+	for event := range events {
+		if event.Type == types.JobEventStatus && backup.JobFinished(event.Status) {
+			break
+		}
+		if event.Type == types.JobEventProgress && (len(phases) == 0 || phases[len(phases)-1] != event.Progress.Phase) {
+			phases = append(phases, event.Progress.Phase)
+		}
+	}
+
+	want := []types.ProgressPhase{
+		types.ProgressPhaseScan,
+		types.ProgressPhaseCopy,
+		types.ProgressPhaseArchive,
+		types.ProgressPhaseUpload,
+		types.ProgressPhaseRetention,
+	}
+	if fmt.Sprint(phases) != fmt.Sprint(want) {
+		t.Fatalf("этапы %v, ожидались %v", phases, want)
+	}
+}
+
+// slowPolicy создает политику, бэкап которой загружается в хранилище
+// несколько секунд: несжимаемые данные и ограничение скорости
+func (a *testAPI) slowPolicy(name string, size int, overlap types.OverlapMode) types.BackupPolicy {
+	a.t.Helper()
+
+	data := make([]byte, size)
+	rand.Read(data)
+	source := filepath.Join(a.dir, "src-"+name)
+	if err := os.MkdirAll(source, 0755); err != nil {
+		a.t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "data.bin"), data, 0644); err != nil {
+		a.t.Fatal(err)
+	}
+
+	var policy types.BackupPolicy
+	a.do(http.MethodPost, "/api/v1/policies", map[string]any{
+		"name":             name,
+		"source_path":      source,
+		"destination_path": "backups-" + name,
+		"retention_count":  1,
+		"archive_enabled":  true,
+		"bandwidth":        map[string]any{"upload_rate": "256KB/s"},
+		"overlap":          overlap,
+	}, http.StatusCreated, &policy)
+	return policy
+}
+
+// waitUpload ждет начала загрузки бэкапа в хранилище по событиям задачи
+func (a *testAPI) waitUpload(events <-chan types.JobEvent) {
+	a.t.Helper()
+
+	for event := range events {
+		if event.Type == types.JobEventStatus && backup.JobFinished(event.Status) {
+			a.t.Fatalf("бэкап завершился до начала загрузки: %+v", event)
+		}
+		if event.Type == types.JobEventProgress && event.Progress.Phase == types.ProgressPhaseUpload && event.Progress.BytesProcessed > 0 {
+			return
+		}
+	}
+}
+
+// startBackup запускает бэкап задачи в отдельной горутине
+func (a *testAPI) startBackup(job *types.BackupJob) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := a.service.ExecuteBackup(context.Background(), job)
+		done <- err
+	}()
+	return done
+}
+
+func TestCancelJob(t *testing.T) {
+	api := newTestAPI(t)
+	policy := api.slowPolicy("cancel", 1<<20, "")
+
+	job, err := api.service.CreatePolicyJob(context.Background(), policy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, unsubscribe := api.service.Events().Subscribe(job.ID)
+	defer unsubscribe()
+
+	done := api.startBackup(job)
+	api.waitUpload(events)
+	api.do(http.MethodPost, "/api/v1/jobs/"+job.ID+"/cancel", CancelRequest{Reason: "тест"}, http.StatusAccepted, nil)
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, backup.ErrCancelled) {
+			t.Fatalf("ошибка отмененного бэкапа: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("бэкап не остановился после отмены")
+	}
+
+	var cancelled types.BackupJob
+	api.do(http.MethodGet, "/api/v1/jobs/"+job.ID, nil, http.StatusOK, &cancelled)
+	if cancelled.Status != types.JobStatusCancelled || !strings.Contains(cancelled.Error, "тест (через API, admin)") {
+		t.Fatalf("отмененная задача: %+v", cancelled)
+	}
+
+	// Частично загруженный бэкап удален из хранилища
+	filepath.WalkDir(filepath.Join(api.dir, "storage"), func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			t.Errorf("в хранилище остался файл %s", path)
+		}
+		return nil
+	})
+
+	api.do(http.MethodPost, "/api/v1/jobs/"+job.ID+"/cancel", nil, http.StatusConflict, nil)
+	api.do(http.MethodPost, "/api/v1/jobs/unknown/cancel", nil, http.StatusNotFound, nil)
+}
+
+func TestCancelPendingJob(t *testing.T) {
+	api := newTestAPI(t)
+	policy := api.createPolicy("pending", map[string]string{"a.txt": "alpha"})
+
+	job, err := api.service.CreatePolicyJob(context.Background(), policy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cancelled types.BackupJob
+	api.do(http.MethodPost, "/api/v1/jobs/"+job.ID+"/cancel", nil, http.StatusAccepted, &cancelled)
+	if cancelled.Status != types.JobStatusCancelled {
+		t.Fatalf("задача до запуска не отменена: %+v", cancelled)
+	}
+
+	// Запуск отмененной задачи сразу завершается отменой
+	if _, err := api.service.ExecuteBackup(context.Background(), job); !errors.Is(err, backup.ErrCancelled) {
+		t.Fatalf("запуск отмененной задачи: %v", err)
+	}
+}
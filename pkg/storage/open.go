@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrOpenUnsupported хранилище не может отдать объект потоком
+var ErrOpenUnsupported = errors.New("хранилище не поддерживает чтение объектов потоком")
+
+// Opener необязательный интерфейс провайдера для чтения объекта потоком
+//
+// Позволяет обрабатывать бэкап по мере скачивания, не сохраняя его
+// целиком на диск. Обертки над провайдерами возвращают ошибку,
+// обернутую вокруг ErrOpenUnsupported, если обернутый провайдер
+// не реализует Opener; такие объекты скачиваются через Download
+// во временный файл.
+type Opener interface {
+	Open(ctx context.Context, remotePath string) (io.ReadCloser, error)
+}
@@ -0,0 +1,443 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Протокол внешних плагинов хранилищ
+//
+// Плагин — исполняемый файл, который читает запросы JSON-RPC 2.0 из stdin
+// и пишет ответы в stdout, по одному JSON объекту на строку. Диагностика
+// пишется в stderr. Запросы могут выполняться параллельно, ответы
+// сопоставляются по id.
+//
+//	initialize {"url": "scheme://host", "options": {...}}  -> null
+//	upload     {"local_path": "...", "remote_path": "..."} -> null
+//	download   {"remote_path": "...", "local_path": "..."} -> null
+//	delete     {"remote_path": "..."}                      -> null
+//	list       {"prefix": "..."}                           -> {"files": ["..."]}
+//	exists     {"remote_path": "..."}                      -> {"exists": true}
+//	shutdown   null                                        -> null
+//
+// При отмене операции отправляется уведомление (без id)
+// cancel {"id": N}; плагин может его игнорировать.
+// Ошибки возвращаются в поле error: {"code": 1, "message": "..."}.
+const (
+	PluginMethodInitialize = "initialize"
+	PluginMethodUpload     = "upload"
+	PluginMethodDownload   = "download"
+	PluginMethodDelete     = "delete"
+	PluginMethodList       = "list"
+	PluginMethodExists     = "exists"
+	PluginMethodShutdown   = "shutdown"
+	PluginMethodCancel     = "cancel"
+)
+
+// pluginShutdownTimeout время ожидания завершения плагина после shutdown;
+// процесс, не завершившийся за это время, принудительно останавливается
+var pluginShutdownTimeout = 10 * time.Second
+
+// Коды ошибок протокола плагинов
+const (
+	PluginErrorParse          = -32700
+	PluginErrorMethodNotFound = -32601
+	PluginErrorInvalidParams  = -32602
+	PluginErrorOperation      = 1
+)
+
+// PluginParams параметры запроса плагина
+type PluginParams struct {
+	URL        string            `json:"url,omitempty"`
+	Options    map[string]string `json:"options,omitempty"`
+	LocalPath  string            `json:"local_path,omitempty"`
+	RemotePath string            `json:"remote_path,omitempty"`
+	Prefix     string            `json:"prefix,omitempty"`
+	ID         uint64            `json:"id,omitempty"`
+}
+
+// PluginResult результат запроса плагина
+type PluginResult struct {
+	Files  []string `json:"files,omitempty"`
+	Exists bool     `json:"exists,omitempty"`
+}
+
+// ErrPluginExited процесс плагина завершился; операции с ним больше невозможны
+var ErrPluginExited = errors.New("процесс плагина завершился")
+
+// PluginError ошибка, возвращенная плагином
+type PluginError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *PluginError) Error() string {
+	return fmt.Sprintf("ошибка плагина (%d): %s", e.Code, e.Message)
+}
+
+type pluginRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      *uint64       `json:"id,omitempty"`
+	Method  string        `json:"method"`
+	Params  *PluginParams `json:"params,omitempty"`
+}
+
+type pluginResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *PluginError    `json:"error,omitempty"`
+}
+
+// PluginProvider провайдер хранения, выполняющий операции во внешнем процессе
+type PluginProvider struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan pluginResponse
+	exited  chan struct{}
+	exitErr error
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// StartPlugin запускает процесс плагина и инициализирует его
+func StartPlugin(ctx context.Context, command string, args []string, env []string, cfg Config) (*PluginProvider, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания stdin плагина: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания stdout плагина: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ошибка запуска плагина %s: %w", command, err)
+	}
+
+	plugin := &PluginProvider{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[uint64]chan pluginResponse),
+		exited:  make(chan struct{}),
+	}
+	go plugin.readResponses(stdout)
+
+	params := &PluginParams{Options: cfg.Options}
+	if cfg.URL != nil {
+		params.URL = cfg.URL.String()
+	}
+	if _, err := plugin.call(ctx, PluginMethodInitialize, params); err != nil {
+		plugin.Close()
+		return nil, fmt.Errorf("ошибка инициализации плагина %s: %w", command, err)
+	}
+
+	return plugin, nil
+}
+
+// readResponses читает ответы плагина и передает их ожидающим запросам
+func (p *PluginProvider) readResponses(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		var resp pluginResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			continue
+		}
+
+		p.mu.Lock()
+		ch, ok := p.pending[resp.ID]
+		delete(p.pending, resp.ID)
+		p.mu.Unlock()
+
+		if ok {
+			ch <- resp
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+
+	p.mu.Lock()
+	p.exitErr = fmt.Errorf("%w: %w", ErrPluginExited, err)
+	p.mu.Unlock()
+	close(p.exited)
+}
+
+// call отправляет запрос плагину и ожидает ответ
+func (p *PluginProvider) call(ctx context.Context, method string, params *PluginParams) (*PluginResult, error) {
+	ch := make(chan pluginResponse, 1)
+
+	p.mu.Lock()
+	if p.exitErr != nil {
+		err := p.exitErr
+		p.mu.Unlock()
+		return nil, err
+	}
+	p.nextID++
+	id := p.nextID
+	p.pending[id] = ch
+	p.mu.Unlock()
+
+	if err := p.send(pluginRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		p.forget(id)
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		result := &PluginResult{}
+		if len(resp.Result) > 0 && string(resp.Result) != "null" {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return nil, fmt.Errorf("неверный ответ плагина: %w", err)
+			}
+		}
+		return result, nil
+	case <-p.exited:
+		p.forget(id)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return nil, p.exitErr
+	case <-ctx.Done():
+		p.forget(id)
+		p.send(pluginRequest{JSONRPC: "2.0", Method: PluginMethodCancel, Params: &PluginParams{ID: id}})
+		return nil, ctx.Err()
+	}
+}
+
+// send записывает запрос в stdin плагина
+func (p *PluginProvider) send(req pluginRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("ошибка кодирования запроса плагина: %w", err)
+	}
+
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	if _, err := p.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("ошибка отправки запроса плагину: %w", err)
+	}
+	return nil
+}
+
+func (p *PluginProvider) forget(id uint64) {
+	p.mu.Lock()
+	delete(p.pending, id)
+	p.mu.Unlock()
+}
+
+func (p *PluginProvider) Upload(ctx context.Context, localPath, remotePath string) error {
+	_, err := p.call(ctx, PluginMethodUpload, &PluginParams{LocalPath: localPath, RemotePath: remotePath})
+	return err
+}
+
+func (p *PluginProvider) Download(ctx context.Context, remotePath, localPath string) error {
+	_, err := p.call(ctx, PluginMethodDownload, &PluginParams{RemotePath: remotePath, LocalPath: localPath})
+	return err
+}
+
+func (p *PluginProvider) Delete(ctx context.Context, remotePath string) error {
+	_, err := p.call(ctx, PluginMethodDelete, &PluginParams{RemotePath: remotePath})
+	return err
+}
+
+func (p *PluginProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	result, err := p.call(ctx, PluginMethodList, &PluginParams{Prefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+	return result.Files, nil
+}
+
+func (p *PluginProvider) Exists(ctx context.Context, remotePath string) (bool, error) {
+	result, err := p.call(ctx, PluginMethodExists, &PluginParams{RemotePath: remotePath})
+	if err != nil {
+		return false, err
+	}
+	return result.Exists, nil
+}
+
+// Close завершает работу плагина и ожидает завершения процесса
+//
+// Если процесс не завершился за pluginShutdownTimeout после запроса
+// shutdown, он принудительно останавливается. Повторные вызовы
+// возвращают результат первого.
+func (p *PluginProvider) Close() error {
+	p.closeOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), pluginShutdownTimeout)
+		defer cancel()
+
+		select {
+		case <-p.exited:
+		default:
+			p.call(ctx, PluginMethodShutdown, nil)
+		}
+		p.stdin.Close()
+
+		// Wait вызывается после чтения stdout, если плагин успел его закрыть
+		select {
+		case <-p.exited:
+		case <-ctx.Done():
+		}
+		waited := make(chan error, 1)
+		go func() { waited <- p.cmd.Wait() }()
+
+		select {
+		case p.closeErr = <-waited:
+		case <-ctx.Done():
+			p.cmd.Process.Kill()
+			p.closeErr = <-waited
+		}
+	})
+	return p.closeErr
+}
+
+// ServePlugin обслуживает протокол плагина поверх r и w
+//
+// Используется для написания плагинов на Go, которые не линкуются
+// в основное приложение. Провайдер создается функцией open при запросе
+// initialize. Возвращает nil после запроса shutdown или закрытия r.
+func ServePlugin(r io.Reader, w io.Writer, open func(ctx context.Context, cfg Config) (Provider, error)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		provider Provider
+		writeMu  sync.Mutex
+		wg       sync.WaitGroup
+		calls    sync.Map
+	)
+	defer wg.Wait()
+
+	reply := func(id uint64, result any, err error) {
+		resp := map[string]any{"jsonrpc": "2.0", "id": id}
+		var pluginErr *PluginError
+		switch {
+		case errors.As(err, &pluginErr):
+			resp["error"] = pluginErr
+		case err != nil:
+			resp["error"] = &PluginError{Code: PluginErrorOperation, Message: err.Error()}
+		default:
+			resp["result"] = result
+		}
+
+		data, _ := json.Marshal(resp)
+		writeMu.Lock()
+		w.Write(append(data, '\n'))
+		writeMu.Unlock()
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		var req pluginRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			reply(0, nil, &PluginError{Code: PluginErrorParse, Message: err.Error()})
+			continue
+		}
+
+		params := req.Params
+		if params == nil {
+			params = &PluginParams{}
+		}
+
+		if req.ID == nil {
+			if req.Method == PluginMethodCancel {
+				if cancelCall, ok := calls.Load(params.ID); ok {
+					cancelCall.(context.CancelFunc)()
+				}
+			}
+			continue
+		}
+		id := *req.ID
+
+		switch req.Method {
+		case PluginMethodInitialize:
+			cfg := Config{Options: params.Options}
+			if params.URL != "" {
+				u, err := url.Parse(params.URL)
+				if err != nil {
+					reply(id, nil, &PluginError{Code: PluginErrorInvalidParams, Message: err.Error()})
+					continue
+				}
+				cfg.URL = u
+			}
+			p, err := open(ctx, cfg)
+			if err == nil {
+				provider = p
+			}
+			reply(id, nil, err)
+			continue
+		case PluginMethodShutdown:
+			wg.Wait()
+			reply(id, nil, nil)
+			return nil
+		}
+
+		if provider == nil {
+			reply(id, nil, &PluginError{Code: PluginErrorOperation, Message: "плагин не инициализирован"})
+			continue
+		}
+
+		callCtx, cancelCall := context.WithCancel(ctx)
+		calls.Store(id, cancelCall)
+
+		wg.Add(1)
+		go func(method string, id uint64) {
+			defer wg.Done()
+			defer calls.Delete(id)
+			defer cancelCall()
+
+			result, err := servePluginCall(callCtx, provider, method, params)
+			reply(id, result, err)
+		}(req.Method, id)
+	}
+
+	return scanner.Err()
+}
+
+// servePluginCall выполняет операцию хранилища для запроса плагина
+func servePluginCall(ctx context.Context, provider Provider, method string, params *PluginParams) (any, error) {
+	switch method {
+	case PluginMethodUpload:
+		return nil, provider.Upload(ctx, params.LocalPath, params.RemotePath)
+	case PluginMethodDownload:
+		return nil, provider.Download(ctx, params.RemotePath, params.LocalPath)
+	case PluginMethodDelete:
+		return nil, provider.Delete(ctx, params.RemotePath)
+	case PluginMethodList:
+		files, err := provider.List(ctx, params.Prefix)
+		return &PluginResult{Files: files}, err
+	case PluginMethodExists:
+		exists, err := provider.Exists(ctx, params.RemotePath)
+		return &PluginResult{Exists: exists}, err
+	default:
+		return nil, &PluginError{Code: PluginErrorMethodNotFound, Message: "неизвестный метод: " + method}
+	}
+}
@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testPluginEnv переменная окружения, в которой тестовый бинарник
+// получает режим работы плагина
+const testPluginEnv = "BACKUPIST_TEST_PLUGIN"
+
+// TestMain позволяет запускать тестовый бинарник как процесс плагина
+func TestMain(m *testing.M) {
+	switch os.Getenv(testPluginEnv) {
+	case "":
+		os.Exit(m.Run())
+	case "hang":
+		// Отвечает на initialize и больше ничего не читает
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Scan()
+		var req pluginRequest
+		json.Unmarshal(scanner.Bytes(), &req)
+		fmt.Printf("{\"jsonrpc\":\"2.0\",\"id\":%d,\"result\":null}\n", *req.ID)
+		time.Sleep(time.Hour)
+	default:
+		err := ServePlugin(os.Stdin, os.Stdout, func(ctx context.Context, cfg Config) (Provider, error) {
+			return newMemoryProvider(), nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+}
+
+// memoryProvider хранилище в памяти процесса плагина
+//
+// Загрузка объекта с префиксом slow/ длится, пока операцию не отменят.
+type memoryProvider struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newMemoryProvider() *memoryProvider {
+	return &memoryProvider{objects: make(map[string][]byte)}
+}
+
+func (m *memoryProvider) Upload(ctx context.Context, localPath, remotePath string) error {
+	if strings.HasPrefix(remotePath, "slow/") {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[remotePath] = data
+	return nil
+}
+
+func (m *memoryProvider) Download(ctx context.Context, remotePath, localPath string) error {
+	m.mu.Lock()
+	data, ok := m.objects[remotePath]
+	m.mu.Unlock()
+	if !ok {
+		return errors.New("объект не найден: " + remotePath)
+	}
+	return os.WriteFile(localPath, data, 0644)
+}
+
+func (m *memoryProvider) Delete(ctx context.Context, remotePath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, remotePath)
+	return nil
+}
+
+func (m *memoryProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var files []string
+	for name := range m.objects {
+		if strings.HasPrefix(name, prefix) {
+			files = append(files, name)
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+func (m *memoryProvider) Exists(ctx context.Context, remotePath string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.objects[remotePath]
+	return ok, nil
+}
+
+// startTestPlugin запускает тестовый бинарник как плагин в режиме mode
+func startTestPlugin(t *testing.T, mode string) *PluginProvider {
+	t.Helper()
+
+	plugin, err := StartPlugin(context.Background(), os.Args[0], []string{"-test.run=^$"}, []string{testPluginEnv + "=" + mode}, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { plugin.Close() })
+	return plugin
+}
+
+func TestPluginConcurrentCalls(t *testing.T) {
+	plugin := startTestPlugin(t, "memory")
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// Параллельные запросы: каждый ответ должен попасть своему вызову
+	const workers = 32
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			content := strings.Repeat(fmt.Sprintf("object %d\n", i), i+1)
+			local := filepath.Join(dir, fmt.Sprintf("upload-%d", i))
+			remote := fmt.Sprintf("objects/%02d", i)
+			if err := os.WriteFile(local, []byte(content), 0644); err != nil {
+				errs <- err
+				return
+			}
+			if err := plugin.Upload(ctx, local, remote); err != nil {
+				errs <- err
+				return
+			}
+			if exists, err := plugin.Exists(ctx, remote); err != nil || !exists {
+				errs <- fmt.Errorf("Exists(%s) = %v, %v", remote, exists, err)
+				return
+			}
+			downloaded := filepath.Join(dir, fmt.Sprintf("download-%d", i))
+			if err := plugin.Download(ctx, remote, downloaded); err != nil {
+				errs <- err
+				return
+			}
+			if data, _ := os.ReadFile(downloaded); string(data) != content {
+				errs <- fmt.Errorf("%s: скачано %d байт чужого объекта", remote, len(data))
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	files, err := plugin.List(ctx, "objects/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != workers {
+		t.Fatalf("List вернул %d объектов, ожидалось %d", len(files), workers)
+	}
+
+	if err := plugin.Download(ctx, "objects/missing", filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("скачивание отсутствующего объекта завершилось без ошибки")
+	}
+}
+
+func TestPluginLargeResponse(t *testing.T) {
+	plugin := startTestPlugin(t, "memory")
+	ctx := context.Background()
+
+	local := filepath.Join(t.TempDir(), "object")
+	if err := os.WriteFile(local, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ответ List длиннее буфера bufio.Scanner по умолчанию (64 КБ)
+	const count = 3000
+	prefix := "large/" + strings.Repeat("x", 64) + "/"
+	for i := range count {
+		if err := plugin.Upload(ctx, local, fmt.Sprintf("%s%04d", prefix, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := plugin.List(ctx, "large/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != count || files[count-1] != fmt.Sprintf("%s%04d", prefix, count-1) {
+		t.Fatalf("List вернул %d объектов", len(files))
+	}
+}
+
+func TestPluginCancel(t *testing.T) {
+	plugin := startTestPlugin(t, "memory")
+
+	local := filepath.Join(t.TempDir(), "object")
+	if err := os.WriteFile(local, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := plugin.Upload(ctx, local, "slow/object"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("отмененная загрузка: %v", err)
+	}
+
+	// После отмены плагин продолжает обслуживать запросы
+	if err := plugin.Upload(context.Background(), local, "fast/object"); err != nil {
+		t.Fatal(err)
+	}
+	if err := plugin.Close(); err != nil {
+		t.Fatalf("завершение плагина: %v", err)
+	}
+	if err := plugin.Close(); err != nil {
+		t.Fatalf("повторное завершение плагина: %v", err)
+	}
+}
+
+func TestPluginCloseKillsHungProcess(t *testing.T) {
+	timeout := pluginShutdownTimeout
+	pluginShutdownTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { pluginShutdownTimeout = timeout })
+
+	plugin := startTestPlugin(t, "hang")
+
+	// Параллельные вызовы Close ждут одного завершения процесса
+	start := time.Now()
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() { errs <- plugin.Close() }()
+	}
+	first, second := <-errs, <-errs
+	if first == nil || second == nil || first.Error() != second.Error() {
+		t.Fatalf("Close зависшего плагина: %v, %v", first, second)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("зависший плагин остановлен через %s", elapsed)
+	}
+	if plugin.cmd.ProcessState == nil || plugin.cmd.ProcessState.Success() {
+		t.Fatalf("процесс плагина: %v", plugin.cmd.ProcessState)
+	}
+}
@@ -0,0 +1,144 @@
+// Package storage публичный API для подключения сторонних хранилищ бэкапов
+//
+// Сторонний модуль регистрирует хранилище в init, а приложение подключает
+// его пустым импортом:
+//
+//	func init() {
+//		storage.Register(storage.Backend{
+//			Scheme:      "tape",
+//			Description: "Ленточная библиотека",
+//			Fields: []storage.Field{
+//				{Name: "device", Description: "путь к устройству", Required: true},
+//			},
+//			New: func(ctx context.Context, cfg storage.Config) (storage.Provider, error) {
+//				return newTapeStorage(cfg.Get("device"))
+//			},
+//		})
+//	}
+//
+// После этого адреса вида tape://library/prefix?device=/dev/nst0 можно
+// использовать как назначение политики или в storage.configs.
+//
+// Хранилища на других языках подключаются как внешние процессы,
+// см. StartPlugin и ServePlugin.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Provider интерфейс провайдера хранения
+type Provider interface {
+	Upload(ctx context.Context, localPath, remotePath string) error
+	Download(ctx context.Context, remotePath, localPath string) error
+	Delete(ctx context.Context, remotePath string) error
+	List(ctx context.Context, prefix string) ([]string, error)
+	Exists(ctx context.Context, path string) (bool, error)
+}
+
+// Field описание параметра конфигурации хранилища
+type Field struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Secret      bool   `json:"secret,omitempty"`
+	Default     string `json:"default,omitempty"`
+}
+
+// Config параметры создания провайдера
+type Config struct {
+	// URL корня хранилища: схема, пользователь и хост, без пути
+	URL *url.URL
+	// Options параметры из запроса URL и из записи storage.configs
+	Options map[string]string
+}
+
+// Get возвращает значение параметра конфигурации
+func (c Config) Get(name string) string {
+	return c.Options[name]
+}
+
+// Backend описание подключаемого хранилища
+type Backend struct {
+	// Scheme схема URL, по которой выбирается хранилище
+	Scheme string
+	// Description краткое описание для справки
+	Description string
+	// Fields схема параметров конфигурации
+	Fields []Field
+	// New создает провайдер для корня хранилища
+	New func(ctx context.Context, cfg Config) (Provider, error)
+}
+
+// Open проверяет параметры по схеме конфигурации и создает провайдер
+func (b Backend) Open(ctx context.Context, cfg Config) (Provider, error) {
+	options := make(map[string]string, len(cfg.Options))
+	for name, value := range cfg.Options {
+		options[name] = value
+	}
+
+	for _, field := range b.Fields {
+		if options[field.Name] == "" && field.Default != "" {
+			options[field.Name] = field.Default
+		}
+		if field.Required && options[field.Name] == "" {
+			return nil, fmt.Errorf("параметр %s обязателен для хранилища %s", field.Name, b.Scheme)
+		}
+	}
+	cfg.Options = options
+
+	return b.New(ctx, cfg)
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]Backend)
+)
+
+// Register регистрирует хранилище
+//
+// Вызывается из init стороннего модуля. Повторная регистрация схемы
+// приводит к панике, как и в database/sql.
+func Register(backend Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if backend.Scheme == "" {
+		panic("storage: не указана схема хранилища")
+	}
+	if backend.New == nil {
+		panic("storage: не указан конструктор хранилища " + backend.Scheme)
+	}
+	if _, exists := backends[backend.Scheme]; exists {
+		panic("storage: хранилище уже зарегистрировано: " + backend.Scheme)
+	}
+
+	backends[backend.Scheme] = backend
+}
+
+// Lookup возвращает зарегистрированное хранилище по схеме
+func Lookup(scheme string) (Backend, bool) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	backend, ok := backends[scheme]
+	return backend, ok
+}
+
+// Backends возвращает зарегистрированные хранилища, отсортированные по схеме
+func Backends() []Backend {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	list := make([]Backend, 0, len(backends))
+	for _, backend := range backends {
+		list = append(list, backend)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Scheme < list[j].Scheme })
+
+	return list
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrChecksumMismatch объект в хранилище не совпадает с локальным файлом
+	ErrChecksumMismatch = errors.New("контрольная сумма объекта в хранилище не совпадает с локальной")
+	// ErrVerifyUnsupported хранилище не может проверить записанный объект
+	ErrVerifyUnsupported = errors.New("хранилище не поддерживает проверку записанных объектов")
+)
+
+// Verifier необязательный интерфейс провайдера для проверки записанного объекта
+//
+// Verify сравнивает объект в хранилище с локальным файлом и возвращает
+// контрольную сумму объекта в виде "алгоритм:значение", например
+// sha256:9f86d0..., md5:098f6b... или crc32c:e3069283. При расхождении
+// возвращается ошибка, обернутая вокруг ErrChecksumMismatch; если объект
+// нельзя проверить (например, из-за шифрования на стороне сервиса) -
+// ошибка, обернутая вокруг ErrVerifyUnsupported.
+type Verifier interface {
+	Verify(ctx context.Context, localPath, remotePath string) (string, error)
+}
@@ -6,18 +6,51 @@ import (
 
 // BackupPolicy определяет политику создания бэкапов
 type BackupPolicy struct {
-	ID                 string       `json:"id" validate:"required"`
-	Name               string       `json:"name" validate:"required,min=1,max=100"`
-	SourcePath         string       `json:"source_path" validate:"required,dir"`
-	DestinationPath    string       `json:"destination_path" validate:"required"`
-	Schedule           string       `json:"schedule" validate:"omitempty,cron"`
-	RetentionCount     int          `json:"retention_count" validate:"min=1,max=100"`
-	ArchiveEnabled     bool         `json:"archive_enabled"`
-	EncryptionEnabled  bool         `json:"encryption_enabled"`
-	EncryptionPassword string       `json:"-"` // Не сериализуем пароль
-	CreatedAt          time.Time    `json:"created_at"`
-	UpdatedAt          time.Time    `json:"updated_at"`
-	Status             BackupStatus `json:"status"`
+	ID                 string             `json:"id" validate:"required"`
+	Name               string             `json:"name" validate:"required,min=1,max=100"`
+	SourcePath         string             `json:"source_path" validate:"required,dir"`
+	DestinationPath    string             `json:"destination_path" validate:"required"`
+	Schedule           string             `json:"schedule" validate:"omitempty,cron"`
+	RetentionCount     int                `json:"retention_count" validate:"min=1,max=100"`
+	ArchiveEnabled     bool               `json:"archive_enabled"`
+	EncryptionEnabled  bool               `json:"encryption_enabled"`
+	EncryptionPassword string             `json:"-"` // Не сериализуем пароль
+	CopyTargets        []CopyTarget       `json:"copy_targets,omitempty" validate:"omitempty,dive"`
+	Bandwidth          *BandwidthConfig   `json:"bandwidth,omitempty"`    // Ограничения скорости, дополняющие глобальные
+	RestoreTest        *RestoreTestConfig `json:"restore_test,omitempty"` // Регулярная проверка восстановления
+	Parity             *ParityConfig      `json:"parity,omitempty"`       // Данные четности для восстановления поврежденных бэкапов
+	VolumeSize         int64              `json:"volume_size"`            // Размер тома в байтах; 0 - без разбиения
+	Overlap            OverlapMode        `json:"overlap,omitempty"`      // Что делать, если предыдущий запуск еще выполняется
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+	Status             BackupStatus       `json:"status"`
+}
+
+// ParityConfig параметры данных четности (кода Рида-Соломона) для бэкапов политики
+type ParityConfig struct {
+	Percent   int   `json:"percent" validate:"min=1,max=100"`      // Сколько процентов поврежденных блоков можно восстановить
+	BlockSize int64 `json:"block_size,omitempty" validate:"min=0"` // Размер блока в байтах; 0 - по умолчанию
+}
+
+// CopyTarget вторичное хранилище, в которое копируются завершенные бэкапы политики
+type CopyTarget struct {
+	Destination    string `json:"destination" validate:"required"`            // Имя из storage.configs или URL хранилища
+	RetentionCount int    `json:"retention_count,omitempty" validate:"min=0"` // 0 - без ограничения
+	RetentionDays  int    `json:"retention_days,omitempty" validate:"min=0"`  // 0 - без ограничения
+}
+
+// BackupCopy запись о копии бэкапа во вторичном хранилище
+type BackupCopy struct {
+	ID          string     `json:"id"`
+	JobID       string     `json:"job_id"`
+	PolicyID    string     `json:"policy_id"`
+	Destination string     `json:"destination"`
+	RemotePath  string     `json:"remote_path"`
+	Status      JobStatus  `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	Size        int64      `json:"size"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
 }
 
 // BackupJob представляет задачу бэкапа
@@ -28,37 +61,324 @@ type BackupJob struct {
 	StartedAt      time.Time    `json:"started_at"`
 	CompletedAt    *time.Time   `json:"completed_at,omitempty"`
 	Error          string       `json:"error,omitempty"`
-	Progress       *JobProgress `json:"progress,omitempty"` // Для совместимости с API
-	FilesProcessed int64        `json:"files_processed"`    // ДОБАВЛЕНО для database.go
-	TotalSize      int64        `json:"total_size"`         // ДОБАВЛЕНО для database.go
-	BackupPath     string       `json:"backup_path"`        // ДОБАВЛЕНО для database.go
-	CreatedAt      time.Time    `json:"created_at"`         // ДОБАВЛЕНО для database.go
+	Progress       *JobProgress `json:"progress,omitempty"`    // Текущий прогресс выполняющейся задачи
+	FilesProcessed int64        `json:"files_processed"`       // ДОБАВЛЕНО для database.go
+	TotalSize      int64        `json:"total_size"`            // ДОБАВЛЕНО для database.go
+	BackupPath     string       `json:"backup_path"`           // ДОБАВЛЕНО для database.go
+	Destination    string       `json:"destination,omitempty"` // Адрес хранилища, в которое записан бэкап
+	CreatedAt      time.Time    `json:"created_at"`            // ДОБАВЛЕНО для database.go
 }
 
 // JobProgress отслеживает прогресс выполнения задачи
+//
+// Счетчики относятся к текущему этапу: при переходе к следующему
+// этапу они обнуляются, а итоги этапа задаются заново.
 type JobProgress struct {
-	FilesProcessed  int64   `json:"files_processed"`
-	TotalFiles      int64   `json:"total_files"`
-	BytesProcessed  int64   `json:"bytes_processed"`
-	TotalBytes      int64   `json:"total_bytes"`
-	PercentComplete float64 `json:"percent_complete"`
-	CurrentFile     string  `json:"current_file"`
+	Phase           ProgressPhase `json:"phase"`
+	FilesProcessed  int64         `json:"files_processed"`
+	TotalFiles      int64         `json:"total_files"`
+	BytesProcessed  int64         `json:"bytes_processed"`
+	TotalBytes      int64         `json:"total_bytes"`
+	PercentComplete float64       `json:"percent_complete"`
+	CurrentFile     string        `json:"current_file"`
+	BytesPerSecond  float64       `json:"bytes_per_second"`
+	ETA             time.Duration `json:"eta,omitempty"` // Оценка оставшегося времени этапа
+	Elapsed         time.Duration `json:"elapsed"`       // Время с начала задачи
+}
+
+// ProgressPhase этап выполнения бэкапа
+type ProgressPhase string
+
+const (
+	ProgressPhaseScan      ProgressPhase = "scan"      // Сканирование исходной директории
+	ProgressPhaseCopy      ProgressPhase = "copy"      // Копирование файлов во временную директорию
+	ProgressPhaseArchive   ProgressPhase = "archive"   // Архивирование (для томов - вместе с шифрованием и загрузкой)
+	ProgressPhaseEncrypt   ProgressPhase = "encrypt"   // Шифрование архива
+	ProgressPhaseUpload    ProgressPhase = "upload"    // Загрузка в хранилище
+	ProgressPhaseRetention ProgressPhase = "retention" // Удаление старых бэкапов
+	ProgressPhaseReplicate ProgressPhase = "replicate" // Копирование во вторичные хранилища
+)
+
+// JobEventType тип события задачи
+type JobEventType string
+
+const (
+	JobEventProgress JobEventType = "progress" // Изменился прогресс задачи
+	JobEventStatus   JobEventType = "status"   // Изменился статус задачи
+)
+
+// JobEvent событие выполнения задачи бэкапа
+type JobEvent struct {
+	Type     JobEventType `json:"type"`
+	JobID    string       `json:"job_id"`
+	PolicyID string       `json:"policy_id"`
+	Status   JobStatus    `json:"status"`
+	Progress *JobProgress `json:"progress,omitempty"`
+	Error    string       `json:"error,omitempty"`
+	Time     time.Time    `json:"time"`
 }
 
 // BackupResult содержит результат выполнения бэкапа
 type BackupResult struct {
-	JobID            string        `json:"job_id"`
-	BackupPath       string        `json:"backup_path"`
-	FilesProcessed   int64         `json:"files_processed"`
-	TotalSize        int64         `json:"total_size"`
-	CompressedSize   int64         `json:"compressed_size,omitempty"`
-	Duration         time.Duration `json:"duration"`
-	Compressed       bool          `json:"compressed"`
-	Encrypted        bool          `json:"encrypted"`
-	CompressionRatio float64       `json:"compression_ratio,omitempty"`
-	Checksum         string        `json:"checksum"`
+	JobID            string         `json:"job_id"`
+	BackupPath       string         `json:"backup_path"`
+	FilesProcessed   int64          `json:"files_processed"`
+	TotalSize        int64          `json:"total_size"`
+	CompressedSize   int64          `json:"compressed_size,omitempty"`
+	Duration         time.Duration  `json:"duration"`
+	Compressed       bool           `json:"compressed"`
+	Encrypted        bool           `json:"encrypted"`
+	CompressionRatio float64        `json:"compression_ratio,omitempty"`
+	Checksum         string         `json:"checksum"`
+	RemoteChecksum   string         `json:"remote_checksum,omitempty"` // Контрольная сумма объекта в хранилище
+	ParityPath       string         `json:"parity_path,omitempty"`     // Файл четности рядом с бэкапом
+	Volumes          int            `json:"volumes,omitempty"`         // Количество томов; BackupPath указывает на манифест томов
+	Targets          []TargetResult `json:"targets,omitempty"`         // Результаты по хранилищам MultiStorage
+	Copies           []BackupCopy   `json:"copies,omitempty"`          // Копии во вторичных хранилищах
+	Files            []BackupFile   `json:"-"`                         // Манифест файлов бэкапа
+}
+
+// BackupFile запись манифеста бэкапа о файле
+type BackupFile struct {
+	JobID      string    `json:"job_id"`
+	Path       string    `json:"path"`        // Путь относительно исходной директории
+	SourcePath string    `json:"source_path"` // Исходный путь файла
+	Size       int64     `json:"size"`
+	Mode       uint32    `json:"mode"` // Права доступа (os.FileMode)
+	ModTime    time.Time `json:"mod_time"`
+	Checksum   string    `json:"checksum"` // SHA-256 содержимого
+}
+
+// VolumeManifest манифест бэкапа, разбитого на тома
+//
+// Хранится рядом с томами, поэтому бэкап можно восстановить
+// и без каталога.
+type VolumeManifest struct {
+	Version    int            `json:"version"`
+	Size       int64          `json:"size"`        // Размер бэкапа (всех томов вместе)
+	Checksum   string         `json:"checksum"`    // SHA-256 бэкапа (всех томов по порядку)
+	VolumeSize int64          `json:"volume_size"` // Размер тома, кроме последнего
+	Compressed bool           `json:"compressed"`
+	Encrypted  bool           `json:"encrypted"`
+	Volumes    []BackupVolume `json:"volumes"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// BackupVolume том бэкапа
+type BackupVolume struct {
+	Number   int    `json:"number"`
+	Path     string `json:"path"` // Имя объекта тома в директории манифеста
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`         // SHA-256 тома
+	Parity   bool   `json:"parity,omitempty"` // Рядом с томом лежит файл четности
+}
+
+// TargetResult результат записи бэкапа в одно из хранилищ MultiStorage
+type TargetResult struct {
+	Target     string        `json:"target"`
+	RemotePath string        `json:"remote_path"`
+	Status     TargetStatus  `json:"status"`
+	Error      string        `json:"error,omitempty"`
+	Checksum   string        `json:"checksum,omitempty"` // Контрольная сумма, подтвержденная хранилищем
+	Duration   time.Duration `json:"duration"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+// BackupVerification результат проверки сохраненного бэкапа
+type BackupVerification struct {
+	ID         string        `json:"id"`
+	JobID      string        `json:"job_id"`
+	PolicyID   string        `json:"policy_id"`
+	BackupPath string        `json:"backup_path"`
+	Status     VerifyStatus  `json:"status"`
+	Error      string        `json:"error,omitempty"`
+	Checksum   string        `json:"checksum,omitempty"`   // SHA-256 скачанного бэкапа
+	Deep       bool          `json:"deep"`                 // Проверено содержимое архива
+	Entries    int           `json:"entries,omitempty"`    // Количество проверенных записей архива
+	Damaged    int           `json:"damaged,omitempty"`    // Поврежденных блоков по данным четности
+	Repairable bool          `json:"repairable,omitempty"` // Повреждения можно исправить командой repair
+	Duration   time.Duration `json:"duration"`
+	VerifiedAt time.Time     `json:"verified_at"`
+}
+
+// RestoreTestConfig параметры регулярной проверки восстановления политики
+type RestoreTestConfig struct {
+	Schedule    string        `json:"schedule,omitempty" validate:"omitempty,cron"` // Пусто - только ручной запуск
+	SampleFiles int           `json:"sample_files,omitempty" validate:"min=0"`      // Сколько случайных файлов сравнивать с манифестом; 0 - все
+	Command     string        `json:"command,omitempty"`                            // Имя команды проверки из restore_test.commands конфигурации сервера
+	Timeout     time.Duration `json:"timeout,omitempty" validate:"min=0"`           // Ограничение времени команды проверки; 0 - без ограничения
+}
+
+// RestoreTestCommandsConfig команды проверки восстановления, разрешенные на сервере
+//
+// Политика ссылается на команду по имени; текст команды берется только из
+// конфигурации сервера, поэтому API и CLI не могут запустить произвольную
+// команду оболочки.
+type RestoreTestCommandsConfig struct {
+	Commands map[string]string `json:"commands,omitempty" mapstructure:"commands" yaml:"commands"` // Имя команды -> команда оболочки
+}
+
+// RestoreTestResult результат проверки восстановления
+type RestoreTestResult struct {
+	ID              string        `json:"id"`
+	PolicyID        string        `json:"policy_id"`
+	JobID           string        `json:"job_id,omitempty"` // Восстановленный бэкап
+	Status          VerifyStatus  `json:"status"`
+	Error           string        `json:"error,omitempty"`
+	FilesChecked    int           `json:"files_checked"`
+	FilesFailed     int           `json:"files_failed"`
+	CommandOutput   string        `json:"command_output,omitempty"`
+	RestoreDuration time.Duration `json:"restore_duration"` // Скачивание, расшифровка и распаковка
+	Duration        time.Duration `json:"duration"`
+	StartedAt       time.Time     `json:"started_at"`
 }
 
+// VerifyStatus результат проверки бэкапа
+type VerifyStatus string
+
+const (
+	VerifyStatusPassed VerifyStatus = "passed"
+	VerifyStatusFailed VerifyStatus = "failed"
+)
+
+// VerifyConfig параметры регулярной проверки сохраненных бэкапов
+type VerifyConfig struct {
+	Schedule string `json:"schedule,omitempty" mapstructure:"schedule" yaml:"schedule" validate:"omitempty,cron"` // Пусто - проверка по расписанию отключена
+	Sample   int    `json:"sample,omitempty" mapstructure:"sample" yaml:"sample" validate:"min=0"`                // Сколько случайных бэкапов проверять за запуск; 0 - все
+	Deep     bool   `json:"deep,omitempty" mapstructure:"deep" yaml:"deep"`                                       // Расшифровывать и проверять каждую запись архива
+}
+
+// SnapshotServerConfig параметры WebDAV-сервера для просмотра бэкапов (команда serve-snapshots)
+//
+// Без TLS токен передается открытым текстом, поэтому сервер без
+// сертификата слушает только loopback-адрес.
+type SnapshotServerConfig struct {
+	Listen  string `json:"listen,omitempty" mapstructure:"listen" yaml:"listen" validate:"required"`
+	Token   string `json:"-" mapstructure:"token" yaml:"token" validate:"required,min=16"` // Токен доступа: Bearer или пароль Basic-авторизации
+	TLSCert string `json:"tls_cert,omitempty" mapstructure:"tls_cert" yaml:"tls_cert"`     // Файл сертификата TLS в формате PEM
+	TLSKey  string `json:"tls_key,omitempty" mapstructure:"tls_key" yaml:"tls_key"`        // Файл закрытого ключа сертификата в формате PEM
+}
+
+// APIServerConfig параметры REST API сервера (cmd/server)
+type APIServerConfig struct {
+	Listen string        `json:"listen,omitempty" mapstructure:"listen" yaml:"listen" validate:"required"`
+	Auth   APIAuthConfig `json:"auth" mapstructure:"auth" yaml:"auth"`
+
+	// RestoreRoot директория, внутри которой API восстанавливает файлы;
+	// пусто - восстановление через API отключено
+	RestoreRoot string `json:"restore_root,omitempty" mapstructure:"restore_root" yaml:"restore_root"`
+}
+
+// APIAuthConfig параметры аутентификации REST API
+//
+// Запросы принимаются с API-токеном (команда api-token) или, если
+// настроен OIDC, с JWT провайдера в заголовке Authorization: Bearer.
+type APIAuthConfig struct {
+	Disabled bool        `json:"disabled,omitempty" mapstructure:"disabled" yaml:"disabled"` // Без аутентификации, любой запрос - admin; только для локальной отладки
+	OIDC     *OIDCConfig `json:"oidc,omitempty" mapstructure:"oidc" yaml:"oidc"`
+}
+
+// OIDCConfig проверка JWT провайдера OpenID Connect
+//
+// Роли берутся из claim RolesClaim: список строк или строка через
+// пробел в формате <RolePrefix>role или <RolePrefix>role:policy-id,
+// например backupist:admin. Значения без префикса относятся к другим
+// приложениям провайдера и не дают прав.
+type OIDCConfig struct {
+	Issuer        string `json:"issuer" mapstructure:"issuer" yaml:"issuer" validate:"required,url"`
+	Audience      string `json:"audience" mapstructure:"audience" yaml:"audience" validate:"required"`
+	JWKSURL       string `json:"jwks_url,omitempty" mapstructure:"jwks_url" yaml:"jwks_url" validate:"omitempty,url"` // Пусто - из /.well-known/openid-configuration
+	RolesClaim    string `json:"roles_claim,omitempty" mapstructure:"roles_claim" yaml:"roles_claim"`                 // По умолчанию roles
+	UsernameClaim string `json:"username_claim,omitempty" mapstructure:"username_claim" yaml:"username_claim"`        // По умолчанию sub
+	RolePrefix    string `json:"role_prefix,omitempty" mapstructure:"role_prefix" yaml:"role_prefix"`                 // По умолчанию backupist:
+}
+
+// Role роль доступа к API
+//
+// Роли упорядочены: каждая следующая включает права предыдущей.
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"   // Просмотр политик, задач и бэкапов
+	RoleOperator Role = "operator" // Запуск бэкапов, восстановление, удаление бэкапов и политик
+	RoleAdmin    Role = "admin"    // Создание политик и журнал аудита
+)
+
+// RoleBinding роль на политику или на все политики
+type RoleBinding struct {
+	Role     Role   `json:"role"`
+	PolicyID string `json:"policy_id,omitempty"` // Пусто - все политики
+}
+
+// APIToken токен доступа к REST API
+//
+// В базе хранится только SHA-256 токена; сам токен выдается один раз
+// при создании.
+type APIToken struct {
+	ID         string        `json:"id"`
+	Name       string        `json:"name"`
+	Roles      []RoleBinding `json:"roles"`
+	CreatedAt  time.Time     `json:"created_at"`
+	ExpiresAt  *time.Time    `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time    `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time    `json:"revoked_at,omitempty"`
+}
+
+// AuditEntry запись журнала аудита REST API
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor"`       // Имя токена или пользователь OIDC; пусто, если аутентификация не пройдена
+	AuthMethod string    `json:"auth_method"` // token, oidc или none
+	Action     string    `json:"action"`      // Например policy.delete, backup.restore
+	PolicyID   string    `json:"policy_id,omitempty"`
+	ResourceID string    `json:"resource_id,omitempty"` // ID политики или задачи из пути запроса
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"` // HTTP-статус ответа
+	RemoteAddr string    `json:"remote_addr"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// NotificationConfig параметры уведомлений о событиях
+type NotificationConfig struct {
+	Webhooks []WebhookConfig `json:"webhooks,omitempty" mapstructure:"webhooks" yaml:"webhooks" validate:"dive"`
+}
+
+// WebhookConfig получатель уведомлений по HTTP
+//
+// Уведомление отправляется POST-запросом с телом Notification в JSON.
+type WebhookConfig struct {
+	URL     string            `json:"url" mapstructure:"url" yaml:"url" validate:"required,url"`
+	Events  []string          `json:"events,omitempty" mapstructure:"events" yaml:"events"` // Пусто - все события
+	Headers map[string]string `json:"-" mapstructure:"headers" yaml:"headers"`
+}
+
+// Notification уведомление о событии
+type Notification struct {
+	Event    string    `json:"event"`
+	PolicyID string    `json:"policy_id,omitempty"`
+	JobID    string    `json:"job_id,omitempty"`
+	Message  string    `json:"message"`
+	Data     any       `json:"data,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// События уведомлений
+const (
+	EventRestoreTestPassed = "restore_test.passed"
+	EventRestoreTestFailed = "restore_test.failed"
+)
+
+// TargetStatus статус копии бэкапа в хранилище
+type TargetStatus string
+
+const (
+	TargetStatusCompleted TargetStatus = "completed"
+	TargetStatusFailed    TargetStatus = "failed"
+	TargetStatusResynced  TargetStatus = "resynced"
+)
+
 // BackupStatus статус политики бэкапа
 type BackupStatus string
 
@@ -81,36 +401,139 @@ const (
 	JobStatusDeleted   JobStatus = "deleted"
 )
 
+// OverlapMode поведение запуска политики, предыдущий запуск которой еще выполняется
+type OverlapMode string
+
+const (
+	OverlapSkip           OverlapMode = "skip"            // Новый запуск отменяется (по умолчанию)
+	OverlapQueue          OverlapMode = "queue"           // Новый запуск ждет завершения предыдущего
+	OverlapCancelPrevious OverlapMode = "cancel-previous" // Предыдущий запуск отменяется
+)
+
 // StorageConfig конфигурация хранилища
 type StorageConfig struct {
-	Type      StorageType `json:"type"`
-	LocalPath string      `json:"local_path,omitempty"`
-	S3Config  *S3Config   `json:"s3_config,omitempty"`
-	GCSConfig *GCSConfig  `json:"gcs_config,omitempty"`
+	Type         StorageType   `json:"type" mapstructure:"type" yaml:"type"`
+	LocalPath    string        `json:"local_path,omitempty" mapstructure:"local_path" yaml:"local_path"`
+	S3Config     *S3Config     `json:"s3_config,omitempty" mapstructure:"s3_config" yaml:"s3_config"`
+	GCSConfig    *GCSConfig    `json:"gcs_config,omitempty" mapstructure:"gcs_config" yaml:"gcs_config"`
+	SFTPConfig   *SFTPConfig   `json:"sftp_config,omitempty" mapstructure:"sftp_config" yaml:"sftp_config"`
+	WebDAVConfig *WebDAVConfig `json:"webdav_config,omitempty" mapstructure:"webdav_config" yaml:"webdav_config"`
+	AzureConfig  *AzureConfig  `json:"azure_config,omitempty" mapstructure:"azure_config" yaml:"azure_config"`
+	MultiConfig  *MultiConfig  `json:"multi_config,omitempty" mapstructure:"multi_config" yaml:"multi_config"`
+
+	// Options параметры сторонних хранилищ и плагинов (см. pkg/storage)
+	Options map[string]string `json:"options,omitempty" mapstructure:"options" yaml:"options"`
 }
 
 type StorageType string
 
 const (
-	StorageTypeLocal StorageType = "local"
-	StorageTypeS3    StorageType = "s3"
-	StorageTypeGCS   StorageType = "gcs"
+	StorageTypeLocal  StorageType = "local"
+	StorageTypeS3     StorageType = "s3"
+	StorageTypeGCS    StorageType = "gcs"
+	StorageTypeSFTP   StorageType = "sftp"
+	StorageTypeWebDAV StorageType = "webdav"
+	StorageTypeAzure  StorageType = "azure"
+	StorageTypeMulti  StorageType = "multi"
 )
 
 // S3Config конфигурация для Amazon S3
 type S3Config struct {
-	Bucket          string `json:"bucket"`
-	Region          string `json:"region"`
-	AccessKeyID     string `json:"access_key_id"`
-	SecretAccessKey string `json:"-"`
-	Endpoint        string `json:"endpoint,omitempty"`
-	UseSSL          bool   `json:"use_ssl"`
+	Bucket          string `json:"bucket" mapstructure:"bucket" yaml:"bucket"`
+	Region          string `json:"region" mapstructure:"region" yaml:"region"`
+	AccessKeyID     string `json:"access_key_id" mapstructure:"access_key_id" yaml:"access_key_id"`
+	SecretAccessKey string `json:"-" mapstructure:"secret_access_key" yaml:"secret_access_key"`
+	SessionToken    string `json:"-" mapstructure:"session_token" yaml:"session_token"`
+	Endpoint        string `json:"endpoint,omitempty" mapstructure:"endpoint" yaml:"endpoint"`
+	UseSSL          bool   `json:"use_ssl" mapstructure:"use_ssl" yaml:"use_ssl"`
 }
 
 // GCSConfig конфигурация для Google Cloud Storage
 type GCSConfig struct {
-	Bucket             string `json:"bucket"`
-	ProjectID          string `json:"project_id"`
-	CredentialsPath    string `json:"credentials_path"`
-	ServiceAccountJSON string `json:"-"`
+	Bucket             string `json:"bucket" mapstructure:"bucket" yaml:"bucket"`
+	ProjectID          string `json:"project_id" mapstructure:"project_id" yaml:"project_id"`
+	CredentialsPath    string `json:"credentials_path" mapstructure:"credentials_path" yaml:"credentials_path"`
+	ServiceAccountJSON string `json:"-" mapstructure:"service_account_json" yaml:"service_account_json"`
+}
+
+// SFTPConfig конфигурация для SFTP хранилища
+type SFTPConfig struct {
+	Host           string `json:"host" mapstructure:"host" yaml:"host"`
+	Port           int    `json:"port" mapstructure:"port" yaml:"port"`
+	User           string `json:"user" mapstructure:"user" yaml:"user"`
+	BasePath       string `json:"base_path" mapstructure:"base_path" yaml:"base_path"`
+	PrivateKeyPath string `json:"private_key_path,omitempty" mapstructure:"private_key_path" yaml:"private_key_path"`
+	KeyPassphrase  string `json:"-" mapstructure:"key_passphrase" yaml:"key_passphrase"`
+	UseAgent       bool   `json:"use_agent" mapstructure:"use_agent" yaml:"use_agent"`
+	KnownHostsPath string `json:"known_hosts_path,omitempty" mapstructure:"known_hosts_path" yaml:"known_hosts_path"`
+}
+
+// WebDAVConfig конфигурация для WebDAV хранилища (Nextcloud, ownCloud и т.д.)
+type WebDAVConfig struct {
+	URL            string `json:"url" mapstructure:"url" yaml:"url"`
+	Username       string `json:"username,omitempty" mapstructure:"username" yaml:"username"`
+	Password       string `json:"-" mapstructure:"password" yaml:"password"`
+	BearerToken    string `json:"-" mapstructure:"bearer_token" yaml:"bearer_token"`
+	ChunkSize      int64  `json:"chunk_size,omitempty" mapstructure:"chunk_size" yaml:"chunk_size"`
+	ChunkUploadURL string `json:"chunk_upload_url,omitempty" mapstructure:"chunk_upload_url" yaml:"chunk_upload_url"`
+}
+
+// AzureConfig конфигурация для Azure Blob Storage
+type AzureConfig struct {
+	AccountName string `json:"account_name" mapstructure:"account_name" yaml:"account_name"`
+	AccountKey  string `json:"-" mapstructure:"account_key" yaml:"account_key"`
+	SASToken    string `json:"-" mapstructure:"sas_token" yaml:"sas_token"`
+	Container   string `json:"container" mapstructure:"container" yaml:"container"`
+	Endpoint    string `json:"endpoint,omitempty" mapstructure:"endpoint" yaml:"endpoint"`
+	AccessTier  string `json:"access_tier,omitempty" mapstructure:"access_tier" yaml:"access_tier"`
+	BlockSize   int64  `json:"block_size,omitempty" mapstructure:"block_size" yaml:"block_size"`
+	Concurrency int    `json:"concurrency,omitempty" mapstructure:"concurrency" yaml:"concurrency"`
+}
+
+// BandwidthConfig ограничения скорости передачи данных в хранилища
+//
+// Скорость задается строкой вида 10MB/s, 512KiB/s или 100M;
+// пустое значение, 0 или unlimited - без ограничения.
+type BandwidthConfig struct {
+	UploadRate       string            `json:"upload_rate,omitempty" mapstructure:"upload_rate" yaml:"upload_rate"`
+	DownloadRate     string            `json:"download_rate,omitempty" mapstructure:"download_rate" yaml:"download_rate"`
+	Schedule         []BandwidthWindow `json:"schedule,omitempty" mapstructure:"schedule" yaml:"schedule"`
+	MaxParallelParts int               `json:"max_parallel_parts,omitempty" mapstructure:"max_parallel_parts" yaml:"max_parallel_parts"` // 0 - по умолчанию провайдера
+}
+
+// BandwidthWindow ограничения скорости в заданные часы
+//
+// Окна проверяются по порядку, действует первое подходящее;
+// вне окон действуют ограничения BandwidthConfig.
+type BandwidthWindow struct {
+	Days         []string `json:"days,omitempty" mapstructure:"days" yaml:"days"` // mon, tue, ..., sun; пусто - каждый день
+	Start        string   `json:"start" mapstructure:"start" yaml:"start"`        // ЧЧ:ММ
+	End          string   `json:"end" mapstructure:"end" yaml:"end"`              // ЧЧ:ММ; раньше Start - окно через полночь
+	UploadRate   string   `json:"upload_rate,omitempty" mapstructure:"upload_rate" yaml:"upload_rate"`
+	DownloadRate string   `json:"download_rate,omitempty" mapstructure:"download_rate" yaml:"download_rate"`
+}
+
+// RetryConfig параметры повтора операций с хранилищем
+type RetryConfig struct {
+	MaxAttempts  int           `json:"max_attempts" mapstructure:"max_attempts" yaml:"max_attempts"`    // 1 - без повторов
+	InitialDelay time.Duration `json:"initial_delay" mapstructure:"initial_delay" yaml:"initial_delay"` // Пауза перед первым повтором
+	MaxDelay     time.Duration `json:"max_delay" mapstructure:"max_delay" yaml:"max_delay"`             // Максимальная пауза между повторами
+	Multiplier   float64       `json:"multiplier" mapstructure:"multiplier" yaml:"multiplier"`          // Множитель паузы для каждого следующего повтора
+}
+
+// MultiConfig конфигурация зеркалирования бэкапов в несколько хранилищ
+type MultiConfig struct {
+	// Targets адреса хранилищ: URL (s3://bucket/prefix) или именованные хранилища (storage://name)
+	Targets []string `json:"targets" mapstructure:"targets" yaml:"targets"`
+	// Quorum сколько копий должно быть записано для успеха: all, any или число N
+	Quorum string `json:"quorum,omitempty" mapstructure:"quorum" yaml:"quorum"`
+	// ResyncInterval период повторной записи копий в хранилища, где запись не удалась
+	ResyncInterval time.Duration `json:"resync_interval,omitempty" mapstructure:"resync_interval" yaml:"resync_interval"`
+}
+
+// StoragePluginConfig конфигурация внешнего плагина хранилища
+type StoragePluginConfig struct {
+	Command string   `json:"command" mapstructure:"command" yaml:"command"`
+	Args    []string `json:"args,omitempty" mapstructure:"args" yaml:"args"`
+	Env     []string `json:"env,omitempty" mapstructure:"env" yaml:"env"`
 }